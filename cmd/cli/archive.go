@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/archive"
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/render"
+)
+
+// runBackfill fetches a school's past menus into the archive.
+func runBackfill(args []string) error {
+	fs := newFlagSet("backfill", "[flags]", `Fetch a school's menus for a date range or a whole school year into the
+archive, every meal type included. Days that already have archived menus
+are skipped unless -refetch is given, so an interrupted backfill picks up
+where it stopped.`)
+	menuFlags := addMenuFlags(fs)
+	dbPath := fs.String("db", os.Getenv("ARCHIVE_DB"), "Archive database (default: "+archive.DefaultPath+")")
+	year := fs.String("year", "", "School year, e.g. 2023-2024 for July 1, 2023 to June 30, 2024, instead of -startDate and -endDate")
+	chunk := fs.Int("chunk", 7, "Days to fetch per request")
+	delay := fs.Duration("delay", time.Second, "Pause between requests to LINQ Connect")
+	refetch := fs.Bool("refetch", false, "Fetch days that are already archived again")
+	fs.Parse(args)
+
+	if *dbPath == "" {
+		*dbPath = archive.DefaultPath
+	}
+	if *chunk < 1 {
+		return usagef("-chunk must be at least 1")
+	}
+	p, err := menuFlags.resolve()
+	if err != nil {
+		return err
+	}
+	var start, end time.Time
+	if *year != "" {
+		if start, end, err = schoolYear(*year); err != nil {
+			return err
+		}
+	} else if start, end, err = menuFlags.dates(p); err != nil {
+		return err
+	}
+
+	store, err := archive.Open(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	archived := make(map[string]bool)
+	if !*refetch {
+		list, err := store.Dates(p.BuildingID, p.DistrictID, start, end)
+		if err != nil {
+			return err
+		}
+		for _, date := range list {
+			archived[date] = true
+		}
+	}
+
+	days, requests := 0, 0
+	for from := start; !from.After(end); from = from.AddDate(0, 0, *chunk) {
+		to := from.AddDate(0, 0, *chunk-1)
+		if to.After(end) {
+			to = end
+		}
+		first, last, ok := unarchived(from, to, archived)
+		if !ok {
+			continue
+		}
+
+		if requests > 0 {
+			time.Sleep(*delay)
+		}
+		requests++
+		m, err := menu.Fetch(p.BuildingID, p.DistrictID, first.Format("01-02-2006"), last.Format("01-02-2006"), *menuFlags.debug)
+		if err != nil {
+			return fmt.Errorf("%w: %s to %s: %w", errFetch, first.Format(menu.DateLayout), last.Format(menu.DateLayout), err)
+		}
+		var fetched []menu.Day
+		for _, d := range m.Days() {
+			if !archived[d.Date] {
+				fetched = append(fetched, d)
+			}
+		}
+		if err := store.SaveDays(p.School, p.BuildingID, p.DistrictID, fetched); err != nil {
+			return err
+		}
+		days += len(fetched)
+		fmt.Fprintf(os.Stderr, "%s to %s: %d days\n", first.Format(menu.DateLayout), last.Format(menu.DateLayout), len(fetched))
+	}
+
+	fmt.Fprintf(os.Stderr, "Archived %d days from %s to %s in %s", days, start.Format(menu.DateLayout), end.Format(menu.DateLayout), *dbPath)
+	if len(archived) > 0 {
+		fmt.Fprintf(os.Stderr, " (%d already archived days skipped)", len(archived))
+	}
+	fmt.Fprintln(os.Stderr)
+	return nil
+}
+
+// unarchived returns the first and last days from start to end that are
+// not archived, or false if only weekend days are missing, so a chunk is
+// fetched no wider than the gap in it.
+func unarchived(start, end time.Time, archived map[string]bool) (first, last time.Time, ok bool) {
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		if archived[d.Format(menu.DateLayout)] {
+			continue
+		}
+		if first.IsZero() {
+			first = d
+		}
+		last = d
+		ok = ok || !dates.Weekend(d)
+	}
+	return first, last, ok
+}
+
+// schoolYear returns July 1 to June 30 of a school year given as
+// 2023-2024, or as 2023 for the year starting then.
+func schoolYear(s string) (start, end time.Time, err error) {
+	first, second, hasSecond := strings.Cut(s, "-")
+	y, err := strconv.Atoi(first)
+	if err == nil && hasSecond {
+		var next int
+		if next, err = strconv.Atoi(second); err == nil && next != y+1 {
+			err = fmt.Errorf("years are not consecutive")
+		}
+	}
+	if err != nil {
+		return start, end, usagef("invalid school year %q, expected e.g. 2023-2024", s)
+	}
+	return time.Date(y, time.July, 1, 0, 0, 0, 0, time.UTC), time.Date(y+1, time.June, 30, 0, 0, 0, 0, time.UTC), nil
+}
+
+// runArchive prints archived menus, or lists the archived schools.
+func runArchive(args []string) error {
+	fs := newFlagSet("archive", "[flags]", `Print a school's archived menus in any format, like fetch but from the
+archive instead of LINQ Connect, so past school years stay available.
+With -list, print the archived schools and the dates they cover.`)
+	menuFlags := addMenuFlags(fs)
+	formatFlags := addFormatFlags(fs)
+	dbPath := fs.String("db", os.Getenv("ARCHIVE_DB"), "Archive database (default: "+archive.DefaultPath+")")
+	year := fs.String("year", "", "School year, e.g. 2023-2024, instead of -startDate and -endDate")
+	list := fs.Bool("list", false, "List the archived schools")
+	fs.Parse(args)
+
+	if *dbPath == "" {
+		*dbPath = archive.DefaultPath
+	}
+	if _, err := os.Stat(*dbPath); err != nil {
+		return usagef("no archive at %s (run school_menu_connector backfill first)", *dbPath)
+	}
+	store, err := archive.Open(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if *list {
+		return listArchive(store, *formatFlags.format)
+	}
+
+	p, err := menuFlags.resolve()
+	if err != nil {
+		return err
+	}
+	var start, end time.Time
+	if *year != "" {
+		start, end, err = schoolYear(*year)
+	} else {
+		start, end, err = menuFlags.dates(p)
+	}
+	if err != nil {
+		return err
+	}
+	opts, err := formatFlags.options()
+	if err != nil {
+		return err
+	}
+
+	days, err := store.Days(archive.Query{BuildingID: p.BuildingID, DistrictID: p.DistrictID, Start: start, End: end, Sessions: p.MealTypes})
+	if err != nil {
+		return err
+	}
+	if len(days) == 0 {
+		return fmt.Errorf("%w for %s in the archive for the specified date range", errNoMenu, strings.Join(p.MealTypes, "/"))
+	}
+	doc := render.Document{School: p.School, Days: days, AllergenNames: p.applyAllergens(days)}
+	return writeFormatted(doc, *formatFlags.format, *formatFlags.output, opts)
+}
+
+func listArchive(store *archive.Store, format string) error {
+	schools, err := store.Schools()
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		if schools == nil {
+			schools = []archive.School{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(schools)
+	case "", "text":
+	default:
+		return usagef("-list supports text and json output")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "BUILDING ID\tDISTRICT ID\tSCHOOL\tFROM\tTO\tDAYS")
+	for _, s := range schools {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", s.BuildingID, s.DistrictID, s.Name, s.FirstDate, s.LastDate, s.Days)
+	}
+	return w.Flush()
+}
+
+// archiveMenu saves a menu fetched for p to the archive at ARCHIVE_DB, if
+// set, so everyday use fills the archive too. Archiving is best effort and
+// never fails the command.
+func archiveMenu(p Profile, m *menu.Menu) {
+	path := os.Getenv("ARCHIVE_DB")
+	if path == "" {
+		return
+	}
+	store, err := archive.Open(path)
+	if err == nil {
+		err = store.Save(p.School, p.BuildingID, p.DistrictID, m)
+		store.Close()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: menu not archived: %v\n", err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/bot"
+	"github.com/asachs01/school_menu_connector/internal/cache"
+	"github.com/sirupsen/logrus"
+)
+
+// runBot answers menu commands in Telegram and Matrix chats and sends
+// daily subscriptions until interrupted.
+func runBot(args []string) error {
+	fs := newFlagSet("bot", "[flags]", `Answer menu commands in Telegram and Matrix chats until interrupted.
+
+Parents send /today, /tomorrow, /week, /menu DATE or /subscribe lunch 7am
+(!today and so on in Matrix). Each chat can choose its own school with
+/school and time zone with /tz; -building and -district set the default.`)
+	telegramToken := fs.String("telegram-token", os.Getenv("TELEGRAM_BOT_TOKEN"), "Telegram bot token")
+	telegramAPI := fs.String("telegram-api", os.Getenv("TELEGRAM_API_URL"), "Telegram Bot API server (default: "+bot.DefaultTelegramAPI+")")
+	homeserver := fs.String("matrix-homeserver", os.Getenv("MATRIX_HOMESERVER"), "Matrix homeserver URL")
+	matrixToken := fs.String("matrix-token", os.Getenv("MATRIX_ACCESS_TOKEN"), "Matrix access token")
+	statePath := fs.String("state", os.Getenv("BOT_STATE"), "File keeping each chat's settings and subscriptions (default: bot-state.json)")
+	cacheDir := fs.String("cache-dir", "", "Menu cache directory (default: /tmp/menu-cache)")
+	buildingID := fs.String("building", os.Getenv("BUILDING_ID"), "Default building ID for chats that have not chosen a school")
+	districtID := fs.String("district", os.Getenv("DISTRICT_ID"), "Default district ID")
+	school := fs.String("school", os.Getenv("SCHOOL_NAME"), "Default school name")
+	timezone := fs.String("tz", "", "Default time zone for chats (default: $SCHOOL_TZ or local)")
+	fs.Parse(args)
+
+	if *statePath == "" {
+		*statePath = "bot-state.json"
+	}
+
+	var transports []bot.Transport
+	if *telegramToken != "" {
+		transports = append(transports, &bot.Telegram{Token: *telegramToken, APIURL: *telegramAPI})
+	}
+	if *homeserver != "" || *matrixToken != "" {
+		if *homeserver == "" || *matrixToken == "" {
+			return usagef("-matrix-homeserver and -matrix-token must be given together")
+		}
+		transports = append(transports, &bot.Matrix{Homeserver: *homeserver, Token: *matrixToken})
+	}
+	if len(transports) == 0 {
+		return usagef("-telegram-token or -matrix-homeserver and -matrix-token are required")
+	}
+	if (*buildingID == "") != (*districtID == "") {
+		return usagef("-building and -district must be given together")
+	}
+	if _, err := time.LoadLocation(*timezone); err != nil {
+		return usageError{err}
+	}
+
+	logger := logrus.New()
+	opts := bot.Options{
+		Logger:   logger,
+		Defaults: bot.Chat{School: *school, BuildingID: *buildingID, DistrictID: *districtID, Timezone: *timezone},
+	}
+	var err error
+	if opts.Cache, err = cache.New(*cacheDir, 0); err != nil {
+		logger.WithError(err).Warn("Menu cache unavailable")
+	}
+	b, err := bot.New(*statePath, opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = b.Run(ctx, transports...)
+	logger.Info("Stopping bot")
+	return err
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/asachs01/school_menu_connector/internal/cache"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/notify"
+)
+
+// runFetch prints the menu, or writes it to a file, in any output format.
+func runFetch(args []string) error {
+	fs := newFlagSet("fetch", "[flags]", "Fetch the menu and print it, or write it to a file with -o. The format is\ntaken from -format, then the -o file extension, and defaults to text.")
+	menuFlags := addMenuFlags(fs)
+	formatFlags := addFormatFlags(fs)
+	fs.Parse(args)
+
+	p, err := menuFlags.resolve()
+	if err != nil {
+		return err
+	}
+	start, end, err := menuFlags.dates(p)
+	if err != nil {
+		return err
+	}
+	opts, err := formatFlags.options()
+	if err != nil {
+		return err
+	}
+
+	doc, err := loadMenu(p, start, end, *menuFlags.debug)
+	if err != nil {
+		return err
+	}
+	return writeFormatted(doc, *formatFlags.format, *formatFlags.output, opts)
+}
+
+// runICS writes the menu as an iCalendar file.
+func runICS(args []string) error {
+	fs := newFlagSet("ics", "[flags]", "Write the menu as an iCalendar file with one all-day event per meal. With a\nprofile's favorites or dislikes, event titles mark favorite and pack-lunch\ndays.")
+	menuFlags := addMenuFlags(fs)
+	output := fs.String("o", os.Getenv("ICS_OUTPUT_PATH"), "Output file, or - for stdout (default: menu_<start>_to_<end>.ics)")
+	fs.Parse(args)
+
+	p, err := menuFlags.resolve()
+	if err != nil {
+		return err
+	}
+	start, end, err := menuFlags.dates(p)
+	if err != nil {
+		return err
+	}
+
+	doc, err := loadMenu(p, start, end, *menuFlags.debug)
+	if err != nil {
+		return err
+	}
+	return writeICS(doc.Days, p.calendarMarker(doc.Days, start, end), *output, start, end)
+}
+
+// runEmail emails the menu. With -profile, the profile's recipients,
+// sender, subject, template and delivery settings are used unless given
+// as flags.
+func runEmail(args []string) error {
+	fs := newFlagSet("email", "[flags]", "Email the menu as an HTML message with a plain-text alternative.")
+	menuFlags := addMenuFlags(fs)
+	mailFlags := addMailFlags(fs)
+	fs.Parse(args)
+
+	p, err := menuFlags.resolve()
+	if err != nil {
+		return err
+	}
+	mailFlags.apply(&p)
+	if *mailFlags.templateDir == "" {
+		*mailFlags.templateDir = p.Template
+	}
+	*mailFlags.attachICS = *mailFlags.attachICS || p.AttachICS
+	*mailFlags.individual = *mailFlags.individual || p.Individual
+
+	start, end, err := menuFlags.dates(p)
+	if err != nil {
+		return err
+	}
+	emailOpts, err := mailFlags.emailOptions(p.School)
+	if err != nil {
+		return err
+	}
+	mailer, err := mailFlags.mailer()
+	if err != nil {
+		return err
+	}
+
+	doc, err := loadMenu(p, start, end, *menuFlags.debug)
+	if err != nil {
+		return err
+	}
+	return sendMenu(doc, start, end, p, mailer, emailOpts, *menuFlags.debug)
+}
+
+// runNotify posts the menu to chat services and push notification servers.
+// With -profile and no target flags, the profile's notify targets are used.
+func runNotify(args []string) error {
+	fs := newFlagSet("notify", "[flags]", `Post the menu to Slack or Discord incoming webhooks, with a section per day
+and recipes grouped by category, or send a one-line summary such as
+"Today: Chicken Tenders, Corn" to ntfy, Gotify or a generic JSON webhook.`)
+	menuFlags := addMenuFlags(fs)
+	slack := fs.String("slack", os.Getenv("SLACK_WEBHOOK_URL"), "Slack incoming webhook URL")
+	discord := fs.String("discord", os.Getenv("DISCORD_WEBHOOK_URL"), "Discord webhook URL")
+	ntfy := fs.String("ntfy", os.Getenv("NTFY_URL"), "ntfy topic URL, e.g. https://ntfy.sh/lincoln-lunch")
+	ntfyToken := fs.String("ntfy-token", os.Getenv("NTFY_TOKEN"), "ntfy access token")
+	gotify := fs.String("gotify", os.Getenv("GOTIFY_URL"), "Gotify server URL")
+	gotifyToken := fs.String("gotify-token", os.Getenv("GOTIFY_TOKEN"), "Gotify application token")
+	webhook := fs.String("webhook", os.Getenv("WEBHOOK_URL"), "URL to post a JSON payload to")
+	webhookTemplate := fs.String("webhook-template", os.Getenv("WEBHOOK_TEMPLATE"), "File with the webhook's JSON payload template (default: built in)")
+	summary := fs.String("summary", "", "Summary rule for ntfy, Gotify and webhooks: "+notify.SummaryFirstCategory+" (default), "+notify.SummaryAll+", or "+notify.SummaryCategories+"NAME,...")
+	summaryItems := fs.Int("summary-items", 0, "Most recipes per meal in a summary, -1 for no limit (default 4)")
+	fs.Parse(args)
+
+	p, err := menuFlags.resolve()
+	if err != nil {
+		return err
+	}
+
+	var payload string
+	if *webhookTemplate != "" {
+		data, err := os.ReadFile(*webhookTemplate)
+		if err != nil {
+			return usagef("reading webhook template: %w", err)
+		}
+		payload = string(data)
+	}
+	var targets []notify.Config
+	add := func(typ, url, token string) {
+		if url != "" {
+			targets = append(targets, notify.Config{
+				Type:         typ,
+				URL:          url,
+				Token:        token,
+				Summary:      *summary,
+				SummaryItems: *summaryItems,
+				Template:     payload,
+				Timezone:     p.Timezone,
+			})
+		}
+	}
+	add(notify.TypeSlack, *slack, "")
+	add(notify.TypeDiscord, *discord, "")
+	add(notify.TypeNtfy, *ntfy, *ntfyToken)
+	add(notify.TypeGotify, *gotify, *gotifyToken)
+	add(notify.TypeWebhook, *webhook, "")
+	if len(targets) == 0 {
+		targets = p.Notify
+	}
+	if len(targets) == 0 {
+		return usagef("-slack, -discord, -ntfy, -gotify or -webhook (or a -profile with notify targets) is required")
+	}
+	if err := notify.Validate(targets); err != nil {
+		return usageError{err}
+	}
+
+	start, end, err := menuFlags.dates(p)
+	if err != nil {
+		return err
+	}
+	doc, err := loadMenu(p, start, end, *menuFlags.debug)
+	if err != nil {
+		return err
+	}
+	return postMenu(doc, targets)
+}
+
+// runDiscover lists a district's schools with the IDs the other commands
+// take.
+func runDiscover(args []string) error {
+	fs := newFlagSet("discover", "[flags] IDENTIFIER", "Look up a district and its schools by the identifier in the district's LINQ\nConnect menu URL, e.g. 4QDPT3, and print their building and district IDs.")
+	jsonFlag := fs.Bool("json", false, "Print the district as JSON")
+	debug := fs.Bool("debug", false, "Enable debug mode")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return usagef("expected one identifier")
+	}
+
+	district, err := menu.Discover(fs.Arg(0), *debug)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errFetch, err)
+	}
+
+	if *jsonFlag {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(district)
+	}
+
+	fmt.Printf("%s\nDistrict ID: %s\n\n", district.DistrictName, district.DistrictID)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "BUILDING ID\tSCHOOL")
+	for _, b := range district.Buildings {
+		fmt.Fprintf(w, "%s\t%s\n", b.BuildingID, b.Name)
+	}
+	return w.Flush()
+}
+
+// runCache warms or clears the menu cache shared with the web server and
+// the serve command.
+func runCache(args []string) error {
+	if len(args) == 0 || (args[0] != "warm" && args[0] != "clear") {
+		fmt.Fprintln(os.Stderr, "Usage: school_menu_connector cache warm|clear [flags]")
+		return usagef("expected warm or clear")
+	}
+	action := args[0]
+
+	fs := newFlagSet("cache "+action, "[flags]", map[string]string{
+		"warm":  "Fetch each day's menu in the date range into the cache.",
+		"clear": "Remove every cached menu.",
+	}[action])
+	dir := fs.String("dir", "", "Cache directory (default: /tmp/menu-cache)")
+	var menuFlags *menuFlags
+	if action == "warm" {
+		menuFlags = addMenuFlags(fs)
+	}
+	fs.Parse(args[1:])
+
+	c, err := cache.New(*dir, 0)
+	if err != nil {
+		return err
+	}
+
+	if action == "clear" {
+		n, err := c.Clear()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Removed %d cached menus from %s\n", n, c.Dir())
+		return nil
+	}
+
+	p, err := menuFlags.resolve()
+	if err != nil {
+		return err
+	}
+	start, end, err := menuFlags.dates(p)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+		dateStr := date.Format("01-02-2006")
+		m, err := menu.Fetch(p.BuildingID, p.DistrictID, dateStr, dateStr, *menuFlags.debug)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", errFetch, dateStr, err)
+		}
+		c.Set(p.BuildingID, p.DistrictID, dateStr, dateStr, m)
+		archiveMenu(p, m)
+		n++
+	}
+	fmt.Fprintf(os.Stderr, "Cached %d days in %s\n", n, c.Dir())
+	return nil
+}
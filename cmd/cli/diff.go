@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/changes"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// runDiff shows the recipes added and removed between two menu snapshots,
+// or between a school's last snapshot and its current menu.
+func runDiff(args []string) error {
+	fs := newFlagSet("diff", "OLD.json NEW.json | [flags]", `Show the recipes added and removed between two menu snapshots. A snapshot is
+a file kept by watch jobs, or the output of fetch -format=json.
+
+Without files, compare the school's last snapshot in -snapshots with its
+current menu for the date range; -update then records the current menu.`)
+	menuFlags := addMenuFlags(fs)
+	snapshotDir := fs.String("snapshots", os.Getenv("SNAPSHOT_DIR"), "Snapshot directory (default: "+changes.DefaultDir+")")
+	update := fs.Bool("update", false, "Record the current menu as the new snapshot")
+	format := fs.String("format", "text", "Output format (text or json)")
+	fs.Parse(args)
+
+	if *format != "text" && *format != "json" {
+		return usagef("unknown format %q (want text or json)", *format)
+	}
+
+	var diffs []changes.DayDiff
+	switch fs.NArg() {
+	case 2:
+		old, err := changes.ReadSnapshot(fs.Arg(0))
+		if err != nil {
+			return usageError{err}
+		}
+		current, err := changes.ReadSnapshot(fs.Arg(1))
+		if err != nil {
+			return usageError{err}
+		}
+		diffs = changes.CompareDays(old.Days, current.Days)
+	case 0:
+		var err error
+		if diffs, err = diffLive(menuFlags, *snapshotDir, *update); err != nil {
+			return err
+		}
+	default:
+		return usagef("diff takes two snapshot files, or none to compare with the current menu")
+	}
+
+	if *format == "json" {
+		if diffs == nil {
+			diffs = []changes.DayDiff{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diffs)
+	}
+	if len(diffs) == 0 {
+		fmt.Fprintln(os.Stderr, "No changes")
+		return nil
+	}
+	fmt.Print(changes.Text(diffs))
+	return nil
+}
+
+// diffLive compares the school's snapshot with its current menu.
+func diffLive(f *menuFlags, snapshotDir string, update bool) ([]changes.DayDiff, error) {
+	p, err := f.resolve()
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := f.dates(p)
+	if err != nil {
+		return nil, err
+	}
+	store, err := changes.Open(snapshotDir)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := menu.Fetch(p.BuildingID, p.DistrictID, start.Format("01-02-2006"), end.Format("01-02-2006"), *f.debug)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errFetch, err)
+	}
+	var days []menu.Day
+	var dates []time.Time
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+		dates = append(dates, date)
+		if day, ok := m.DayFor(date, p.MealTypes...); ok {
+			days = append(days, day)
+		}
+	}
+
+	diffs, snap, err := store.Check(p.School, p.BuildingID, p.DistrictID, dates, days)
+	if err != nil {
+		return nil, err
+	}
+	if update {
+		if err := store.Save(snap); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Snapshot saved to %s\n", store.Path(p.BuildingID, p.DistrictID))
+	}
+	return diffs, nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/email"
+	"github.com/asachs01/school_menu_connector/internal/render"
+)
+
+// newFlagSet returns a flag set for a subcommand whose -h output shows the
+// usage line and description before the flags.
+func newFlagSet(name, args, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: school_menu_connector %s %s\n\n%s\n", name, args, description)
+		fmt.Fprintln(out, "\nFlags:")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// menuFlags select a school, date range and meal types.
+type menuFlags struct {
+	fs         *flag.FlagSet
+	config     *string
+	profile    *string
+	buildingID *string
+	districtID *string
+	school     *string
+	startDate  *string
+	endDate    *string
+	weekStart  *string
+	timezone   *string
+	mealTypes  *string
+	debug      *bool
+}
+
+func addMenuFlags(fs *flag.FlagSet) *menuFlags {
+	return &menuFlags{
+		fs:         fs,
+		config:     fs.String("config", defaultConfigPath(), "Config file with profiles (YAML)"),
+		profile:    fs.String("profile", "", "Take the school, meal types and allergens from this profile"),
+		buildingID: fs.String("building", os.Getenv("BUILDING_ID"), "Building ID"),
+		districtID: fs.String("district", os.Getenv("DISTRICT_ID"), "District ID"),
+		school:     fs.String("school", os.Getenv("SCHOOL_NAME"), "School name shown in formatted output"),
+		startDate:  fs.String("startDate", os.Getenv("START_DATE"), "Start date: MM-DD-YYYY, YYYY-MM-DD, an offset such as +14d, or "+strings.Join(dates.Names, ", ")+" (default: today)"),
+		endDate:    fs.String("endDate", os.Getenv("END_DATE"), "End date, in the same forms (default: the end of the start date's range)"),
+		weekStart:  fs.String("week-start", os.Getenv("WEEK_START"), "Cover the seven days from this date instead of startDate to endDate"),
+		timezone:   fs.String("tz", "", "Time zone relative dates are resolved in (default: $SCHOOL_TZ or local)"),
+		mealTypes:  fs.String("meal-types", "Lunch", "Comma-separated list of meal types (Breakfast,Lunch,Snack)"),
+		debug:      fs.Bool("debug", false, "Enable debug mode"),
+	}
+}
+
+// resolve returns the profile to fetch: the named profile from the config
+// file, if any, with flags given on the command line taking precedence.
+func (f *menuFlags) resolve() (Profile, error) {
+	var p Profile
+	if *f.profile != "" {
+		cfg, err := loadProfiles(*f.config)
+		if err != nil {
+			return p, err
+		}
+		var ok bool
+		if p, ok = cfg.Profiles[*f.profile]; !ok {
+			return p, usagef("no profile %q in %s", *f.profile, *f.config)
+		}
+	}
+
+	set := make(map[string]bool)
+	f.fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	override := func(name string, dst *string, value string) {
+		if set[name] || *dst == "" {
+			*dst = value
+		}
+	}
+	override("building", &p.BuildingID, *f.buildingID)
+	override("district", &p.DistrictID, *f.districtID)
+	override("school", &p.School, *f.school)
+	override("tz", &p.Timezone, *f.timezone)
+	if set["meal-types"] || len(p.MealTypes) == 0 {
+		p.MealTypes = splitList(*f.mealTypes)
+	}
+
+	if p.BuildingID == "" || p.DistrictID == "" {
+		return p, usagef("-building and -district (or -profile) are required")
+	}
+	return p, nil
+}
+
+// dates returns the date range the flags select, resolving relative dates
+// in the profile's time zone, which resolve has set from -tz if given.
+func (f *menuFlags) dates(p Profile) (start, end time.Time, err error) {
+	return parseRange(*f.startDate, *f.endDate, *f.weekStart, p.Timezone)
+}
+
+// parseRange resolves date expressions in the time zone tz. The start
+// defaults to today; a week start overrides both with the seven days from
+// it.
+func parseRange(startDate, endDate, weekStart, tz string) (start, end time.Time, err error) {
+	now, err := dates.Now(tz)
+	if err != nil {
+		return start, end, usageError{err}
+	}
+	if weekStart != "" {
+		if start, _, err = dates.Range(weekStart, now); err != nil {
+			return start, end, usagef("invalid week start: %v", err)
+		}
+		return start, start.AddDate(0, 0, 6), nil
+	}
+	if start, end, err = dates.Resolve(startDate, endDate, now); err != nil {
+		return start, end, usageError{err}
+	}
+	return start, end, nil
+}
+
+// formatFlags choose how a menu is rendered and where it is written.
+type formatFlags struct {
+	format       *string
+	output       *string
+	pdfLayout    *string
+	pdfAllergens *bool
+	imageSize    *string
+	imageDepth   *int
+	imageDither  *bool
+}
+
+func addFormatFlags(fs *flag.FlagSet) *formatFlags {
+	return &formatFlags{
+		format:       fs.String("format", "", "Output format ("+strings.Join(render.Formats(), ", ")+")"),
+		output:       fs.String("o", "", "Write formatted menu to this file, or - for stdout"),
+		pdfLayout:    fs.String("pdf-layout", "week", "PDF calendar layout (week or month)"),
+		pdfAllergens: fs.Bool("pdf-allergens", false, "Mark allergens in the PDF calendar"),
+		imageSize:    fs.String("image-size", "800x480", "PNG image size in pixels (WIDTHxHEIGHT)"),
+		imageDepth:   fs.Int("image-depth", 8, "PNG bits per pixel (1, 2, 4, 8 for grayscale, 24 for color)"),
+		imageDither:  fs.Bool("image-dither", false, "Dither PNG output for e-ink displays"),
+	}
+}
+
+func (f *formatFlags) options() (formatOptions, error) {
+	opts := formatOptions{
+		pdf: render.PDF{Monthly: *f.pdfLayout == "month", Allergens: *f.pdfAllergens},
+		png: render.PNG{Depth: *f.imageDepth, Dither: *f.imageDither},
+	}
+	if _, err := fmt.Sscanf(*f.imageSize, "%dx%d", &opts.png.Width, &opts.png.Height); err != nil {
+		return opts, usagef("invalid image size %q, expected WIDTHxHEIGHT", *f.imageSize)
+	}
+	return opts, nil
+}
+
+// mailFlags configure who gets the menu email and how it is sent.
+type mailFlags struct {
+	recipients   *string
+	sender       *string
+	subject      *string
+	password     *string
+	smtpServer   *string
+	smtpUsername *string
+	smtpSecurity *string
+	smtpAuth     *string
+	smtpCAFile   *string
+	smtpTimeout  *time.Duration
+	provider     *string
+	outboxDir    *string
+	attachICS    *bool
+	individual   *bool
+	batchSize    *int
+	templateDir  *string
+}
+
+func addMailFlags(fs *flag.FlagSet) *mailFlags {
+	return &mailFlags{
+		recipients:   fs.String("recipient", os.Getenv("RECIPIENT_EMAIL"), "Recipient email address(es), comma-separated"),
+		sender:       fs.String("sender", os.Getenv("SENDER_EMAIL"), "Sender email address"),
+		subject:      fs.String("subject", os.Getenv("EMAIL_SUBJECT"), "Email subject line"),
+		password:     fs.String("password", os.Getenv("EMAIL_PASSWORD"), "Sender email password"),
+		smtpServer:   fs.String("smtp", os.Getenv("SMTP_SERVER"), "SMTP server and port"),
+		smtpUsername: fs.String("smtp-username", os.Getenv("SMTP_USERNAME"), "SMTP login name (default: sender address)"),
+		smtpSecurity: fs.String("smtp-security", os.Getenv("SMTP_SECURITY"), "SMTP encryption (auto, tls, starttls, none)"),
+		smtpAuth:     fs.String("smtp-auth", os.Getenv("SMTP_AUTH"), "SMTP auth mechanism (auto, plain, login, cram-md5, none)"),
+		smtpCAFile:   fs.String("smtp-ca", os.Getenv("SMTP_CA_FILE"), "PEM file of extra CA certificates for the SMTP server"),
+		smtpTimeout:  fs.Duration("smtp-timeout", email.DefaultSMTPTimeout, "Timeout for sending each message"),
+		provider:     fs.String("email-provider", os.Getenv("EMAIL_PROVIDER"), "Email provider (smtp, mailjet, outbox)"),
+		outboxDir:    fs.String("outbox", os.Getenv("EMAIL_OUTBOX_DIR"), "Directory the outbox provider writes .eml files to"),
+		attachICS:    fs.Bool("attach-ics", false, "Attach the menu as a calendar file to the email"),
+		individual:   fs.Bool("individual", false, "Send each recipient their own copy instead of one blind-copied message"),
+		batchSize:    fs.Int("batch-size", email.DefaultBatchSize, "Maximum recipients per blind-copied message"),
+		templateDir:  fs.String("template", os.Getenv("EMAIL_TEMPLATE_DIR"), "Directory of email templates overriding the built-in ones"),
+	}
+}
+
+// mailer returns the configured email provider.
+func (f *mailFlags) mailer() (email.Mailer, error) {
+	cfg := email.MailerConfig{
+		Provider: *f.provider,
+		SMTP: email.SMTPConfig{
+			Server:   *f.smtpServer,
+			Username: *f.smtpUsername,
+			Password: *f.password,
+			CAFile:   *f.smtpCAFile,
+			Timeout:  *f.smtpTimeout,
+		},
+		MailjetPublicKey:  os.Getenv("MJ_APIKEY_PUBLIC"),
+		MailjetPrivateKey: os.Getenv("MJ_APIKEY_PRIVATE"),
+		OutboxDir:         *f.outboxDir,
+	}
+	var err error
+	if cfg.SMTP.Security, err = email.ParseSecurity(*f.smtpSecurity); err != nil {
+		return nil, usageError{err}
+	}
+	if cfg.SMTP.Auth, err = email.ParseAuth(*f.smtpAuth); err != nil {
+		return nil, usageError{err}
+	}
+	mailer, err := email.NewMailer(cfg)
+	if err != nil {
+		return nil, usagef("configuring email: %v", err)
+	}
+	return mailer, nil
+}
+
+// emailOptions returns the template and delivery settings.
+func (f *mailFlags) emailOptions(school string) (email.MenuEmailOptions, error) {
+	opts := email.MenuEmailOptions{
+		School:    school,
+		AttachICS: *f.attachICS,
+		Delivery:  email.DeliveryOptions{BatchSize: *f.batchSize},
+	}
+	if *f.individual {
+		opts.Delivery.Mode = email.DeliverIndividual
+	}
+	if *f.templateDir != "" {
+		templates, err := email.LoadTemplates(*f.templateDir)
+		if err != nil {
+			return opts, fmt.Errorf("loading email templates: %w", err)
+		}
+		opts.Templates = templates
+	}
+	return opts, nil
+}
+
+// apply copies the recipients, sender and subject into p, unless the flags
+// are empty and the profile sets them.
+func (f *mailFlags) apply(p *Profile) {
+	if list := splitList(*f.recipients); len(list) > 0 {
+		p.Recipients = list
+	}
+	if *f.sender != "" {
+		p.Sender = *f.sender
+	}
+	if *f.subject != "" {
+		p.Subject = *f.subject
+	}
+}
+
+// splitList splits a comma-separated list, trimming spaces and dropping
+// empty entries.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
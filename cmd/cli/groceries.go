@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/asachs01/school_menu_connector/internal/grocery"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// runGroceries writes the shopping list for the pack-lunch days in the
+// profile's plan.
+func runGroceries(args []string) error {
+	fs := newFlagSet("groceries", "-profile NAME [flags]", `Build a shopping list for the days that need a packed lunch: days whose
+entrees are all disliked or have the child's allergens, or with no menu.
+Each day gets a lunch from the profile's pantry, taking the options in turn
+and leaving out any with the child's allergens or dislikes. Covers the rest
+of this school week, or next week from Saturday on, unless dates are given.
+
+The todo format is JSON tasks, one per item, due the day before it is first
+packed.`)
+	menuFlags := addMenuFlags(fs)
+	format := fs.String("format", "markdown", "Output format ("+strings.Join(grocery.Formats, ", ")+")")
+	output := fs.String("o", "", "Write the list to this file instead of stdout")
+	fs.Parse(args)
+
+	switch *format {
+	case "markdown", "md", "csv", "todo", "json":
+	default:
+		return usagef("unknown format %q (want %s)", *format, strings.Join(grocery.Formats, ", "))
+	}
+	p, err := menuFlags.resolve()
+	if err != nil {
+		return err
+	}
+	if len(p.Pantry) == 0 {
+		return usagef("no pantry of packed lunches (add one to the profile given with -profile)")
+	}
+
+	plan, err := loadPlan(menuFlags, p)
+	if err != nil {
+		return err
+	}
+	allergens := make([]string, 0, len(p.Allergens))
+	for name := range p.Allergens {
+		allergens = append(allergens, name)
+	}
+	sort.Strings(allergens)
+	list := grocery.Build(plan, p.Pantry, grocery.Preferences{Allergens: allergens, Dislikes: p.Dislikes})
+	for _, lunch := range list.Unplanned() {
+		fmt.Fprintf(os.Stderr, "Warning: no safe pantry option for %s\n", menu.Day{Date: lunch.Date}.Time().Format("Monday, January 2"))
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(list)
+	case "csv":
+		err = grocery.WriteCSV(&buf, list)
+	case "todo":
+		err = grocery.WriteTodo(&buf, list)
+	default:
+		err = grocery.WriteMarkdown(&buf, list)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output == "" || *output == "-" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := os.WriteFile(*output, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing grocery list: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Grocery list written to %s\n", *output)
+	return nil
+}
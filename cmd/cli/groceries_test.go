@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/asachs01/school_menu_connector/internal/grocery"
+)
+
+const pantryProfile = `profiles:
+  emma:
+    school: Lincoln
+    building: b1
+    district: d1
+    allergens:
+      Milk: a-milk
+    pantry:
+      - name: Turkey sandwich
+        ingredients:
+          - {item: Sandwich bread, quantity: 2, unit: slices}
+          - {item: Apple}
+      - name: Cheese and crackers
+        allergens: [Milk]
+        ingredients:
+          - {item: Cheddar, quantity: 2, unit: oz}
+      - name: Hummus and veggies
+        ingredients:
+          - {item: Hummus, quantity: 0.5, unit: cup}
+          - {item: Apple}
+`
+
+func TestGroceriesUnpublishedWeek(t *testing.T) {
+	newFakeLINQ(t, unpublished)
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "profiles.yaml")
+	if err := os.WriteFile(cfg, []byte(pantryProfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "groceries.json")
+
+	err := runGroceries([]string{"-config", cfg, "-profile", "emma", "-startDate=2024-09-02", "-endDate=2024-09-06", "-format", "json", "-o", out})
+	if err != nil {
+		t.Fatalf("groceries for an unpublished week: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list grocery.List
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatal(err)
+	}
+
+	var lunches []string
+	for _, l := range list.Lunches {
+		lunches = append(lunches, l.Date+" "+l.Option+" "+strings.Join(l.Reasons, ","))
+	}
+	want := "2024-09-02 Turkey sandwich no-menu, 2024-09-03 Hummus and veggies no-menu, 2024-09-04 Turkey sandwich no-menu, " +
+		"2024-09-05 Hummus and veggies no-menu, 2024-09-06 Turkey sandwich no-menu"
+	if got := strings.Join(lunches, ", "); got != want {
+		t.Errorf("lunches = %s\nwant %s", got, want)
+	}
+
+	var items []string
+	for _, it := range list.Items {
+		items = append(items, strings.Join(strings.Fields(fmt.Sprintf("%g %s %s", it.Quantity, it.Unit, it.Name)), " "))
+	}
+	if got, want := strings.Join(items, ", "), "5 Apple, 1 cup Hummus, 6 slices Sandwich bread"; got != want {
+		t.Errorf("items = %s, want %s", got, want)
+	}
+	if len(list.Excluded) != 1 || list.Excluded[0].Option != "Cheese and crackers" {
+		t.Errorf("excluded = %+v, want Cheese and crackers", list.Excluded)
+	}
+}
+
+func TestGroceriesNeedsPantry(t *testing.T) {
+	newFakeLINQ(t, unpublished)
+	err := runGroceries([]string{"-building=b1", "-district=d1", "-startDate=2024-09-02", "-endDate=2024-09-06"})
+	var usage usageError
+	if !errors.As(err, &usage) {
+		t.Errorf("groceries without a pantry = %v, want a usage error", err)
+	}
+}
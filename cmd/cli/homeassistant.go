@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/cache"
+	"github.com/asachs01/school_menu_connector/internal/homeassistant"
+	"github.com/asachs01/school_menu_connector/internal/notify"
+	"github.com/sirupsen/logrus"
+)
+
+// runHomeAssistant publishes today's and tomorrow's menus as Home Assistant
+// sensors over MQTT, refreshing them until interrupted.
+func runHomeAssistant(args []string) error {
+	fs := newFlagSet("mqtt", "-broker URL [flags]", `Publish today's and tomorrow's menus to Home Assistant over MQTT, with
+discovery so the sensors appear by themselves, and keep them up to date.
+
+Each profile in the config file (or those named by -profiles) gets a
+device with a sensor per meal type and day; -building and -district
+publish a single school instead.`)
+	broker := fs.String("broker", os.Getenv("MQTT_BROKER"), "MQTT broker URL, e.g. tcp://homeassistant.local:1883")
+	username := fs.String("mqtt-user", os.Getenv("MQTT_USERNAME"), "MQTT username")
+	password := fs.String("mqtt-password", os.Getenv("MQTT_PASSWORD"), "MQTT password")
+	clientID := fs.String("client-id", "", "MQTT client ID (default: school-menu-connector)")
+	discoveryPrefix := fs.String("discovery-prefix", homeassistant.DefaultDiscoveryPrefix, "Home Assistant discovery topic prefix")
+	topicPrefix := fs.String("topic-prefix", homeassistant.DefaultTopicPrefix, "Prefix for the sensors' state topics")
+	interval := fs.Duration("interval", homeassistant.DefaultInterval, "How often to refresh the sensors")
+	once := fs.Bool("once", false, "Publish once and exit")
+	cacheDir := fs.String("cache-dir", "", "Menu cache directory (default: /tmp/menu-cache)")
+	configPath := fs.String("config", defaultConfigPath(), "Config file with profiles (YAML)")
+	profiles := fs.String("profiles", "", "Comma-separated profiles to publish (default: all)")
+	buildingID := fs.String("building", os.Getenv("BUILDING_ID"), "Building ID, instead of profiles")
+	districtID := fs.String("district", os.Getenv("DISTRICT_ID"), "District ID")
+	school := fs.String("school", os.Getenv("SCHOOL_NAME"), "School name")
+	mealTypes := fs.String("meal-types", "Lunch", "Comma-separated meal types, with -building")
+	timezone := fs.String("tz", "", "Time zone that decides which day is today (default: $SCHOOL_TZ or local)")
+	summary := fs.String("summary", "", "Recipes in each sensor's state: "+notify.SummaryFirstCategory+" (default), "+notify.SummaryAll+", or "+notify.SummaryCategories+"NAME,...")
+	summaryItems := fs.Int("summary-items", 0, "Most recipes in a sensor's state, -1 for no limit (default 4)")
+	fs.Parse(args)
+
+	if *broker == "" {
+		return usagef("-broker is required")
+	}
+
+	var schools []homeassistant.School
+	if *buildingID != "" || *districtID != "" {
+		if *buildingID == "" || *districtID == "" {
+			return usagef("-building and -district must be given together")
+		}
+		id := *school
+		if id == "" {
+			id = *buildingID
+		}
+		schools = append(schools, homeassistant.School{ID: id, Name: *school, BuildingID: *buildingID, DistrictID: *districtID, Sessions: splitList(*mealTypes)})
+	} else {
+		cfg, err := loadProfiles(*configPath)
+		if err != nil {
+			return err
+		}
+		names := splitList(*profiles)
+		if len(names) == 0 {
+			for name := range cfg.Profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+		}
+		for _, name := range names {
+			p, ok := cfg.Profiles[name]
+			if !ok {
+				return usagef("no profile %q in %s", name, *configPath)
+			}
+			if *timezone == "" {
+				*timezone = p.Timezone
+			}
+			schools = append(schools, homeassistant.School{ID: name, Name: p.School, BuildingID: p.BuildingID, DistrictID: p.DistrictID, Sessions: p.MealTypes, Allergens: p.Allergens})
+		}
+	}
+
+	logger := logrus.New()
+	opts := homeassistant.Options{
+		Broker:          *broker,
+		Username:        *username,
+		Password:        *password,
+		ClientID:        *clientID,
+		DiscoveryPrefix: *discoveryPrefix,
+		TopicPrefix:     *topicPrefix,
+		Timezone:        *timezone,
+		Summary:         notify.Summary{Rule: *summary, MaxItems: *summaryItems},
+		Logger:          logger,
+	}
+	var err error
+	if opts.Cache, err = cache.New(*cacheDir, 0); err != nil {
+		logger.WithError(err).Warn("Menu cache unavailable")
+	}
+	publisher, err := homeassistant.New(schools, opts)
+	if err != nil {
+		return usageError{err}
+	}
+	if err := publisher.Connect(); err != nil {
+		return err
+	}
+	defer publisher.Close()
+
+	if *once {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		if err := publisher.Publish(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Menus published to Home Assistant")
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = publisher.Run(ctx, *interval)
+	logger.Info("Stopping Home Assistant publisher")
+	return err
+}
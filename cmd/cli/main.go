@@ -19,94 +19,321 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package main
 
 import (
-	"flag"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/asachs01/school_menu_connector/internal/menu"
-	"github.com/asachs01/school_menu_connector/internal/ics"
 	"github.com/asachs01/school_menu_connector/internal/email"
+	"github.com/asachs01/school_menu_connector/internal/ics"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/notify"
+	"github.com/asachs01/school_menu_connector/internal/render"
+)
+
+// Exit codes, so scripts can tell a day without school lunch from a real
+// failure.
+const (
+	exitError    = 1 // any other error
+	exitUsage    = 2 // invalid flags, arguments or configuration
+	exitNoMenu   = 3 // the school has no menu for the dates
+	exitFetch    = 4 // LINQ Connect could not be reached or returned an error
+	exitDelivery = 5 // the email or a chat post did not reach some or all recipients
+)
+
+var (
+	errNoMenu   = errors.New("no menu found")
+	errFetch    = errors.New("fetching menu")
+	errDelivery = errors.New("sending email")
+	errNotify   = errors.New("posting menu")
 )
 
+// usageError is an error in the flags, arguments or configuration.
+type usageError struct{ error }
+
+func usagef(format string, args ...interface{}) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
+
+// exitCode returns the exit status for err.
+func exitCode(err error) int {
+	switch {
+	case errors.As(err, new(usageError)):
+		return exitUsage
+	case errors.Is(err, errNoMenu):
+		return exitNoMenu
+	case errors.Is(err, errFetch):
+		return exitFetch
+	case errors.Is(err, errDelivery), errors.Is(err, errNotify):
+		return exitDelivery
+	}
+	return exitError
+}
+
+// command is a subcommand of the CLI.
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"fetch":     {"Print the menu or write it to a file in any format", runFetch},
+	"ics":       {"Write the menu as an iCalendar file", runICS},
+	"email":     {"Email the menu", runEmail},
+	"notify":    {"Post the menu to chat or push notification services", runNotify},
+	"bot":       {"Answer menu commands in Telegram and Matrix chats", runBot},
+	"mqtt":      {"Publish menus to Home Assistant over MQTT", runHomeAssistant},
+	"diff":      {"Show menu changes between snapshots", runDiff},
+	"backfill":  {"Fetch past school years into the menu archive", runBackfill},
+	"archive":   {"Print archived menus or list archived schools", runArchive},
+	"report":    {"Report recipe frequency, cycles, calories and allergens from the archive", runReport},
+	"search":    {"Find the days a recipe is served", runSearch},
+	"plan":      {"Plan favorite and pack-lunch days from a profile's likes", runPlan},
+	"groceries": {"Build a shopping list for pack-lunch days from a profile's pantry", runGroceries},
+	"discover":  {"Look up a district's schools by menu identifier", runDiscover},
+	"serve":     {"Serve a school's menu and calendar feed over HTTP", runServe},
+	"cache":     {"Warm or clear the menu cache", runCache},
+	"schedule":  {"Run jobs from a schedule file", runSchedule},
+	"run":       {"Run profiles from the config file", runProfiles},
+	"preview":   {"Preview the email templates with sample data", runPreview},
+}
+
 func main() {
-	buildingID := flag.String("building", os.Getenv("BUILDING_ID"), "Building ID")
-	districtID := flag.String("district", os.Getenv("DISTRICT_ID"), "District ID")
-	recipients := flag.String("recipient", os.Getenv("RECIPIENT_EMAIL"), "Recipient email address(es), comma-separated")
-	sender := flag.String("sender", os.Getenv("SENDER_EMAIL"), "Sender email address")
-	password := flag.String("password", os.Getenv("EMAIL_PASSWORD"), "Sender email password")
-	smtpServer := flag.String("smtp", os.Getenv("SMTP_SERVER"), "SMTP server and port")
-	subject := flag.String("subject", os.Getenv("EMAIL_SUBJECT"), "Email subject line")
-	startDate := flag.String("startDate", os.Getenv("START_DATE"), "Start date (MM-DD-YYYY)")
-	endDate := flag.String("endDate", os.Getenv("END_DATE"), "End date (MM-DD-YYYY)")
-	weekStart := flag.String("week-start", "", "Start date of the week (MM-DD-YYYY) for calendar file")
-	icsOutputPath := flag.String("ics-output-path", "", "Output path for the ICS file")
-	emailFlag := flag.Bool("email", false, "Send email")
-	icsFlag := flag.Bool("ics", false, "Generate ICS file")
-	debugFlag := flag.Bool("debug", false, "Enable debug mode")
-	mealTypesFlag := flag.String("meal-types", "Lunch", "Comma-separated list of meal types (Breakfast,Lunch,Snack)")
-	flag.Parse()
-
-	mealTypes := strings.Split(*mealTypesFlag, ",")
-	for i, mt := range mealTypes {
-		mealTypes[i] = strings.TrimSpace(mt)
-	}
-
-	if err := run(*buildingID, *districtID, *recipients, *sender, *password, *smtpServer, *subject, *startDate, *endDate, *weekStart, *icsOutputPath, *emailFlag, *icsFlag, *debugFlag, mealTypes); err != nil {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(exitUsage)
+	}
+
+	name, args := os.Args[1], os.Args[2:]
+	var err error
+	switch cmd, ok := commands[name]; {
+	case name == "help" || name == "-h" || name == "-help" || name == "--help":
+		usage(os.Stdout)
+		return
+	case ok:
+		err = cmd.run(args)
+	case strings.HasPrefix(name, "-"):
+		// Flags without a command: the original single-command interface.
+		err = runLegacy(os.Args[1:])
+	default:
+		err = usagef("unknown command %q (run school_menu_connector help)", name)
+	}
+
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: school_menu_connector <command> [flags]")
+	fmt.Fprintln(w, "\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-9s %s\n", name, commands[name].summary)
 	}
+	fmt.Fprintln(w, "\nRun school_menu_connector <command> -h for a command's flags.")
+	fmt.Fprintf(w, "\nExit codes: 0 success, %d error, %d usage, %d no menu for the dates, %d menu fetch failed, %d email or chat delivery failed.\n",
+		exitError, exitUsage, exitNoMenu, exitFetch, exitDelivery)
 }
 
-func run(buildingID, districtID, recipients, sender, password, smtpServer, subject, startDate, endDate, weekStart, icsOutputPath string, emailFlag, icsFlag, debugFlag bool, mealTypes []string) error {
-	start, err := time.Parse("01-02-2006", startDate)
+// runLegacy runs the original flag-only interface, in which -email, -ics
+// and -format choose what to do with the menu.
+func runLegacy(args []string) error {
+	fs := newFlagSet("", "[flags]", "Fetch the menu and email it, write it as a calendar, or print it.")
+	menuFlags := addMenuFlags(fs)
+	formatFlags := addFormatFlags(fs)
+	mailFlags := addMailFlags(fs)
+	emailFlag := fs.Bool("email", false, "Send email")
+	icsFlag := fs.Bool("ics", false, "Generate ICS file")
+	icsOutputPath := fs.String("ics-output-path", os.Getenv("ICS_OUTPUT_PATH"), "Output path for the ICS file, or - for stdout")
+	fs.Parse(args)
+
+	p, err := menuFlags.resolve()
 	if err != nil {
-		return fmt.Errorf("invalid start date: %w", err)
+		return err
 	}
+	mailFlags.apply(&p)
+	p.Email = *emailFlag
+	p.ICS = *icsFlag
+	p.ICSOutput = *icsOutputPath
+	p.Format = *formatFlags.format
+	p.Output = *formatFlags.output
 
-	end, err := time.Parse("01-02-2006", endDate)
+	opts, err := formatFlags.options()
+	if err != nil {
+		return err
+	}
+	emailOpts, err := mailFlags.emailOptions(p.School)
 	if err != nil {
-		return fmt.Errorf("invalid end date: %w", err)
+		return err
+	}
+	var mailer email.Mailer
+	if p.Email {
+		if mailer, err = mailFlags.mailer(); err != nil {
+			return err
+		}
 	}
 
-	if debugFlag {
-		fmt.Printf("Fetching menu for date range: %s to %s\n", start.Format("01-02-2006"), end.Format("01-02-2006"))
+	start, end, err := menuFlags.dates(p)
+	if err != nil {
+		return err
 	}
+	return run(p, start, end, mailer, *menuFlags.debug, opts, emailOpts)
+}
 
-	menuData, err := menu.Fetch(buildingID, districtID, start.Format("01-02-2006"), end.Format("01-02-2006"), debugFlag)
+// loadMenu fetches the profile's menu for the date range, with only the
+// profile's allergens marked.
+func loadMenu(p Profile, start, end time.Time, debug bool) (render.Document, error) {
+	if debug {
+		fmt.Fprintf(os.Stderr, "Fetching menu for date range: %s to %s\n", start.Format("01-02-2006"), end.Format("01-02-2006"))
+	}
+
+	menuData, err := menu.Fetch(p.BuildingID, p.DistrictID, start.Format("01-02-2006"), end.Format("01-02-2006"), debug)
 	if err != nil {
-		return fmt.Errorf("fetching menu: %w", err)
+		return render.Document{}, fmt.Errorf("%w: %w", errFetch, err)
 	}
+	archiveMenu(p, menuData)
 
-	lunchMenu := menuData.GetLunchMenuString()
-	if lunchMenu == "" {
-		return fmt.Errorf("no lunch menu found for the specified date range")
+	days := menuData.Days(p.MealTypes...)
+	if len(days) == 0 {
+		return render.Document{}, fmt.Errorf("%w for %s in the specified date range", errNoMenu, strings.Join(p.MealTypes, "/"))
+	}
+	return render.Document{School: p.School, Days: days, AllergenNames: p.applyAllergens(days)}, nil
+}
+
+// run fetches the profile's menu for the date range and writes, emails,
+// posts or exports it as the profile asks.
+func run(p Profile, start, end time.Time, mailer email.Mailer, debugFlag bool, opts formatOptions, emailOpts email.MenuEmailOptions) error {
+	doc, err := loadMenu(p, start, end, debugFlag)
+	if err != nil {
+		return err
 	}
 
 	if debugFlag {
-		fmt.Println("Lunch menu found:")
-		fmt.Println(lunchMenu)
+		fmt.Fprintln(os.Stderr, "Menu found:")
+		(render.Text{}).Render(os.Stderr, render.Document{Days: doc.Days})
+	}
+
+	if p.Format != "" || p.Output != "" {
+		if err := writeFormatted(doc, p.Format, p.Output, opts); err != nil {
+			return err
+		}
 	}
 
-	if emailFlag {
-		if err := email.SendLunchMenu(buildingID, districtID, start.Format("01-02-2006"), end.Format("01-02-2006"), recipients, smtpServer, sender, password, subject, debugFlag); err != nil {
-			return fmt.Errorf("sending email: %w", err)
+	if p.Email {
+		if err := sendMenu(doc, start, end, p, mailer, emailOpts, debugFlag); err != nil {
+			return err
 		}
-		fmt.Println("Lunch menu sent successfully!")
 	}
 
-	if icsFlag {
-		outputPath := fmt.Sprintf("menu_%s_to_%s.ics", start.Format("01-02-2006"), end.Format("01-02-2006"))
-		icsData, err := ics.GenerateICSFileWithMealTypes(buildingID, districtID, startDate, endDate, mealTypes, debugFlag)
+	if len(p.Notify) > 0 {
+		if err := postMenu(doc, p.Notify); err != nil {
+			return err
+		}
+	}
+
+	if p.ICS {
+		if err := writeICS(doc.Days, p.calendarMarker(doc.Days, start, end), p.ICSOutput, start, end); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func postMenu(doc render.Document, targets []notify.Config) error {
+	if err := notify.Send(context.Background(), targets, doc); err != nil {
+		return fmt.Errorf("%w: %w", errNotify, err)
+	}
+	fmt.Fprintln(os.Stderr, "Menu posted successfully!")
+	return nil
+}
+
+func sendMenu(doc render.Document, start, end time.Time, p Profile, mailer email.Mailer, emailOpts email.MenuEmailOptions, debug bool) error {
+	if len(p.Recipients) == 0 {
+		return usagef("no recipients")
+	}
+	if err := email.SendMenu(doc, start, end, p.Recipients, mailer, p.Sender, p.Subject, emailOpts, debug); err != nil {
+		return fmt.Errorf("%w: %w", errDelivery, err)
+	}
+	fmt.Fprintln(os.Stderr, "Menu sent successfully!")
+	return nil
+}
+
+// writeICS writes days as a calendar, with event titles marked by marker,
+// to path, to stdout if path is "-", or to menu_<start>_to_<end>.ics if
+// path is empty.
+func writeICS(days []menu.Day, marker ics.Marker, path string, start, end time.Time) error {
+	data := ics.GenerateMarked(days, marker)
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if path == "" {
+		path = fmt.Sprintf("menu_%s_to_%s.ics", start.Format("01-02-2006"), end.Format("01-02-2006"))
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write ICS file: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "ICS file generated successfully: %s\n", path)
+	return nil
+}
+
+// formatOptions holds the settings for formats that take options.
+type formatOptions struct {
+	pdf render.PDF
+	png render.PNG
+}
+
+// writeFormatted renders doc to output, or to stdout if output is empty or
+// "-". When no format is given it is inferred from the output file
+// extension, falling back to plain text.
+func writeFormatted(doc render.Document, format, output string, opts formatOptions) error {
+	var renderer render.Renderer = render.Text{}
+	if format != "" {
+		r, err := render.ForFormat(format)
 		if err != nil {
-			return fmt.Errorf("failed to generate ICS file: %v", err)
+			return usageError{err}
 		}
-		if err := os.WriteFile(outputPath, icsData, 0644); err != nil {
-			return fmt.Errorf("failed to write ICS file: %v", err)
+		renderer = r
+	} else if r, err := render.ForFormat(filepath.Ext(output)); err == nil {
+		renderer = r
+	}
+	switch renderer.(type) {
+	case render.PDF:
+		renderer = opts.pdf
+	case render.PNG:
+		if err := opts.png.Validate(); err != nil {
+			return usageError{err}
 		}
-		fmt.Printf("ICS file generated successfully: %s\n", outputPath)
+		renderer = opts.png
+	}
+
+	if output == "" || output == "-" {
+		return renderer.Render(os.Stdout, doc)
 	}
 
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("creating output file: %w", err)
+	}
+	if err := renderer.Render(f, doc); err != nil {
+		f.Close()
+		return fmt.Errorf("rendering menu: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Menu written to %s\n", output)
 	return nil
 }
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"usage", usagef("-building and -district (or -profile) are required"), exitUsage},
+		{"wrapped usage", fmt.Errorf("profile emma: %w", usagef("no recipients")), exitUsage},
+		{"no menu", fmt.Errorf("%w for Lunch in the specified date range", errNoMenu), exitNoMenu},
+		{"fetch", fmt.Errorf("%w: %w", errFetch, errors.New("connection refused")), exitFetch},
+		{"email", fmt.Errorf("%w: %w", errDelivery, errors.New("550 mailbox unavailable")), exitDelivery},
+		{"chat", fmt.Errorf("%w: %w", errNotify, errors.New("slack: unexpected status 404")), exitDelivery},
+		{"other", errors.New("writing output file: disk full"), exitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// readFixture returns the sample LINQ Connect response the email templates
+// are previewed with.
+func readFixture(t *testing.T) string {
+	t.Helper()
+	fixture, err := os.ReadFile(filepath.Join("..", "..", "internal", "email", "templates", "fixture.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(fixture)
+}
+
+func TestRunLegacy(t *testing.T) {
+	for _, env := range []string{"BUILDING_ID", "DISTRICT_ID", "START_DATE", "END_DATE", "WEEK_START", "ICS_OUTPUT_PATH", "RECIPIENT_EMAIL", "SENDER_EMAIL", "EMAIL_PROVIDER", "EMAIL_OUTBOX_DIR", "EMAIL_TEMPLATE_DIR"} {
+		t.Setenv(env, "")
+	}
+	fixture := readFixture(t)
+	school := []string{"-building=b1", "-district=d1", "-startDate=2024-09-04", "-endDate=2024-09-05"}
+
+	blocked := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		args     func(dir string) []string
+		wantCode int
+		check    func(t *testing.T, dir string)
+	}{
+		{
+			name: "format",
+			body: fixture,
+			args: func(dir string) []string {
+				return []string{"-format", "markdown", "-o", filepath.Join(dir, "menu.md")}
+			},
+			check: func(t *testing.T, dir string) {
+				data, err := os.ReadFile(filepath.Join(dir, "menu.md"))
+				if err != nil || !strings.Contains(string(data), "Cheesy Pasta Bake") {
+					t.Errorf("menu.md = %.60q, %v, want the menu", data, err)
+				}
+			},
+		},
+		{
+			name: "ics",
+			body: fixture,
+			args: func(dir string) []string {
+				return []string{"-ics", "-ics-output-path", filepath.Join(dir, "menu.ics")}
+			},
+			check: func(t *testing.T, dir string) {
+				data, err := os.ReadFile(filepath.Join(dir, "menu.ics"))
+				if err != nil || strings.Count(string(data), "BEGIN:VEVENT") != 2 {
+					t.Errorf("menu.ics = %.60q, %v, want an event for each day", data, err)
+				}
+			},
+		},
+		{
+			name: "email",
+			body: fixture,
+			args: func(dir string) []string {
+				return []string{"-email", "-recipient", "family@example.com", "-sender", "menus@example.com", "-email-provider", "outbox", "-outbox", dir}
+			},
+			check: func(t *testing.T, dir string) {
+				files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+				if len(files) != 1 {
+					t.Errorf("outbox has %d messages, want 1", len(files))
+				}
+			},
+		},
+		{
+			name: "email not delivered",
+			body: fixture,
+			args: func(dir string) []string {
+				return []string{"-email", "-recipient", "family@example.com", "-sender", "menus@example.com", "-email-provider", "outbox", "-outbox", filepath.Join(blocked, "outbox")}
+			},
+			wantCode: exitDelivery,
+		},
+		{
+			name: "email without a provider",
+			body: fixture,
+			args: func(dir string) []string {
+				return []string{"-email", "-recipient", "family@example.com", "-email-provider", "pigeon"}
+			},
+			wantCode: exitUsage,
+		},
+		{
+			name:     "no menu",
+			body:     unpublished,
+			args:     func(dir string) []string { return []string{"-format", "text", "-o", filepath.Join(dir, "menu.txt")} },
+			wantCode: exitNoMenu,
+		},
+		{
+			name:     "fetch failure",
+			body:     "<html>Service Unavailable</html>",
+			args:     func(dir string) []string { return nil },
+			wantCode: exitFetch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFakeLINQ(t, tt.body)
+			dir := t.TempDir()
+			err := runLegacy(append(append([]string{}, school...), tt.args(dir)...))
+			if tt.wantCode == 0 && err != nil || tt.wantCode != 0 && (err == nil || exitCode(err) != tt.wantCode) {
+				t.Fatalf("runLegacy = %v, want exit code %d", err, tt.wantCode)
+			}
+			if tt.check != nil {
+				tt.check(t, dir)
+			}
+		})
+	}
+
+	t.Run("school required", func(t *testing.T) {
+		linq := newFakeLINQ(t, fixture)
+		if err := runLegacy([]string{"-format", "text"}); exitCode(err) != exitUsage {
+			t.Errorf("runLegacy = %v, want a usage error", err)
+		}
+		if linq.count() != 0 {
+			t.Errorf("made %d requests without a school", linq.count())
+		}
+	})
+}
+
+func TestFetchDebugToStdout(t *testing.T) {
+	newFakeLINQ(t, readFixture(t))
+	out, err := captureStdout(t, func() error {
+		return runFetch([]string{"-building=b1", "-district=d1", "-startDate=2024-09-04", "-endDate=2024-09-05", "-format", "json", "-o", "-", "-debug"})
+	})
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	for _, debug := range []string{"API URL", "Response body", "Parsed menu", "Menu found"} {
+		if strings.Contains(out, debug) {
+			t.Errorf("stdout has debug output %q", debug)
+		}
+	}
+	if !json.Valid([]byte(out)) {
+		t.Errorf("stdout = %.60q, want only the JSON menu", out)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/email"
+	"github.com/asachs01/school_menu_connector/internal/ics"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/notify"
+	"github.com/asachs01/school_menu_connector/internal/planner"
+)
+
+// runPlan prints or sends the week's lunch plan: favorite days and the
+// days to pack a lunch.
+func runPlan(args []string) error {
+	fs := newFlagSet("plan", "[flags]", `Plan lunches by a profile's favorites, dislikes and allergens. Days serving
+a favorite recipe are favorite days; days whose entrees are all disliked or
+have the child's allergens, or with no menu, are pack-lunch days. Covers
+the rest of this school week, or next week from Saturday on, unless dates
+are given.
+
+With -send, the plan goes to the profile's notify targets and, if the
+profile emails, its recipients.`)
+	menuFlags := addMenuFlags(fs)
+	favorites := fs.String("favorites", "", "Comma-separated favorite recipe patterns (default: the profile's)")
+	dislikes := fs.String("dislikes", "", "Comma-separated disliked recipe patterns (default: the profile's)")
+	format := fs.String("format", "text", "Output format (text or json)")
+	send := fs.Bool("send", false, "Send the plan to the profile's notify targets and email recipients")
+	fs.Parse(args)
+
+	if *format != "text" && *format != "json" {
+		return usagef("unknown format %q (want text or json)", *format)
+	}
+	p, err := menuFlags.resolve()
+	if err != nil {
+		return err
+	}
+	if *favorites != "" {
+		p.Favorites = splitList(*favorites)
+	}
+	if *dislikes != "" {
+		p.Dislikes = splitList(*dislikes)
+	}
+	prefs := p.preferences()
+	if prefs.Empty() {
+		return usagef("no favorites, dislikes or allergens to plan by (set them in the profile or use -favorites and -dislikes)")
+	}
+	if *send && len(p.Notify) == 0 && !p.Email {
+		return usagef("-send needs a profile with notify targets or email")
+	}
+
+	plan, err := loadPlan(menuFlags, p)
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(plan); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("%s\n\n%s", plan.Title(), plan.Text())
+	}
+	if *send {
+		return sendPlan(p, plan)
+	}
+	return nil
+}
+
+// loadPlan fetches the menu for the dates the flags select and plans it
+// by the profile's preferences. Without dates, it covers the rest of this
+// school week, or next week on a weekend.
+func loadPlan(f *menuFlags, p Profile) (planner.Plan, error) {
+	var start, end time.Time
+	var err error
+	if *f.startDate == "" && *f.weekStart == "" {
+		now, err := dates.Now(p.Timezone)
+		if err != nil {
+			return planner.Plan{}, usageError{err}
+		}
+		start = dates.Day(now)
+		if dates.Weekend(start) {
+			start = dates.SchoolDayAfter(start, nil)
+		}
+		end = dates.Monday(start).AddDate(0, 0, 4)
+	} else if start, end, err = f.dates(p); err != nil {
+		return planner.Plan{}, err
+	}
+
+	// A week the district has not published is all pack-lunch days, not
+	// an error.
+	doc, err := loadMenu(p, start, end, *f.debug)
+	if err != nil && !errors.Is(err, errNoMenu) {
+		return planner.Plan{}, err
+	}
+	return planner.Build(p.School, schoolDays(start, end), doc.Days, p.preferences()), nil
+}
+
+// sendPlan posts the plan to the profile's notify targets and emails it to
+// its recipients.
+func sendPlan(p Profile, plan planner.Plan) error {
+	if len(p.Notify) > 0 {
+		alert := notify.Alert{School: p.School, Title: plan.Title(), Text: plan.Text(), Details: plan}
+		if err := notify.SendAlert(context.Background(), p.Notify, alert); err != nil {
+			return fmt.Errorf("%w: %w", errNotify, err)
+		}
+	}
+	if p.Email {
+		mailerConfig, err := email.MailerConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		mailer, err := email.NewMailer(mailerConfig)
+		if err != nil {
+			return usagef("configuring email: %v", err)
+		}
+		delivery := email.DeliveryOptions{}
+		if p.Individual {
+			delivery.Mode = email.DeliverIndividual
+		}
+		msg := &email.Message{From: p.Sender, Subject: plan.Title(), Text: plan.Text()}
+		if _, err := mailer.Send(msg, p.Recipients, delivery); err != nil {
+			return fmt.Errorf("%w: %w", errDelivery, err)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Plan sent successfully!")
+	return nil
+}
+
+// preferences returns the profile's likes, dislikes and allergens,
+// planned for lunch unless the profile has no lunch.
+func (p Profile) preferences() planner.Preferences {
+	prefs := planner.Preferences{Favorites: p.Favorites, Dislikes: p.Dislikes, Session: planner.SessionFor(p.MealTypes)}
+	if len(p.Allergens) > 0 {
+		prefs.Allergens = make(map[string]string, len(p.Allergens))
+		for name, id := range p.Allergens {
+			prefs.Allergens[id] = name
+		}
+	}
+	return prefs
+}
+
+// calendarMarker marks favorite and pack-lunch days in the profile's
+// calendar events, or returns nil if the profile has no preferences.
+func (p Profile) calendarMarker(days []menu.Day, start, end time.Time) ics.Marker {
+	prefs := p.preferences()
+	if prefs.Empty() {
+		return nil
+	}
+	return planner.Build(p.School, schoolDays(start, end), days, prefs).Marker
+}
+
+// schoolDays returns the weekdays from start to end.
+func schoolDays(start, end time.Time) []time.Time {
+	var list []time.Time
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		if !dates.Weekend(d) {
+			list = append(list, d)
+		}
+	}
+	return list
+}
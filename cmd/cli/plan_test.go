@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/asachs01/school_menu_connector/internal/planner"
+)
+
+// fakeLINQ serves body for every menu request through PROXY_URL and
+// counts the requests.
+type fakeLINQ struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests int
+}
+
+func newFakeLINQ(t *testing.T, body string) *fakeLINQ {
+	f := &fakeLINQ{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests++
+		f.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(f.Close)
+	t.Setenv("PROXY_URL", f.URL)
+	t.Setenv("PROXY_AUTH_TOKEN", "")
+	t.Setenv("ARCHIVE_DB", "")
+	return f
+}
+
+func (f *fakeLINQ) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+// unpublished is LINQ Connect's answer for dates without a menu.
+const unpublished = `{"FamilyMenuSessions":[],"AcademicCalendars":[]}`
+
+func parseMenuFlags(t *testing.T, args ...string) (*menuFlags, Profile) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := addMenuFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	p, err := f.resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, p
+}
+
+func TestLoadPlanUnpublishedWeek(t *testing.T) {
+	linq := newFakeLINQ(t, unpublished)
+	f, p := parseMenuFlags(t, "-building=b1", "-district=d1", "-school=Lincoln", "-startDate=2024-09-02", "-endDate=2024-09-08")
+	p.Dislikes = []string{"fish stick"}
+
+	plan, err := loadPlan(f, p)
+	if err != nil {
+		t.Fatalf("loadPlan: %v", err)
+	}
+	if len(plan.Days) != 5 {
+		t.Fatalf("planned %d days, want the 5 weekdays", len(plan.Days))
+	}
+	for _, d := range plan.Days {
+		if !d.PackLunch || len(d.Reasons) != 1 || d.Reasons[0] != planner.ReasonNoMenu {
+			t.Errorf("%s = %+v, want a pack-lunch day with no menu", d.Date, d)
+		}
+	}
+	if got := plan.Title(); got != "Lincoln lunch plan: pack 5 of 5 days" {
+		t.Errorf("Title = %q", got)
+	}
+	if n := linq.count(); n != 1 {
+		t.Errorf("%d menu requests, want 1", n)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/asachs01/school_menu_connector/internal/email"
+)
+
+// runPreview renders the email templates against sample menu data and
+// prints the result, so template changes can be checked without sending
+// anything.
+func runPreview(args []string) error {
+	fs := newFlagSet("preview", "[flags]", "Render the email templates against sample menu data and print the result.")
+	templateDir := fs.String("template", os.Getenv("EMAIL_TEMPLATE_DIR"), "Directory of email templates to preview")
+	fixture := fs.String("fixture", "", "LINQ Connect FamilyMenu JSON file to use instead of the built-in sample")
+	part := fs.String("part", "all", "Part to print (subject, text, html, or all)")
+	school := fs.String("school", "", "School name to use in the preview")
+	fs.Parse(args)
+
+	templates, err := email.LoadTemplates(*templateDir)
+	if err != nil {
+		return fmt.Errorf("loading email templates: %w", err)
+	}
+
+	data, err := email.FixtureData()
+	if *fixture != "" {
+		raw, readErr := os.ReadFile(*fixture)
+		if readErr != nil {
+			return fmt.Errorf("reading fixture: %w", readErr)
+		}
+		data, err = email.FixtureDataFrom(raw)
+	}
+	if err != nil {
+		return err
+	}
+	if *school != "" {
+		data.School = *school
+	}
+
+	subject, text, html, err := templates.Execute(data)
+	if err != nil {
+		return err
+	}
+
+	switch *part {
+	case "subject":
+		fmt.Println(subject)
+	case "text":
+		fmt.Print(text)
+	case "html":
+		fmt.Print(html)
+	case "all":
+		fmt.Printf("Subject: %s\n\n----- text/plain -----\n%s\n----- text/html -----\n%s", subject, text, html)
+	default:
+		return fmt.Errorf("unknown part %q (want subject, text, html, or all)", *part)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStdout runs fn and returns what it wrote to os.Stdout.
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	err = fn()
+	w.Close()
+	return <-out, err
+}
+
+func TestPreviewTemplates(t *testing.T) {
+	t.Setenv("EMAIL_TEMPLATE_DIR", "")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "subject.tmpl"), []byte("Lunch at {{.School}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"overridden subject", []string{"-template", dir, "-part", "subject", "-school", "Lincoln"}, "Lunch at Lincoln\n"},
+		{"default subject", []string{"-part", "subject"}, "Lunch Menu (09-04-2024 - 09-05-2024)\n"},
+		{"default text with an override", []string{"-template", dir, "-part", "text"}, "Lunch Menu for 9/4/2024:"},
+		{"all", []string{"-template", dir}, "Subject: Lunch at Sample Elementary\n\n----- text/plain -----\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := captureStdout(t, func() error { return runPreview(tt.args) })
+			if err != nil {
+				t.Fatalf("preview: %v", err)
+			}
+			if !strings.HasPrefix(out, tt.want) {
+				t.Errorf("preview printed\n%s\nwant it to start with\n%s", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviewTemplateError(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "body.html.tmpl"), []byte("<p>{{.School</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := captureStdout(t, func() error { return runPreview([]string{"-template", dir}) })
+	if err == nil || !strings.HasPrefix(err.Error(), "loading email templates: parsing body.html.tmpl:") {
+		t.Errorf("preview = %v, want a parse error naming body.html.tmpl", err)
+	}
+	if out != "" {
+		t.Errorf("preview printed %q despite the error", out)
+	}
+}
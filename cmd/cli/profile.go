@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/email"
+	"github.com/asachs01/school_menu_connector/internal/grocery"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/notify"
+	"github.com/asachs01/school_menu_connector/internal/render"
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is one child's menu: their school, the meals and allergens that
+// matter to them, and what to do with the menu.
+type Profile struct {
+	School     string   `yaml:"school"`
+	BuildingID string   `yaml:"building"`
+	DistrictID string   `yaml:"district"`
+	MealTypes  []string `yaml:"mealTypes"`
+	// Timezone is the school's IANA time zone, used to resolve relative
+	// dates such as today. Defaults to SCHOOL_TZ or the local zone.
+	Timezone string `yaml:"timezone"`
+	// Allergens maps display names to LINQ allergen IDs. When set, only
+	// these allergens are shown on the menu, under these names.
+	Allergens map[string]string `yaml:"allergens"`
+	// Favorites and Dislikes are recipe patterns, such as pizza or fish
+	// stick. They mark favorite days and pack-lunch days in calendars and
+	// the plan command.
+	Favorites []string `yaml:"favorites"`
+	Dislikes  []string `yaml:"dislikes"`
+	// Pantry lists the packed lunches the child will eat, for the
+	// groceries command.
+	Pantry []grocery.Option `yaml:"pantry"`
+
+	Email      bool     `yaml:"email"`
+	Recipients []string `yaml:"recipients"`
+	Sender     string   `yaml:"sender"`
+	Subject    string   `yaml:"subject"`
+	Template   string   `yaml:"template"`
+	AttachICS  bool     `yaml:"attachICS"`
+	Individual bool     `yaml:"individual"`
+
+	// Notify lists chat targets the menu is posted to.
+	Notify []notify.Config `yaml:"notify"`
+
+	ICS       bool   `yaml:"ics"`
+	ICSOutput string `yaml:"icsOutput"`
+	Format    string `yaml:"format"`
+	Output    string `yaml:"output"`
+	PDFLayout string `yaml:"pdfLayout"`
+}
+
+// profileConfig is the CLI config file.
+type profileConfig struct {
+	// Sender is the default sender for profiles that do not set one.
+	Sender   string             `yaml:"sender"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// defaultConfigPath returns SMC_CONFIG, or config.yaml in the user config
+// directory, e.g. ~/.config/school_menu_connector/config.yaml.
+func defaultConfigPath() string {
+	if p := os.Getenv("SMC_CONFIG"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "config.yaml"
+	}
+	return filepath.Join(dir, "school_menu_connector", "config.yaml")
+}
+
+func loadProfiles(path string) (*profileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, usagef("reading config: %w", err)
+	}
+
+	var cfg profileConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, usagef("parsing config: %w", err)
+	}
+	if len(cfg.Profiles) == 0 {
+		return nil, usagef("%s has no profiles", path)
+	}
+	for name, p := range cfg.Profiles {
+		if p.BuildingID == "" || p.DistrictID == "" {
+			return nil, usagef("profile %s: building and district are required", name)
+		}
+		if p.Email && len(p.Recipients) == 0 {
+			return nil, usagef("profile %s: email needs at least one recipient", name)
+		}
+		for i := range p.Notify {
+			if p.Notify[i].Timezone == "" {
+				p.Notify[i].Timezone = p.Timezone
+			}
+		}
+		if err := notify.Validate(p.Notify); err != nil {
+			return nil, usagef("profile %s: %w", name, err)
+		}
+		if err := grocery.Validate(p.Pantry); err != nil {
+			return nil, usagef("profile %s: %w", name, err)
+		}
+		if len(p.MealTypes) == 0 {
+			p.MealTypes = []string{"Lunch"}
+		}
+		if p.Sender == "" {
+			p.Sender = cfg.Sender
+		}
+		cfg.Profiles[name] = p
+	}
+	return &cfg, nil
+}
+
+// runProfiles runs one profile from the config file, or all of them. With
+// -all, every profile is attempted even if an earlier one fails.
+func runProfiles(args []string) error {
+	fs := newFlagSet("run", "-profile NAME | -all [flags]", "Fetch the menu for one or every profile in the config file and email, export\nor write it as each profile asks.")
+	configPath := fs.String("config", defaultConfigPath(), "Config file with profiles (YAML)")
+	name := fs.String("profile", "", "Profile to run")
+	all := fs.Bool("all", false, "Run every profile")
+	startDate := fs.String("startDate", os.Getenv("START_DATE"), "Start date: MM-DD-YYYY, YYYY-MM-DD, an offset such as +14d, or "+strings.Join(dates.Names, ", ")+" (default: today)")
+	endDate := fs.String("endDate", os.Getenv("END_DATE"), "End date, in the same forms (default: the end of the start date's range)")
+	weekStart := fs.String("week-start", os.Getenv("WEEK_START"), "Cover the seven days from this date")
+	debugFlag := fs.Bool("debug", false, "Enable debug mode")
+	fs.Parse(args)
+
+	if (*name == "") == !*all {
+		return usagef("use either -profile NAME or -all")
+	}
+
+	cfg, err := loadProfiles(*configPath)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	if *all {
+		for n := range cfg.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+	} else {
+		if _, ok := cfg.Profiles[*name]; !ok {
+			return usagef("no profile %q in %s", *name, *configPath)
+		}
+		names = []string{*name}
+	}
+
+	var mailer email.Mailer
+	for _, n := range names {
+		if cfg.Profiles[n].Email {
+			mailerConfig, err := email.MailerConfigFromEnv()
+			if err != nil {
+				return err
+			}
+			if mailer, err = email.NewMailer(mailerConfig); err != nil {
+				return usagef("configuring email: %v", err)
+			}
+			break
+		}
+	}
+
+	var failed []string
+	for _, n := range names {
+		if *all {
+			fmt.Fprintf(os.Stderr, "== %s\n", n)
+		}
+		p := cfg.Profiles[n]
+		start, end, err := parseRange(*startDate, *endDate, *weekStart, p.Timezone)
+		if err == nil {
+			err = runProfile(p, start, end, mailer, *debugFlag)
+		}
+		if err != nil {
+			if !*all {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Error: profile %s: %v\n", n, err)
+			failed = append(failed, n)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d profiles failed: %s", len(failed), len(names), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func runProfile(p Profile, start, end time.Time, mailer email.Mailer, debug bool) error {
+	opts := formatOptions{
+		pdf: render.PDF{Monthly: p.PDFLayout == "month", Allergens: len(p.Allergens) > 0},
+		png: render.PNG{Width: 800, Height: 480, Depth: 8},
+	}
+	emailOpts := email.MenuEmailOptions{School: p.School, AttachICS: p.AttachICS}
+	if p.Individual {
+		emailOpts.Delivery.Mode = email.DeliverIndividual
+	}
+	if p.Template != "" {
+		templates, err := email.LoadTemplates(p.Template)
+		if err != nil {
+			return fmt.Errorf("loading email templates: %w", err)
+		}
+		emailOpts.Templates = templates
+	}
+	return run(p, start, end, mailer, debug, opts, emailOpts)
+}
+
+// applyAllergens keeps only the profile's allergens on each item, in
+// place, and returns their display names by ID.
+func (p Profile) applyAllergens(days []menu.Day) map[string]string {
+	if len(p.Allergens) == 0 {
+		return nil
+	}
+	names := make(map[string]string, len(p.Allergens))
+	for name, id := range p.Allergens {
+		names[id] = name
+	}
+
+	for _, d := range days {
+		for _, s := range d.Sessions {
+			for _, c := range s.Categories {
+				for i, item := range c.Recipes {
+					kept := []string{}
+					for _, id := range item.Allergens {
+						if names[id] != "" {
+							kept = append(kept, id)
+						}
+					}
+					c.Recipes[i].Allergens = kept
+				}
+			}
+		}
+	}
+	return names
+}
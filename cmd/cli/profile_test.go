@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a profile config file into a temporary directory.
+func writeConfig(t *testing.T, yaml string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadProfiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+		check   func(t *testing.T, cfg *profileConfig)
+	}{
+		{
+			name:    "no profiles",
+			yaml:    "sender: menus@example.com\n",
+			wantErr: "has no profiles",
+		},
+		{
+			name:    "not yaml",
+			yaml:    "profiles: [",
+			wantErr: "parsing config:",
+		},
+		{
+			name:    "building required",
+			yaml:    "profiles:\n  emma:\n    district: d1\n",
+			wantErr: "profile emma: building and district are required",
+		},
+		{
+			name:    "district required",
+			yaml:    "profiles:\n  emma:\n    building: b1\n",
+			wantErr: "profile emma: building and district are required",
+		},
+		{
+			name:    "email needs recipients",
+			yaml:    "profiles:\n  emma:\n    building: b1\n    district: d1\n    email: true\n",
+			wantErr: "profile emma: email needs at least one recipient",
+		},
+		{
+			name:    "notify inherits an invalid time zone",
+			yaml:    "profiles:\n  emma:\n    building: b1\n    district: d1\n    timezone: Mars/Olympus\n    notify:\n      - {type: ntfy, url: https://ntfy.sh/lunch}\n",
+			wantErr: `profile emma: invalid time zone "Mars/Olympus"`,
+		},
+		{
+			name:    "invalid pantry",
+			yaml:    "profiles:\n  emma:\n    building: b1\n    district: d1\n    pantry:\n      - name: Soup\n",
+			wantErr: "profile emma: pantry option Soup: at least one ingredient is required",
+		},
+		{
+			name: "notify time zones",
+			yaml: `profiles:
+  emma:
+    building: b1
+    district: d1
+    timezone: America/Chicago
+    notify:
+      - {type: ntfy, url: https://ntfy.sh/lunch}
+      - {type: ntfy, url: https://ntfy.sh/dinner, timezone: America/Denver}
+`,
+			check: func(t *testing.T, cfg *profileConfig) {
+				n := cfg.Profiles["emma"].Notify
+				if len(n) != 2 || n[0].Timezone != "America/Chicago" || n[1].Timezone != "America/Denver" {
+					t.Errorf("notify = %+v, want the first to inherit America/Chicago and the second to keep America/Denver", n)
+				}
+			},
+		},
+		{
+			name: "defaults",
+			yaml: `sender: Menus <menus@example.com>
+profiles:
+  emma:
+    building: b1
+    district: d1
+    email: true
+    recipients: [parent@example.com]
+  noah:
+    building: b2
+    district: d1
+    mealTypes: [Breakfast, Lunch]
+    sender: noah@example.com
+`,
+			check: func(t *testing.T, cfg *profileConfig) {
+				emma, noah := cfg.Profiles["emma"], cfg.Profiles["noah"]
+				if strings.Join(emma.MealTypes, ",") != "Lunch" || strings.Join(noah.MealTypes, ",") != "Breakfast,Lunch" {
+					t.Errorf("meal types = %v and %v, want Lunch by default", emma.MealTypes, noah.MealTypes)
+				}
+				if emma.Sender != "Menus <menus@example.com>" || noah.Sender != "noah@example.com" {
+					t.Errorf("senders = %q and %q, want the default only where unset", emma.Sender, noah.Sender)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadProfiles(writeConfig(t, tt.yaml))
+			if tt.wantErr != "" {
+				var usage usageError
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !errors.As(err, &usage) {
+					t.Fatalf("loadProfiles = %v, want a usage error with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+
+	if _, err := loadProfiles(filepath.Join(t.TempDir(), "missing.yaml")); err == nil || !strings.HasPrefix(err.Error(), "reading config:") {
+		t.Errorf("loadProfiles of a missing file = %v", err)
+	}
+}
+
+func TestRunProfiles(t *testing.T) {
+	newFakeLINQ(t, readFixture(t))
+	dir := t.TempDir()
+	cfg := writeConfig(t, `profiles:
+  alpha:
+    building: b1
+    district: d1
+    output: `+filepath.Join(dir, "alpha.md")+`
+  broken:
+    building: b1
+    district: d1
+    format: carrier-pigeon
+  zulu:
+    building: b1
+    district: d1
+    output: `+filepath.Join(dir, "zulu.csv")+`
+`)
+	dates := []string{"-config", cfg, "-startDate=2024-09-04", "-endDate=2024-09-05"}
+
+	// -all keeps going past a failed profile and names it at the end.
+	err := runProfiles(append(dates, "-all"))
+	if err == nil || err.Error() != "1 of 3 profiles failed: broken" {
+		t.Errorf("run -all = %v, want the broken profile reported", err)
+	}
+	for _, name := range []string{"alpha.md", "zulu.csv"} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || !strings.Contains(string(data), "Cheesy Pasta Bake") {
+			t.Errorf("%s = %.60q, %v, want the menu", name, data, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"one profile", []string{"-profile", "alpha"}, ""},
+		{"failing profile", []string{"-profile", "broken"}, `unknown format "carrier-pigeon"`},
+		{"unknown profile", []string{"-profile", "nobody"}, `no profile "nobody"`},
+		{"neither", nil, "use either -profile NAME or -all"},
+		{"both", []string{"-profile", "alpha", "-all"}, "use either -profile NAME or -all"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runProfiles(append(append([]string(nil), dates...), tt.args...))
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("run = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/asachs01/school_menu_connector/internal/archive"
+	"github.com/asachs01/school_menu_connector/internal/report"
+)
+
+// runReport prints analytics on a school's archived menus.
+func runReport(args []string) error {
+	fs := newFlagSet("report", "[flags]", `Report on a school's archived menus: the most served recipes, repeat
+cycles such as pizza every Friday, average daily calories by meal type and
+allergens by week. With -sections=buildings, compare the archived
+buildings of the school's district. Without dates the whole archive is
+covered.
+
+Markdown shows every section; CSV holds one, the one -sections names.`)
+	menuFlags := addMenuFlags(fs)
+	dbPath := fs.String("db", os.Getenv("ARCHIVE_DB"), "Archive database (default: "+archive.DefaultPath+")")
+	year := fs.String("year", "", "School year, e.g. 2023-2024, instead of -startDate and -endDate")
+	sections := fs.String("sections", "", "Comma-separated sections: recipes, cycles, calories, allergens, buildings (default: all but buildings)")
+	format := fs.String("format", "markdown", "Output format (markdown, csv or json)")
+	output := fs.String("o", "", "Write the report to this file instead of stdout")
+	top := fs.Int("top", 25, "Most recipes to list, 0 for all")
+	minRepeats := fs.Int("min-repeats", report.DefaultMinRepeats, "Fewest servings for a recipe to count as a cycle")
+	fs.Parse(args)
+
+	opts := report.Options{Sections: splitList(*sections), MinRepeats: *minRepeats, Top: *top}
+	if err := opts.Validate(); err != nil {
+		return usageError{err}
+	}
+	switch *format {
+	case "markdown", "md", "json":
+	case "csv":
+		if len(opts.Sections) == 0 {
+			opts.Sections = []string{report.SectionRecipes}
+		}
+		if len(opts.Sections) > 1 {
+			return usagef("-format=csv holds a single section, e.g. -sections=%s", opts.Sections[0])
+		}
+	default:
+		return usagef("unknown format %q (want markdown, csv or json)", *format)
+	}
+
+	if *dbPath == "" {
+		*dbPath = archive.DefaultPath
+	}
+	if _, err := os.Stat(*dbPath); err != nil {
+		return usagef("no archive at %s (run school_menu_connector backfill first)", *dbPath)
+	}
+
+	p, err := menuFlags.resolve()
+	if err != nil {
+		return err
+	}
+	q := archive.Query{BuildingID: p.BuildingID, DistrictID: p.DistrictID, Sessions: p.MealTypes}
+	switch {
+	case *year != "":
+		q.Start, q.End, err = schoolYear(*year)
+	case *menuFlags.startDate != "" || *menuFlags.weekStart != "":
+		q.Start, q.End, err = menuFlags.dates(p)
+	}
+	if err != nil {
+		return err
+	}
+	if len(p.Allergens) > 0 {
+		opts.AllergenNames = make(map[string]string, len(p.Allergens))
+		for name, id := range p.Allergens {
+			opts.AllergenNames[id] = name
+		}
+	}
+
+	store, err := archive.Open(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	r, err := report.Load(store, q, opts)
+	if err != nil {
+		return err
+	}
+	r.School = p.School
+	if r.Days == 0 {
+		return fmt.Errorf("%w in the archive for the specified date range", errNoMenu)
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(r)
+	case "csv":
+		err = report.WriteCSV(&buf, r, opts.Sections[0])
+	default:
+		err = report.WriteMarkdown(&buf, r)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output == "" || *output == "-" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := os.WriteFile(*output, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Report written to %s\n", *output)
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/asachs01/school_menu_connector/internal/archive"
+	"github.com/asachs01/school_menu_connector/internal/cache"
+	"github.com/asachs01/school_menu_connector/internal/email"
+	"github.com/asachs01/school_menu_connector/internal/schedule"
+	"github.com/sirupsen/logrus"
+)
+
+// runSchedule runs the jobs in a schedule file until interrupted, or runs a
+// single job once with -run. Email settings come from the same environment
+// variables as the web server.
+func runSchedule(args []string) error {
+	fs := newFlagSet("schedule", "-config FILE [flags]", "Run the jobs in a schedule file until interrupted, or one job with -run.")
+	configPath := fs.String("config", os.Getenv("SCHEDULE_CONFIG"), "Schedule file (YAML)")
+	listen := fs.String("listen", os.Getenv("SCHEDULE_LISTEN"), "Address to serve job status on, e.g. :8081")
+	runJob := fs.String("run", "", "Run this job once and exit")
+	cacheDir := fs.String("cache-dir", "", "Menu cache directory for warm-cache jobs (default: /tmp/menu-cache)")
+	fs.Parse(args)
+
+	if *configPath == "" {
+		return usagef("-config is required")
+	}
+	cfg, err := schedule.Load(*configPath)
+	if err != nil {
+		return err
+	}
+
+	logger := logrus.New()
+	opts := schedule.Options{Logger: logger, From: os.Getenv("EMAIL_FROM")}
+	if opts.From == "" {
+		opts.From = os.Getenv("SENDER_EMAIL")
+	}
+	if opts.Cache, err = cache.New(*cacheDir, 0); err != nil {
+		logger.WithError(err).Warn("Menu cache unavailable")
+	}
+	if path := os.Getenv("ARCHIVE_DB"); path != "" {
+		if opts.Archive, err = archive.Open(path); err != nil {
+			return err
+		}
+		defer opts.Archive.Close()
+	}
+	mailerConfig, err := email.MailerConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	if opts.Mailer, err = email.NewMailer(mailerConfig); err != nil {
+		logger.WithError(err).Warn("Email provider not configured, email jobs will fail")
+	}
+
+	scheduler, err := schedule.New(cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	if *runJob != "" {
+		return scheduler.RunJob(context.Background(), *runJob)
+	}
+
+	if err := scheduler.Start(); err != nil {
+		return err
+	}
+	if *listen != "" {
+		go func() {
+			logger.Infof("Serving job status on %s", *listen)
+			if err := http.ListenAndServe(*listen, scheduler); err != nil {
+				logger.WithError(err).Error("Status server stopped")
+			}
+		}()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	logger.Info("Stopping scheduler")
+	scheduler.Stop()
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/asachs01/school_menu_connector/internal/archive"
+	"github.com/asachs01/school_menu_connector/internal/cache"
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/search"
+)
+
+// runSearch finds the days a recipe is served in the archived and cached
+// menus.
+func runSearch(args []string) error {
+	fs := newFlagSet("search", "[flags] QUERY...", `Find the days and meals a recipe is served, e.g. search pizza, in the menu
+archive and the cache. Words match recipe and category names regardless of
+plurals and small typos. Upcoming days are searched unless -past is given
+or -startDate is earlier; without a school, every archived or cached
+school is searched.`)
+	configPath := fs.String("config", defaultConfigPath(), "Config file with profiles (YAML)")
+	profile := fs.String("profile", "", "Search this profile's school")
+	buildingID := fs.String("building", os.Getenv("BUILDING_ID"), "Building ID")
+	districtID := fs.String("district", os.Getenv("DISTRICT_ID"), "District ID")
+	mealTypes := fs.String("meal-types", "", "Comma-separated meal types to search (default: all)")
+	startDate := fs.String("startDate", "", "First date to search, in any date form (default: today)")
+	endDate := fs.String("endDate", "", "Last date to search (default: no limit)")
+	timezone := fs.String("tz", "", "Time zone relative dates are resolved in (default: $SCHOOL_TZ or local)")
+	past := fs.Bool("past", false, "Search past days too")
+	dbPath := fs.String("db", os.Getenv("ARCHIVE_DB"), "Archive database (default: "+archive.DefaultPath+", if it exists)")
+	cacheDir := fs.String("cache-dir", "", "Menu cache directory (default: /tmp/menu-cache)")
+	limit := fs.Int("limit", search.DefaultLimit, "Most results to show, -1 for all")
+	jsonFlag := fs.Bool("json", false, "Print the results as JSON")
+	fs.Parse(args)
+
+	q := search.Query{
+		Text:       strings.Join(fs.Args(), " "),
+		BuildingID: *buildingID,
+		DistrictID: *districtID,
+		Sessions:   splitList(*mealTypes),
+		Limit:      *limit,
+	}
+	if strings.TrimSpace(q.Text) == "" {
+		fs.Usage()
+		return usagef("expected a search query")
+	}
+	if *limit < -1 {
+		return usagef("-limit must be -1 for all results, or 0 or more")
+	}
+	if *profile != "" {
+		cfg, err := loadProfiles(*configPath)
+		if err != nil {
+			return err
+		}
+		p, ok := cfg.Profiles[*profile]
+		if !ok {
+			return usagef("no profile %q in %s", *profile, *configPath)
+		}
+		q.BuildingID, q.DistrictID = p.BuildingID, p.DistrictID
+		if len(q.Sessions) == 0 {
+			q.Sessions = p.MealTypes
+		}
+		if *timezone == "" {
+			*timezone = p.Timezone
+		}
+	}
+
+	now, err := dates.Now(*timezone)
+	if err != nil {
+		return usageError{err}
+	}
+	if *startDate != "" {
+		if q.Start, _, err = dates.Range(*startDate, now); err != nil {
+			return usagef("invalid start date: %v", err)
+		}
+	} else if !*past {
+		q.Start = dates.Day(now)
+	}
+	if *endDate != "" {
+		if _, q.End, err = dates.Range(*endDate, now); err != nil {
+			return usagef("invalid end date: %v", err)
+		}
+	}
+
+	var store *archive.Store
+	if *dbPath == "" {
+		*dbPath = archive.DefaultPath
+	}
+	if _, err := os.Stat(*dbPath); err == nil {
+		if store, err = archive.Open(*dbPath); err != nil {
+			return err
+		}
+		defer store.Close()
+	}
+	c, err := cache.New(*cacheDir, 0)
+	if err != nil {
+		return err
+	}
+
+	schools, err := search.Collect(store, c, q)
+	if err != nil {
+		return err
+	}
+	results, err := search.Search(schools, q)
+	if err != nil {
+		return usageError{err}
+	}
+
+	if *jsonFlag {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	if len(results) == 0 {
+		if len(schools) == 0 {
+			return fmt.Errorf("%w: no archived or cached menus to search (run backfill or cache warm first)", errNoMenu)
+		}
+		return fmt.Errorf("%w matching %q", errNoMenu, q.Text)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprint(w, search.Text(results))
+	return w.Flush()
+}
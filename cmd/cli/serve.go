@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/cache"
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/ics"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/render"
+)
+
+// runServe serves one school's menu over HTTP, for calendar apps, home
+// dashboards and e-ink displays on the local network.
+func runServe(args []string) error {
+	fs := newFlagSet("serve", "[flags]", `Serve a school's menu over HTTP:
+
+  GET /menu[.ext]     the menu for ?start= to ?end= (a date or a relative date
+                      such as this-week or +14d, default today) in the format
+                      of the extension, ?format= or Accept header
+  GET /calendar.ics   a calendar feed from this Monday for ?weeks= weeks
+  GET /health         a liveness check`)
+	menuFlags := addMenuFlags(fs)
+	listen := fs.String("listen", ":8080", "Address to listen on")
+	cacheDir := fs.String("cache-dir", "", "Menu cache directory (default: /tmp/menu-cache)")
+	weeks := fs.Int("weeks", 4, "Default number of weeks in the calendar feed")
+	fs.Parse(args)
+
+	p, err := menuFlags.resolve()
+	if err != nil {
+		return err
+	}
+	c, err := cache.New(*cacheDir, 0)
+	if err != nil {
+		return err
+	}
+
+	s := &menuServer{profile: p, cache: c, weeks: *weeks}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/menu" && !strings.HasPrefix(r.URL.Path, "/menu.") {
+			http.NotFound(w, r)
+			return
+		}
+		s.menu(w, r)
+	})
+	mux.HandleFunc("/calendar.ics", s.calendar)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+
+	fmt.Fprintf(os.Stderr, "Serving the menu for %s on %s\n", p.BuildingID, *listen)
+	return http.ListenAndServe(*listen, mux)
+}
+
+type menuServer struct {
+	profile Profile
+	cache   *cache.Cache
+	weeks   int
+}
+
+// document returns the menu for each day from start to end, one cached
+// fetch per day so entries are shared with the web server and cache command.
+func (s *menuServer) document(start, end time.Time) (render.Document, error) {
+	var days []menu.Day
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+		dateStr := date.Format("01-02-2006")
+		m, ok := s.cache.Get(s.profile.BuildingID, s.profile.DistrictID, dateStr, dateStr)
+		if !ok {
+			var err error
+			if m, err = menu.Fetch(s.profile.BuildingID, s.profile.DistrictID, dateStr, dateStr, false); err != nil {
+				return render.Document{}, err
+			}
+			s.cache.Set(s.profile.BuildingID, s.profile.DistrictID, dateStr, dateStr, m)
+			archiveMenu(s.profile, m)
+		}
+		if day, ok := m.DayFor(date, s.profile.MealTypes...); ok {
+			days = append(days, day)
+		}
+	}
+	return render.Document{School: s.profile.School, Days: days, AllergenNames: s.profile.applyAllergens(days)}, nil
+}
+
+// menu renders the menu for a date range.
+// GET /menu[.ext]?start=DATE&end=DATE&tz=ZONE&format=
+func (s *menuServer) menu(w http.ResponseWriter, r *http.Request) {
+	var renderer render.Renderer
+	var err error
+	if ext := path.Ext(r.URL.Path); ext != "" {
+		renderer, err = render.ForFormat(ext)
+	} else if format := r.URL.Query().Get("format"); format != "" {
+		renderer, err = render.ForFormat(format)
+	} else {
+		renderer = render.Negotiate(r.Header.Get("Accept"), render.Text{})
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now, err := dates.Now(queryOr(r, "tz", s.profile.Timezone))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	start, end, err := dates.Resolve(r.URL.Query().Get("start"), r.URL.Query().Get("end"), now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if end.Sub(start) > 62*24*time.Hour {
+		http.Error(w, "Invalid end date, expected within two months of start", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := s.document(start, end)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching menu: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, doc); err != nil {
+		http.Error(w, fmt.Sprintf("Error rendering menu: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", renderer.ContentType())
+	w.Header().Set("Vary", "Accept")
+	w.Write(buf.Bytes())
+}
+
+// calendar serves a calendar feed starting this Monday.
+// GET /calendar.ics?weeks=N
+func (s *menuServer) calendar(w http.ResponseWriter, r *http.Request) {
+	weeks, err := strconv.Atoi(queryOr(r, "weeks", strconv.Itoa(s.weeks)))
+	if err != nil || weeks < 1 || weeks > 8 {
+		http.Error(w, "Invalid weeks, expected 1 to 8", http.StatusBadRequest)
+		return
+	}
+
+	now, err := dates.Now(s.profile.Timezone)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	start := dates.Monday(dates.Day(now))
+	end := start.AddDate(0, 0, 7*weeks-1)
+	doc, err := s.document(start, end)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching menu: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write(ics.GenerateMarked(doc.Days, s.profile.calendarMarker(doc.Days, start, end)))
+}
+
+func queryOr(r *http.Request, name, fallback string) string {
+	if v := r.URL.Query().Get(name); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/render"
+	"github.com/sirupsen/logrus"
+)
+
+// menuQuery is a validated request for a school's menu over a date range.
+type menuQuery struct {
+	BuildingID         string
+	DistrictID         string
+	School             string
+	Start              time.Time
+	End                time.Time
+	MealTypes          []string
+	IncludeDescription bool
+}
+
+// parseMenuQuery validates the common menu parameters from a query string
+// or form.
+func parseMenuQuery(values url.Values) (menuQuery, error) {
+	q := menuQuery{
+		BuildingID:         values.Get("buildingId"),
+		DistrictID:         values.Get("districtId"),
+		School:             values.Get("school"),
+		MealTypes:          values["mealTypes"],
+		IncludeDescription: values.Get("includeDescription") == "true",
+	}
+
+	startDate := values.Get("startDate")
+	if q.BuildingID == "" || q.DistrictID == "" || startDate == "" {
+		return q, fmt.Errorf("missing required fields")
+	}
+
+	if len(q.MealTypes) == 1 && strings.Contains(q.MealTypes[0], ",") {
+		q.MealTypes = strings.Split(q.MealTypes[0], ",")
+	}
+	if len(q.MealTypes) == 0 {
+		q.MealTypes = []string{"Lunch"}
+	}
+
+	var err error
+	q.Start, q.End, err = resolveDates(startDate, values.Get("endDate"), values.Get("tz"))
+	return q, err
+}
+
+// loadMenuDays fetches each day in the query's range through the cache and
+// returns the normalized menu for the requested meal types.
+func loadMenuDays(q menuQuery) []menu.Day {
+	days := []menu.Day{}
+
+	for date := q.Start; !date.After(q.End); date = date.AddDate(0, 0, 1) {
+		dateStr := date.Format("01-02-2006")
+		menuData := fetchWithCache(q.BuildingID, q.DistrictID, dateStr, dateStr)
+		if menuData == nil {
+			continue
+		}
+
+		day, ok := menuData.DayFor(date, q.MealTypes...)
+		if !ok {
+			continue
+		}
+		if q.IncludeDescription {
+			for i, session := range day.Sessions {
+				day.Sessions[i].Description = render.SessionText(day, session.Name)
+			}
+		}
+		days = append(days, day)
+	}
+
+	return days
+}
+
+// apiRouter dispatches /api/v1/ requests. Resources may carry a format
+// extension, so /api/v1/menu and /api/v1/menu.csv reach the same handler.
+func apiRouter(w http.ResponseWriter, r *http.Request) {
+	resource := strings.TrimPrefix(r.URL.Path, "/api/v1/")
+	resource = strings.TrimSuffix(resource, path.Ext(resource))
+
+	switch resource {
+	case "menu":
+		apiMenuHandler(w, r)
+	case "today":
+		apiTodayHandler(w, r)
+	case "archive":
+		apiArchiveHandler(w, r)
+	case "archive/schools":
+		apiArchiveSchoolsHandler(w, r)
+	case "report":
+		apiReportHandler(w, r)
+	case "search":
+		apiSearchHandler(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// apiMenuHandler serves a school's menu in any supported format.
+// GET /api/v1/menu?buildingId=&districtId=&startDate=&endDate=&tz=&mealTypes=
+// The format is taken from the path extension (/api/v1/menu.csv), the
+// format query parameter, or the Accept header, in that order.
+func apiMenuHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	renderer, err := rendererFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	q, err := parseMenuQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeRendered(w, renderer, render.Document{School: q.School, Days: loadMenuDays(q)})
+}
+
+// rendererFor picks the output format for an /api/v1/menu request.
+func rendererFor(r *http.Request) (render.Renderer, error) {
+	var renderer render.Renderer
+	var err error
+	if ext := path.Ext(r.URL.Path); ext != "" {
+		renderer, err = render.ForFormat(ext)
+	} else if format := r.URL.Query().Get("format"); format != "" {
+		renderer, err = render.ForFormat(format)
+	} else {
+		renderer = render.Negotiate(r.Header.Get("Accept"), render.JSON{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.URL.Query()
+	switch renderer.(type) {
+	case render.PDF:
+		// layout=month for a monthly grid, allergens=true for allergen markers.
+		renderer = render.PDF{
+			Monthly:   query.Get("layout") == "month",
+			Allergens: query.Get("allergens") == "true",
+		}
+	case render.PNG:
+		// Parameters left out keep their defaults; any given must be valid.
+		img := render.PNG{Dither: query.Get("dither") == "true"}.WithDefaults()
+		for name, dst := range map[string]*int{"width": &img.Width, "height": &img.Height, "depth": &img.Depth} {
+			if v := query.Get(name); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s: %s", name, v)
+				}
+				*dst = n
+			}
+		}
+		if err := img.Validate(); err != nil {
+			return nil, err
+		}
+		renderer = img
+	}
+	return renderer, nil
+}
+
+// apiTodayHandler serves today's menu, or the day's menu for any date
+// expression such as tomorrow or next-school-day, for dashboards and e-ink
+// displays, usually as /api/v1/today.png. Menus come from the cache, so
+// devices can poll without each request reaching LINQ Connect.
+// GET /api/v1/today.png?buildingId=&districtId=&day=next-school-day&tz=America/Chicago&width=800&height=480&depth=1&dither=true
+func apiTodayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	renderer, err := rendererFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	day := query.Get("day")
+	if day == "" {
+		day = dates.Today
+	}
+	values := url.Values{
+		"buildingId": {query.Get("buildingId")},
+		"districtId": {query.Get("districtId")},
+		"startDate":  {day},
+		"tz":         {query.Get("tz")},
+		"mealTypes":  query["mealTypes"],
+		"school":     {query.Get("school")},
+	}
+	q, err := parseMenuQuery(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=900")
+	writeRendered(w, renderer, render.Document{School: q.School, Days: loadMenuDays(q)})
+}
+
+// writeRendered renders doc into a buffer first so that rendering errors can
+// still be reported with a proper status code.
+func writeRendered(w http.ResponseWriter, renderer render.Renderer, doc render.Document) {
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, doc); err != nil {
+		logger.WithError(err).Error("Error rendering menu")
+		http.Error(w, fmt.Sprintf("Error rendering menu: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", renderer.ContentType())
+	w.Header().Set("Vary", "Accept")
+	if _, ok := renderer.(render.PDF); ok {
+		w.Header().Set("Content-Disposition", "inline; filename=school_menu.pdf")
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		logger.WithError(err).WithFields(logrus.Fields{
+			"contentType": renderer.ContentType(),
+		}).Error("Error writing response")
+	}
+}
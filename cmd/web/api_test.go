@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/asachs01/school_menu_connector/internal/render"
+)
+
+func TestRendererFor(t *testing.T) {
+	tests := []struct {
+		target  string
+		want    render.Renderer
+		wantErr string
+	}{
+		{"/api/v1/menu", render.JSON{}, ""},
+		{"/api/v1/menu.pdf?layout=month&allergens=true", render.PDF{Monthly: true, Allergens: true}, ""},
+		{"/api/v1/menu?format=png", render.PNG{Width: 800, Height: 480, Depth: 8}, ""},
+		{"/api/v1/menu.png?width=296&height=128&depth=1&dither=true", render.PNG{Width: 296, Height: 128, Depth: 1, Dither: true}, ""},
+		{"/api/v1/menu.png?width=0", nil, "image size must be between 64 and 4096 pixels, got 0x480"},
+		{"/api/v1/menu.png?height=-1", nil, "image size must be between 64 and 4096 pixels, got 800x-1"},
+		{"/api/v1/menu.png?width=5000", nil, "image size must be between 64 and 4096 pixels, got 5000x480"},
+		{"/api/v1/menu.png?depth=0", nil, "image depth must be 1, 2, 4, 8 or 24, got 0"},
+		{"/api/v1/menu.png?depth=3", nil, "image depth must be 1, 2, 4, 8 or 24, got 3"},
+		{"/api/v1/menu.png?width=wide", nil, "invalid width: wide"},
+		{"/api/v1/menu.xml", nil, `unknown format "xml" (available: csv, html, json, markdown, pdf, png, text)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			got, err := rendererFor(httptest.NewRequest("GET", tt.target, nil))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("rendererFor = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("rendererFor = %#v, %v, want %#v", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+
+	"github.com/asachs01/school_menu_connector/internal/archive"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/render"
+	"github.com/sirupsen/logrus"
+)
+
+var menuArchive *archive.Store
+
+// initArchive enables the menu archive when ARCHIVE_DB names its database.
+// Every menu fetched from LINQ Connect is then archived.
+func initArchive() {
+	path := os.Getenv("ARCHIVE_DB")
+	if path == "" {
+		logger.Info("Menu archive disabled: ARCHIVE_DB is not set")
+		return
+	}
+	var err error
+	menuArchive, err = archive.Open(path)
+	if err != nil {
+		logger.WithError(err).Warn("Menu archive disabled: cannot open database")
+		return
+	}
+	logger.WithField("db", path).Info("Menu archive enabled")
+}
+
+// archiveMenu saves a freshly fetched menu to the archive, if enabled.
+func archiveMenu(buildingID, districtID string, m *menu.Menu) {
+	if menuArchive == nil {
+		return
+	}
+	if err := menuArchive.Save("", buildingID, districtID, m); err != nil {
+		logger.WithError(err).WithFields(logrus.Fields{
+			"buildingID": buildingID,
+		}).Warn("Failed to archive menu")
+	}
+}
+
+// apiArchiveHandler serves a school's archived menu in any supported
+// format, with the same parameters as /api/v1/menu.
+// GET /api/v1/archive?buildingId=&districtId=&startDate=&endDate=&tz=&mealTypes=
+func apiArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if menuArchive == nil {
+		http.Error(w, "Menu archive is not enabled", http.StatusNotFound)
+		return
+	}
+
+	renderer, err := rendererFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q, err := parseMenuQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	days, err := menuArchive.Days(archive.Query{BuildingID: q.BuildingID, DistrictID: q.DistrictID, Start: q.Start, End: q.End, Sessions: q.MealTypes})
+	if err != nil {
+		logger.WithError(err).Error("Error querying menu archive")
+		http.Error(w, "Error querying menu archive", http.StatusInternalServerError)
+		return
+	}
+	if days == nil {
+		days = []menu.Day{}
+	}
+	if q.IncludeDescription {
+		for _, day := range days {
+			for i, session := range day.Sessions {
+				day.Sessions[i].Description = render.SessionText(day, session.Name)
+			}
+		}
+	}
+	writeRendered(w, renderer, render.Document{School: q.School, Days: days})
+}
+
+// apiArchiveSchoolsHandler lists the archived schools and the dates their
+// menus cover.
+// GET /api/v1/archive/schools
+func apiArchiveSchoolsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if menuArchive == nil {
+		http.Error(w, "Menu archive is not enabled", http.StatusNotFound)
+		return
+	}
+
+	schools, err := menuArchive.Schools()
+	if err != nil {
+		logger.WithError(err).Error("Error listing archived schools")
+		http.Error(w, "Error listing archived schools", http.StatusInternalServerError)
+		return
+	}
+	if schools == nil {
+		schools = []archive.School{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"schools": schools})
+}
@@ -2,16 +2,21 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/asachs01/school_menu_connector/internal/cache"
+	"github.com/asachs01/school_menu_connector/internal/dates"
 	"github.com/asachs01/school_menu_connector/internal/ics"
 	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/render"
 	"github.com/sirupsen/logrus"
 )
 
@@ -34,6 +39,9 @@ func init() {
 }
 
 func main() {
+	initSubscriptions()
+	initArchive()
+
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/get-menu", logMiddleware(getMenuHandler))
@@ -41,11 +49,19 @@ func main() {
 	mux.HandleFunc("/menu", logMiddleware(serveMenuForm))
 	mux.HandleFunc("/health", healthHandler)
 	mux.HandleFunc("/refresh-cache", logMiddleware(refreshCacheHandler))
+	mux.HandleFunc("/discover", logMiddleware(discoverHandler))
+	mux.HandleFunc("/api/v1/", logMiddleware(apiRouter))
+	mux.HandleFunc("/subscribe", logMiddleware(subscribeHandler))
+	mux.HandleFunc("/confirm", logMiddleware(confirmHandler))
+	mux.HandleFunc("/unsubscribe", logMiddleware(unsubscribeHandler))
+	mux.HandleFunc("/subscriptions/send", logMiddleware(sendDigestsHandler))
 	mux.HandleFunc("/", logMiddleware(serveIndex))
 
 	fs := http.FileServer(http.Dir("web/static"))
 	mux.Handle("/static/", http.StripPrefix("/static/", fs))
 
+	initScheduler(mux)
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
@@ -72,24 +88,15 @@ func serveIndex(w http.ResponseWriter, r *http.Request) {
 
 // MenuRequest holds the JSON body for menu endpoints.
 type MenuRequest struct {
-	BuildingID string   `json:"buildingId"`
-	DistrictID string   `json:"districtId"`
-	StartDate  string   `json:"startDate"`
-	EndDate    string   `json:"endDate"`
-	MealTypes  []string `json:"mealTypes"`
-}
-
-// MenuEvent represents a single calendar event for JSON response.
-type MenuEvent struct {
-	Date        string `json:"date"`
-	MealType    string `json:"mealType"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-}
-
-// MenuEventsResponse is the JSON response for /get-menu-json.
-type MenuEventsResponse struct {
-	Events []MenuEvent `json:"events"`
+	BuildingID string `json:"buildingId"`
+	DistrictID string `json:"districtId"`
+	StartDate  string `json:"startDate"`
+	EndDate    string `json:"endDate"`
+	// TZ is the time zone relative dates such as today are resolved in.
+	TZ        string   `json:"tz"`
+	MealTypes []string `json:"mealTypes"`
+	// IncludeDescription adds the preformatted text menu to each session.
+	IncludeDescription bool `json:"includeDescription"`
 }
 
 func getMenuHandler(w http.ResponseWriter, r *http.Request) {
@@ -107,7 +114,7 @@ func getMenuHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var buildingID, districtID, startDate, endDate string
+	var buildingID, districtID, startDate, endDate, tz string
 	var mealTypes []string
 	contentType := r.Header.Get("Content-Type")
 
@@ -123,6 +130,7 @@ func getMenuHandler(w http.ResponseWriter, r *http.Request) {
 		districtID = req.DistrictID
 		startDate = req.StartDate
 		endDate = req.EndDate
+		tz = req.TZ
 		mealTypes = req.MealTypes
 	} else {
 		if err := r.ParseForm(); err != nil {
@@ -134,6 +142,7 @@ func getMenuHandler(w http.ResponseWriter, r *http.Request) {
 		districtID = r.Form.Get("districtId")
 		startDate = r.Form.Get("startDate")
 		endDate = r.Form.Get("endDate")
+		tz = r.Form.Get("tz")
 		mealTypes = r.Form["mealTypes"]
 	}
 
@@ -141,25 +150,20 @@ func getMenuHandler(w http.ResponseWriter, r *http.Request) {
 		mealTypes = []string{"Lunch"}
 	}
 
-	if buildingID == "" || districtID == "" || startDate == "" || endDate == "" {
+	if buildingID == "" || districtID == "" || startDate == "" {
 		logger.Error("Missing required fields")
 		http.Error(w, "Missing required fields", http.StatusBadRequest)
 		return
 	}
 
-	startDate, err := validateAndConvertDate(startDate)
+	start, end, err := resolveDates(startDate, endDate, tz)
 	if err != nil {
-		logger.WithError(err).Error("Invalid start date")
-		http.Error(w, fmt.Sprintf("Invalid start date: %v", err), http.StatusBadRequest)
-		return
-	}
-
-	endDate, err = validateAndConvertDate(endDate)
-	if err != nil {
-		logger.WithError(err).Error("Invalid end date")
-		http.Error(w, fmt.Sprintf("Invalid end date: %v", err), http.StatusBadRequest)
+		logger.WithError(err).Error("Invalid date range")
+		http.Error(w, fmt.Sprintf("Invalid date range: %v", err), http.StatusBadRequest)
 		return
 	}
+	startDate = start.Format("01-02-2006")
+	endDate = end.Format("01-02-2006")
 
 	logger.WithFields(logrus.Fields{
 		"buildingID": buildingID,
@@ -198,8 +202,7 @@ func getMenuJSONHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var buildingID, districtID, startDate, endDate string
-	var mealTypes []string
+	values := url.Values{}
 	contentType := r.Header.Get("Content-Type")
 
 	if contentType == "application/json" {
@@ -208,70 +211,71 @@ func getMenuJSONHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Error parsing JSON request", http.StatusBadRequest)
 			return
 		}
-		buildingID = req.BuildingID
-		districtID = req.DistrictID
-		startDate = req.StartDate
-		endDate = req.EndDate
-		mealTypes = req.MealTypes
+		values.Set("buildingId", req.BuildingID)
+		values.Set("districtId", req.DistrictID)
+		values.Set("startDate", req.StartDate)
+		values.Set("endDate", req.EndDate)
+		values.Set("tz", req.TZ)
+		values.Set("includeDescription", strconv.FormatBool(req.IncludeDescription))
+		values["mealTypes"] = req.MealTypes
 	} else {
 		if err := r.ParseForm(); err != nil {
 			http.Error(w, "Error parsing form data", http.StatusBadRequest)
 			return
 		}
-		buildingID = r.Form.Get("buildingId")
-		districtID = r.Form.Get("districtId")
-		startDate = r.Form.Get("startDate")
-		endDate = r.Form.Get("endDate")
-		mealTypes = r.Form["mealTypes"]
+		values = r.Form
 	}
 
-	if len(mealTypes) == 0 {
-		mealTypes = []string{"Lunch"}
-	}
-
-	if buildingID == "" || districtID == "" || startDate == "" || endDate == "" {
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
-		return
-	}
-
-	startDateInternal, err := validateAndConvertDate(startDate)
+	q, err := parseMenuQuery(values)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid start date: %v", err), http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	endDateInternal, err := validateAndConvertDate(endDate)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid end date: %v", err), http.StatusBadRequest)
+
+	renderer := render.Negotiate(r.Header.Get("Accept"), render.JSON{})
+	days := loadMenuDays(q)
+	if _, ok := renderer.(render.JSON); !ok {
+		writeRendered(w, renderer, render.Document{School: q.School, Days: days})
 		return
 	}
 
-	start, _ := time.Parse("01-02-2006", startDateInternal)
-	end, _ := time.Parse("01-02-2006", endDateInternal)
+	w.Header().Set("Content-Type", renderer.ContentType())
+	w.Header().Set("Vary", "Accept")
+	json.NewEncoder(w).Encode(MenuResponse{School: q.School, Days: days, Events: menuEvents(days)})
+}
 
-	var events []MenuEvent
+// MenuEvent is one session of one day as a calendar event, the shape
+// /get-menu-json returned before it had structured days.
+type MenuEvent struct {
+	Date        string `json:"date"`
+	MealType    string `json:"mealType"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
 
-	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
-		dateStr := date.Format("01-02-2006")
-		menuData := fetchWithCache(buildingID, districtID, dateStr, dateStr)
-		if menuData == nil {
-			continue
-		}
+// MenuResponse is the JSON response for /get-menu-json. Events is kept
+// for existing clients; new clients should read Days.
+type MenuResponse struct {
+	School string      `json:"school,omitempty"`
+	Days   []menu.Day  `json:"days"`
+	Events []MenuEvent `json:"events"`
+}
 
-		for _, mealType := range mealTypes {
-			mealMenu := menuData.GetMenuForSession(mealType, date.Format("1/2/2006"), false)
-			if mealMenu != "" {
-				events = append(events, MenuEvent{
-					Date:        date.Format("2006-01-02"),
-					MealType:    mealType,
-					Title:       fmt.Sprintf("%s Menu - %s", mealType, date.Format("01/02/2006")),
-					Description: mealMenu,
-				})
-			}
+// menuEvents lists every session in days as an event.
+func menuEvents(days []menu.Day) []MenuEvent {
+	events := []MenuEvent{}
+	for _, day := range days {
+		date := day.Time()
+		for _, session := range day.Sessions {
+			events = append(events, MenuEvent{
+				Date:        day.Date,
+				MealType:    session.Name,
+				Title:       fmt.Sprintf("%s Menu - %s", session.Name, date.Format("01/02/2006")),
+				Description: render.SessionText(day, session.Name),
+			})
 		}
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(MenuEventsResponse{Events: events})
+	return events
 }
 
 // fetchWithCache tries the cache first, then the API, and falls back to
@@ -302,6 +306,7 @@ func fetchWithCache(buildingID, districtID, startDate, endDate string) *menu.Men
 	if menuCache != nil {
 		menuCache.Set(buildingID, districtID, startDate, endDate, menuData)
 	}
+	archiveMenu(buildingID, districtID, menuData)
 
 	return menuData
 }
@@ -314,6 +319,7 @@ type RefreshRequest struct {
 	} `json:"schools"`
 	StartDate string `json:"startDate"`
 	EndDate   string `json:"endDate"`
+	TZ        string `json:"tz"`
 }
 
 // refreshCacheHandler pre-fetches menus for a list of schools and warms the cache.
@@ -340,19 +346,14 @@ func refreshCacheHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(req.Schools) == 0 || req.StartDate == "" || req.EndDate == "" {
-		http.Error(w, "Missing required fields: schools, startDate, endDate", http.StatusBadRequest)
+	if len(req.Schools) == 0 || req.StartDate == "" {
+		http.Error(w, "Missing required fields: schools, startDate", http.StatusBadRequest)
 		return
 	}
 
-	startDate, err := validateAndConvertDate(req.StartDate)
+	start, end, err := resolveDates(req.StartDate, req.EndDate, req.TZ)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid start date: %v", err), http.StatusBadRequest)
-		return
-	}
-	endDate, err := validateAndConvertDate(req.EndDate)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid end date: %v", err), http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("Invalid date range: %v", err), http.StatusBadRequest)
 		return
 	}
 
@@ -360,9 +361,6 @@ func refreshCacheHandler(w http.ResponseWriter, r *http.Request) {
 	failed := 0
 
 	for _, school := range req.Schools {
-		start, _ := time.Parse("01-02-2006", startDate)
-		end, _ := time.Parse("01-02-2006", endDate)
-
 		for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
 			dateStr := date.Format("01-02-2006")
 			menuData, fetchErr := menu.Fetch(school.BuildingID, school.DistrictID, dateStr, dateStr, false)
@@ -377,6 +375,7 @@ func refreshCacheHandler(w http.ResponseWriter, r *http.Request) {
 			if menuCache != nil {
 				menuCache.Set(school.BuildingID, school.DistrictID, dateStr, dateStr, menuData)
 			}
+			archiveMenu(school.BuildingID, school.DistrictID, menuData)
 			cached++
 		}
 	}
@@ -388,23 +387,51 @@ func refreshCacheHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// discoverHandler resolves a LINQ Connect district identifier into the
+// district and its buildings.
+// GET /discover?identifier=XXXXXX
+func discoverHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	identifier := r.URL.Query().Get("identifier")
+	if identifier == "" {
+		http.Error(w, "Missing required field: identifier", http.StatusBadRequest)
+		return
+	}
+
+	district, err := menu.Discover(identifier, false)
+	if err != nil {
+		logger.WithError(err).WithField("identifier", identifier).Warn("District discovery failed")
+		status := http.StatusBadGateway
+		if errors.Is(err, menu.ErrNoDistrict) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, fmt.Sprintf("Error discovering district: %v", err), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(district)
+}
+
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
-func validateAndConvertDate(date string) (string, error) {
-	if _, err := time.Parse("2006-01-02", date); err == nil {
-		t, _ := time.Parse("2006-01-02", date)
-		return t.Format("01-02-2006"), nil
-	}
-
-	if _, err := time.Parse("01-02-2006", date); err == nil {
-		return date, nil
+// resolveDates resolves a start and end date, either of which may be a
+// relative date such as today, this-week or +14d, in the time zone tz or
+// SCHOOL_TZ. An empty end date is the end of the start date's range.
+func resolveDates(startDate, endDate, tz string) (start, end time.Time, err error) {
+	now, err := dates.Now(tz)
+	if err != nil {
+		return start, end, err
 	}
-
-	return "", fmt.Errorf("invalid date format: %s", date)
+	return dates.Resolve(startDate, endDate, now)
 }
 
 func logMiddleware(next http.HandlerFunc) http.HandlerFunc {
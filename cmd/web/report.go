@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/asachs01/school_menu_connector/internal/archive"
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/report"
+)
+
+// apiReportHandler serves analytics on a school's archived menus as JSON.
+// Without startDate the whole archive is covered. sections is a
+// comma-separated list of recipes, cycles, calories, allergens and
+// buildings, by default all but buildings.
+// GET /api/v1/report?buildingId=&districtId=&startDate=&endDate=&mealTypes=&sections=&top=&minRepeats=
+func apiReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if menuArchive == nil {
+		http.Error(w, "Menu archive is not enabled", http.StatusNotFound)
+		return
+	}
+
+	values := r.URL.Query()
+	// parseMenuQuery requires a start date; the range is dropped again
+	// below when none was given.
+	wholeArchive := values.Get("startDate") == ""
+	if wholeArchive {
+		values.Set("startDate", dates.Today)
+	}
+	mq, err := parseMenuQuery(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := archive.Query{BuildingID: mq.BuildingID, DistrictID: mq.DistrictID, Sessions: mq.MealTypes}
+	if !wholeArchive {
+		q.Start, q.End = mq.Start, mq.End
+	}
+
+	opts := report.Options{MinRepeats: report.DefaultMinRepeats}
+	for _, s := range strings.Split(values.Get("sections"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			opts.Sections = append(opts.Sections, s)
+		}
+	}
+	for name, dst := range map[string]*int{"top": &opts.Top, "minRepeats": &opts.MinRepeats} {
+		if v := values.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				http.Error(w, "Invalid "+name+": "+v, http.StatusBadRequest)
+				return
+			}
+			*dst = n
+		}
+	}
+	if err := opts.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rep, err := report.Load(menuArchive, q, opts)
+	if err != nil {
+		logger.WithError(err).Error("Error building menu report")
+		http.Error(w, "Error building menu report", http.StatusInternalServerError)
+		return
+	}
+	rep.School = mq.School
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rep)
+}
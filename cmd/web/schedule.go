@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/asachs01/school_menu_connector/internal/schedule"
+	"github.com/asachs01/school_menu_connector/internal/subscription"
+)
+
+// initScheduler starts the jobs in SCHEDULE_CONFIG, if set, and serves
+// their status at /schedule. Besides the built-in actions, the web server
+// offers digest, which emails subscribers as /subscriptions/send does.
+func initScheduler(mux *http.ServeMux) {
+	path := os.Getenv("SCHEDULE_CONFIG")
+	if path == "" {
+		return
+	}
+
+	cfg, err := schedule.Load(path)
+	if err != nil {
+		logger.WithError(err).Fatal("Error loading schedule")
+	}
+	scheduler, err := schedule.New(cfg, schedule.Options{
+		Cache:   menuCache,
+		Archive: menuArchive,
+		Mailer:  mailer,
+		From:    mailFrom,
+		Logger:  logger,
+	})
+	if err != nil {
+		logger.WithError(err).Fatal("Error creating scheduler")
+	}
+	scheduler.Register("digest", digestAction)
+	if err := scheduler.Start(); err != nil {
+		logger.WithError(err).Fatal("Error starting scheduler")
+	}
+
+	mux.Handle("/schedule", scheduler)
+	logger.WithField("config", path).Info("Scheduler started")
+}
+
+// digestAction sends the digests for the job's frequency parameter (daily
+// or weekly) starting on the first day of the job's range.
+func digestAction(ctx context.Context, run schedule.Run) error {
+	if subscriptions == nil {
+		return fmt.Errorf("email subscriptions are not enabled")
+	}
+
+	frequency := run.Job.Params["frequency"]
+	if frequency == "" {
+		frequency = subscription.Daily
+	}
+	if frequency != subscription.Daily && frequency != subscription.Weekly {
+		return fmt.Errorf("frequency must be daily or weekly")
+	}
+
+	sent, failed, err := sendDigests(publicURL, frequency, run.Period.Start)
+	if err != nil {
+		return err
+	}
+	if failed > 0 && sent > 0 {
+		return fmt.Errorf("%w: %d digests failed", schedule.ErrPartial, failed)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d digests failed", failed)
+	}
+	return nil
+}
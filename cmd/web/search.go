@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/search"
+)
+
+// searchPastYears is how far back a search without a school may go, so one
+// request cannot read every school's whole archive.
+const searchPastYears = 1
+
+// apiSearchHandler finds the days and sessions whose menus match a query,
+// across the archived and cached menus. Without startDate only upcoming
+// days are searched, unless past=true. Without a school, past days are
+// searched back a year at most.
+// GET /api/v1/search?q=pizza&buildingId=&districtId=&mealTypes=&startDate=&endDate=&tz=&past=&limit=
+func apiSearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	values := r.URL.Query()
+	q := search.Query{
+		Text:       values.Get("q"),
+		BuildingID: values.Get("buildingId"),
+		DistrictID: values.Get("districtId"),
+		Sessions:   values["mealTypes"],
+	}
+	if strings.TrimSpace(q.Text) == "" {
+		http.Error(w, "Missing search query", http.StatusBadRequest)
+		return
+	}
+	if len(q.Sessions) == 1 && strings.Contains(q.Sessions[0], ",") {
+		q.Sessions = strings.Split(q.Sessions[0], ",")
+	}
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < -1 {
+			http.Error(w, "Invalid limit: "+v+" (want -1 for all results, or 0 or more)", http.StatusBadRequest)
+			return
+		}
+		q.Limit = n
+	}
+
+	now, err := dates.Now(values.Get("tz"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if v := values.Get("startDate"); v != "" {
+		if q.Start, _, err = dates.Range(v, now); err != nil {
+			http.Error(w, "Invalid startDate: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else if values.Get("past") != "true" {
+		q.Start = dates.Day(now)
+	}
+	if v := values.Get("endDate"); v != "" {
+		if _, q.End, err = dates.Range(v, now); err != nil {
+			http.Error(w, "Invalid endDate: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if earliest := dates.Day(now).AddDate(-searchPastYears, 0, 0); q.BuildingID == "" && q.Start.Before(earliest) {
+		if values.Get("startDate") != "" {
+			http.Error(w, "Searching more than a year back needs buildingId and districtId", http.StatusBadRequest)
+			return
+		}
+		q.Start = earliest
+	}
+
+	schools, err := search.Collect(menuArchive, menuCache, q)
+	if err != nil {
+		logger.WithError(err).Error("Error collecting menus to search")
+		http.Error(w, "Error searching menus", http.StatusInternalServerError)
+		return
+	}
+	results, err := search.Search(schools, q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"query": q.Text, "results": results})
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/email"
+	"github.com/asachs01/school_menu_connector/internal/render"
+	"github.com/asachs01/school_menu_connector/internal/subscription"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	subscriptions *subscription.Store
+	signer        *subscription.Signer
+	mailer        email.Mailer
+	mailFrom      string
+	// publicURL is the base of the links in subscription emails. It never
+	// comes from request headers, which anyone can set.
+	publicURL string
+)
+
+// initSubscriptions enables email subscriptions when SUBSCRIPTION_SECRET,
+// EMAIL_FROM, PUBLIC_URL and a mail provider are configured. The database
+// lives at SUBSCRIPTIONS_DB, subscriptions.db by default.
+func initSubscriptions() {
+	secret := os.Getenv("SUBSCRIPTION_SECRET")
+	mailFrom = os.Getenv("EMAIL_FROM")
+	if secret == "" || mailFrom == "" {
+		logger.Info("Email subscriptions disabled: SUBSCRIPTION_SECRET and EMAIL_FROM are not set")
+		return
+	}
+	publicURL = strings.TrimRight(os.Getenv("PUBLIC_URL"), "/")
+	if publicURL == "" {
+		logger.Warn("Email subscriptions disabled: PUBLIC_URL is not set")
+		return
+	}
+
+	cfg, err := email.MailerConfigFromEnv()
+	if err == nil {
+		mailer, err = email.NewMailer(cfg)
+	}
+	if err != nil {
+		logger.WithError(err).Warn("Email subscriptions disabled: mail provider is not configured")
+		return
+	}
+
+	path := os.Getenv("SUBSCRIPTIONS_DB")
+	if path == "" {
+		path = "subscriptions.db"
+	}
+	subscriptions, err = subscription.Open(path)
+	if err != nil {
+		logger.WithError(err).Warn("Email subscriptions disabled: cannot open database")
+		return
+	}
+
+	signer = subscription.NewSigner(secret)
+	logger.WithField("db", path).Info("Email subscriptions enabled")
+}
+
+// SubscribeRequest holds the JSON body for /subscribe.
+type SubscribeRequest struct {
+	Email      string   `json:"email"`
+	BuildingID string   `json:"buildingId"`
+	DistrictID string   `json:"districtId"`
+	School     string   `json:"school"`
+	Frequency  string   `json:"frequency"`
+	MealTypes  []string `json:"mealTypes"`
+}
+
+// subscribeHandler records a pending subscription and emails a confirmation
+// link. An address that is already subscribed keeps its subscription as it
+// is until the link confirms the new preferences. The response is the same
+// either way, so the endpoint cannot be used to look up subscribers.
+// POST /subscribe
+func subscribeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if subscriptions == nil {
+		http.Error(w, "Email subscriptions are not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req SubscribeRequest
+	if r.Header.Get("Content-Type") == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Error parsing JSON request", http.StatusBadRequest)
+			return
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Error parsing form data", http.StatusBadRequest)
+			return
+		}
+		req = SubscribeRequest{
+			Email:      r.Form.Get("email"),
+			BuildingID: r.Form.Get("buildingId"),
+			DistrictID: r.Form.Get("districtId"),
+			School:     r.Form.Get("school"),
+			Frequency:  r.Form.Get("frequency"),
+			MealTypes:  r.Form["mealTypes"],
+		}
+	}
+
+	if req.Email == "" || req.BuildingID == "" || req.DistrictID == "" {
+		http.Error(w, "Missing required fields: email, buildingId, districtId", http.StatusBadRequest)
+		return
+	}
+	addr, err := mail.ParseAddress(req.Email)
+	if err != nil {
+		http.Error(w, "Invalid email address", http.StatusBadRequest)
+		return
+	}
+	if req.Frequency == "" {
+		req.Frequency = subscription.Daily
+	}
+	if req.Frequency != subscription.Daily && req.Frequency != subscription.Weekly {
+		http.Error(w, "Frequency must be daily or weekly", http.StatusBadRequest)
+		return
+	}
+	if len(req.MealTypes) == 0 {
+		req.MealTypes = []string{"Lunch"}
+	}
+
+	sub, err := subscriptions.Subscribe(subscription.Subscription{
+		Email:      addr.Address,
+		BuildingID: req.BuildingID,
+		DistrictID: req.DistrictID,
+		School:     req.School,
+		Frequency:  req.Frequency,
+		MealTypes:  req.MealTypes,
+	})
+	if err != nil {
+		logger.WithError(err).Error("Error saving subscription")
+		http.Error(w, "Error saving subscription", http.StatusInternalServerError)
+		return
+	}
+
+	if err := sendConfirmation(sub); err != nil {
+		logger.WithError(err).WithField("subscription", sub.ID).Error("Error sending confirmation email")
+		http.Error(w, "Error sending confirmation email", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "pending",
+		"message": "Check your email for a link to confirm your subscription.",
+	})
+}
+
+// sendConfirmation emails the link that confirms a new subscription, or
+// the pending change to an active one.
+func sendConfirmation(sub *subscription.Subscription) error {
+	link := signer.ConfirmURL(publicURL, sub.Token)
+	prefs, request := subscription.Preferences{School: sub.School, Frequency: sub.Frequency}, "want"
+	if sub.Pending != nil {
+		prefs, request = *sub.Pending, "want to switch to"
+	}
+	school := prefs.School
+	if school == "" {
+		school = "your school"
+	}
+
+	text := fmt.Sprintf("Please confirm that you %s the %s menu for %s emailed to %s:\n\n%s\n\nIf you did not ask for this, ignore this email and nothing will change.\n",
+		request, prefs.Frequency, school, sub.Email, link)
+	html := fmt.Sprintf(`<p>Please confirm that you %s the %s menu for %s emailed to %s.</p><p><a href="%s">Confirm subscription</a></p><p style="color:#6c757d;font-size:12px;">If you did not ask for this, ignore this email and nothing will change.</p>`,
+		request, template.HTMLEscapeString(prefs.Frequency), template.HTMLEscapeString(school),
+		template.HTMLEscapeString(sub.Email), template.HTMLEscapeString(link))
+
+	msg := &email.Message{
+		From:    mailFrom,
+		Subject: "Confirm your school menu subscription",
+		Text:    text,
+		HTML:    html,
+	}
+	_, err := mailer.Send(msg, []string{sub.Email}, email.DeliveryOptions{Mode: email.DeliverIndividual})
+	return err
+}
+
+// confirmHandler activates a subscription, or applies the change to an
+// active one, from its signed opt-in link.
+// GET /confirm?token=...&sig=...
+func confirmHandler(w http.ResponseWriter, r *http.Request) {
+	if subscriptions == nil {
+		http.Error(w, "Email subscriptions are not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if !signer.VerifyToken(token, r.URL.Query().Get("sig")) {
+		writePage(w, http.StatusBadRequest, page{Title: "Invalid link", Message: "This confirmation link is invalid."})
+		return
+	}
+	sub, err := subscriptions.Confirm(token)
+	if errors.Is(err, subscription.ErrNotFound) {
+		writePage(w, http.StatusNotFound, page{
+			Title:   "Link expired",
+			Message: "This confirmation link is invalid or has already been used.",
+		})
+		return
+	}
+	if err != nil {
+		logger.WithError(err).Error("Error confirming subscription")
+		http.Error(w, "Error confirming subscription", http.StatusInternalServerError)
+		return
+	}
+
+	logger.WithFields(logrus.Fields{"subscription": sub.ID, "frequency": sub.Frequency}).Info("Subscription confirmed")
+	writePage(w, http.StatusOK, page{
+		Title:   "Subscription confirmed",
+		Message: fmt.Sprintf("%s will now get the %s menu. Every email has a link to unsubscribe.", sub.Email, sub.Frequency),
+	})
+}
+
+// unsubscribeHandler stops a subscription from a signed link. GET shows a
+// confirmation button so that link scanners cannot unsubscribe anyone; POST
+// unsubscribes, which also serves RFC 8058 one-click requests from mail
+// clients.
+// GET|POST /unsubscribe?id=...&sig=...
+func unsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	if subscriptions == nil {
+		http.Error(w, "Email subscriptions are not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	invalid := page{Title: "Invalid link", Message: "This unsubscribe link is invalid."}
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		writePage(w, http.StatusBadRequest, invalid)
+		return
+	}
+	sub, err := subscriptions.Get(id)
+	if err != nil || !signer.Verify(*sub, r.URL.Query().Get("sig")) {
+		writePage(w, http.StatusBadRequest, invalid)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if sub.Status == subscription.StatusUnsubscribed {
+			writePage(w, http.StatusOK, page{Title: "Unsubscribed", Message: sub.Email + " is not subscribed."})
+			return
+		}
+		writePage(w, http.StatusOK, page{
+			Title:   "Unsubscribe",
+			Message: fmt.Sprintf("Stop emailing the %s menu to %s?", sub.Frequency, sub.Email),
+			Action:  r.URL.RequestURI(),
+			Button:  "Unsubscribe",
+		})
+	case http.MethodPost:
+		if err := subscriptions.Unsubscribe(sub.ID); err != nil {
+			logger.WithError(err).Error("Error unsubscribing")
+			http.Error(w, "Error unsubscribing", http.StatusInternalServerError)
+			return
+		}
+		logger.WithField("subscription", sub.ID).Info("Unsubscribed")
+		writePage(w, http.StatusOK, page{Title: "Unsubscribed", Message: sub.Email + " will not get any more menu emails."})
+	default:
+		http.Error(w, "Only GET and POST requests are supported", http.StatusMethodNotAllowed)
+	}
+}
+
+// sendDigestsHandler emails the menu to every active subscriber with the
+// given frequency. Daily digests cover the date, weekly digests the seven
+// days from it.
+// POST /subscriptions/send?frequency=daily|weekly[&date=DATE&tz=ZONE]
+// Requires SUBSCRIPTION_API_KEY in the X-API-Key header.
+func sendDigestsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if subscriptions == nil {
+		http.Error(w, "Email subscriptions are not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	apiKey := os.Getenv("SUBSCRIPTION_API_KEY")
+	if apiKey == "" || r.Header.Get("X-API-Key") != apiKey {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	frequency := r.URL.Query().Get("frequency")
+	if frequency != subscription.Daily && frequency != subscription.Weekly {
+		http.Error(w, "Frequency must be daily or weekly", http.StatusBadRequest)
+		return
+	}
+	date, _, err := resolveDates(r.URL.Query().Get("date"), "", r.URL.Query().Get("tz"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid date: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	sent, failed, err := sendDigests(publicURL, frequency, date)
+	if err != nil {
+		logger.WithError(err).Error("Error sending digests")
+		http.Error(w, "Error sending digests", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"sent": sent, "failed": failed})
+}
+
+// sendDigests emails each active subscriber with the given frequency their
+// school's menu starting at date. Subscribers whose school has no menu in
+// the period, such as on weekends, are skipped.
+func sendDigests(base, frequency string, date time.Time) (sent, failed int, err error) {
+	subs, err := subscriptions.Active(frequency)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	end := start
+	if frequency == subscription.Weekly {
+		end = start.AddDate(0, 0, 6)
+	}
+
+	// Subscribers to the same school and meals share one fetch.
+	menus := make(map[string]render.Document)
+	for _, sub := range subs {
+		key := strings.Join([]string{sub.BuildingID, sub.DistrictID, strings.Join(sub.MealTypes, ",")}, "|")
+		doc, ok := menus[key]
+		if !ok {
+			doc = render.Document{
+				School: sub.School,
+				Days: loadMenuDays(menuQuery{
+					BuildingID: sub.BuildingID,
+					DistrictID: sub.DistrictID,
+					Start:      start,
+					End:        end,
+					MealTypes:  sub.MealTypes,
+				}),
+			}
+			menus[key] = doc
+		}
+		if len(doc.Days) == 0 {
+			continue
+		}
+		doc.School = sub.School
+
+		if err := sendDigest(base, sub, doc, start, end); err != nil {
+			logger.WithError(err).WithField("subscription", sub.ID).Warn("Error sending digest")
+			failed++
+			continue
+		}
+		sent++
+	}
+
+	logger.WithFields(logrus.Fields{"frequency": frequency, "sent": sent, "failed": failed}).Info("Digests sent")
+	return sent, failed, nil
+}
+
+func sendDigest(base string, sub subscription.Subscription, doc render.Document, start, end time.Time) error {
+	unsubscribe := signer.UnsubscribeURL(base, sub)
+
+	data := email.NewTemplateData(doc, start, end)
+	data.UnsubscribeURL = unsubscribe
+	msg, err := email.MenuMessage(nil, data, mailFrom, "")
+	if err != nil {
+		return err
+	}
+	msg.Header = map[string]string{
+		"List-Unsubscribe":      "<" + unsubscribe + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+
+	_, err = mailer.Send(msg, []string{sub.Email}, email.DeliveryOptions{Mode: email.DeliverIndividual})
+	return err
+}
+
+// page is a minimal HTML response for links opened from emails.
+type page struct {
+	Title   string
+	Message string
+	// Action and Button, if set, add a form that POSTs to Action.
+	Action string
+	Button string
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} - School Menu Connector</title>
+</head>
+<body style="font-family:-apple-system,BlinkMacSystemFont,'Segoe UI',Roboto,Helvetica,Arial,sans-serif;background:#f8f9fa;color:#212529;padding:32px 16px;">
+<div style="max-width:480px;margin:0 auto;background:#fff;border-radius:8px;padding:24px;">
+<h1 style="font-size:22px;margin-top:0;">{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{- if .Button}}
+<form method="POST" action="{{.Action}}"><button type="submit" style="padding:8px 16px;font-size:16px;">{{.Button}}</button></form>
+{{- end}}
+</div>
+</body>
+</html>
+`))
+
+func writePage(w http.ResponseWriter, status int, p page) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := pageTemplate.Execute(w, p); err != nil {
+		logger.WithError(err).Error("Error writing page")
+	}
+}
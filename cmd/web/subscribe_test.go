@@ -0,0 +1,254 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/asachs01/school_menu_connector/internal/email"
+	"github.com/asachs01/school_menu_connector/internal/subscription"
+)
+
+// fakeMailer records the messages sent through it.
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []*email.Message
+	to   [][]string
+}
+
+func (m *fakeMailer) Send(msg *email.Message, recipients []string, opts email.DeliveryOptions) ([]email.DeliveryResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	m.to = append(m.to, recipients)
+	return nil, nil
+}
+
+// last returns the most recent message and its recipients.
+func (m *fakeMailer) last(t *testing.T) (*email.Message, []string) {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.sent) == 0 {
+		t.Fatal("no email sent")
+	}
+	return m.sent[len(m.sent)-1], m.to[len(m.to)-1]
+}
+
+func (m *fakeMailer) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sent)
+}
+
+// enableSubscriptions sets up subscriptions as initSubscriptions would, with
+// a fresh database and a fake mailer.
+func enableSubscriptions(t *testing.T) *fakeMailer {
+	t.Helper()
+	store, err := subscription.Open(filepath.Join(t.TempDir(), "subscriptions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &fakeMailer{}
+	subscriptions, signer, mailer, mailFrom, publicURL = store, subscription.NewSigner("secret"), m, "menus@example.com", "https://menus.example.com"
+	logger.SetOutput(io.Discard)
+	t.Cleanup(func() {
+		store.Close()
+		subscriptions, signer, mailer, mailFrom, publicURL = nil, nil, nil, "", ""
+	})
+	return m
+}
+
+func subscribe(t *testing.T, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/subscribe", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	subscribeHandler(w, req)
+	return w
+}
+
+var confirmLink = regexp.MustCompile(`https://menus\.example\.com(/confirm\?\S+)`)
+
+// confirm follows the confirmation link in msg.
+func confirm(t *testing.T, msg *email.Message) *httptest.ResponseRecorder {
+	t.Helper()
+	m := confirmLink.FindStringSubmatch(msg.Text)
+	if m == nil {
+		t.Fatalf("no confirmation link in %q", msg.Text)
+	}
+	w := httptest.NewRecorder()
+	confirmHandler(w, httptest.NewRequest(http.MethodGet, m[1], nil))
+	return w
+}
+
+func TestSubscribeHandler(t *testing.T) {
+	m := enableSubscriptions(t)
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		ctype  string
+		status int
+	}{
+		{"get", http.MethodGet, "", "", http.StatusMethodNotAllowed},
+		{"bad json", http.MethodPost, "{", "application/json", http.StatusBadRequest},
+		{"missing district", http.MethodPost, `{"email":"parent@example.com","buildingId":"b1"}`, "application/json", http.StatusBadRequest},
+		{"bad address", http.MethodPost, `{"email":"parent","buildingId":"b1","districtId":"d1"}`, "application/json", http.StatusBadRequest},
+		{"bad frequency", http.MethodPost, `{"email":"parent@example.com","buildingId":"b1","districtId":"d1","frequency":"hourly"}`, "application/json", http.StatusBadRequest},
+		{"json", http.MethodPost, `{"email":"Parent <parent@example.com>","buildingId":"b1","districtId":"d1","school":"Lincoln"}`, "application/json", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/subscribe", strings.NewReader(tt.body))
+			if tt.ctype != "" {
+				req.Header.Set("Content-Type", tt.ctype)
+			}
+			w := httptest.NewRecorder()
+			subscribeHandler(w, req)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+
+	if n := m.count(); n != 1 {
+		t.Fatalf("%d emails sent, want 1 confirmation", n)
+	}
+	msg, to := m.last(t)
+	if len(to) != 1 || to[0] != "parent@example.com" || msg.Subject != "Confirm your school menu subscription" {
+		t.Errorf("sent %q to %v", msg.Subject, to)
+	}
+	if !strings.Contains(msg.Text, "want the daily menu for Lincoln") {
+		t.Errorf("confirmation = %q, want the daily Lincoln menu", msg.Text)
+	}
+	if w := confirm(t, msg); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Subscription confirmed") {
+		t.Errorf("confirm = %d %s", w.Code, w.Body)
+	}
+}
+
+func TestSubscribeHandlerChange(t *testing.T) {
+	m := enableSubscriptions(t)
+	form := url.Values{"email": {"parent@example.com"}, "buildingId": {"b1"}, "districtId": {"d1"}, "school": {"Lincoln"}, "mealTypes": {"Lunch"}}
+	if w := subscribe(t, form); w.Code != http.StatusAccepted {
+		t.Fatalf("subscribe = %d %s", w.Code, w.Body)
+	}
+	msg, _ := m.last(t)
+	if w := confirm(t, msg); w.Code != http.StatusOK {
+		t.Fatalf("confirm = %d %s", w.Code, w.Body)
+	}
+
+	// Anyone can ask to change the subscription, but nothing changes until
+	// the subscriber confirms.
+	form.Set("school", "Click here to win")
+	form.Set("frequency", subscription.Weekly)
+	form["mealTypes"] = []string{"Breakfast"}
+	w := subscribe(t, form)
+	if w.Code != http.StatusAccepted || !strings.Contains(w.Body.String(), `"status":"pending"`) {
+		t.Fatalf("subscribe again = %d %s", w.Code, w.Body)
+	}
+	active, err := subscriptions.Active(subscription.Daily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].School != "Lincoln" || strings.Join(active[0].MealTypes, ",") != "Lunch" {
+		t.Fatalf("active daily subscriptions = %+v, want Lincoln lunches unchanged", active)
+	}
+
+	change, _ := m.last(t)
+	if !strings.Contains(change.Text, "want to switch to the weekly menu for Click here to win") {
+		t.Errorf("change confirmation = %q", change.Text)
+	}
+	if w := confirm(t, change); w.Code != http.StatusOK {
+		t.Fatalf("confirm change = %d %s", w.Code, w.Body)
+	}
+	weekly, err := subscriptions.Active(subscription.Weekly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(weekly) != 1 || weekly[0].ID != active[0].ID || strings.Join(weekly[0].MealTypes, ",") != "Breakfast" {
+		t.Errorf("active weekly subscriptions = %+v, want the confirmed change", weekly)
+	}
+	if w := confirm(t, change); w.Code != http.StatusNotFound {
+		t.Errorf("confirming twice = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestConfirmHandler(t *testing.T) {
+	enableSubscriptions(t)
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"no token", "", http.StatusBadRequest},
+		{"unsigned", "token=abc", http.StatusBadRequest},
+		{"forged", "token=abc&sig=" + subscription.NewSigner("other").SignToken("abc"), http.StatusBadRequest},
+		{"unknown", "token=abc&sig=" + signer.SignToken("abc"), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			confirmHandler(w, httptest.NewRequest(http.MethodGet, "/confirm?"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestUnsubscribeHandler(t *testing.T) {
+	m := enableSubscriptions(t)
+	if w := subscribe(t, url.Values{"email": {"parent@example.com"}, "buildingId": {"b1"}, "districtId": {"d1"}}); w.Code != http.StatusAccepted {
+		t.Fatalf("subscribe = %d %s", w.Code, w.Body)
+	}
+	msg, _ := m.last(t)
+	if w := confirm(t, msg); w.Code != http.StatusOK {
+		t.Fatalf("confirm = %d %s", w.Code, w.Body)
+	}
+	active, err := subscriptions.Active(subscription.Daily)
+	if err != nil || len(active) != 1 {
+		t.Fatalf("active = %+v, %v", active, err)
+	}
+	link, err := url.Parse(signer.UnsubscribeURL(publicURL, active[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := link.RequestURI()
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+		body   string
+	}{
+		{"bad id", http.MethodGet, "/unsubscribe?id=x", http.StatusBadRequest, "invalid"},
+		{"unknown id", http.MethodGet, "/unsubscribe?id=99&sig=" + link.Query().Get("sig"), http.StatusBadRequest, "invalid"},
+		{"bad signature", http.MethodGet, "/unsubscribe?id=1&sig=x", http.StatusBadRequest, "invalid"},
+		{"put", http.MethodPut, target, http.StatusMethodNotAllowed, "Only GET and POST"},
+		// Opening the link only asks, so link scanners cannot unsubscribe.
+		{"get", http.MethodGet, target, http.StatusOK, `<form method="POST"`},
+		{"post", http.MethodPost, target, http.StatusOK, "will not get any more menu emails"},
+		{"get after", http.MethodGet, target, http.StatusOK, "is not subscribed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			unsubscribeHandler(w, httptest.NewRequest(tt.method, tt.target, nil))
+			if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.body) {
+				t.Errorf("%s %s = %d %s, want %d with %q", tt.method, tt.target, w.Code, w.Body, tt.status, tt.body)
+			}
+		})
+	}
+	if active, err := subscriptions.Active(subscription.Daily); err != nil || len(active) != 0 {
+		t.Errorf("active after unsubscribing = %+v, %v", active, err)
+	}
+}
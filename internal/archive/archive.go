@@ -0,0 +1,242 @@
+// Package archive keeps every fetched menu day in a SQLite database.
+//
+// Unlike the cache, the archive never expires: each school, date and
+// session is stored as normalized rows, down to each recipe's nutrients,
+// so past school years stay available for queries and reports after LINQ
+// Connect has moved on. Saving a day again replaces its rows, so a day can
+// be archived any number of times.
+package archive
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+
+	_ "modernc.org/sqlite"
+)
+
+// DefaultPath is the database file used unless configured otherwise.
+const DefaultPath = "archive.db"
+
+// Store keeps archived menus in a SQLite database.
+type Store struct {
+	db *sql.DB
+}
+
+// School is an archived school and the dates its menus cover.
+type School struct {
+	BuildingID string `json:"buildingId"`
+	DistrictID string `json:"districtId"`
+	Name       string `json:"name,omitempty"`
+	// FirstDate and LastDate are YYYY-MM-DD.
+	FirstDate string `json:"firstDate"`
+	LastDate  string `json:"lastDate"`
+	Days      int    `json:"days"`
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS schools (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	building_id TEXT NOT NULL,
+	district_id TEXT NOT NULL,
+	name        TEXT NOT NULL DEFAULT '',
+	UNIQUE (building_id, district_id)
+);
+CREATE TABLE IF NOT EXISTS menu_sessions (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	school_id  INTEGER NOT NULL REFERENCES schools (id),
+	date       TEXT NOT NULL,
+	session    TEXT NOT NULL,
+	position   INTEGER NOT NULL,
+	fetched_at TIMESTAMP NOT NULL,
+	UNIQUE (school_id, date, session)
+);
+CREATE TABLE IF NOT EXISTS recipes (
+	session_id   INTEGER NOT NULL REFERENCES menu_sessions (id),
+	position     INTEGER NOT NULL,
+	meal         TEXT NOT NULL,
+	category     TEXT NOT NULL,
+	color        TEXT NOT NULL DEFAULT '',
+	item_id      TEXT NOT NULL,
+	identifier   TEXT NOT NULL DEFAULT '',
+	name         TEXT NOT NULL,
+	serving_size TEXT NOT NULL DEFAULT '',
+	allergens    TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (session_id, position)
+);
+CREATE TABLE IF NOT EXISTS nutrients (
+	session_id INTEGER NOT NULL,
+	position   INTEGER NOT NULL,
+	name       TEXT NOT NULL,
+	value      REAL NOT NULL,
+	unit       TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (session_id, position, name),
+	FOREIGN KEY (session_id, position) REFERENCES recipes (session_id, position)
+);
+CREATE INDEX IF NOT EXISTS menu_sessions_date ON menu_sessions (school_id, date);
+CREATE INDEX IF NOT EXISTS recipes_name ON recipes (name);
+CREATE INDEX IF NOT EXISTS recipes_item ON recipes (item_id);
+`
+
+// Open opens or creates the archive database at path.
+func Open(path string) (*Store, error) {
+	if path == "" {
+		path = DefaultPath
+	}
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("opening archive database: %w", err)
+	}
+	// SQLite allows a single writer; one connection avoids lock errors.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating archive tables: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save archives every day in a menu fetched from LINQ Connect, with all of
+// its sessions. A session archived earlier for one of those days but no
+// longer served is removed; dates the menu does not list are left as they
+// are. school names the school and may be empty if it is not known.
+func (s *Store) Save(school, buildingID, districtID string, m *menu.Menu) error {
+	return s.SaveDays(school, buildingID, districtID, m.Days())
+}
+
+// SaveDays archives normalized days, each with every session served that
+// day, in one transaction.
+func (s *Store) SaveDays(school, buildingID, districtID string, days []menu.Day) error {
+	if len(days) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("archiving menu: %w", err)
+	}
+	defer tx.Rollback()
+
+	var schoolID int64
+	err = tx.QueryRow(`
+		INSERT INTO schools (building_id, district_id, name) VALUES (?, ?, ?)
+		ON CONFLICT (building_id, district_id) DO UPDATE SET
+			name = CASE WHEN excluded.name <> '' THEN excluded.name ELSE schools.name END
+		RETURNING id`,
+		buildingID, districtID, school).Scan(&schoolID)
+	if err != nil {
+		return fmt.Errorf("archiving school: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for _, day := range days {
+		if err := saveDay(tx, schoolID, day, now); err != nil {
+			return fmt.Errorf("archiving %s: %w", day.Date, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("archiving menu: %w", err)
+	}
+	return nil
+}
+
+// saveDay upserts a day's sessions and replaces their recipes and
+// nutrients.
+func saveDay(tx *sql.Tx, schoolID int64, day menu.Day, fetched time.Time) error {
+	names := make([]interface{}, 0, len(day.Sessions)+2)
+	names = append(names, schoolID, day.Date)
+	for i, session := range day.Sessions {
+		names = append(names, session.Name)
+
+		var sessionID int64
+		err := tx.QueryRow(`
+			INSERT INTO menu_sessions (school_id, date, session, position, fetched_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (school_id, date, session) DO UPDATE SET
+				position = excluded.position,
+				fetched_at = excluded.fetched_at
+			RETURNING id`,
+			schoolID, day.Date, session.Name, i, fetched).Scan(&sessionID)
+		if err != nil {
+			return err
+		}
+		if err := clearSession(tx, sessionID); err != nil {
+			return err
+		}
+
+		position := 0
+		for _, c := range session.Categories {
+			for _, item := range c.Recipes {
+				_, err := tx.Exec(`
+					INSERT INTO recipes (session_id, position, meal, category, color, item_id, identifier, name, serving_size, allergens)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+					sessionID, position, c.Meal, c.Name, c.Color, item.ItemID, item.Identifier, item.Name,
+					item.ServingSize, strings.Join(item.Allergens, ","))
+				if err != nil {
+					return err
+				}
+				for _, n := range item.Nutrients {
+					_, err := tx.Exec(`
+						INSERT INTO nutrients (session_id, position, name, value, unit) VALUES (?, ?, ?, ?, ?)
+						ON CONFLICT (session_id, position, name) DO UPDATE SET
+							value = excluded.value,
+							unit = excluded.unit`,
+						sessionID, position, n.Name, n.Value, n.Unit)
+					if err != nil {
+						return err
+					}
+				}
+				position++
+			}
+		}
+	}
+
+	// Drop sessions the day no longer has.
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(day.Sessions)), ", ")
+	where := `school_id = ? AND date = ?`
+	if len(day.Sessions) > 0 {
+		where += ` AND session NOT IN (` + placeholders + `)`
+	}
+	rows, err := tx.Query(`SELECT id FROM menu_sessions WHERE `+where, names...)
+	if err != nil {
+		return err
+	}
+	var stale []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		stale = append(stale, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range stale {
+		if err := clearSession(tx, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM menu_sessions WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearSession removes a session's recipes and their nutrients.
+func clearSession(tx *sql.Tx, sessionID int64) error {
+	if _, err := tx.Exec(`DELETE FROM nutrients WHERE session_id = ?`, sessionID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM recipes WHERE session_id = ?`, sessionID)
+	return err
+}
@@ -0,0 +1,162 @@
+package archive
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+func openTest(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "archive.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func testDays() []menu.Day {
+	pizza := menu.Item{
+		ItemID: "id-pizza", Identifier: "R1", Name: "Cheese Pizza", ServingSize: "1 slice",
+		Allergens: []string{"milk", "wheat"},
+		Nutrients: []menu.ItemNutrient{{Name: "Calories", Value: 300, Unit: "kcals"}, {Name: "Protein", Value: 12.5, Unit: "g"}},
+	}
+	milk := menu.Item{ItemID: "id-milk", Name: "1% Milk", Allergens: []string{"milk"}, Nutrients: []menu.ItemNutrient{}}
+	return []menu.Day{
+		{Date: "2024-09-04", Sessions: []menu.Session{
+			{Name: "Lunch", Categories: []menu.Category{
+				{Name: "Entree", Meal: "Main", Color: "#ff0000", Recipes: []menu.Item{pizza}},
+				{Name: "Milk", Meal: "Main", Recipes: []menu.Item{milk}},
+				{Name: "Entree", Meal: "Combos", Recipes: []menu.Item{{ItemID: "id-soup", Name: "Soup", Allergens: []string{}, Nutrients: []menu.ItemNutrient{}}}},
+			}},
+			{Name: "Breakfast", Categories: []menu.Category{
+				{Name: "Entree", Meal: "Main", Recipes: []menu.Item{{ItemID: "id-waffles", Name: "Waffles", Allergens: []string{}, Nutrients: []menu.ItemNutrient{}}}},
+			}},
+		}},
+		{Date: "2024-09-05", Sessions: []menu.Session{
+			{Name: "Lunch", Categories: []menu.Category{{Name: "Milk", Meal: "Main", Recipes: []menu.Item{milk}}}},
+		}},
+	}
+}
+
+func TestSaveDaysRoundTrip(t *testing.T) {
+	s := openTest(t)
+	want := testDays()
+	if err := s.SaveDays("Lincoln", "b1", "d1", want); err != nil {
+		t.Fatal(err)
+	}
+	// Another school's days must not leak into the query.
+	if err := s.SaveDays("Emma", "b2", "d1", want[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Days(Query{BuildingID: "b1", DistrictID: "d1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := mustJSON(t, got), mustJSON(t, want); g != w {
+		t.Errorf("Days =\n%s\nwant\n%s", g, w)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  string
+	}{
+		{"one date", Query{Start: date("2024-09-05"), End: date("2024-09-05")}, "2024-09-05:Lunch"},
+		{"from a date", Query{Start: date("2024-09-05")}, "2024-09-05:Lunch"},
+		{"until a date", Query{End: date("2024-09-04")}, "2024-09-04:Lunch,Breakfast"},
+		{"sessions in the order given", Query{Sessions: []string{"Breakfast", "Lunch"}}, "2024-09-04:Breakfast,Lunch 2024-09-05:Lunch"},
+		{"one session", Query{Sessions: []string{"Breakfast"}}, "2024-09-04:Breakfast"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.BuildingID, tt.query.DistrictID = "b1", "d1"
+			days, err := s.Days(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := outline(days); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveDaysReplaces(t *testing.T) {
+	s := openTest(t)
+	if err := s.SaveDays("Lincoln", "b1", "d1", testDays()); err != nil {
+		t.Fatal(err)
+	}
+	// Breakfast is no longer served on the 4th and lunch changed; the name
+	// is kept when a later save does not know it.
+	changed := []menu.Day{{Date: "2024-09-04", Sessions: []menu.Session{{Name: "Lunch", Categories: []menu.Category{
+		{Name: "Entree", Meal: "Main", Recipes: []menu.Item{{ItemID: "id-tacos", Name: "Tacos", Allergens: []string{}, Nutrients: []menu.ItemNutrient{}}}},
+	}}}}}
+	if err := s.SaveDays("", "b1", "d1", changed); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Days(Query{BuildingID: "b1", DistrictID: "d1", End: date("2024-09-04")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := mustJSON(t, got), mustJSON(t, changed); g != w {
+		t.Errorf("Days =\n%s\nwant\n%s", g, w)
+	}
+
+	dates, err := s.Dates("b1", "d1", date("2024-09-01"), date("2024-09-30"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dates) != 2 || dates[0] != "2024-09-04" || dates[1] != "2024-09-05" {
+		t.Errorf("Dates = %v", dates)
+	}
+	schools, err := s.Schools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := School{BuildingID: "b1", DistrictID: "d1", Name: "Lincoln", FirstDate: "2024-09-04", LastDate: "2024-09-05", Days: 2}
+	if len(schools) != 1 || schools[0] != want {
+		t.Errorf("Schools = %+v, want %+v", schools, want)
+	}
+}
+
+func date(s string) time.Time {
+	t, err := time.Parse(menu.DateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// outline lists each day's sessions, e.g. "2024-09-04:Lunch,Breakfast".
+func outline(days []menu.Day) string {
+	var out string
+	for i, d := range days {
+		if i > 0 {
+			out += " "
+		}
+		out += d.Date + ":"
+		for j, s := range d.Sessions {
+			if j > 0 {
+				out += ","
+			}
+			out += s.Name
+		}
+	}
+	return out
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,250 @@
+package archive
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// Query selects archived days for a school.
+type Query struct {
+	BuildingID string
+	DistrictID string
+	// Start and End bound the dates, inclusive. A zero time leaves that
+	// end open.
+	Start time.Time
+	End   time.Time
+	// Sessions are included in the order given; none means every session
+	// in the order LINQ Connect listed them.
+	Sessions []string
+}
+
+// where returns the SQL conditions and arguments that select q's sessions,
+// for a query joining schools as sc and menu_sessions as ms.
+func (q Query) where() (string, []interface{}) {
+	conds := []string{`sc.building_id = ?`, `sc.district_id = ?`}
+	args := []interface{}{q.BuildingID, q.DistrictID}
+	if !q.Start.IsZero() {
+		conds = append(conds, `ms.date >= ?`)
+		args = append(args, q.Start.Format(menu.DateLayout))
+	}
+	if !q.End.IsZero() {
+		conds = append(conds, `ms.date <= ?`)
+		args = append(args, q.End.Format(menu.DateLayout))
+	}
+	if len(q.Sessions) > 0 {
+		conds = append(conds, `ms.session IN (`+strings.TrimSuffix(strings.Repeat("?, ", len(q.Sessions)), ", ")+`)`)
+		for _, s := range q.Sessions {
+			args = append(args, s)
+		}
+	}
+	return strings.Join(conds, ` AND `), args
+}
+
+// Days returns the archived menu for q in the same normalized form as
+// menu.Menu.Days, sorted by date. Days without a matching session are
+// omitted.
+func (s *Store) Days(q Query) ([]menu.Day, error) {
+	where, args := q.where()
+	rows, err := s.db.Query(`
+		SELECT ms.id, ms.date, ms.session, r.position, r.meal, r.category, r.color,
+			r.item_id, r.identifier, r.name, r.serving_size, r.allergens
+		FROM menu_sessions ms
+		JOIN schools sc ON sc.id = ms.school_id
+		JOIN recipes r ON r.session_id = ms.id
+		WHERE `+where+`
+		ORDER BY ms.date, ms.position, r.position`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying archive: %w", err)
+	}
+	defer rows.Close()
+
+	type itemKey struct {
+		session  int64
+		position int
+	}
+	var days []menu.Day
+	var keys []itemKey
+	var sessionIDs []interface{}
+	lastSession := int64(-1)
+	for rows.Next() {
+		var (
+			sessionID             int64
+			date, session         string
+			position              int
+			meal, category, color string
+			allergens             string
+			item                  menu.Item
+		)
+		if err := rows.Scan(&sessionID, &date, &session, &position, &meal, &category, &color,
+			&item.ItemID, &item.Identifier, &item.Name, &item.ServingSize, &allergens); err != nil {
+			return nil, fmt.Errorf("reading archive: %w", err)
+		}
+		item.Allergens = splitList(allergens)
+		item.Nutrients = []menu.ItemNutrient{}
+
+		if len(days) == 0 || days[len(days)-1].Date != date {
+			days = append(days, menu.Day{Date: date})
+		}
+		day := &days[len(days)-1]
+		if sessionID != lastSession {
+			day.Sessions = append(day.Sessions, menu.Session{Name: session})
+			sessionIDs = append(sessionIDs, sessionID)
+			lastSession = sessionID
+		}
+		sess := &day.Sessions[len(day.Sessions)-1]
+		if n := len(sess.Categories); n == 0 || sess.Categories[n-1].Name != category || sess.Categories[n-1].Meal != meal {
+			sess.Categories = append(sess.Categories, menu.Category{Name: category, Meal: meal, Color: color})
+		}
+		cat := &sess.Categories[len(sess.Categories)-1]
+		cat.Recipes = append(cat.Recipes, item)
+		keys = append(keys, itemKey{sessionID, position})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+
+	nutrients := make(map[itemKey][]menu.ItemNutrient)
+	if err := s.loadNutrients(sessionIDs, func(session int64, position int, n menu.ItemNutrient) {
+		key := itemKey{session, position}
+		nutrients[key] = append(nutrients[key], n)
+	}); err != nil {
+		return nil, err
+	}
+	// Recipes were appended in row order, so walking them in the same
+	// order lines them up with keys.
+	i := 0
+	for _, day := range days {
+		for _, sess := range day.Sessions {
+			for _, c := range sess.Categories {
+				for ri := range c.Recipes {
+					if list := nutrients[keys[i]]; list != nil {
+						c.Recipes[ri].Nutrients = list
+					}
+					i++
+				}
+			}
+		}
+	}
+
+	if len(q.Sessions) > 0 {
+		for i := range days {
+			days[i].Sessions = ordered(days[i].Sessions, q.Sessions)
+		}
+	}
+	return days, nil
+}
+
+// loadNutrients calls add for each nutrient of the given sessions' recipes,
+// in batches that stay within SQLite's limit on query parameters.
+func (s *Store) loadNutrients(sessionIDs []interface{}, add func(session int64, position int, n menu.ItemNutrient)) error {
+	const batch = 500
+	for len(sessionIDs) > 0 {
+		ids := sessionIDs
+		if len(ids) > batch {
+			ids = ids[:batch]
+		}
+		sessionIDs = sessionIDs[len(ids):]
+
+		rows, err := s.db.Query(`
+			SELECT session_id, position, name, value, unit FROM nutrients
+			WHERE session_id IN (`+strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")+`)
+			ORDER BY session_id, position, rowid`, ids...)
+		if err != nil {
+			return fmt.Errorf("querying nutrients: %w", err)
+		}
+		for rows.Next() {
+			var session int64
+			var position int
+			var n menu.ItemNutrient
+			if err := rows.Scan(&session, &position, &n.Name, &n.Value, &n.Unit); err != nil {
+				rows.Close()
+				return fmt.Errorf("reading nutrients: %w", err)
+			}
+			add(session, position, n)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("reading nutrients: %w", err)
+		}
+	}
+	return nil
+}
+
+// ordered returns sessions in the order of names.
+func ordered(sessions []menu.Session, names []string) []menu.Session {
+	out := make([]menu.Session, 0, len(sessions))
+	for _, name := range names {
+		for _, s := range sessions {
+			if s.Name == name {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// Dates returns the archived dates for a school between start and end,
+// inclusive, as YYYY-MM-DD.
+func (s *Store) Dates(buildingID, districtID string, start, end time.Time) ([]string, error) {
+	q := Query{BuildingID: buildingID, DistrictID: districtID, Start: start, End: end}
+	where, args := q.where()
+	rows, err := s.db.Query(`
+		SELECT DISTINCT ms.date FROM menu_sessions ms
+		JOIN schools sc ON sc.id = ms.school_id
+		WHERE `+where+`
+		ORDER BY ms.date`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying archive: %w", err)
+	}
+	defer rows.Close()
+
+	var list []string
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			return nil, fmt.Errorf("reading archive: %w", err)
+		}
+		list = append(list, date)
+	}
+	return list, rows.Err()
+}
+
+// Schools returns every archived school with the dates it covers.
+func (s *Store) Schools() ([]School, error) {
+	rows, err := s.db.Query(`
+		SELECT sc.building_id, sc.district_id, sc.name,
+			COALESCE(MIN(ms.date), ''), COALESCE(MAX(ms.date), ''), COUNT(DISTINCT ms.date)
+		FROM schools sc
+		LEFT JOIN menu_sessions ms ON ms.school_id = sc.id
+		GROUP BY sc.id
+		ORDER BY sc.district_id, sc.name, sc.building_id`)
+	if err != nil {
+		return nil, fmt.Errorf("listing archived schools: %w", err)
+	}
+	defer rows.Close()
+
+	var schools []School
+	for rows.Next() {
+		var sc School
+		if err := rows.Scan(&sc.BuildingID, &sc.DistrictID, &sc.Name, &sc.FirstDate, &sc.LastDate, &sc.Days); err != nil {
+			return nil, fmt.Errorf("reading archived schools: %w", err)
+		}
+		schools = append(schools, sc)
+	}
+	return schools, rows.Err()
+}
+
+// splitList splits a comma-separated list, always returning a non-nil
+// slice so empty lists encode as [].
+func splitList(s string) []string {
+	list := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,212 @@
+// Package bot answers menu questions in Telegram and Matrix chats.
+//
+// Parents send commands such as /today, /week or /subscribe lunch 7am; the
+// bot replies from the menu cache shared with the web server and keeps each
+// chat's school, time zone and subscriptions in a state file. The chat
+// services are Transports, so the bot can run against any of them, or a
+// local stand-in.
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/cache"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/sirupsen/logrus"
+)
+
+// Message is a text message received in a chat.
+type Message struct {
+	ChatID string
+	Text   string
+}
+
+// Transport connects the bot to a chat service.
+type Transport interface {
+	// Name identifies the service, e.g. telegram. Chat settings are kept
+	// per service and chat ID.
+	Name() string
+	// Receive waits for new messages, returning when some arrive, its
+	// long-poll timeout passes, or ctx is done.
+	Receive(ctx context.Context) ([]Message, error)
+	// Send posts a reply to a chat, splitting it if the service needs to.
+	Send(ctx context.Context, chatID, text string) error
+}
+
+// Options configures a Bot.
+type Options struct {
+	// Cache is read before fetching from LINQ Connect. Optional.
+	Cache *cache.Cache
+	// Defaults are the school and time zone for chats that have not set
+	// their own.
+	Defaults Chat
+	Logger   *logrus.Logger
+}
+
+// Bot answers menu commands and sends subscriptions.
+type Bot struct {
+	store    *store
+	cache    *cache.Cache
+	defaults Chat
+	logger   *logrus.Logger
+}
+
+// checkInterval is how often subscriptions are checked.
+const checkInterval = time.Minute
+
+// retryDelay is how long a transport waits after a failed receive.
+const retryDelay = 5 * time.Second
+
+// New returns a Bot keeping chat settings in the JSON file at statePath.
+func New(statePath string, opts Options) (*Bot, error) {
+	s, err := loadStore(statePath)
+	if err != nil {
+		return nil, err
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	return &Bot{store: s, cache: opts.Cache, defaults: opts.Defaults, logger: logger}, nil
+}
+
+// Run answers messages from every transport and sends subscriptions until
+// ctx is done.
+func (b *Bot) Run(ctx context.Context, transports ...Transport) error {
+	if len(transports) == 0 {
+		return fmt.Errorf("no chat services configured")
+	}
+	byName := make(map[string]Transport, len(transports))
+	var wg sync.WaitGroup
+	for _, t := range transports {
+		byName[t.Name()] = t
+		wg.Add(1)
+		go func(t Transport) {
+			defer wg.Done()
+			b.serve(ctx, t)
+		}(t)
+	}
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		b.deliver(ctx, byName, time.Now())
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil
+		}
+	}
+}
+
+// serve answers one transport's messages until ctx is done.
+func (b *Bot) serve(ctx context.Context, t Transport) {
+	b.logger.WithField("service", t.Name()).Info("Bot listening")
+	for ctx.Err() == nil {
+		msgs, err := t.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			b.logger.WithError(err).WithField("service", t.Name()).Warn("Receiving messages failed")
+			select {
+			case <-time.After(retryDelay):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		for _, msg := range msgs {
+			reply := b.Reply(t.Name(), msg.ChatID, msg.Text)
+			if reply == "" {
+				continue
+			}
+			if err := t.Send(ctx, msg.ChatID, reply); err != nil {
+				b.logger.WithError(err).WithFields(logrus.Fields{"service": t.Name(), "chat": msg.ChatID}).Warn("Sending reply failed")
+			}
+		}
+	}
+}
+
+// deliver sends the subscriptions that are due at now.
+func (b *Bot) deliver(ctx context.Context, transports map[string]Transport, now time.Time) {
+	for _, key := range b.store.subscribed() {
+		service, chatID, _ := strings.Cut(key, ":")
+		t, ok := transports[service]
+		if !ok {
+			continue
+		}
+		chat := b.store.get(key).withDefaults(b.defaults)
+		local, err := chatTime(chat, now)
+		if err != nil {
+			continue
+		}
+
+		for _, sub := range chat.Subscriptions {
+			date, due := sub.due(local)
+			if !due {
+				continue
+			}
+			fields := logrus.Fields{"service": service, "chat": chatID, "meal": sub.Meal, "date": date.Format(menu.DateLayout)}
+			text, err := b.menuText(chat, date, date, sub.Meal)
+			if err != nil {
+				// Try again on the next check.
+				b.logger.WithError(err).WithFields(fields).Warn("Fetching subscribed menu failed")
+				continue
+			}
+			if text != "" {
+				if err := t.Send(ctx, chatID, text); err != nil {
+					b.logger.WithError(err).WithFields(fields).Warn("Sending subscribed menu failed")
+					continue
+				}
+				b.logger.WithFields(fields).Info("Sent subscribed menu")
+			}
+			b.markSent(key, sub, date)
+		}
+	}
+}
+
+// markSent records that a subscription's menu for date was sent, or that
+// there was none to send.
+func (b *Bot) markSent(key string, sub Subscription, date time.Time) {
+	err := b.store.update(key, func(c *Chat) {
+		for i := range c.Subscriptions {
+			if c.Subscriptions[i].Meal == sub.Meal && c.Subscriptions[i].At == sub.At {
+				c.Subscriptions[i].LastSent = date.Format(menu.DateLayout)
+			}
+		}
+	})
+	if err != nil {
+		b.logger.WithError(err).Warn("Saving bot state failed")
+	}
+}
+
+// days returns the menu for a meal on each day from start to end, one
+// cached fetch per day as the web server reads them.
+func (b *Bot) days(chat Chat, start, end time.Time, meal string) ([]menu.Day, error) {
+	var days []menu.Day
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+		dateStr := date.Format("01-02-2006")
+		var m *menu.Menu
+		if b.cache != nil {
+			m, _ = b.cache.Get(chat.BuildingID, chat.DistrictID, dateStr, dateStr)
+		}
+		if m == nil {
+			var err error
+			if m, err = menu.Fetch(chat.BuildingID, chat.DistrictID, dateStr, dateStr, false); err != nil {
+				return nil, err
+			}
+			if b.cache != nil {
+				b.cache.Set(chat.BuildingID, chat.DistrictID, dateStr, dateStr, m)
+			}
+		}
+		if day, ok := m.DayFor(date, meal); ok {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
@@ -0,0 +1,328 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/render"
+)
+
+const helpText = `I send school menus.
+
+/today [meal] - today's menu
+/tomorrow [meal] - tomorrow's menu
+/week [meal] - this week's menu, or next week's at the weekend
+/menu DATE [meal] - the menu for a date, e.g. next-school-day or 2024-09-04
+/subscribe MEAL TIME - the menu every school day, e.g. /subscribe lunch 7am
+/unsubscribe [meal] - stop subscriptions
+/school BUILDING_ID DISTRICT_ID [name] - choose the school
+/tz ZONE - set the time zone, e.g. America/Chicago
+/settings - show this chat's settings
+
+Meals are breakfast, lunch (the default) and snack. Subscriptions before
+noon send that day's menu; later ones send the next school day's.`
+
+// meals are the serving sessions a command can name.
+var meals = []string{"Breakfast", "Lunch", "Snack"}
+
+// Reply returns the bot's answer to a message in a chat, or "" if the
+// message is not a command. Commands start with / (Telegram) or !
+// (Matrix), and may be addressed as /today@MenuBot.
+func (b *Bot) Reply(service, chatID, text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || (fields[0][0] != '/' && fields[0][0] != '!') {
+		return ""
+	}
+	cmd, _, _ := strings.Cut(strings.ToLower(fields[0][1:]), "@")
+	args := fields[1:]
+	key := service + ":" + chatID
+
+	reply, err := b.command(key, cmd, args)
+	if err != nil {
+		return err.Error()
+	}
+	return reply
+}
+
+func (b *Bot) command(key, cmd string, args []string) (string, error) {
+	chat := b.store.get(key).withDefaults(b.defaults)
+
+	switch cmd {
+	case "start", "help":
+		return helpText, nil
+	case "settings":
+		return settingsText(chat), nil
+	case "school":
+		if len(args) < 2 {
+			return "", fmt.Errorf("Usage: /school BUILDING_ID DISTRICT_ID [name]")
+		}
+		name := strings.Join(args[2:], " ")
+		err := b.store.update(key, func(c *Chat) {
+			c.BuildingID, c.DistrictID, c.School = args[0], args[1], name
+		})
+		if err != nil {
+			return "", err
+		}
+		if name == "" {
+			name = args[0]
+		}
+		return "School set to " + name + ".", nil
+	case "tz":
+		if len(args) != 1 {
+			return "", fmt.Errorf("Usage: /tz ZONE, e.g. /tz America/Chicago")
+		}
+		if _, err := time.LoadLocation(args[0]); err != nil {
+			return "", fmt.Errorf("Unknown time zone %q.", args[0])
+		}
+		if err := b.store.update(key, func(c *Chat) { c.Timezone = args[0] }); err != nil {
+			return "", err
+		}
+		return "Time zone set to " + args[0] + ".", nil
+	case "subscribe":
+		return b.subscribe(key, chat, args)
+	case "unsubscribe":
+		return b.unsubscribe(key, chat, args)
+	case "today", "tomorrow", "week", "menu":
+		return b.menuCommand(chat, cmd, args)
+	}
+	return "", fmt.Errorf("Unknown command /%s. Send /help for the list.", cmd)
+}
+
+// menuCommand answers /today, /tomorrow, /week and /menu.
+func (b *Bot) menuCommand(chat Chat, cmd string, args []string) (string, error) {
+	if chat.BuildingID == "" {
+		return "", fmt.Errorf("Choose a school first with /school BUILDING_ID DISTRICT_ID [name].")
+	}
+	now, err := chatTime(chat, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	expr := cmd
+	switch cmd {
+	case "today":
+		expr = dates.Today
+	case "tomorrow":
+		expr = dates.Tomorrow
+	case "week":
+		expr = dates.ThisWeek
+		if dates.Weekend(dates.Day(now)) {
+			expr = dates.NextWeek
+		}
+	case "menu":
+		if len(args) == 0 {
+			return "", fmt.Errorf("Usage: /menu DATE [meal], e.g. /menu next-school-day")
+		}
+		expr, args = args[0], args[1:]
+	}
+	start, end, err := dates.Range(expr, now)
+	if err != nil {
+		return "", fmt.Errorf("I don't know the date %q. Try today, tomorrow, next-week, +3d or 2024-09-04.", expr)
+	}
+	if end.Sub(start) > 31*24*time.Hour {
+		return "", fmt.Errorf("That's too many days; ask for a month or less.")
+	}
+
+	meal := "Lunch"
+	if len(args) > 0 {
+		if meal, err = parseMeal(args[0]); err != nil {
+			return "", err
+		}
+	}
+
+	text, err := b.menuText(chat, start, end, meal)
+	if err != nil {
+		return "", fmt.Errorf("Sorry, I couldn't get the menu right now. Please try again later.")
+	}
+	if text == "" {
+		if start.Equal(end) {
+			return fmt.Sprintf("No %s menu for %s.", strings.ToLower(meal), start.Format("Monday, January 2")), nil
+		}
+		return fmt.Sprintf("No %s menu from %s to %s.", strings.ToLower(meal), start.Format("January 2"), end.Format("January 2")), nil
+	}
+	return text, nil
+}
+
+// menuText renders a meal's menu for each day from start to end as plain
+// text, or returns "" if there is none.
+func (b *Bot) menuText(chat Chat, start, end time.Time, meal string) (string, error) {
+	days, err := b.days(chat, start, end, meal)
+	if err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, day := range days {
+		if text := render.SessionText(day, meal); text != "" {
+			parts = append(parts, strings.TrimSpace(text))
+		}
+	}
+	text := strings.Join(parts, "\n\n")
+	if text != "" && chat.School != "" {
+		text = chat.School + "\n\n" + text
+	}
+	return text, nil
+}
+
+func (b *Bot) subscribe(key string, chat Chat, args []string) (string, error) {
+	if len(args) < 2 {
+		return "", fmt.Errorf("Usage: /subscribe MEAL TIME, e.g. /subscribe lunch 7am")
+	}
+	if chat.BuildingID == "" {
+		return "", fmt.Errorf("Choose a school first with /school BUILDING_ID DISTRICT_ID [name].")
+	}
+	meal, err := parseMeal(args[0])
+	if err != nil {
+		return "", err
+	}
+	at, err := parseClock(strings.Join(args[1:], ""))
+	if err != nil {
+		return "", err
+	}
+	now, err := chatTime(chat, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	sub := Subscription{Meal: meal, At: at}
+	// Don't send a menu that is already past due straight away.
+	if date, due := sub.due(now); due {
+		sub.LastSent = date.Format(menu.DateLayout)
+	}
+	err = b.store.update(key, func(c *Chat) {
+		kept := []Subscription{sub}
+		for _, s := range c.Subscriptions {
+			if s.Meal != meal {
+				kept = append(kept, s)
+			}
+		}
+		c.Subscriptions = kept
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("You'll get the %s menu at %s every school day (%s).", strings.ToLower(meal), at, now.Location()), nil
+}
+
+func (b *Bot) unsubscribe(key string, chat Chat, args []string) (string, error) {
+	meal := ""
+	if len(args) > 0 {
+		var err error
+		if meal, err = parseMeal(args[0]); err != nil {
+			return "", err
+		}
+	}
+	removed := 0
+	err := b.store.update(key, func(c *Chat) {
+		var kept []Subscription
+		for _, s := range c.Subscriptions {
+			if meal != "" && s.Meal != meal {
+				kept = append(kept, s)
+			}
+		}
+		removed = len(c.Subscriptions) - len(kept)
+		c.Subscriptions = kept
+	})
+	if err != nil {
+		return "", err
+	}
+	if removed == 0 {
+		return "You have no subscriptions to stop.", nil
+	}
+	return fmt.Sprintf("Stopped %d subscription(s).", removed), nil
+}
+
+func settingsText(chat Chat) string {
+	var b strings.Builder
+	switch {
+	case chat.BuildingID == "":
+		b.WriteString("School: not set\n")
+	case chat.School != "":
+		fmt.Fprintf(&b, "School: %s (%s, %s)\n", chat.School, chat.BuildingID, chat.DistrictID)
+	default:
+		fmt.Fprintf(&b, "School: %s, %s\n", chat.BuildingID, chat.DistrictID)
+	}
+	tz := chat.Timezone
+	if tz == "" {
+		tz = "server default"
+	}
+	fmt.Fprintf(&b, "Time zone: %s\n", tz)
+	if len(chat.Subscriptions) == 0 {
+		b.WriteString("Subscriptions: none")
+	}
+	for _, s := range chat.Subscriptions {
+		fmt.Fprintf(&b, "Subscription: %s at %s", strings.ToLower(s.Meal), s.At)
+		b.WriteString("\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// chatTime returns now in the chat's time zone.
+func chatTime(chat Chat, now time.Time) (time.Time, error) {
+	loc, err := dates.Location(chat.Timezone)
+	if err != nil {
+		return now, err
+	}
+	return now.In(loc), nil
+}
+
+// due returns the menu date a subscription should send at local time now,
+// and whether it is due: its time has passed today and that date has not
+// been sent yet. Morning subscriptions send the day's menu; from noon they
+// send the next school day's.
+func (s Subscription) due(now time.Time) (time.Time, bool) {
+	today := dates.Day(now)
+	date := today
+	if s.At >= "12:00" {
+		date = dates.SchoolDayAfter(today, nil)
+	} else if dates.Weekend(today) {
+		return date, false
+	}
+	if now.Format("15:04") < s.At || s.LastSent == date.Format(menu.DateLayout) {
+		return date, false
+	}
+	return date, true
+}
+
+// parseMeal matches a meal name such as lunch to its serving session.
+func parseMeal(s string) (string, error) {
+	for _, m := range meals {
+		if strings.EqualFold(s, m) {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("Unknown meal %q. Use breakfast, lunch or snack.", s)
+}
+
+var clockPattern = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+
+// parseClock parses a time of day such as 7am, 7:30pm or 18:00 as HH:MM.
+func parseClock(s string) (string, error) {
+	m := clockPattern.FindStringSubmatch(strings.ToLower(s))
+	if m == nil {
+		return "", fmt.Errorf("I don't understand the time %q. Try 7am, 6:30pm or 18:00.", s)
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	switch m[3] {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return "", fmt.Errorf("I don't understand the time %q. Try 7am, 6:30pm or 18:00.", s)
+		}
+		hour %= 12
+		if m[3] == "pm" {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return "", fmt.Errorf("I don't understand the time %q. Try 7am, 6:30pm or 18:00.", s)
+	}
+	return fmt.Sprintf("%02d:%02d", hour, minute), nil
+}
@@ -0,0 +1,156 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Matrix receives and sends messages through a Matrix homeserver's
+// client-server API as the user the access token belongs to. It joins
+// rooms it is invited to, and answers commands starting with ! as well as /.
+type Matrix struct {
+	// Homeserver is the base URL, e.g. https://matrix.example.org.
+	Homeserver string
+	Token      string
+	// Client defaults to one whose timeout outlasts the long poll.
+	Client *http.Client
+
+	userID string
+	since  string
+	// txn numbers sent messages, so the homeserver can tell a retry from
+	// a new message. Replies and subscribed menus are sent from different
+	// goroutines.
+	txn atomic.Int64
+}
+
+func (m *Matrix) Name() string { return "matrix" }
+
+type matrixSync struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []struct {
+					Type    string `json:"type"`
+					Sender  string `json:"sender"`
+					Content struct {
+						MsgType string `json:"msgtype"`
+						Body    string `json:"body"`
+					} `json:"content"`
+				} `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+		Invite map[string]json.RawMessage `json:"invite"`
+	} `json:"rooms"`
+}
+
+func (m *Matrix) Receive(ctx context.Context) ([]Message, error) {
+	if m.userID == "" {
+		var who struct {
+			UserID string `json:"user_id"`
+		}
+		if err := m.call(ctx, http.MethodGet, "/account/whoami", nil, &who); err != nil {
+			return nil, err
+		}
+		m.userID = who.UserID
+	}
+
+	// The first sync only finds where the timeline ends, so messages sent
+	// while the bot was down are not answered late.
+	first := m.since == ""
+	query := url.Values{"timeout": {"0"}}
+	if !first {
+		query.Set("since", m.since)
+		query.Set("timeout", strconv.FormatInt(pollTimeout.Milliseconds(), 10))
+	}
+	var sync matrixSync
+	if err := m.call(ctx, http.MethodGet, "/sync?"+query.Encode(), nil, &sync); err != nil {
+		return nil, err
+	}
+	m.since = sync.NextBatch
+
+	for room := range sync.Rooms.Invite {
+		if err := m.call(ctx, http.MethodPost, "/rooms/"+url.PathEscape(room)+"/join", struct{}{}, nil); err != nil {
+			return nil, err
+		}
+	}
+	if first {
+		return nil, nil
+	}
+
+	var msgs []Message
+	for room, joined := range sync.Rooms.Join {
+		for _, ev := range joined.Timeline.Events {
+			if ev.Type != "m.room.message" || ev.Content.MsgType != "m.text" || ev.Sender == m.userID {
+				continue
+			}
+			msgs = append(msgs, Message{ChatID: room, Text: ev.Content.Body})
+		}
+	}
+	return msgs, nil
+}
+
+func (m *Matrix) Send(ctx context.Context, roomID, text string) error {
+	m.txn.CompareAndSwap(0, time.Now().UnixNano())
+	txn := m.txn.Add(1)
+	content := map[string]string{"msgtype": "m.text", "body": text}
+	path := fmt.Sprintf("/rooms/%s/send/m.room.message/%d", url.PathEscape(roomID), txn)
+	return m.call(ctx, http.MethodPut, path, content, nil)
+}
+
+// call makes a client-server API request and decodes the response into out.
+func (m *Matrix) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	endpoint := strings.TrimSuffix(m.Homeserver, "/") + "/_matrix/client/v3" + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return fmt.Errorf("matrix: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+m.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := m.Client
+	if client == nil {
+		client = &http.Client{Timeout: 2 * pollTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("matrix: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&e)
+		if e.Error == "" {
+			e.Error = resp.Status
+		}
+		return fmt.Errorf("matrix %s %s: %s", method, strings.SplitN(path, "?", 2)[0], e.Error)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("matrix: decoding response: %w", err)
+		}
+	}
+	return nil
+}
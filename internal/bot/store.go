@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Chat is what the bot remembers about one chat: its school and
+// subscriptions. Empty fields fall back to Options.Defaults.
+type Chat struct {
+	School        string         `json:"school,omitempty"`
+	BuildingID    string         `json:"buildingId,omitempty"`
+	DistrictID    string         `json:"districtId,omitempty"`
+	Timezone      string         `json:"timezone,omitempty"`
+	Subscriptions []Subscription `json:"subscriptions,omitempty"`
+}
+
+// Subscription sends a meal's menu to a chat every school day.
+type Subscription struct {
+	// Meal is the serving session, e.g. Lunch.
+	Meal string `json:"meal"`
+	// At is the time of day to send, as HH:MM in the chat's time zone.
+	// Before noon it sends that day's menu, from noon the next school day's.
+	At string `json:"at"`
+	// LastSent is the menu date last sent, as YYYY-MM-DD, so each day's
+	// menu is sent once.
+	LastSent string `json:"lastSent,omitempty"`
+}
+
+// withDefaults fills the chat's unset school and time zone from d.
+func (c Chat) withDefaults(d Chat) Chat {
+	if c.BuildingID == "" {
+		c.BuildingID, c.DistrictID, c.School = d.BuildingID, d.DistrictID, d.School
+	}
+	if c.Timezone == "" {
+		c.Timezone = d.Timezone
+	}
+	return c
+}
+
+// store persists chat settings as JSON, keyed by service and chat ID.
+type store struct {
+	path  string
+	mu    sync.Mutex
+	chats map[string]Chat
+}
+
+func loadStore(path string) (*store, error) {
+	s := &store{path: path, chats: make(map[string]Chat)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading bot state: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.chats); err != nil {
+		return nil, fmt.Errorf("parsing bot state: %w", err)
+	}
+	return s, nil
+}
+
+func (s *store) get(key string) Chat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.chats[key]
+}
+
+// subscribed returns the keys of chats with subscriptions.
+func (s *store) subscribed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key, c := range s.chats {
+		if len(c.Subscriptions) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// update changes a chat's settings and writes the file. The file is
+// replaced atomically so a crash cannot leave it half-written.
+func (s *store) update(key string, change func(*Chat)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.chats[key]
+	change(&c)
+	s.chats[key] = c
+
+	data, err := json.MarshalIndent(s.chats, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating state dir: %w", err)
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("writing bot state: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
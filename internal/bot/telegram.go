@@ -0,0 +1,158 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTelegramAPI is the Telegram Bot API server.
+const DefaultTelegramAPI = "https://api.telegram.org"
+
+// telegramMaxText is the longest message Telegram accepts.
+const telegramMaxText = 4096
+
+// pollTimeout is how long a long poll waits for messages.
+const pollTimeout = 30 * time.Second
+
+// Telegram receives and sends messages through the Telegram Bot API using
+// long polling.
+type Telegram struct {
+	Token string
+	// APIURL defaults to DefaultTelegramAPI; set it to use a local Bot API
+	// server or a stand-in.
+	APIURL string
+	// Client defaults to one whose timeout outlasts the long poll.
+	Client *http.Client
+
+	offset int64
+}
+
+func (t *Telegram) Name() string { return "telegram" }
+
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+func (t *Telegram) Receive(ctx context.Context) ([]Message, error) {
+	params := map[string]interface{}{
+		"timeout":         int(pollTimeout.Seconds()),
+		"allowed_updates": []string{"message"},
+	}
+	if t.offset != 0 {
+		params["offset"] = t.offset
+	}
+	var updates []telegramUpdate
+	if err := t.call(ctx, "getUpdates", params, &updates); err != nil {
+		return nil, err
+	}
+
+	var msgs []Message
+	for _, u := range updates {
+		t.offset = u.UpdateID + 1
+		if u.Message == nil || u.Message.Text == "" {
+			continue
+		}
+		msgs = append(msgs, Message{ChatID: strconv.FormatInt(u.Message.Chat.ID, 10), Text: u.Message.Text})
+	}
+	return msgs, nil
+}
+
+func (t *Telegram) Send(ctx context.Context, chatID, text string) error {
+	for _, part := range split(text, telegramMaxText) {
+		params := map[string]interface{}{"chat_id": chatID, "text": part}
+		if err := t.call(ctx, "sendMessage", params, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call invokes a Bot API method and decodes its result into out.
+func (t *Telegram) call(ctx context.Context, method string, params interface{}, out interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	api := t.APIURL
+	if api == "" {
+		api = DefaultTelegramAPI
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(api, "/")+"/bot"+t.Token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("telegram %s: %w", method, t.redact(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := t.Client
+	if client == nil {
+		client = &http.Client{Timeout: 2 * pollTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram %s: %w", method, t.redact(err))
+	}
+	defer resp.Body.Close()
+
+	var r telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("telegram %s: %s", method, resp.Status)
+	}
+	if !r.OK {
+		return fmt.Errorf("telegram %s: %s", method, r.Description)
+	}
+	if out != nil {
+		if err := json.Unmarshal(r.Result, out); err != nil {
+			return fmt.Errorf("telegram %s: decoding result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// redact removes the bot token, which is part of every request URL, from
+// an error so it does not end up in logs.
+func (t *Telegram) redact(err error) error {
+	var urlErr *url.Error
+	if t.Token == "" || !errors.As(err, &urlErr) {
+		return err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), t.Token, "REDACTED"))
+}
+
+// split breaks text into parts of at most max bytes, at line breaks where
+// it can.
+func split(text string, max int) []string {
+	var parts []string
+	for len(text) > max {
+		cut := strings.LastIndex(text[:max], "\n")
+		if cut <= 0 {
+			cut = max
+			// Don't cut a UTF-8 sequence in half.
+			for cut > 0 && text[cut]&0xC0 == 0x80 {
+				cut--
+			}
+		}
+		parts = append(parts, text[:cut])
+		text = strings.TrimLeft(text[cut:], "\n")
+	}
+	return append(parts, text)
+}
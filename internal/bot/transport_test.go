@@ -0,0 +1,252 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// apiCall is one request a fake chat API received.
+type apiCall struct {
+	method, path, query string
+	header              http.Header
+	body                map[string]interface{}
+}
+
+// fakeAPI serves canned responses by "METHOD /path" and records the calls.
+type fakeAPI struct {
+	*httptest.Server
+	mu        sync.Mutex
+	calls     []apiCall
+	responses map[string][]string
+}
+
+func newFakeAPI(t *testing.T, responses map[string][]string) *fakeAPI {
+	api := &fakeAPI{responses: responses}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := apiCall{method: r.Method, path: r.URL.Path, query: r.URL.RawQuery, header: r.Header.Clone()}
+		if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+			json.Unmarshal(data, &call.body)
+		}
+
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		api.calls = append(api.calls, call)
+		key := r.Method + " " + r.URL.Path
+		if strings.Contains(r.URL.Path, "/send/") {
+			key = r.Method + " send"
+		}
+		queue := api.responses[key]
+		if len(queue) == 0 {
+			http.Error(w, `{"errcode":"M_NOT_FOUND","error":"no response for `+key+`"}`, http.StatusNotFound)
+			return
+		}
+		resp := queue[0]
+		if len(queue) > 1 {
+			api.responses[key] = queue[1:]
+		}
+		if status, body, ok := strings.Cut(resp, " "); ok && len(status) == 3 && status[0] >= '4' {
+			var code int
+			fmt.Sscan(status, &code)
+			w.WriteHeader(code)
+			resp = body
+		}
+		io.WriteString(w, resp)
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+func (api *fakeAPI) recorded() []apiCall {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return append([]apiCall(nil), api.calls...)
+}
+
+func TestTelegramReceive(t *testing.T) {
+	api := newFakeAPI(t, map[string][]string{
+		"POST /botSECRET/getUpdates": {
+			`{"ok":true,"result":[{"update_id":10,"message":{"text":"/today","chat":{"id":42}}},{"update_id":11},{"update_id":12,"message":{"text":"/week","chat":{"id":-7}}}]}`,
+			`{"ok":true,"result":[]}`,
+		},
+	})
+	tg := &Telegram{Token: "SECRET", APIURL: api.URL + "/"}
+
+	msgs, err := tg.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Message{{ChatID: "42", Text: "/today"}, {ChatID: "-7", Text: "/week"}}
+	if fmt.Sprint(msgs) != fmt.Sprint(want) {
+		t.Errorf("messages = %v, want %v", msgs, want)
+	}
+	if _, err := tg.Receive(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := api.recorded()
+	if len(calls) != 2 {
+		t.Fatalf("got %d calls, want 2", len(calls))
+	}
+	if _, ok := calls[0].body["offset"]; ok {
+		t.Errorf("first poll sent an offset: %v", calls[0].body)
+	}
+	if calls[0].body["timeout"] != float64(30) || calls[0].header.Get("Content-Type") != "application/json" {
+		t.Errorf("first poll = %v", calls[0].body)
+	}
+	if calls[1].body["offset"] != float64(13) {
+		t.Errorf("second poll offset = %v, want 13 to acknowledge every update", calls[1].body["offset"])
+	}
+}
+
+func TestTelegramSend(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		responses []string
+		wantParts int
+		wantErr   string
+	}{
+		{"short", "Lunch: Pizza", []string{`{"ok":true,"result":{}}`}, 1, ""},
+		{"split at lines", strings.Repeat(strings.Repeat("x", 99)+"\n", 50), []string{`{"ok":true,"result":{}}`}, 2, ""},
+		{"api error", "hi", []string{`{"ok":false,"description":"Bad Request: chat not found"}`}, 1, "telegram sendMessage: Bad Request: chat not found"},
+		{"not json", "hi", []string{`502 <html>bad gateway</html>`}, 1, "telegram sendMessage: 502 Bad Gateway"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeAPI(t, map[string][]string{"POST /botSECRET/sendMessage": tt.responses})
+			tg := &Telegram{Token: "SECRET", APIURL: api.URL}
+			err := tg.Send(context.Background(), "42", tt.text)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+
+			calls := api.recorded()
+			if len(calls) != tt.wantParts {
+				t.Fatalf("sent %d parts, want %d", len(calls), tt.wantParts)
+			}
+			var joined []string
+			for _, c := range calls {
+				text, _ := c.body["text"].(string)
+				if len(text) > telegramMaxText || c.body["chat_id"] != "42" {
+					t.Errorf("part = %d bytes to %v", len(text), c.body["chat_id"])
+				}
+				joined = append(joined, text)
+			}
+			if tt.wantErr == "" && strings.Join(joined, "\n") != strings.TrimRight(tt.text, "\n") && strings.Join(joined, "\n") != tt.text {
+				t.Error("parts do not add up to the text")
+			}
+		})
+	}
+}
+
+func TestTelegramRedactsToken(t *testing.T) {
+	api := newFakeAPI(t, nil)
+	api.Close()
+	tg := &Telegram{Token: "123:SECRET", APIURL: api.URL}
+	err := tg.Send(context.Background(), "42", "hi")
+	if err == nil || strings.Contains(err.Error(), "SECRET") || !strings.Contains(err.Error(), "REDACTED") {
+		t.Errorf("err = %v, want the token redacted", err)
+	}
+}
+
+func TestMatrixReceive(t *testing.T) {
+	api := newFakeAPI(t, map[string][]string{
+		"GET /_matrix/client/v3/account/whoami": {`{"user_id":"@menubot:example.org"}`},
+		"GET /_matrix/client/v3/sync": {
+			`{"next_batch":"s1","rooms":{"invite":{"!new:example.org":{}},"join":{"!old:example.org":{"timeline":{"events":[{"type":"m.room.message","sender":"@parent:example.org","content":{"msgtype":"m.text","body":"!today"}}]}}}}}`,
+			`{"next_batch":"s2","rooms":{"join":{"!room:example.org":{"timeline":{"events":[
+				{"type":"m.room.message","sender":"@parent:example.org","content":{"msgtype":"m.text","body":"!week"}},
+				{"type":"m.room.message","sender":"@menubot:example.org","content":{"msgtype":"m.text","body":"Lunch: Pizza"}},
+				{"type":"m.room.message","sender":"@parent:example.org","content":{"msgtype":"m.image","body":"photo.jpg"}},
+				{"type":"m.room.member","sender":"@parent:example.org","content":{}}
+			]}}}}}`,
+		},
+		"POST /_matrix/client/v3/rooms/!new:example.org/join": {`{"room_id":"!new:example.org"}`},
+	})
+	m := &Matrix{Homeserver: api.URL + "/", Token: "tok"}
+
+	// The first sync only catches up, so the old message is not answered.
+	msgs, err := m.Receive(context.Background())
+	if err != nil || len(msgs) != 0 {
+		t.Fatalf("first Receive = %v, %v; want nothing", msgs, err)
+	}
+	msgs, err = m.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Message{{ChatID: "!room:example.org", Text: "!week"}}; fmt.Sprint(msgs) != fmt.Sprint(want) {
+		t.Errorf("messages = %v, want %v", msgs, want)
+	}
+
+	var paths []string
+	for _, c := range api.recorded() {
+		if c.header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("%s %s without the access token", c.method, c.path)
+		}
+		p := c.method + " " + c.path
+		if c.query != "" {
+			p += "?" + c.query
+		}
+		paths = append(paths, p)
+	}
+	want := []string{
+		"GET /_matrix/client/v3/account/whoami",
+		"GET /_matrix/client/v3/sync?timeout=0",
+		"POST /_matrix/client/v3/rooms/!new:example.org/join",
+		"GET /_matrix/client/v3/sync?since=s1&timeout=30000",
+	}
+	if strings.Join(paths, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls:\n%s\nwant:\n%s", strings.Join(paths, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMatrixSend(t *testing.T) {
+	const n = 20
+	var ok []string
+	for i := 0; i < n; i++ {
+		ok = append(ok, `{"event_id":"$e"}`)
+	}
+	api := newFakeAPI(t, map[string][]string{"PUT send": ok})
+	m := &Matrix{Homeserver: api.URL, Token: "tok"}
+
+	// Replies and subscribed menus are sent from different goroutines.
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := m.Send(context.Background(), "!room:example.org", fmt.Sprint("menu ", i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	txns := make(map[string]bool)
+	for _, c := range api.recorded() {
+		prefix := "/_matrix/client/v3/rooms/!room:example.org/send/m.room.message/"
+		if !strings.HasPrefix(c.path, prefix) || c.body["msgtype"] != "m.text" {
+			t.Errorf("%s %s %v", c.method, c.path, c.body)
+		}
+		txns[strings.TrimPrefix(c.path, prefix)] = true
+	}
+	if len(txns) != n {
+		t.Errorf("%d distinct transaction IDs for %d messages", len(txns), n)
+	}
+}
+
+func TestMatrixError(t *testing.T) {
+	api := newFakeAPI(t, map[string][]string{"PUT send": {`403 {"errcode":"M_FORBIDDEN","error":"not in room"}`}})
+	m := &Matrix{Homeserver: api.URL, Token: "tok"}
+	err := m.Send(context.Background(), "!room:example.org", "hi")
+	if err == nil || !strings.HasSuffix(err.Error(), ": not in room") || !strings.HasPrefix(err.Error(), "matrix PUT /rooms/") {
+		t.Errorf("err = %v, want the homeserver's error", err)
+	}
+}
@@ -17,10 +17,24 @@ const (
 	defaultCacheDir = "/tmp/menu-cache"
 )
 
-// entry wraps a cached menu with its expiration time.
+// entry wraps a cached menu with its expiration time and the request it
+// answers.
 type entry struct {
-	Menu      *menu.Menu `json:"menu"`
-	ExpiresAt time.Time  `json:"expires_at"`
+	Menu       *menu.Menu `json:"menu"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	BuildingID string     `json:"building_id,omitempty"`
+	DistrictID string     `json:"district_id,omitempty"`
+	StartDate  string     `json:"start_date,omitempty"`
+	EndDate    string     `json:"end_date,omitempty"`
+}
+
+// Entry is a cached menu and the school and dates it was fetched for.
+type Entry struct {
+	BuildingID string
+	DistrictID string
+	StartDate  string
+	EndDate    string
+	Menu       *menu.Menu
 }
 
 // Cache provides a file-backed menu cache with in-memory reads.
@@ -83,8 +97,12 @@ func (c *Cache) Set(buildingID, districtID, startDate, endDate string, m *menu.M
 	defer c.mu.Unlock()
 
 	e := entry{
-		Menu:      m,
-		ExpiresAt: time.Now().Add(c.ttl),
+		Menu:       m,
+		ExpiresAt:  time.Now().Add(c.ttl),
+		BuildingID: buildingID,
+		DistrictID: districtID,
+		StartDate:  startDate,
+		EndDate:    endDate,
 	}
 
 	data, err := json.Marshal(e)
@@ -95,3 +113,51 @@ func (c *Cache) Set(buildingID, districtID, startDate, endDate string, m *menu.M
 	path := filepath.Join(c.dir, cacheKey(buildingID, districtID, startDate, endDate))
 	_ = os.WriteFile(path, data, 0644)
 }
+
+// Entries returns every cached menu that has not expired. Entries written
+// before the cache recorded their school are skipped.
+func (c *Cache) Entries() ([]Entry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	paths, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var entries []Entry
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var e entry
+		if err := json.Unmarshal(data, &e); err != nil || e.Menu == nil || e.BuildingID == "" || now.After(e.ExpiresAt) {
+			continue
+		}
+		entries = append(entries, Entry{BuildingID: e.BuildingID, DistrictID: e.DistrictID, StartDate: e.StartDate, EndDate: e.EndDate, Menu: e.Menu})
+	}
+	return entries, nil
+}
+
+// Dir returns the directory the cache is stored in.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Clear removes every cached menu and returns how many were removed.
+func (c *Cache) Clear() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	for i, path := range paths {
+		if err := os.Remove(path); err != nil {
+			return i, fmt.Errorf("removing cache entry: %w", err)
+		}
+	}
+	return len(paths), nil
+}
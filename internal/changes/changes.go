@@ -0,0 +1,185 @@
+// Package changes detects edits to menus that were already published.
+//
+// Districts often swap recipes after a menu goes out. A Store keeps the
+// last-seen normalized menu for each school and date, and Compare reports
+// the recipes added and removed in each session, so families can be told
+// when an upcoming day changes.
+package changes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// DayDiff is the change to one day's menu.
+type DayDiff struct {
+	// Date is YYYY-MM-DD.
+	Date     string        `json:"date"`
+	Sessions []SessionDiff `json:"sessions"`
+}
+
+// SessionDiff lists the recipes added to and removed from a session. A
+// session that was dropped or newly served has every recipe removed or
+// added.
+type SessionDiff struct {
+	Session string   `json:"session"`
+	Added   []Recipe `json:"added,omitempty"`
+	Removed []Recipe `json:"removed,omitempty"`
+}
+
+// Recipe is a recipe in a diff, with where it is served.
+type Recipe struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Meal     string `json:"meal,omitempty"`
+}
+
+// key identifies a recipe within a session. A recipe moved to another
+// category counts as removed from one and added to the other.
+func (r Recipe) key() string {
+	return r.Meal + "\x00" + r.Category + "\x00" + r.Name
+}
+
+// Empty reports whether the day is unchanged.
+func (d DayDiff) Empty() bool {
+	return len(d.Sessions) == 0
+}
+
+// Compare returns the changes from old to new, which are the same day's
+// menu. Sessions appear in new's order, then any sessions only old had.
+func Compare(old, new menu.Day) DayDiff {
+	diff := DayDiff{Date: new.Date}
+	if diff.Date == "" {
+		diff.Date = old.Date
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, s := range append(append([]menu.Session{}, new.Sessions...), old.Sessions...) {
+		if !seen[s.Name] {
+			seen[s.Name] = true
+			names = append(names, s.Name)
+		}
+	}
+	for _, name := range names {
+		before, _ := old.Session(name)
+		after, _ := new.Session(name)
+		s := SessionDiff{Session: name}
+		s.Added, s.Removed = subtract(recipes(after), recipes(before)), subtract(recipes(before), recipes(after))
+		if len(s.Added) > 0 || len(s.Removed) > 0 {
+			diff.Sessions = append(diff.Sessions, s)
+		}
+	}
+	return diff
+}
+
+// CompareDays compares two snapshots of a date range day by day. A day in
+// only one of them has every recipe added or removed. Unchanged days are
+// left out.
+func CompareDays(old, new []menu.Day) []DayDiff {
+	byDate := make(map[string][2]menu.Day)
+	for _, d := range old {
+		pair := byDate[d.Date]
+		pair[0] = d
+		byDate[d.Date] = pair
+	}
+	for _, d := range new {
+		pair := byDate[d.Date]
+		pair[1] = d
+		byDate[d.Date] = pair
+	}
+	dateList := make([]string, 0, len(byDate))
+	for date := range byDate {
+		dateList = append(dateList, date)
+	}
+	sort.Strings(dateList)
+
+	var diffs []DayDiff
+	for _, date := range dateList {
+		pair := byDate[date]
+		if d := Compare(pair[0], pair[1]); !d.Empty() {
+			d.Date = date
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs
+}
+
+func recipes(s menu.Session) []Recipe {
+	var list []Recipe
+	for _, c := range s.Categories {
+		for _, item := range c.Recipes {
+			list = append(list, Recipe{Name: item.Name, Category: c.Name, Meal: c.Meal})
+		}
+	}
+	return list
+}
+
+// subtract returns the recipes in a that are not in b, counting
+// duplicates, so a recipe listed twice and then once is reported as
+// removed once.
+func subtract(a, b []Recipe) []Recipe {
+	counts := make(map[string]int)
+	for _, r := range b {
+		counts[r.key()]++
+	}
+	var out []Recipe
+	for _, r := range a {
+		if counts[r.key()] > 0 {
+			counts[r.key()]--
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// Text describes the changes for people, e.g.
+//
+//	Tuesday, October 20 · Lunch
+//	  + Cheese Pizza (Lunch Entree)
+//	  - Chicken Pot Pie (Lunch Entree)
+func Text(diffs []DayDiff) string {
+	var b strings.Builder
+	for i, d := range diffs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		day := menu.Day{Date: d.Date}
+		for _, s := range d.Sessions {
+			fmt.Fprintf(&b, "%s · %s\n", day.Time().Format("Monday, January 2"), s.Session)
+			meals := s.multipleMeals()
+			for _, r := range s.Added {
+				fmt.Fprintf(&b, "  + %s\n", r.label(meals))
+			}
+			for _, r := range s.Removed {
+				fmt.Fprintf(&b, "  - %s\n", r.label(meals))
+			}
+		}
+	}
+	return b.String()
+}
+
+// multipleMeals reports whether the changed recipes come from more than
+// one meal, such as Main and Combos, whose categories may share names.
+func (s SessionDiff) multipleMeals() bool {
+	all := append(append([]Recipe{}, s.Added...), s.Removed...)
+	for _, r := range all {
+		if r.Meal != all[0].Meal {
+			return true
+		}
+	}
+	return false
+}
+
+// label names the recipe with its category, and its meal if withMeal,
+// e.g. "Cheese Pizza (Combos · Lunch Entree)".
+func (r Recipe) label(withMeal bool) string {
+	if withMeal && r.Meal != "" {
+		return fmt.Sprintf("%s (%s · %s)", r.Name, r.Meal, r.Category)
+	}
+	return fmt.Sprintf("%s (%s)", r.Name, r.Category)
+}
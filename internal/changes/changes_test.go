@@ -0,0 +1,111 @@
+package changes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// lunch returns a day with one Lunch session. Each category in the Main
+// meal is given as its name followed by its recipes.
+func lunch(date string, categories ...[]string) menu.Day {
+	s := menu.Session{Name: "Lunch"}
+	for _, c := range categories {
+		cat := menu.Category{Name: c[0], Meal: "Main"}
+		for _, name := range c[1:] {
+			cat.Recipes = append(cat.Recipes, menu.Item{Name: name})
+		}
+		s.Categories = append(s.Categories, cat)
+	}
+	return menu.Day{Date: date, Sessions: []menu.Session{s}}
+}
+
+func TestCompare(t *testing.T) {
+	breakfast := menu.Session{Name: "Breakfast", Categories: []menu.Category{{Name: "Entree", Meal: "Main", Recipes: []menu.Item{{Name: "Waffles"}}}}}
+	withBreakfast := lunch("2024-09-04", []string{"Entree", "Pizza"})
+	withBreakfast.Sessions = append(withBreakfast.Sessions, breakfast)
+
+	tests := []struct {
+		name     string
+		old, new menu.Day
+		want     string
+	}{
+		{"unchanged", lunch("2024-09-04", []string{"Entree", "Pizza"}), lunch("2024-09-04", []string{"Entree", "Pizza"}), "2024-09-04 []"},
+		{"swapped", lunch("2024-09-04", []string{"Entree", "Pizza"}), lunch("2024-09-04", []string{"Entree", "Tacos"}),
+			"2024-09-04 [{Lunch [{Tacos Entree Main}] [{Pizza Entree Main}]}]"},
+		{"moved category", lunch("2024-09-04", []string{"Entree", "Pizza"}), lunch("2024-09-04", []string{"Alternate", "Pizza"}),
+			"2024-09-04 [{Lunch [{Pizza Alternate Main}] [{Pizza Entree Main}]}]"},
+		{"duplicate removed once", lunch("2024-09-04", []string{"Fruit", "Apple", "Apple"}), lunch("2024-09-04", []string{"Fruit", "Apple"}),
+			"2024-09-04 [{Lunch [] [{Apple Fruit Main}]}]"},
+		{"session added", lunch("2024-09-04", []string{"Entree", "Pizza"}), withBreakfast,
+			"2024-09-04 [{Breakfast [{Waffles Entree Main}] []}]"},
+		{"session dropped", withBreakfast, lunch("2024-09-04", []string{"Entree", "Pizza"}),
+			"2024-09-04 [{Breakfast [] [{Waffles Entree Main}]}]"},
+		{"day no longer served", lunch("2024-09-04", []string{"Entree", "Pizza"}), menu.Day{},
+			"2024-09-04 [{Lunch [] [{Pizza Entree Main}]}]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Compare(tt.old, tt.new)
+			if got := fmt.Sprint(d.Date, " ", d.Sessions); got != tt.want {
+				t.Errorf("Compare = %s, want %s", got, tt.want)
+			}
+			if d.Empty() != (len(d.Sessions) == 0) {
+				t.Error("Empty disagrees with Sessions")
+			}
+		})
+	}
+}
+
+func TestCompareDays(t *testing.T) {
+	old := []menu.Day{
+		lunch("2024-09-04", []string{"Entree", "Pizza"}),
+		lunch("2024-09-03", []string{"Entree", "Tacos"}),
+	}
+	new := []menu.Day{
+		lunch("2024-09-05", []string{"Entree", "Soup"}),
+		lunch("2024-09-04", []string{"Entree", "Pizza"}),
+	}
+	var got []string
+	for _, d := range CompareDays(old, new) {
+		got = append(got, fmt.Sprint(d.Date, " ", d.Sessions))
+	}
+	want := []string{
+		"2024-09-03 [{Lunch [] [{Tacos Entree Main}]}]",
+		"2024-09-05 [{Lunch [{Soup Entree Main}] []}]",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("CompareDays = %q, want %q", got, want)
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name  string
+		diffs []DayDiff
+		want  string
+	}{
+		{"one meal", []DayDiff{{Date: "2024-10-22", Sessions: []SessionDiff{{
+			Session: "Lunch",
+			Added:   []Recipe{{Name: "Cheese Pizza", Category: "Lunch Entree", Meal: "Main"}},
+			Removed: []Recipe{{Name: "Chicken Pot Pie", Category: "Lunch Entree", Meal: "Main"}},
+		}}}}, "Tuesday, October 22 · Lunch\n  + Cheese Pizza (Lunch Entree)\n  - Chicken Pot Pie (Lunch Entree)\n"},
+		{"several meals", []DayDiff{{Date: "2024-10-22", Sessions: []SessionDiff{{
+			Session: "Lunch",
+			Added:   []Recipe{{Name: "Cheese Pizza", Category: "Entree", Meal: "Combos"}},
+			Removed: []Recipe{{Name: "Tacos", Category: "Entree", Meal: "Main"}},
+		}}}}, "Tuesday, October 22 · Lunch\n  + Cheese Pizza (Combos · Entree)\n  - Tacos (Main · Entree)\n"},
+		{"days apart", []DayDiff{
+			{Date: "2024-10-22", Sessions: []SessionDiff{{Session: "Lunch", Added: []Recipe{{Name: "Soup", Category: "Entree"}}}}},
+			{Date: "2024-10-23", Sessions: []SessionDiff{{Session: "Breakfast", Removed: []Recipe{{Name: "Waffles", Category: "Entree"}}}}},
+		}, "Tuesday, October 22 · Lunch\n  + Soup (Entree)\n\nWednesday, October 23 · Breakfast\n  - Waffles (Entree)\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.diffs); got != tt.want {
+				t.Errorf("Text =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
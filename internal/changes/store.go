@@ -0,0 +1,165 @@
+package changes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// DefaultDir is where snapshots are kept unless configured otherwise.
+const DefaultDir = "menu-snapshots"
+
+// keepDays is how long days before the checked range stay in a snapshot.
+const keepDays = 30
+
+// Snapshot is the last-seen menu for a school, in the same form as the
+// CLI's JSON output, so either can be compared with the diff command.
+type Snapshot struct {
+	School     string     `json:"school,omitempty"`
+	BuildingID string     `json:"buildingId,omitempty"`
+	DistrictID string     `json:"districtId,omitempty"`
+	Days       []menu.Day `json:"days"`
+}
+
+// Store keeps a snapshot file per school in a directory.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Open returns a Store in dir, creating it if needed. An empty dir means
+// DefaultDir.
+func Open(dir string) (*Store, error) {
+	if dir == "" {
+		dir = DefaultDir
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating snapshot dir: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+var unsafe = regexp.MustCompile(`[^A-Za-z0-9-]+`)
+
+// Path returns the snapshot file for a school.
+func (s *Store) Path(buildingID, districtID string) string {
+	return filepath.Join(s.dir, unsafe.ReplaceAllString(buildingID+"_"+districtID, "_")+".json")
+}
+
+// Load returns a school's snapshot, which is empty if it has never been
+// checked.
+func (s *Store) Load(buildingID, districtID string) (Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(buildingID, districtID)
+}
+
+func (s *Store) load(buildingID, districtID string) (Snapshot, error) {
+	snap, err := ReadSnapshot(s.Path(buildingID, districtID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Snapshot{BuildingID: buildingID, DistrictID: districtID}, nil
+	}
+	return snap, err
+}
+
+// Check compares the menu just fetched for dates with the snapshot and
+// returns the days that changed, and the snapshot updated with the fetched
+// menu for Save to record once the changes are handled. days holds the
+// fetched menu; a date without a day in it has no menu. A date seen for
+// the first time is not reported, so only edits to a published menu count
+// as changes.
+func (s *Store) Check(school, buildingID, districtID string, dates []time.Time, days []menu.Day) ([]DayDiff, Snapshot, error) {
+	snap, err := s.Load(buildingID, districtID)
+	if err != nil {
+		return nil, snap, err
+	}
+	seen := make(map[string]menu.Day, len(snap.Days))
+	for _, d := range snap.Days {
+		seen[d.Date] = d
+	}
+	fetched := make(map[string]menu.Day, len(days))
+	for _, d := range days {
+		fetched[d.Date] = d
+	}
+
+	var diffs []DayDiff
+	for _, date := range dates {
+		key := date.Format(menu.DateLayout)
+		day, ok := fetched[key]
+		if !ok {
+			day = menu.Day{Date: key}
+		}
+		if old, ok := seen[key]; ok {
+			if d := Compare(old, day); !d.Empty() {
+				diffs = append(diffs, d)
+			}
+		}
+		if len(day.Sessions) > 0 {
+			seen[key] = day
+		} else {
+			delete(seen, key)
+		}
+	}
+
+	var oldest string
+	if len(dates) > 0 {
+		oldest = dates[0].AddDate(0, 0, -keepDays).Format(menu.DateLayout)
+	}
+	snap.Days = []menu.Day{}
+	for date, d := range seen {
+		if date >= oldest {
+			snap.Days = append(snap.Days, d)
+		}
+	}
+	sort.Slice(snap.Days, func(i, j int) bool { return snap.Days[i].Date < snap.Days[j].Date })
+	snap.School, snap.BuildingID, snap.DistrictID = school, buildingID, districtID
+	return diffs, snap, nil
+}
+
+// Save writes a school's snapshot. The file is replaced atomically so a
+// crash cannot leave it half-written.
+func (s *Store) Save(snap Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := s.Path(snap.BuildingID, snap.DistrictID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadSnapshot reads a snapshot file. Besides snapshots it accepts the
+// CLI's JSON output, a single day, or a list of days.
+func ReadSnapshot(path string) (Snapshot, error) {
+	var snap Snapshot
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snap, err
+	}
+	if err := json.Unmarshal(data, &snap); err == nil && snap.Days != nil {
+		return snap, nil
+	}
+	var day menu.Day
+	if err := json.Unmarshal(data, &day); err == nil && day.Date != "" {
+		return Snapshot{Days: []menu.Day{day}}, nil
+	}
+	var days []menu.Day
+	if err := json.Unmarshal(data, &days); err == nil {
+		return Snapshot{Days: days}, nil
+	}
+	return snap, fmt.Errorf("%s is not a menu snapshot or JSON menu", path)
+}
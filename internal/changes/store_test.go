@@ -0,0 +1,131 @@
+package changes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(menu.DateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestStoreCheck(t *testing.T) {
+	week := []time.Time{date("2024-09-03"), date("2024-09-04"), date("2024-09-05")}
+	tests := []struct {
+		name      string
+		saved     []menu.Day
+		fetched   []menu.Day
+		wantDiffs []string
+		wantSaved []string
+	}{
+		{
+			name:      "first check reports nothing",
+			fetched:   []menu.Day{lunch("2024-09-03", []string{"Entree", "Pizza"})},
+			wantSaved: []string{"2024-09-03"},
+		},
+		{
+			name:      "edited day",
+			saved:     []menu.Day{lunch("2024-09-03", []string{"Entree", "Pizza"}), lunch("2024-09-04", []string{"Entree", "Tacos"})},
+			fetched:   []menu.Day{lunch("2024-09-03", []string{"Entree", "Pizza"}), lunch("2024-09-04", []string{"Entree", "Soup"})},
+			wantDiffs: []string{"2024-09-04 [{Lunch [{Soup Entree Main}] [{Tacos Entree Main}]}]"},
+			wantSaved: []string{"2024-09-03", "2024-09-04"},
+		},
+		{
+			name:      "menu taken down",
+			saved:     []menu.Day{lunch("2024-09-05", []string{"Entree", "Pizza"})},
+			wantDiffs: []string{"2024-09-05 [{Lunch [] [{Pizza Entree Main}]}]"},
+			wantSaved: []string{},
+		},
+		{
+			name:      "days outside the range are kept until a month old",
+			saved:     []menu.Day{lunch("2024-07-01", []string{"Entree", "Pizza"}), lunch("2024-08-20", []string{"Entree", "Pizza"}), lunch("2024-09-20", []string{"Entree", "Pizza"})},
+			wantSaved: []string{"2024-08-20", "2024-09-20"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := Open(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.saved != nil {
+				if err := store.Save(Snapshot{BuildingID: "b1", DistrictID: "d1", Days: tt.saved}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			diffs, snap, err := store.Check("Lincoln", "b1", "d1", week, tt.fetched)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, d := range diffs {
+				got = append(got, fmt.Sprint(d.Date, " ", d.Sessions))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantDiffs) {
+				t.Errorf("diffs = %q, want %q", got, tt.wantDiffs)
+			}
+			saved := []string{}
+			for _, d := range snap.Days {
+				saved = append(saved, d.Date)
+			}
+			if fmt.Sprint(saved) != fmt.Sprint(tt.wantSaved) {
+				t.Errorf("snapshot days = %v, want %v", saved, tt.wantSaved)
+			}
+			if snap.School != "Lincoln" || snap.BuildingID != "b1" || snap.DistrictID != "d1" {
+				t.Errorf("snapshot school = %q %q %q", snap.School, snap.BuildingID, snap.DistrictID)
+			}
+
+			// Check does not save, so the same edit is reported again
+			// until the caller saves the snapshot.
+			again, _, _ := store.Check("Lincoln", "b1", "d1", week, tt.fetched)
+			if len(again) != len(diffs) {
+				t.Errorf("second Check before Save found %d changes, want %d", len(again), len(diffs))
+			}
+			if err := store.Save(snap); err != nil {
+				t.Fatal(err)
+			}
+			if after, _, _ := store.Check("Lincoln", "b1", "d1", week, tt.fetched); len(after) != 0 {
+				t.Errorf("Check after Save found %v", after)
+			}
+		})
+	}
+}
+
+func TestReadSnapshot(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantDays int
+		wantErr  bool
+	}{
+		{"snapshot", `{"school":"Lincoln","days":[{"date":"2024-09-04","sessions":[]}]}`, 1, false},
+		{"single day", `{"date":"2024-09-04","sessions":[]}`, 1, false},
+		{"list of days", `[{"date":"2024-09-04"},{"date":"2024-09-05"}]`, 2, false},
+		{"not a menu", `{"hello":"world"}`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "menu.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			snap, err := ReadSnapshot(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if len(snap.Days) != tt.wantDays {
+				t.Errorf("got %d days, want %d", len(snap.Days), tt.wantDays)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// Package dates resolves the date expressions accepted wherever a menu date
+// is: exact dates, named ranges such as this-week, and offsets such as +14d.
+//
+// Expressions are resolved against a time in the school's time zone, so
+// "today" is the school's today even when the server runs in UTC. Resolved
+// dates are at midnight UTC, like menu.Day.Time.
+package dates
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Named ranges.
+const (
+	Today         = "today"
+	Tomorrow      = "tomorrow"
+	Yesterday     = "yesterday"
+	NextSchoolDay = "next-school-day"
+	ThisWeek      = "this-week"
+	NextWeek      = "next-week"
+	LastWeek      = "last-week"
+	ThisMonth     = "this-month"
+	NextMonth     = "next-month"
+)
+
+// Names lists the named ranges, for help text.
+var Names = []string{Today, Tomorrow, Yesterday, NextSchoolDay, ThisWeek, NextWeek, LastWeek, ThisMonth, NextMonth}
+
+// layouts are the exact date formats accepted: the LINQ Connect
+// MM-DD-YYYY and ISO YYYY-MM-DD.
+var layouts = []string{"01-02-2006", "2006-01-02"}
+
+var offsetPattern = regexp.MustCompile(`^([+-]\d+)([dw])$`)
+
+// Location returns the named IANA time zone, or the zone in SCHOOL_TZ, or
+// the local zone if neither is set.
+func Location(tz string) (*time.Location, error) {
+	if tz == "" {
+		tz = os.Getenv("SCHOOL_TZ")
+	}
+	if tz == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q", tz)
+	}
+	return loc, nil
+}
+
+// Now returns the current time in the named time zone, as Location
+// resolves it.
+func Now(tz string) (time.Time, error) {
+	loc, err := Location(tz)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().In(loc), nil
+}
+
+// Day returns t's calendar date at midnight UTC.
+func Day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Monday returns the Monday of d's week.
+func Monday(d time.Time) time.Time {
+	return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+}
+
+// Weekend reports whether d is a Saturday or Sunday.
+func Weekend(d time.Time) bool {
+	return d.Weekday() == time.Saturday || d.Weekday() == time.Sunday
+}
+
+// SchoolDayAfter returns the first day after d that is not a weekend and
+// not skipped. skip may be nil. It gives up after four weeks, which is
+// longer than any school break it is used to step over.
+func SchoolDayAfter(d time.Time, skip func(time.Time) bool) time.Time {
+	next := d.AddDate(0, 0, 1)
+	for i := 0; i < 28 && (Weekend(next) || skip != nil && skip(next)); i++ {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Relative reports whether expr is a named range or an offset rather than
+// an exact date.
+func Relative(expr string) bool {
+	expr = strings.ToLower(strings.TrimSpace(expr))
+	for _, name := range Names {
+		if expr == name {
+			return true
+		}
+	}
+	return offsetPattern.MatchString(expr)
+}
+
+// Range resolves expr against now. Exact dates and offsets such as +14d or
+// -1w are single days; named ranges may span several, e.g. this-week runs
+// from Monday to Sunday.
+func Range(expr string, now time.Time) (start, end time.Time, err error) {
+	expr = strings.ToLower(strings.TrimSpace(expr))
+	today := Day(now)
+
+	switch expr {
+	case Today:
+		return today, today, nil
+	case Tomorrow:
+		d := today.AddDate(0, 0, 1)
+		return d, d, nil
+	case Yesterday:
+		d := today.AddDate(0, 0, -1)
+		return d, d, nil
+	case NextSchoolDay:
+		d := SchoolDayAfter(today, nil)
+		return d, d, nil
+	case ThisWeek:
+		start = Monday(today)
+		return start, start.AddDate(0, 0, 6), nil
+	case NextWeek:
+		start = Monday(today).AddDate(0, 0, 7)
+		return start, start.AddDate(0, 0, 6), nil
+	case LastWeek:
+		start = Monday(today).AddDate(0, 0, -7)
+		return start, start.AddDate(0, 0, 6), nil
+	case ThisMonth:
+		start = today.AddDate(0, 0, 1-today.Day())
+		return start, start.AddDate(0, 1, -1), nil
+	case NextMonth:
+		start = today.AddDate(0, 0, 1-today.Day()).AddDate(0, 1, 0)
+		return start, start.AddDate(0, 1, -1), nil
+	}
+
+	if m := offsetPattern.FindStringSubmatch(expr); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if m[2] == "w" {
+			n *= 7
+		}
+		d := today.AddDate(0, 0, n)
+		return d, d, nil
+	}
+
+	for _, layout := range layouts {
+		if d, err := time.Parse(layout, expr); err == nil {
+			return d, d, nil
+		}
+	}
+	return start, end, fmt.Errorf("invalid date %q: use MM-DD-YYYY, YYYY-MM-DD, an offset such as +14d, or one of %s", expr, strings.Join(Names, ", "))
+}
+
+// Resolve resolves a start and end expression into a date range. An empty
+// start is today. An empty end is the end of the start's range, so a start
+// of this-week alone covers the whole week; otherwise the end is the end of
+// its own range, so "today" to "next-week" runs through next Sunday.
+func Resolve(startExpr, endExpr string, now time.Time) (start, end time.Time, err error) {
+	if startExpr == "" {
+		startExpr = Today
+	}
+	start, end, err = Range(startExpr, now)
+	if err != nil {
+		return start, end, err
+	}
+	if endExpr != "" {
+		if _, end, err = Range(endExpr, now); err != nil {
+			return start, end, err
+		}
+	}
+	if end.Before(start) {
+		return start, end, fmt.Errorf("end date is before start date")
+	}
+	return start, end, nil
+}
@@ -0,0 +1,106 @@
+package dates
+
+import (
+	"testing"
+	"time"
+)
+
+// eastern stands in for America/New_York in summer.
+var eastern = time.FixedZone("EDT", -4*60*60)
+
+func at(date string) time.Time {
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestRange(t *testing.T) {
+	// Late on Wednesday evening at school, already Thursday in UTC.
+	wednesday := time.Date(2024, 9, 4, 23, 30, 0, 0, eastern)
+	friday := time.Date(2024, 9, 6, 12, 0, 0, 0, eastern)
+	leap := time.Date(2024, 1, 31, 12, 0, 0, 0, eastern)
+
+	tests := []struct {
+		expr       string
+		now        time.Time
+		start, end string
+	}{
+		{"today", wednesday, "2024-09-04", "2024-09-04"},
+		{" Today ", wednesday, "2024-09-04", "2024-09-04"},
+		{"tomorrow", wednesday, "2024-09-05", "2024-09-05"},
+		{"yesterday", wednesday, "2024-09-03", "2024-09-03"},
+		{"next-school-day", wednesday, "2024-09-05", "2024-09-05"},
+		{"next-school-day", friday, "2024-09-09", "2024-09-09"},
+		{"this-week", wednesday, "2024-09-02", "2024-09-08"},
+		{"next-week", wednesday, "2024-09-09", "2024-09-15"},
+		{"last-week", wednesday, "2024-08-26", "2024-09-01"},
+		{"this-month", wednesday, "2024-09-01", "2024-09-30"},
+		{"next-month", wednesday, "2024-10-01", "2024-10-31"},
+		{"next-month", leap, "2024-02-01", "2024-02-29"},
+		{"+14d", wednesday, "2024-09-18", "2024-09-18"},
+		{"-1w", wednesday, "2024-08-28", "2024-08-28"},
+		{"+0d", wednesday, "2024-09-04", "2024-09-04"},
+		{"09-10-2024", wednesday, "2024-09-10", "2024-09-10"},
+		{"2024-12-25", wednesday, "2024-12-25", "2024-12-25"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			start, end, err := Range(tt.expr, tt.now)
+			if err != nil {
+				t.Fatalf("Range(%q): %v", tt.expr, err)
+			}
+			if !start.Equal(at(tt.start)) || !end.Equal(at(tt.end)) {
+				t.Errorf("Range(%q) = %s to %s, want %s to %s", tt.expr,
+					start.Format("2006-01-02"), end.Format("2006-01-02"), tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestRangeInvalid(t *testing.T) {
+	for _, expr := range []string{"", "someday", "+14", "14d", "+1m", "2024-13-01", "9/4/2024"} {
+		if _, _, err := Range(expr, time.Now()); err == nil {
+			t.Errorf("Range(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	wednesday := time.Date(2024, 9, 4, 8, 0, 0, 0, eastern)
+	tests := []struct {
+		name       string
+		start, end string
+		wantStart  string
+		wantEnd    string
+		wantErr    bool
+	}{
+		{"defaults to today", "", "", "2024-09-04", "2024-09-04", false},
+		{"named range alone", "this-week", "", "2024-09-02", "2024-09-08", false},
+		{"end of the end's range", "today", "next-week", "2024-09-04", "2024-09-15", false},
+		{"exact dates", "2024-09-09", "09-13-2024", "2024-09-09", "2024-09-13", false},
+		{"offset end", "tomorrow", "+7d", "2024-09-05", "2024-09-11", false},
+		{"end before start", "next-week", "today", "", "", true},
+		{"bad start", "soon", "", "", "", true},
+		{"bad end", "today", "later", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := Resolve(tt.start, tt.end, wednesday)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Resolve(%q, %q) succeeded, want an error", tt.start, tt.end)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q, %q): %v", tt.start, tt.end, err)
+			}
+			if !start.Equal(at(tt.wantStart)) || !end.Equal(at(tt.wantEnd)) {
+				t.Errorf("Resolve(%q, %q) = %s to %s, want %s to %s", tt.start, tt.end,
+					start.Format("2006-01-02"), end.Format("2006-01-02"), tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
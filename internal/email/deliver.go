@@ -0,0 +1,165 @@
+package email
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DeliveryMode controls how a message reaches multiple recipients.
+type DeliveryMode int
+
+const (
+	// DeliverBcc sends one copy of the message with every recipient only on
+	// the SMTP envelope. The To header reads "undisclosed-recipients", so no
+	// recipient can see any other address.
+	DeliverBcc DeliveryMode = iota
+	// DeliverIndividual sends a separate copy to each recipient, addressed
+	// to them in the To header.
+	DeliverIndividual
+)
+
+// DefaultBatchSize is the number of envelope recipients per message in
+// DeliverBcc mode when DeliveryOptions.BatchSize is not set. Many providers
+// reject messages with more than 50 or 100 recipients.
+const DefaultBatchSize = 50
+
+// DeliveryOptions configures how SendMessage delivers to a recipient list.
+type DeliveryOptions struct {
+	Mode DeliveryMode
+	// BatchSize limits the recipients per message in DeliverBcc mode.
+	BatchSize int
+}
+
+// DeliveryResult is the outcome of delivering to one recipient. Err is nil
+// if the server accepted the message for the recipient.
+type DeliveryResult struct {
+	Recipient string
+	Err       error
+}
+
+// DeliveryError is returned when delivery failed for some or all
+// recipients. Results holds the outcome for every recipient.
+type DeliveryError struct {
+	Results []DeliveryResult
+}
+
+func (e *DeliveryError) Error() string {
+	failed := e.Failed()
+	if len(failed) == 1 {
+		return fmt.Sprintf("delivery to %s failed: %v", failed[0].Recipient, failed[0].Err)
+	}
+	parts := make([]string, len(failed))
+	for i, r := range failed {
+		parts[i] = fmt.Sprintf("%s: %v", r.Recipient, r.Err)
+	}
+	return fmt.Sprintf("delivery failed for %d of %d recipients: %s", len(failed), len(e.Results), strings.Join(parts, "; "))
+}
+
+// Failed returns the results for recipients that were not delivered to.
+func (e *DeliveryError) Failed() []DeliveryResult {
+	var failed []DeliveryResult
+	for _, r := range e.Results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// SendMessage delivers msg to recipients through the SMTP server in cfg.
+// Recipients never appear in a Bcc header: in DeliverBcc mode they are only
+// on the SMTP envelope, and in DeliverIndividual mode each copy is
+// addressed to a single recipient. msg.To is ignored.
+//
+// Every recipient gets a result, and a recipient rejected by the server
+// does not stop delivery to the others. The error is a *DeliveryError if
+// any recipient failed.
+func SendMessage(cfg SMTPConfig, msg *Message, recipients []string, opts DeliveryOptions) ([]DeliveryResult, error) {
+	from := addressOf(msg.From)
+	results := make([]DeliveryResult, 0, len(recipients))
+
+	c, err := cfg.dial(from)
+	if err != nil {
+		for _, r := range recipients {
+			results = append(results, DeliveryResult{Recipient: r, Err: err})
+		}
+		return results, &DeliveryError{Results: results}
+	}
+	defer c.Close()
+
+	if opts.Mode == DeliverIndividual {
+		for _, r := range recipients {
+			m := *msg
+			m.To = []string{r}
+			results = append(results, sendOne(c, from, &m, []string{r})...)
+		}
+	} else {
+		m := *msg
+		m.To = nil
+		for _, batch := range batches(recipients, opts.BatchSize) {
+			results = append(results, sendOne(c, from, &m, batch)...)
+		}
+	}
+	c.Quit()
+
+	for _, r := range results {
+		if r.Err != nil {
+			return results, &DeliveryError{Results: results}
+		}
+	}
+	return results, nil
+}
+
+// sendOne sends a single copy of msg to the envelope recipients on an open
+// connection. Recipients the server rejects are reported individually and
+// the message is still sent to the rest.
+func sendOne(c *smtpConn, from string, msg *Message, recipients []string) []DeliveryResult {
+	results := make([]DeliveryResult, len(recipients))
+	for i, r := range recipients {
+		results[i].Recipient = r
+	}
+	failAll := func(err error) []DeliveryResult {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i].Err = err
+			}
+		}
+		c.Reset()
+		return results
+	}
+
+	data, err := msg.Bytes()
+	if err != nil {
+		return failAll(fmt.Errorf("building message: %w", err))
+	}
+
+	c.extendDeadline()
+	if err := c.Mail(from); err != nil {
+		return failAll(err)
+	}
+	accepted := 0
+	for i, r := range recipients {
+		if err := c.Rcpt(addressOf(r)); err != nil {
+			results[i].Err = err
+			continue
+		}
+		accepted++
+	}
+	if accepted == 0 {
+		c.Reset()
+		return results
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return failAll(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return failAll(err)
+	}
+	if err := w.Close(); err != nil {
+		return failAll(err)
+	}
+	return results
+}
@@ -0,0 +1,76 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestSendMessageBcc(t *testing.T) {
+	var many []string
+	for i := 0; i < DefaultBatchSize+1; i++ {
+		many = append(many, fmt.Sprintf("parent%d@example.com", i))
+	}
+	tests := []struct {
+		name       string
+		recipients []string
+		batchSize  int
+		reject     string
+		batches    []int
+	}{
+		{"one batch", []string{"a@example.com", "Bee <b@example.com>"}, 0, "", []int{2}},
+		{"configured size", []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"}, 2, "", []int{2, 2, 1}},
+		{"default size", many, 0, "", []int{DefaultBatchSize, 1}},
+		{"rejected recipient", []string{"a@example.com", "gone@example.com", "c@example.com"}, 2, "gone@example.com", []int{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fakeSMTP{reject: map[string]bool{tt.reject: true}}
+			cfg := SMTPConfig{Server: s.start(t, false), Security: SecurityNone}
+			msg := testMessage()
+			msg.To = []string{"ignored@example.com"}
+
+			results, err := SendMessage(cfg, msg, tt.recipients, DeliveryOptions{Mode: DeliverBcc, BatchSize: tt.batchSize})
+			var delivery *DeliveryError
+			if tt.reject == "" && err != nil || tt.reject != "" && !errors.As(err, &delivery) {
+				t.Fatalf("SendMessage: %v", err)
+			}
+			if len(results) != len(tt.recipients) {
+				t.Errorf("got %d results, want %d", len(results), len(tt.recipients))
+			}
+
+			_, mails := s.results()
+			var sizes []int
+			for _, m := range mails {
+				sizes = append(sizes, len(m.to))
+				parsed, err := mail.ReadMessage(strings.NewReader(m.data))
+				if err != nil {
+					t.Fatalf("parsing message: %v", err)
+				}
+				if got := parsed.Header.Get("To"); got != "undisclosed-recipients:;" {
+					t.Errorf("To = %q, want undisclosed-recipients:;", got)
+				}
+				if _, ok := parsed.Header["Bcc"]; ok {
+					t.Errorf("message has a Bcc header: %q", parsed.Header.Get("Bcc"))
+				}
+				// Recipients are only on the envelope, never in the headers.
+				for _, r := range append(tt.recipients, msg.To...) {
+					if addr := addressOf(r); strings.Contains(headerBlock(m.data), addr) {
+						t.Errorf("headers reveal %s:\n%s", addr, headerBlock(m.data))
+					}
+				}
+			}
+			if fmt.Sprint(sizes) != fmt.Sprint(tt.batches) {
+				t.Errorf("batches of %v recipients, want %v", sizes, tt.batches)
+			}
+		})
+	}
+}
+
+// headerBlock returns the header section of a raw message.
+func headerBlock(data string) string {
+	header, _, _ := strings.Cut(data, "\r\n\r\n")
+	return header
+}
@@ -0,0 +1,178 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Mailer delivers a message to a list of recipients. Implementations must
+// not reveal recipients to each other and report a result per recipient, as
+// SendMessage does.
+type Mailer interface {
+	Send(msg *Message, recipients []string, opts DeliveryOptions) ([]DeliveryResult, error)
+}
+
+// Mail providers accepted by NewMailer.
+const (
+	ProviderSMTP    = "smtp"
+	ProviderMailjet = "mailjet"
+	ProviderOutbox  = "outbox"
+)
+
+// MailerConfig selects and configures a Mailer.
+type MailerConfig struct {
+	// Provider is smtp (the default), mailjet or outbox.
+	Provider string
+	SMTP     SMTPConfig
+	// MailjetPublicKey and MailjetPrivateKey are the Mailjet API key pair.
+	MailjetPublicKey  string
+	MailjetPrivateKey string
+	// OutboxDir is where the outbox provider writes .eml files.
+	OutboxDir string
+}
+
+// MailerConfigFromEnv reads the mailer configuration from the environment:
+// EMAIL_PROVIDER, SMTP_SERVER, SMTP_USERNAME, EMAIL_PASSWORD, SMTP_SECURITY,
+// SMTP_AUTH, SMTP_CA_FILE, MJ_APIKEY_PUBLIC, MJ_APIKEY_PRIVATE and
+// EMAIL_OUTBOX_DIR.
+func MailerConfigFromEnv() (MailerConfig, error) {
+	cfg := MailerConfig{
+		Provider: os.Getenv("EMAIL_PROVIDER"),
+		SMTP: SMTPConfig{
+			Server:   os.Getenv("SMTP_SERVER"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("EMAIL_PASSWORD"),
+			CAFile:   os.Getenv("SMTP_CA_FILE"),
+		},
+		MailjetPublicKey:  os.Getenv("MJ_APIKEY_PUBLIC"),
+		MailjetPrivateKey: os.Getenv("MJ_APIKEY_PRIVATE"),
+		OutboxDir:         os.Getenv("EMAIL_OUTBOX_DIR"),
+	}
+
+	var err error
+	if cfg.SMTP.Security, err = ParseSecurity(os.Getenv("SMTP_SECURITY")); err != nil {
+		return cfg, err
+	}
+	if cfg.SMTP.Auth, err = ParseAuth(os.Getenv("SMTP_AUTH")); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// NewMailer returns the Mailer for cfg.Provider.
+func NewMailer(cfg MailerConfig) (Mailer, error) {
+	switch strings.ToLower(cfg.Provider) {
+	case "", ProviderSMTP:
+		if cfg.SMTP.Server == "" {
+			return nil, fmt.Errorf("SMTP server is required")
+		}
+		return &SMTPMailer{Config: cfg.SMTP}, nil
+	case ProviderMailjet:
+		if cfg.MailjetPublicKey == "" || cfg.MailjetPrivateKey == "" {
+			return nil, fmt.Errorf("Mailjet public and private API keys are required")
+		}
+		return NewMailjetMailer(cfg.MailjetPublicKey, cfg.MailjetPrivateKey), nil
+	case ProviderOutbox:
+		if cfg.OutboxDir == "" {
+			return nil, fmt.Errorf("outbox directory is required")
+		}
+		return &OutboxMailer{Dir: cfg.OutboxDir}, nil
+	}
+	return nil, fmt.Errorf("unknown email provider %q (want smtp, mailjet or outbox)", cfg.Provider)
+}
+
+// SMTPMailer sends through an SMTP server.
+type SMTPMailer struct {
+	Config SMTPConfig
+}
+
+func (m *SMTPMailer) Send(msg *Message, recipients []string, opts DeliveryOptions) ([]DeliveryResult, error) {
+	return SendMessage(m.Config, msg, recipients, opts)
+}
+
+// OutboxMailer writes messages to .eml files instead of sending them, for
+// dry runs. Each file is exactly what would have gone over SMTP, preceded by
+// an X-Envelope-To header listing the envelope recipients.
+type OutboxMailer struct {
+	Dir string
+}
+
+var outboxSeq atomic.Int64
+
+func (m *OutboxMailer) Send(msg *Message, recipients []string, opts DeliveryOptions) ([]DeliveryResult, error) {
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating outbox: %w", err)
+	}
+
+	results := make([]DeliveryResult, 0, len(recipients))
+	write := func(out *Message, envelope []string) {
+		err := m.write(out, envelope)
+		for _, r := range envelope {
+			results = append(results, DeliveryResult{Recipient: r, Err: err})
+		}
+	}
+
+	if opts.Mode == DeliverIndividual {
+		for _, r := range recipients {
+			single := *msg
+			single.To = []string{r}
+			write(&single, []string{r})
+		}
+	} else {
+		bcc := *msg
+		bcc.To = nil
+		for _, batch := range batches(recipients, opts.BatchSize) {
+			write(&bcc, batch)
+		}
+	}
+
+	for _, r := range results {
+		if r.Err != nil {
+			return results, &DeliveryError{Results: results}
+		}
+	}
+	return results, nil
+}
+
+func (m *OutboxMailer) write(msg *Message, envelope []string) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return fmt.Errorf("building message: %w", err)
+	}
+
+	addrs := make([]string, len(envelope))
+	for i, r := range envelope {
+		addrs[i] = "<" + addressOf(r) + ">"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "X-Envelope-To: %s\r\n", strings.Join(addrs, ", "))
+	buf.Write(data)
+
+	name := fmt.Sprintf("%s-%04d.eml", time.Now().Format("20060102-150405"), outboxSeq.Add(1))
+	if err := os.WriteFile(filepath.Join(m.Dir, name), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
+
+// batches splits recipients into groups of at most size, or
+// DefaultBatchSize if size is not positive.
+func batches(recipients []string, size int) [][]string {
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	var out [][]string
+	for start := 0; start < len(recipients); start += size {
+		end := start + size
+		if end > len(recipients) {
+			end = len(recipients)
+		}
+		out = append(out, recipients[start:end])
+	}
+	return out
+}
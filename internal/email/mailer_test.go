@@ -0,0 +1,126 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNewMailer(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     MailerConfig
+		want    string
+		wantErr string
+	}{
+		{"default smtp", MailerConfig{SMTP: SMTPConfig{Server: "smtp.example.com:587"}}, "*email.SMTPMailer", ""},
+		{"smtp", MailerConfig{Provider: "SMTP", SMTP: SMTPConfig{Server: "smtp.example.com:587"}}, "*email.SMTPMailer", ""},
+		{"smtp without server", MailerConfig{Provider: ProviderSMTP}, "", "SMTP server is required"},
+		{"mailjet", MailerConfig{Provider: ProviderMailjet, MailjetPublicKey: "pub", MailjetPrivateKey: "priv"}, "*email.MailjetMailer", ""},
+		{"mailjet without keys", MailerConfig{Provider: ProviderMailjet, MailjetPublicKey: "pub"}, "", "Mailjet public and private API keys are required"},
+		{"outbox", MailerConfig{Provider: ProviderOutbox, OutboxDir: "outbox"}, "*email.OutboxMailer", ""},
+		{"outbox without dir", MailerConfig{Provider: ProviderOutbox}, "", "outbox directory is required"},
+		{"unknown", MailerConfig{Provider: "pigeon"}, "", `unknown email provider "pigeon" (want smtp, mailjet or outbox)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMailer(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("NewMailer = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprintf("%T", m); got != tt.want {
+				t.Errorf("NewMailer = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// readOutbox returns the messages in dir in the order they were written.
+func readOutbox(t *testing.T, dir string) []*mail.Message {
+	t.Helper()
+	names, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	var msgs []*mail.Message
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := mail.ReadMessage(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("parsing %s: %v", name, err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestOutboxMailer(t *testing.T) {
+	recipients := []string{"a@example.com", "Bee <b@example.com>", "c@example.com"}
+	tests := []struct {
+		name     string
+		opts     DeliveryOptions
+		envelope []string
+		to       []string
+	}{
+		{"bcc", DeliveryOptions{Mode: DeliverBcc, BatchSize: 2},
+			[]string{"<a@example.com>, <b@example.com>", "<c@example.com>"},
+			[]string{"undisclosed-recipients:;", "undisclosed-recipients:;"}},
+		{"individual", DeliveryOptions{Mode: DeliverIndividual},
+			[]string{"<a@example.com>", "<b@example.com>", "<c@example.com>"},
+			[]string{"<a@example.com>", `"Bee" <b@example.com>`, "<c@example.com>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "outbox")
+			results, err := (&OutboxMailer{Dir: dir}).Send(testMessage(), recipients, tt.opts)
+			if err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if len(results) != len(recipients) {
+				t.Errorf("got %d results, want %d", len(results), len(recipients))
+			}
+
+			msgs := readOutbox(t, dir)
+			if len(msgs) != len(tt.envelope) {
+				t.Fatalf("wrote %d messages, want %d", len(msgs), len(tt.envelope))
+			}
+			for i, msg := range msgs {
+				if got := msg.Header.Get("X-Envelope-To"); got != tt.envelope[i] {
+					t.Errorf("message %d X-Envelope-To = %q, want %q", i, got, tt.envelope[i])
+				}
+				if got := msg.Header.Get("To"); got != tt.to[i] {
+					t.Errorf("message %d To = %q, want %q", i, got, tt.to[i])
+				}
+				if got := msg.Header.Get("Subject"); got != "Lunch menu" {
+					t.Errorf("message %d Subject = %q", i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestOutboxMailerError(t *testing.T) {
+	// A file where the outbox directory should be.
+	dir := filepath.Join(t.TempDir(), "outbox")
+	if err := os.WriteFile(dir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&OutboxMailer{Dir: dir}).Send(testMessage(), []string{"a@example.com"}, DeliveryOptions{}); err == nil || !strings.Contains(err.Error(), "creating outbox") {
+		t.Errorf("Send = %v, want an error creating the outbox", err)
+	}
+}
@@ -0,0 +1,128 @@
+package email
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+
+	"github.com/mailjet/mailjet-apiv3-go/v4"
+)
+
+// mailjetMaxMessages is the most messages the Send API accepts per call.
+const mailjetMaxMessages = 50
+
+// MailjetMailer sends through the Mailjet Send API v3.1. Every recipient
+// gets their own copy addressed to them, so DeliverBcc and
+// DeliverIndividual behave the same; BatchSize sets how many copies go in
+// each API call, up to 50.
+type MailjetMailer struct {
+	client *mailjet.Client
+	// SandboxMode validates messages without delivering them.
+	SandboxMode bool
+}
+
+// NewMailjetMailer returns a Mailer using the given Mailjet API key pair.
+// baseURL optionally overrides the API endpoint.
+func NewMailjetMailer(publicKey, privateKey string, baseURL ...string) *MailjetMailer {
+	return &MailjetMailer{client: mailjet.NewMailjetClient(publicKey, privateKey, baseURL...)}
+}
+
+func (m *MailjetMailer) Send(msg *Message, recipients []string, opts DeliveryOptions) ([]DeliveryResult, error) {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender: %w", err)
+	}
+
+	size := opts.BatchSize
+	if size <= 0 || size > mailjetMaxMessages {
+		size = mailjetMaxMessages
+	}
+
+	results := make([]DeliveryResult, 0, len(recipients))
+	for _, batch := range batches(recipients, size) {
+		results = append(results, m.sendBatch(from, msg, batch)...)
+	}
+
+	for _, r := range results {
+		if r.Err != nil {
+			return results, &DeliveryError{Results: results}
+		}
+	}
+	return results, nil
+}
+
+// sendBatch sends one copy of msg per recipient in a single API call.
+func (m *MailjetMailer) sendBatch(from *mail.Address, msg *Message, recipients []string) []DeliveryResult {
+	results := make([]DeliveryResult, len(recipients))
+	infos := make([]mailjet.InfoMessagesV31, len(recipients))
+	for i, r := range recipients {
+		results[i].Recipient = r
+		to := mailjet.RecipientV31{Email: addressOf(r)}
+		if parsed, err := mail.ParseAddress(r); err == nil {
+			to.Name = parsed.Name
+		}
+		infos[i] = mailjetMessage(from, msg, to)
+	}
+
+	_, err := m.client.SendMailV31(&mailjet.MessagesV31{Info: infos, SandBoxMode: m.SandboxMode})
+	if err == nil {
+		return results
+	}
+
+	// Validation errors are reported per message, in request order.
+	var feedback *mailjet.APIFeedbackErrorsV31
+	if errors.As(err, &feedback) && len(feedback.Messages) == len(recipients) {
+		for i, fm := range feedback.Messages {
+			if len(fm.Errors) == 0 {
+				continue
+			}
+			msgs := make([]string, len(fm.Errors))
+			for j, e := range fm.Errors {
+				msgs[j] = e.ErrorMessage
+			}
+			results[i].Err = fmt.Errorf("mailjet: %s", strings.Join(msgs, "; "))
+		}
+		return results
+	}
+
+	for i := range results {
+		results[i].Err = fmt.Errorf("mailjet: %w", err)
+	}
+	return results
+}
+
+func mailjetMessage(from *mail.Address, msg *Message, to mailjet.RecipientV31) mailjet.InfoMessagesV31 {
+	info := mailjet.InfoMessagesV31{
+		From:     &mailjet.RecipientV31{Email: from.Address, Name: from.Name},
+		To:       &mailjet.RecipientsV31{to},
+		Subject:  msg.Subject,
+		TextPart: msg.Text,
+		HTMLPart: msg.HTML,
+	}
+	if len(msg.Header) > 0 {
+		info.Headers = make(map[string]interface{}, len(msg.Header))
+		for k, v := range msg.Header {
+			info.Headers[k] = v
+		}
+	}
+	if len(msg.Attachments) > 0 {
+		attachments := make(mailjet.AttachmentsV31, len(msg.Attachments))
+		for i, a := range msg.Attachments {
+			// The API takes a bare media type without parameters.
+			contentType, _, err := mime.ParseMediaType(a.ContentType)
+			if err != nil {
+				contentType = "application/octet-stream"
+			}
+			attachments[i] = mailjet.AttachmentV31{
+				ContentType:   contentType,
+				Filename:      a.Filename,
+				Base64Content: base64.StdEncoding.EncodeToString(a.Data),
+			}
+		}
+		info.Attachments = &attachments
+	}
+	return info
+}
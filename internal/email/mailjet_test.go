@@ -0,0 +1,183 @@
+package email
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeMailjet stands in for the Send API v3.1. It accepts every message
+// unless its address is in reject, and fails every call if status is set.
+type fakeMailjet struct {
+	reject map[string]bool
+	status int
+
+	mu    sync.Mutex
+	calls [][]mailjetSent
+}
+
+type mailjetSent struct {
+	From, To, Subject string
+}
+
+func (f *fakeMailjet) start(t *testing.T) *MailjetMailer {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3.1/send" {
+			http.NotFound(w, r)
+			return
+		}
+		if user, pass, _ := r.BasicAuth(); user != "pub" || pass != "priv" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Messages []struct {
+				From    struct{ Email string }
+				To      []struct{ Email string }
+				Subject string
+			}
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var sent []mailjetSent
+		type detail struct{ ErrorMessage string }
+		feedback := struct{ Messages []struct{ Errors []detail } }{}
+		rejected := false
+		for _, m := range req.Messages {
+			var to []string
+			for _, r := range m.To {
+				to = append(to, r.Email)
+			}
+			sent = append(sent, mailjetSent{m.From.Email, strings.Join(to, ","), m.Subject})
+			var errs []detail
+			if f.reject[strings.Join(to, ",")] {
+				errs, rejected = []detail{{"blocked recipient"}}, true
+			}
+			feedback.Messages = append(feedback.Messages, struct{ Errors []detail }{errs})
+		}
+		f.mu.Lock()
+		f.calls = append(f.calls, sent)
+		f.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case f.status != 0:
+			w.WriteHeader(f.status)
+			io.WriteString(w, `{"ErrorMessage":"internal error","StatusCode":500}`)
+		case rejected:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(feedback)
+		default:
+			io.WriteString(w, `{"Messages":[]}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return NewMailjetMailer("pub", "priv", srv.URL+"/v3")
+}
+
+func (f *fakeMailjet) recorded() [][]mailjetSent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]mailjetSent(nil), f.calls...)
+}
+
+func TestMailjetMailerBatches(t *testing.T) {
+	var recipients []string
+	for i := 0; i < 2*mailjetMaxMessages+1; i++ {
+		recipients = append(recipients, fmt.Sprintf("parent%d@example.com", i))
+	}
+	tests := []struct {
+		name      string
+		batchSize int
+		want      []int
+	}{
+		{"default", 0, []int{50, 50, 1}},
+		{"too large", 500, []int{50, 50, 1}},
+		{"configured", 40, []int{40, 40, 21}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeMailjet{}
+			results, err := f.start(t).Send(testMessage(), recipients, DeliveryOptions{BatchSize: tt.batchSize})
+			if err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if len(results) != len(recipients) {
+				t.Errorf("got %d results, want %d", len(results), len(recipients))
+			}
+
+			var sizes []int
+			n := 0
+			for _, call := range f.recorded() {
+				sizes = append(sizes, len(call))
+				for _, m := range call {
+					// Every recipient gets their own copy.
+					if m.To != recipients[n] || m.From != "menus@example.com" || m.Subject != "Lunch menu" {
+						t.Errorf("message %d = %+v, want one to %s", n, m, recipients[n])
+					}
+					n++
+				}
+			}
+			if fmt.Sprint(sizes) != fmt.Sprint(tt.want) {
+				t.Errorf("calls of %v messages, want %v", sizes, tt.want)
+			}
+		})
+	}
+}
+
+func TestMailjetMailerErrors(t *testing.T) {
+	recipients := []string{"a@example.com", "gone@example.com", "c@example.com"}
+	tests := []struct {
+		name   string
+		fake   *fakeMailjet
+		failed string
+		reason string
+	}{
+		{"rejected recipient", &fakeMailjet{reject: map[string]bool{"gone@example.com": true}}, "gone@example.com", "mailjet: blocked recipient"},
+		{"server error", &fakeMailjet{status: http.StatusInternalServerError}, "a@example.com,gone@example.com,c@example.com", "internal error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := tt.fake.start(t).Send(testMessage(), recipients, DeliveryOptions{BatchSize: 2})
+			var delivery *DeliveryError
+			if !errors.As(err, &delivery) {
+				t.Fatalf("Send = %v, want a *DeliveryError", err)
+			}
+			if len(results) != len(recipients) {
+				t.Errorf("got %d results, want %d", len(results), len(recipients))
+			}
+			var failed []string
+			for _, r := range delivery.Failed() {
+				failed = append(failed, r.Recipient)
+				if !strings.Contains(r.Err.Error(), tt.reason) {
+					t.Errorf("%s failed with %v, want %q", r.Recipient, r.Err, tt.reason)
+				}
+			}
+			if got := strings.Join(failed, ","); got != tt.failed {
+				t.Errorf("failed = %s, want %s", got, tt.failed)
+			}
+		})
+	}
+}
+
+func TestMailjetMailerInvalidSender(t *testing.T) {
+	f := &fakeMailjet{}
+	msg := testMessage()
+	msg.From = "not an address"
+	if _, err := f.start(t).Send(msg, []string{"a@example.com"}, DeliveryOptions{}); err == nil || !strings.Contains(err.Error(), "invalid sender") {
+		t.Errorf("Send = %v, want an invalid sender error", err)
+	}
+	if calls := f.recorded(); len(calls) != 0 {
+		t.Errorf("made %d API calls with an invalid sender", len(calls))
+	}
+}
@@ -0,0 +1,242 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Message is an email with a plain-text body, an optional HTML alternative
+// and optional attachments.
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+	// Attachments are added after the body parts.
+	Attachments []Attachment
+	// Header holds any extra headers to send.
+	Header map[string]string
+}
+
+// Attachment is a file attached to a Message.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Bytes returns the message encoded as RFC 5322 with MIME parts:
+// text/plain alone, multipart/alternative when there is an HTML body, and
+// multipart/mixed around that when there are attachments. Header values are
+// RFC 2047 encoded where needed.
+func (m *Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	from, err := formatAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender: %w", err)
+	}
+	to := make([]string, 0, len(m.To))
+	for _, addr := range m.To {
+		formatted, err := formatAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient: %w", err)
+		}
+		to = append(to, formatted)
+	}
+
+	writeHeader(&buf, "From", from)
+	if len(to) > 0 {
+		writeHeader(&buf, "To", strings.Join(to, ", "))
+	} else {
+		// Blind-copied messages name no recipients (RFC 5322 section 3.6.3).
+		writeHeader(&buf, "To", "undisclosed-recipients:;")
+	}
+	writeHeader(&buf, "Subject", encodeHeaderValue(m.Subject))
+	writeHeader(&buf, "Date", time.Now().Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", messageID(m.From))
+	writeHeader(&buf, "MIME-Version", "1.0")
+
+	keys := make([]string, 0, len(m.Header))
+	for k := range m.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeHeader(&buf, textproto.CanonicalMIMEHeaderKey(k), encodeHeaderValue(m.Header[k]))
+	}
+
+	switch {
+	case len(m.Attachments) > 0:
+		mixed := multipart.NewWriter(&buf)
+		writeHeader(&buf, "Content-Type", `multipart/mixed; boundary="`+mixed.Boundary()+`"`)
+		buf.WriteString("\r\n")
+
+		if err := m.writeBody(mixed); err != nil {
+			return nil, err
+		}
+		for _, a := range m.Attachments {
+			if err := writeAttachment(mixed, a); err != nil {
+				return nil, err
+			}
+		}
+		if err := mixed.Close(); err != nil {
+			return nil, err
+		}
+
+	case m.HTML != "":
+		alt := multipart.NewWriter(&buf)
+		writeHeader(&buf, "Content-Type", `multipart/alternative; boundary="`+alt.Boundary()+`"`)
+		buf.WriteString("\r\n")
+		if err := m.writeAlternatives(alt); err != nil {
+			return nil, err
+		}
+
+	default:
+		writeHeader(&buf, "Content-Type", `text/plain; charset="utf-8"`)
+		writeHeader(&buf, "Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, m.Text); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeBody writes the text and HTML bodies as a part of a multipart/mixed
+// message: a nested multipart/alternative when there is HTML, otherwise a
+// single text/plain part.
+func (m *Message) writeBody(mixed *multipart.Writer) error {
+	if m.HTML == "" {
+		return writeTextPart(mixed, "text/plain", m.Text)
+	}
+
+	var inner bytes.Buffer
+	alt := multipart.NewWriter(&inner)
+	if err := m.writeAlternatives(alt); err != nil {
+		return err
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", `multipart/alternative; boundary="`+alt.Boundary()+`"`)
+	part, err := mixed.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(inner.Bytes())
+	return err
+}
+
+// writeAlternatives writes the plain-text part followed by the HTML part,
+// so that clients prefer HTML, and closes the writer.
+func (m *Message) writeAlternatives(alt *multipart.Writer) error {
+	if err := writeTextPart(alt, "text/plain", m.Text); err != nil {
+		return err
+	}
+	if err := writeTextPart(alt, "text/html", m.HTML); err != nil {
+		return err
+	}
+	return alt.Close()
+}
+
+func writeTextPart(w *multipart.Writer, contentType, body string) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType+`; charset="utf-8"`)
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	return writeQuotedPrintable(part, body)
+}
+
+func writeAttachment(w *multipart.Writer, a Attachment) error {
+	mediaType, params, err := mime.ParseMediaType(a.ContentType)
+	if err != nil {
+		mediaType, params = "application/octet-stream", map[string]string{}
+	}
+	params["name"] = a.Filename
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	header.Set("Content-Transfer-Encoding", "base64")
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(a.Data)
+	for len(encoded) > 76 {
+		if _, err := part.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err = part.Write([]byte(encoded + "\r\n"))
+	return err
+}
+
+func writeQuotedPrintable(w interface{ Write([]byte) (int, error) }, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func writeHeader(buf *bytes.Buffer, key, value string) {
+	fmt.Fprintf(buf, "%s: %s\r\n", key, value)
+}
+
+// formatAddress parses an address such as "Menu Bot <menu@example.com>" and
+// returns it with the display name RFC 2047 encoded if needed.
+func formatAddress(addr string) (string, error) {
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil {
+		return "", err
+	}
+	return parsed.String(), nil
+}
+
+// addressOf returns the bare address from "Name <addr>", or addr unchanged
+// if it cannot be parsed.
+func addressOf(addr string) string {
+	if parsed, err := mail.ParseAddress(addr); err == nil {
+		return parsed.Address
+	}
+	return addr
+}
+
+// encodeHeaderValue Q-encodes values that contain non-ASCII characters and
+// strips line breaks so a value cannot inject extra headers.
+func encodeHeaderValue(v string) string {
+	v = strings.NewReplacer("\r", "", "\n", "").Replace(v)
+	return mime.QEncoding.Encode("utf-8", v)
+}
+
+// messageID returns a unique Message-ID in the sender's domain.
+func messageID(from string) string {
+	domain := "localhost"
+	if parsed, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(parsed.Address, "@"); at >= 0 {
+			domain = parsed.Address[at+1:]
+		}
+	}
+
+	var b [12]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("<%x.%d@%s>", b, time.Now().UnixNano(), domain)
+}
@@ -0,0 +1,146 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// readPart returns a part's media type, parameters and decoded body.
+// Quoted-printable bodies are decoded by multipart.Reader itself.
+func readPart(t *testing.T, p *multipart.Part) (string, map[string]string, string) {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(p.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body io.Reader = p
+	if p.Header.Get("Content-Transfer-Encoding") == "base64" {
+		body = base64.NewDecoder(base64.StdEncoding, p)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mediaType, params, string(data)
+}
+
+func TestMessageBytes(t *testing.T) {
+	ics := []byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nX-WR-CALNAME:" + strings.Repeat("Lunch ", 20) + "\r\nEND:VCALENDAR\r\n")
+	msg := &Message{
+		From:    "Cafétéria <menus@example.com>",
+		To:      []string{"parent@example.com"},
+		Subject: "Menü de la semaine 🍕",
+		Text:    "Lundi : Pizza à la crème",
+		HTML:    "<p>Lundi : Pizza à la crème</p>",
+		Attachments: []Attachment{
+			{Filename: "menu.ics", ContentType: "text/calendar; method=PUBLISH", Data: ics},
+		},
+	}
+	data, err := msg.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("parsing message: %v", err)
+	}
+
+	var dec mime.WordDecoder
+	if subject, err := dec.DecodeHeader(parsed.Header.Get("Subject")); err != nil || subject != msg.Subject {
+		t.Errorf("Subject = %q, %v, want %q", subject, err, msg.Subject)
+	}
+	if from, err := parsed.Header.AddressList("From"); err != nil || from[0].Name != "Cafétéria" {
+		t.Errorf("From = %v, %v", from, err)
+	}
+	if parsed.Header.Get("MIME-Version") != "1.0" || !strings.HasSuffix(parsed.Header.Get("Message-ID"), "@example.com>") {
+		t.Errorf("headers = %v", parsed.Header)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %s, %v, want multipart/mixed", mediaType, err)
+	}
+	mixed := multipart.NewReader(parsed.Body, params["boundary"])
+
+	// The body comes first, as text and HTML alternatives.
+	body, err := mixed.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err = mime.ParseMediaType(body.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("body Content-Type = %s, %v, want multipart/alternative", mediaType, err)
+	}
+	alt := multipart.NewReader(body, params["boundary"])
+	for _, want := range []struct{ mediaType, body string }{{"text/plain", msg.Text}, {"text/html", msg.HTML}} {
+		p, err := alt.NextPart()
+		if err != nil {
+			t.Fatalf("reading %s: %v", want.mediaType, err)
+		}
+		mediaType, params, text := readPart(t, p)
+		if mediaType != want.mediaType || params["charset"] != "utf-8" || text != want.body {
+			t.Errorf("part = %s %v %q, want %s %q", mediaType, params, text, want.mediaType, want.body)
+		}
+	}
+	if _, err := alt.NextPart(); err != io.EOF {
+		t.Errorf("more alternatives after HTML: %v", err)
+	}
+
+	// Then the calendar attachment.
+	attachment, err := mixed.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, content := readPart(t, attachment)
+	if mediaType != "text/calendar" || params["method"] != "PUBLISH" || params["name"] != "menu.ics" {
+		t.Errorf("attachment Content-Type = %s %v", mediaType, params)
+	}
+	disposition, dparams, err := mime.ParseMediaType(attachment.Header.Get("Content-Disposition"))
+	if err != nil || disposition != "attachment" || dparams["filename"] != "menu.ics" {
+		t.Errorf("Content-Disposition = %q", attachment.Header.Get("Content-Disposition"))
+	}
+	if content != string(ics) {
+		t.Errorf("attachment = %q, want %q", content, ics)
+	}
+	if _, err := mixed.NextPart(); err != io.EOF {
+		t.Errorf("more parts after the attachment: %v", err)
+	}
+}
+
+func TestMessageBytesLayout(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+		want string
+	}{
+		{"text", Message{Text: "Pizza"}, "text/plain"},
+		{"html", Message{Text: "Pizza", HTML: "<p>Pizza</p>"}, "multipart/alternative"},
+		{"text and attachment", Message{Text: "Pizza", Attachments: []Attachment{{Filename: "menu.pdf", ContentType: "application/pdf"}}}, "multipart/mixed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.msg.From = "menus@example.com"
+			tt.msg.Subject = "Lunch\r\nBcc: everyone@example.com"
+			data, err := tt.msg.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := mail.ReadMessage(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mediaType, _, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type")); mediaType != tt.want {
+				t.Errorf("Content-Type = %s, want %s", mediaType, tt.want)
+			}
+			if _, ok := parsed.Header["Bcc"]; ok || parsed.Header.Get("Subject") != "LunchBcc: everyone@example.com" {
+				t.Errorf("Subject = %q, want line breaks stripped", parsed.Header.Get("Subject"))
+			}
+		})
+	}
+}
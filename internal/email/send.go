@@ -2,46 +2,55 @@ package email
 
 import (
 	"fmt"
-	"net/smtp"
+	"os"
 	"strings"
+	"time"
 
+	"github.com/asachs01/school_menu_connector/internal/ics"
 	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/render"
 )
 
-// Send sends an email with the given parameters
+// Send sends a plain-text email with the given parameters. Recipients are
+// blind-copied so they cannot see each other's addresses.
 func Send(smtpServer, from, password string, to []string, subject, body string) error {
-	mainRecipient := to[0]
-	bcc := strings.Join(to[1:], ", ")
-
-	header := make(map[string]string)
-	header["From"] = from
-	header["To"] = mainRecipient
-	header["Subject"] = subject
-	header["MIME-Version"] = "1.0"
-	header["Content-Type"] = "text/plain; charset=\"utf-8\""
-	if bcc != "" {
-		header["Bcc"] = bcc
-	}
-
-	message := ""
-	for k, v := range header {
-		message += fmt.Sprintf("%s: %s\r\n", k, v)
-	}
-	message += "\r\n" + body
-
-	auth := smtp.PlainAuth("", from, password, strings.Split(smtpServer, ":")[0])
+	mailer := &SMTPMailer{Config: SMTPConfig{Server: smtpServer, Password: password}}
+	_, err := mailer.Send(&Message{From: from, Subject: subject, Text: body}, to, DeliveryOptions{})
+	return err
+}
 
-	return smtp.SendMail(smtpServer, auth, from, to, []byte(message))
+// MenuEmailOptions holds the optional parts of a menu email.
+type MenuEmailOptions struct {
+	// School is the school name shown in the email.
+	School string
+	// Templates renders the subject and bodies. Nil uses the built-in
+	// templates.
+	Templates *Templates
+	// AttachICS attaches the menu as a calendar file.
+	AttachICS bool
+	// Delivery selects blind-copied batches or individual messages.
+	Delivery DeliveryOptions
 }
 
-func SendLunchMenu(buildingID, districtID, startDate, endDate string, recipients, smtpServer, sender, password, subject string, debug bool) error {
+// SendLunchMenu emails the lunch menu for the date range as an HTML message
+// with a plain-text alternative. An empty subject uses the subject template.
+func SendLunchMenu(buildingID, districtID, startDate, endDate, recipients string, mailer Mailer, sender, subject string, opts MenuEmailOptions, debug bool) error {
+	start, err := time.Parse("01-02-2006", startDate)
+	if err != nil {
+		return fmt.Errorf("invalid start date: %w", err)
+	}
+	end, err := time.Parse("01-02-2006", endDate)
+	if err != nil {
+		return fmt.Errorf("invalid end date: %w", err)
+	}
+
 	menuData, err := menu.Fetch(buildingID, districtID, startDate, endDate, debug)
 	if err != nil {
 		return fmt.Errorf("fetching menu: %w", err)
 	}
 
-	lunchMenu := menuData.GetLunchMenuString()
-	if lunchMenu == "" {
+	days := menuData.Days("Lunch")
+	if len(days) == 0 {
 		return fmt.Errorf("no lunch menu found for the specified date range")
 	}
 
@@ -50,17 +59,71 @@ func SendLunchMenu(buildingID, districtID, startDate, endDate string, recipients
 		recipientList[i] = strings.TrimSpace(email)
 	}
 
-	if subject == "" {
-		subject = fmt.Sprintf("Lunch Menu (%s - %s)", startDate, endDate)
+	doc := render.Document{School: opts.School, Days: days}
+	return SendMenu(doc, start, end, recipientList, mailer, sender, subject, opts, debug)
+}
+
+// SendMenu emails an already fetched menu covering start to end. The
+// document's school name is used rather than opts.School.
+func SendMenu(doc render.Document, start, end time.Time, recipients []string, mailer Mailer, sender, subject string, opts MenuEmailOptions, debug bool) error {
+	data := NewTemplateData(doc, start, end)
+	msg, err := MenuMessage(opts.Templates, data, sender, subject)
+	if err != nil {
+		return err
+	}
+	if opts.AttachICS {
+		filename := fmt.Sprintf("lunch_menu_%s_to_%s.ics", start.Format("01-02-2006"), end.Format("01-02-2006"))
+		msg.Attachments = append(msg.Attachments, CalendarAttachment(doc.Days, filename))
 	}
 
 	if debug {
-		fmt.Printf("Sending email to %s with subject: %s\n", recipients, subject)
+		fmt.Fprintf(os.Stderr, "Sending email to %s with subject: %s\n", strings.Join(recipients, ", "), msg.Subject)
 	}
 
-	if err := Send(smtpServer, sender, password, recipientList, subject, lunchMenu); err != nil {
-		return fmt.Errorf("sending email: %w", err)
+	results, err := mailer.Send(msg, recipients, opts.Delivery)
+	if debug {
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Fprintf(os.Stderr, "Delivery to %s failed: %v\n", r.Recipient, r.Err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Delivered to %s\n", r.Recipient)
+			}
+		}
 	}
+	return err
+}
 
-	return nil
+// MenuMessage renders a menu email from templates. The HTML body groups
+// meals by category color and the plain-text body is its alternative. A
+// non-empty subject overrides the subject template; nil templates use the
+// built-in ones.
+func MenuMessage(tmpl *Templates, data TemplateData, from, subject string) (*Message, error) {
+	if tmpl == nil {
+		tmpl = DefaultTemplates()
+	}
+
+	renderedSubject, text, html, err := tmpl.Execute(data)
+	if err != nil {
+		return nil, err
+	}
+	if subject == "" {
+		subject = renderedSubject
+	}
+
+	return &Message{
+		From:    from,
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+	}, nil
+}
+
+// CalendarAttachment returns days as an iCalendar attachment that mail
+// clients offer to add to the recipient's calendar.
+func CalendarAttachment(days []menu.Day, filename string) Attachment {
+	return Attachment{
+		Filename:    filename,
+		ContentType: "text/calendar; charset=utf-8; method=PUBLISH",
+		Data:        ics.Generate(days),
+	}
 }
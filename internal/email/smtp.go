@@ -0,0 +1,288 @@
+package email
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Security is how the connection to the SMTP server is encrypted.
+type Security string
+
+const (
+	// SecurityAuto uses implicit TLS on port 465 and otherwise upgrades
+	// with STARTTLS if the server offers it.
+	SecurityAuto Security = ""
+	// SecurityTLS connects with TLS from the start (SMTPS, usually port 465).
+	SecurityTLS Security = "tls"
+	// SecurityStartTLS requires the server to support STARTTLS.
+	SecurityStartTLS Security = "starttls"
+	// SecurityNone never encrypts, for local relays.
+	SecurityNone Security = "none"
+)
+
+// AuthMechanism is the SMTP AUTH mechanism used to log in.
+type AuthMechanism string
+
+const (
+	// AuthAuto picks PLAIN, LOGIN or CRAM-MD5, in that order, from the
+	// mechanisms the server offers. It skips authentication if there is no
+	// password or the server does not support AUTH.
+	AuthAuto    AuthMechanism = ""
+	AuthPlain   AuthMechanism = "plain"
+	AuthLogin   AuthMechanism = "login"
+	AuthCRAMMD5 AuthMechanism = "cram-md5"
+	AuthNone    AuthMechanism = "none"
+)
+
+// Default SMTP timeouts.
+const (
+	DefaultDialTimeout = 30 * time.Second
+	DefaultSMTPTimeout = 2 * time.Minute
+)
+
+// SMTPConfig describes how to connect and log in to an SMTP server.
+type SMTPConfig struct {
+	// Server is the host and port, e.g. smtp.gmail.com:587.
+	Server string
+	// Username defaults to the sender's address.
+	Username string
+	Password string
+	Security Security
+	Auth     AuthMechanism
+	// CAFile is a PEM file of extra CA certificates to trust, for servers
+	// with private certificates.
+	CAFile string
+	// DialTimeout limits connecting and the TLS handshake.
+	DialTimeout time.Duration
+	// Timeout limits each message, from MAIL FROM to the end of DATA.
+	Timeout time.Duration
+}
+
+// ParseSecurity parses a Security name: auto, tls (or ssl), starttls or none.
+func ParseSecurity(s string) (Security, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "auto":
+		return SecurityAuto, nil
+	case "tls", "ssl", "smtps", "implicit":
+		return SecurityTLS, nil
+	case "starttls":
+		return SecurityStartTLS, nil
+	case "none", "plain", "insecure":
+		return SecurityNone, nil
+	}
+	return "", fmt.Errorf("unknown SMTP security %q (want auto, tls, starttls or none)", s)
+}
+
+// ParseAuth parses an AuthMechanism name: auto, plain, login, cram-md5 or
+// none.
+func ParseAuth(s string) (AuthMechanism, error) {
+	switch m := AuthMechanism(strings.ToLower(strings.TrimSpace(s))); m {
+	case "", "auto":
+		return AuthAuto, nil
+	case AuthPlain, AuthLogin, AuthCRAMMD5, AuthNone:
+		return m, nil
+	case "crammd5":
+		return AuthCRAMMD5, nil
+	}
+	return "", fmt.Errorf("unknown SMTP auth mechanism %q (want auto, plain, login, cram-md5 or none)", s)
+}
+
+// smtpConn is an open SMTP session.
+type smtpConn struct {
+	*smtp.Client
+	conn    net.Conn
+	timeout time.Duration
+}
+
+// extendDeadline gives the next message the full configured timeout.
+func (c *smtpConn) extendDeadline() {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+}
+
+// dial connects and logs in to the server described by cfg. from is the
+// sender address, used as the username if none is configured.
+func (cfg SMTPConfig) dial(from string) (*smtpConn, error) {
+	host, port, err := net.SplitHostPort(cfg.Server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP server %q: %w", cfg.Server, err)
+	}
+
+	dialTimeout := cfg.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSMTPTimeout
+	}
+
+	tlsConfig, err := cfg.tlsConfig(host)
+	if err != nil {
+		return nil, err
+	}
+
+	security := cfg.Security
+	if security == SecurityAuto && port == "465" {
+		security = SecurityTLS
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	if security == SecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", cfg.Server, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", cfg.Server)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connecting to SMTP server: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connecting to SMTP server: %w", err)
+	}
+	c := &smtpConn{Client: client, conn: conn, timeout: timeout}
+
+	if security == SecurityAuto || security == SecurityStartTLS {
+		ok, _ := c.Extension("STARTTLS")
+		if !ok && security == SecurityStartTLS {
+			c.Close()
+			return nil, errors.New("SMTP server does not support STARTTLS")
+		}
+		if ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				c.Close()
+				return nil, fmt.Errorf("starting TLS: %w", err)
+			}
+		}
+	}
+
+	auth, err := cfg.auth(c, host, from)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("authenticating: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+func (cfg SMTPConfig) tlsConfig(host string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: host}
+	if cfg.CAFile == "" {
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(cfg.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA file: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+	}
+	config.RootCAs = pool
+	return config, nil
+}
+
+// auth returns the smtp.Auth for the configured mechanism, or nil to skip
+// authentication.
+func (cfg SMTPConfig) auth(c *smtpConn, host, from string) (smtp.Auth, error) {
+	username := cfg.Username
+	if username == "" {
+		username = from
+	}
+
+	mechanism := cfg.Auth
+	if mechanism == AuthNone {
+		return nil, nil
+	}
+	ok, offered := c.Extension("AUTH")
+	if mechanism == AuthAuto {
+		if !ok || cfg.Password == "" {
+			return nil, nil
+		}
+		mechanism = AuthPlain
+		mechanisms := strings.Fields(strings.ToUpper(offered))
+		for _, m := range []AuthMechanism{AuthPlain, AuthLogin, AuthCRAMMD5} {
+			if contains(mechanisms, strings.ToUpper(string(m))) {
+				mechanism = m
+				break
+			}
+		}
+	} else if !ok {
+		return nil, errors.New("SMTP server does not support authentication")
+	}
+
+	switch mechanism {
+	case AuthPlain:
+		return smtp.PlainAuth("", username, cfg.Password, host), nil
+	case AuthLogin:
+		return &loginAuth{username: username, password: cfg.Password, host: host}, nil
+	case AuthCRAMMD5:
+		return smtp.CRAMMD5Auth(username, cfg.Password), nil
+	}
+	return nil, fmt.Errorf("unknown SMTP auth mechanism %q", mechanism)
+}
+
+// loginAuth implements the LOGIN mechanism, which some servers (notably
+// Office 365 and older Exchange) offer instead of PLAIN.
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Like smtp.PlainAuth, refuse to send the password in the clear except
+	// to localhost.
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	prompt := strings.ToLower(string(fromServer))
+	switch {
+	case strings.Contains(prompt, "user"):
+		return []byte(a.username), nil
+	case strings.Contains(prompt, "pass"):
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,491 @@
+package email
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is a scripted SMTP server for testing the client side of the
+// dialogue. It accepts every message unless told otherwise.
+type fakeSMTP struct {
+	ln  net.Listener
+	tls *tls.Config
+	// starttls offers STARTTLS and auth the AUTH mechanisms, e.g. "PLAIN
+	// LOGIN".
+	starttls bool
+	auth     string
+	password string
+	// reject refuses RCPT TO for these addresses.
+	reject map[string]bool
+	// stall stops answering at this command, or at the greeting.
+	stall string
+
+	mu       sync.Mutex
+	commands []string
+	logins   []fakeLogin
+	mails    []fakeMail
+}
+
+type fakeLogin struct {
+	mechanism, username string
+	secure              bool
+}
+
+type fakeMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// start listens on a local port, with TLS from the start if implicitTLS.
+func (s *fakeSMTP) start(t *testing.T, implicitTLS bool) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if implicitTLS {
+		ln = tls.NewListener(ln, s.tls)
+	}
+	s.ln = ln
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	if s.stall == "greeting" {
+		io.Copy(io.Discard, conn)
+		return
+	}
+	_, secure := conn.(*tls.Conn)
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 fake ESMTP")
+
+	var mail fakeMail
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			tp.PrintfLine("500 empty command")
+			continue
+		}
+		verb := strings.ToUpper(fields[0])
+		s.mu.Lock()
+		s.commands = append(s.commands, verb)
+		s.mu.Unlock()
+		if verb == s.stall {
+			io.Copy(io.Discard, conn)
+			return
+		}
+
+		switch verb {
+		case "EHLO":
+			lines := []string{"fake"}
+			if s.starttls && !secure {
+				lines = append(lines, "STARTTLS")
+			}
+			if s.auth != "" {
+				lines = append(lines, "AUTH "+s.auth)
+			}
+			for _, l := range lines {
+				tp.PrintfLine("250-%s", l)
+			}
+			tp.PrintfLine("250 HELP")
+		case "STARTTLS":
+			tp.PrintfLine("220 ready to start TLS")
+			tc := tls.Server(conn, s.tls)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tc, true
+			tp = textproto.NewConn(conn)
+		case "AUTH":
+			s.authenticate(tp, fields[1:], secure)
+		case "MAIL":
+			mail = fakeMail{from: envelopeAddress(line)}
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			addr := envelopeAddress(line)
+			if s.reject[addr] {
+				tp.PrintfLine("550 5.1.1 no such user")
+				continue
+			}
+			mail.to = append(mail.to, addr)
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.data = string(data)
+			s.mu.Lock()
+			s.mails = append(s.mails, mail)
+			s.mu.Unlock()
+			mail = fakeMail{}
+			tp.PrintfLine("250 queued")
+		case "RSET":
+			mail = fakeMail{}
+			tp.PrintfLine("250 ok")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+// authenticate answers an AUTH command, checking the password.
+func (s *fakeSMTP) authenticate(tp *textproto.Conn, args []string, secure bool) {
+	challenge := func(prompt string) (string, bool) {
+		tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(prompt)))
+		line, err := tp.ReadLine()
+		if err != nil {
+			return "", false
+		}
+		b, err := base64.StdEncoding.DecodeString(line)
+		return string(b), err == nil
+	}
+
+	var mechanism, username, password string
+	ok := len(args) > 0
+	if ok {
+		mechanism = strings.ToUpper(args[0])
+	}
+	switch mechanism {
+	case "PLAIN":
+		var resp string
+		if len(args) > 1 {
+			b, err := base64.StdEncoding.DecodeString(args[1])
+			resp, ok = string(b), err == nil
+		} else {
+			resp, ok = challenge("")
+		}
+		parts := strings.Split(resp, "\x00")
+		if ok = ok && len(parts) == 3; ok {
+			username, password = parts[1], parts[2]
+		}
+	case "LOGIN":
+		username, ok = challenge("Username:")
+		if ok {
+			password, ok = challenge("Password:")
+		}
+	case "CRAM-MD5":
+		nonce := "<1896.697170952@fake>"
+		var resp string
+		resp, ok = challenge(nonce)
+		var digest string
+		username, digest, _ = strings.Cut(resp, " ")
+		mac := hmac.New(md5.New, []byte(s.password))
+		mac.Write([]byte(nonce))
+		// The digest proves the password without sending it.
+		if ok = ok && digest == hex.EncodeToString(mac.Sum(nil)); ok {
+			password = s.password
+		}
+	default:
+		tp.PrintfLine("504 unrecognized mechanism")
+		return
+	}
+
+	s.mu.Lock()
+	s.logins = append(s.logins, fakeLogin{mechanism, username, secure})
+	s.mu.Unlock()
+	if !ok || password != s.password {
+		tp.PrintfLine("535 5.7.8 bad credentials")
+		return
+	}
+	tp.PrintfLine("235 authenticated")
+}
+
+// results returns the logins and messages the server has seen.
+func (s *fakeSMTP) results() ([]fakeLogin, []fakeMail) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]fakeLogin(nil), s.logins...), append([]fakeMail(nil), s.mails...)
+}
+
+func (s *fakeSMTP) sent(verb string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return contains(s.commands, verb)
+}
+
+func envelopeAddress(line string) string {
+	start, end := strings.Index(line, "<"), strings.Index(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+// testTLS returns a server config with a self-signed certificate for
+// 127.0.0.1, and a CA file that trusts it.
+func testTLS(t *testing.T) (*tls.Config, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake smtp"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, caFile
+}
+
+func testMessage() *Message {
+	return &Message{From: "Menus <menus@example.com>", Subject: "Lunch menu", Text: "Pizza"}
+}
+
+func TestSendMessageImplicitTLS(t *testing.T) {
+	tlsConfig, caFile := testTLS(t)
+	s := &fakeSMTP{tls: tlsConfig, auth: "PLAIN LOGIN", password: "secret"}
+	addr := s.start(t, true)
+
+	cfg := SMTPConfig{Server: addr, Password: "secret", Security: SecurityTLS, CAFile: caFile}
+	recipients := []string{"a@example.com", "Bee <b@example.com>"}
+	results, err := SendMessage(cfg, testMessage(), recipients, DeliveryOptions{})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	logins, mails := s.results()
+	if len(logins) != 1 || logins[0] != (fakeLogin{"PLAIN", "menus@example.com", true}) {
+		t.Errorf("logins = %+v, want PLAIN as the sender over TLS", logins)
+	}
+	if s.sent("STARTTLS") {
+		t.Error("sent STARTTLS on an implicit TLS connection")
+	}
+	if len(mails) != 1 {
+		t.Fatalf("got %d messages, want 1", len(mails))
+	}
+	m := mails[0]
+	if m.from != "menus@example.com" || strings.Join(m.to, ",") != "a@example.com,b@example.com" {
+		t.Errorf("envelope = %s to %v", m.from, m.to)
+	}
+	if !strings.Contains(m.data, "To: undisclosed-recipients:;") || strings.Contains(m.data, "b@example.com") {
+		t.Errorf("message reveals recipients:\n%s", m.data)
+	}
+}
+
+func TestSendMessageStartTLS(t *testing.T) {
+	tlsConfig, caFile := testTLS(t)
+	tests := []struct {
+		name     string
+		security Security
+		offered  bool
+		upgrade  bool
+		wantErr  string
+	}{
+		{"auto upgrades", SecurityAuto, true, true, ""},
+		{"auto without offer", SecurityAuto, false, false, ""},
+		{"required", SecurityStartTLS, true, true, ""},
+		{"required without offer", SecurityStartTLS, false, false, "does not support STARTTLS"},
+		{"none never upgrades", SecurityNone, true, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fakeSMTP{tls: tlsConfig, starttls: tt.offered, auth: "PLAIN", password: "secret"}
+			addr := s.start(t, false)
+			cfg := SMTPConfig{Server: addr, Username: "user", Password: "secret", Security: tt.security, CAFile: caFile}
+			_, err := SendMessage(cfg, testMessage(), []string{"a@example.com"}, DeliveryOptions{})
+			logins, mails := s.results()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if len(mails) != 0 {
+					t.Error("sent a message anyway")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SendMessage: %v", err)
+			}
+			if got := s.sent("STARTTLS"); got != tt.upgrade {
+				t.Errorf("sent STARTTLS = %v, want %v", got, tt.upgrade)
+			}
+			if len(logins) != 1 || logins[0].secure != tt.upgrade {
+				t.Errorf("logins = %+v, want one with secure = %v", logins, tt.upgrade)
+			}
+		})
+	}
+}
+
+func TestSendMessageAuth(t *testing.T) {
+	tests := []struct {
+		name      string
+		mechanism AuthMechanism
+		offered   string
+		password  string
+		want      string
+		wantErr   bool
+	}{
+		{"plain", AuthPlain, "PLAIN LOGIN CRAM-MD5", "secret", "PLAIN", false},
+		{"login", AuthLogin, "PLAIN LOGIN CRAM-MD5", "secret", "LOGIN", false},
+		{"cram-md5", AuthCRAMMD5, "PLAIN LOGIN CRAM-MD5", "secret", "CRAM-MD5", false},
+		{"auto prefers plain", AuthAuto, "CRAM-MD5 LOGIN PLAIN", "secret", "PLAIN", false},
+		{"auto falls back to login", AuthAuto, "CRAM-MD5 LOGIN", "secret", "LOGIN", false},
+		{"auto falls back to cram-md5", AuthAuto, "cram-md5", "secret", "CRAM-MD5", false},
+		{"auto without password", AuthAuto, "PLAIN", "", "", false},
+		{"none", AuthNone, "PLAIN", "secret", "", false},
+		{"wrong password", AuthPlain, "PLAIN", "wrong", "PLAIN", true},
+		{"wrong cram-md5 password", AuthCRAMMD5, "CRAM-MD5", "wrong", "CRAM-MD5", true},
+		{"not offered", AuthLogin, "", "secret", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fakeSMTP{auth: tt.offered, password: "secret"}
+			addr := s.start(t, false)
+			cfg := SMTPConfig{Server: addr, Username: "user", Password: tt.password, Security: SecurityNone, Auth: tt.mechanism}
+			_, err := SendMessage(cfg, testMessage(), []string{"a@example.com"}, DeliveryOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			logins, mails := s.results()
+			var got string
+			if len(logins) > 0 {
+				got = logins[0].mechanism
+				if logins[0].username != "user" {
+					t.Errorf("username = %q, want user", logins[0].username)
+				}
+			}
+			if got != tt.want {
+				t.Errorf("mechanism = %q, want %q", got, tt.want)
+			}
+			want := 1
+			if tt.wantErr {
+				want = 0
+			}
+			if len(mails) != want {
+				t.Errorf("sent %d messages, want %d", len(mails), want)
+			}
+		})
+	}
+}
+
+func TestSendMessageTimeouts(t *testing.T) {
+	tests := []struct {
+		name  string
+		stall string
+		cfg   SMTPConfig
+	}{
+		{"greeting", "greeting", SMTPConfig{DialTimeout: 100 * time.Millisecond}},
+		{"data", "DATA", SMTPConfig{Timeout: 100 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fakeSMTP{stall: tt.stall}
+			cfg := tt.cfg
+			cfg.Server = s.start(t, false)
+			cfg.Security = SecurityNone
+
+			begin := time.Now()
+			results, err := SendMessage(cfg, testMessage(), []string{"a@example.com"}, DeliveryOptions{})
+			if elapsed := time.Since(begin); elapsed > 5*time.Second {
+				t.Errorf("took %v to time out", elapsed)
+			}
+			var delivery *DeliveryError
+			if !errors.As(err, &delivery) {
+				t.Fatalf("err = %v, want a *DeliveryError", err)
+			}
+			var netErr net.Error
+			if len(results) != 1 || !errors.As(results[0].Err, &netErr) || !netErr.Timeout() {
+				t.Errorf("results = %+v, want a timeout", results)
+			}
+		})
+	}
+}
+
+func TestSendMessageIndividualFailures(t *testing.T) {
+	s := &fakeSMTP{reject: map[string]bool{"gone@example.com": true}}
+	addr := s.start(t, false)
+
+	cfg := SMTPConfig{Server: addr, Security: SecurityNone}
+	recipients := []string{"a@example.com", "gone@example.com", "c@example.com"}
+	results, err := SendMessage(cfg, testMessage(), recipients, DeliveryOptions{Mode: DeliverIndividual})
+
+	var delivery *DeliveryError
+	if !errors.As(err, &delivery) {
+		t.Fatalf("err = %v, want a *DeliveryError", err)
+	}
+	failed := delivery.Failed()
+	if len(failed) != 1 || failed[0].Recipient != "gone@example.com" {
+		t.Fatalf("failed = %+v, want only gone@example.com", failed)
+	}
+	var smtpErr *textproto.Error
+	if !errors.As(failed[0].Err, &smtpErr) || smtpErr.Code != 550 {
+		t.Errorf("failure = %v, want the server's 550", failed[0].Err)
+	}
+	for i, r := range results {
+		if r.Recipient != recipients[i] {
+			t.Errorf("result %d is for %s, want %s", i, r.Recipient, recipients[i])
+		}
+	}
+
+	_, mails := s.results()
+	if len(mails) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(mails))
+	}
+	for i, want := range []string{"a@example.com", "c@example.com"} {
+		m := mails[i]
+		if len(m.to) != 1 || m.to[0] != want {
+			t.Errorf("message %d envelope = %v, want %s", i, m.to, want)
+		}
+		if !strings.Contains(m.data, "To: <"+want+">") {
+			t.Errorf("message %d is not addressed to %s:\n%s", i, want, m.data)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package email
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/render"
+)
+
+// Template file names. A template directory may contain any of these; the
+// embedded defaults are used for the rest.
+const (
+	SubjectTemplate = "subject.tmpl"
+	TextTemplate    = "body.txt.tmpl"
+	HTMLTemplate    = "body.html.tmpl"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+//go:embed templates/fixture.json
+var fixtureJSON []byte
+
+// TemplateData is the model passed to email templates.
+type TemplateData struct {
+	School    string
+	StartDate time.Time
+	EndDate   time.Time
+	// Sessions lists the serving sessions that appear in Days, in order.
+	Sessions []string
+	Days     []menu.Day
+	// AllergenNames maps LINQ allergen IDs to display names.
+	AllergenNames map[string]string
+	// UnsubscribeURL is set for subscription digests.
+	UnsubscribeURL string
+}
+
+// NewTemplateData builds the template model for doc over a date range.
+func NewTemplateData(doc render.Document, start, end time.Time) TemplateData {
+	var sessions []string
+	seen := make(map[string]bool)
+	for _, d := range doc.Days {
+		for _, s := range d.Sessions {
+			if !seen[s.Name] {
+				seen[s.Name] = true
+				sessions = append(sessions, s.Name)
+			}
+		}
+	}
+
+	return TemplateData{
+		School:        doc.School,
+		StartDate:     start,
+		EndDate:       end,
+		Sessions:      sessions,
+		Days:          doc.Days,
+		AllergenNames: doc.AllergenNames,
+	}
+}
+
+// AllergensFor returns the names of an item's allergens. Allergens without a
+// known name are left out.
+func (d TemplateData) AllergensFor(item menu.Item) []string {
+	var names []string
+	for _, id := range item.Allergens {
+		if name := d.AllergenNames[id]; name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Templates renders the subject, plain-text body and HTML body of a menu
+// email.
+type Templates struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+func templateFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		"join":        strings.Join,
+		"meals":       render.Meals,
+		"sessionText": render.SessionText,
+		"nutrient": func(item menu.Item, name string) float64 {
+			for _, n := range item.Nutrients {
+				if n.Name == name {
+					return n.Value
+				}
+			}
+			return 0
+		},
+		"number": func(v float64) string {
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		},
+		"color": func(c string) string {
+			return c
+		},
+	}
+}
+
+// DefaultTemplates returns the built-in templates.
+func DefaultTemplates() *Templates {
+	t, err := LoadTemplates("")
+	if err != nil {
+		panic(fmt.Sprintf("email: parsing default templates: %v", err))
+	}
+	return t
+}
+
+// LoadTemplates reads templates from dir, falling back to the built-in
+// template for any file that is missing. An empty dir loads only the
+// built-in templates.
+func LoadTemplates(dir string) (*Templates, error) {
+	read := func(name string) (string, error) {
+		if dir != "" {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err == nil {
+				return string(data), nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return "", fmt.Errorf("reading template: %w", err)
+			}
+		}
+		data, err := defaultTemplates.ReadFile("templates/" + name)
+		if err != nil {
+			return "", fmt.Errorf("reading default template: %w", err)
+		}
+		return string(data), nil
+	}
+
+	funcs := templateFuncs()
+	htmlFuncs := templateFuncs()
+	htmlFuncs["color"] = func(c string) htmltemplate.CSS {
+		if hexColor(c) {
+			return htmltemplate.CSS(c)
+		}
+		return htmltemplate.CSS("#e9ecef")
+	}
+
+	var t Templates
+	src, err := read(SubjectTemplate)
+	if err != nil {
+		return nil, err
+	}
+	if t.subject, err = texttemplate.New(SubjectTemplate).Funcs(funcs).Parse(src); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", SubjectTemplate, err)
+	}
+
+	if src, err = read(TextTemplate); err != nil {
+		return nil, err
+	}
+	if t.text, err = texttemplate.New(TextTemplate).Funcs(funcs).Parse(src); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", TextTemplate, err)
+	}
+
+	if src, err = read(HTMLTemplate); err != nil {
+		return nil, err
+	}
+	if t.html, err = htmltemplate.New(HTMLTemplate).Funcs(htmlFuncs).Parse(src); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", HTMLTemplate, err)
+	}
+
+	return &t, nil
+}
+
+// Execute renders all three templates with data. The subject is collapsed
+// onto a single line.
+func (t *Templates) Execute(data TemplateData) (subject, text, html string, err error) {
+	var b strings.Builder
+	if err := t.subject.Execute(&b, data); err != nil {
+		return "", "", "", fmt.Errorf("rendering subject: %w", err)
+	}
+	subject = strings.Join(strings.Fields(b.String()), " ")
+
+	b.Reset()
+	if err := t.text.Execute(&b, data); err != nil {
+		return "", "", "", fmt.Errorf("rendering text body: %w", err)
+	}
+	text = b.String()
+
+	b.Reset()
+	if err := t.html.Execute(&b, data); err != nil {
+		return "", "", "", fmt.Errorf("rendering HTML body: %w", err)
+	}
+	html = b.String()
+
+	return subject, text, html, nil
+}
+
+// FixtureData returns sample template data built from a saved LINQ Connect
+// response, for previewing templates without calling the API.
+func FixtureData() (TemplateData, error) {
+	return FixtureDataFrom(fixtureJSON)
+}
+
+// FixtureDataFrom builds template data from a raw LINQ Connect FamilyMenu
+// response.
+func FixtureDataFrom(data []byte) (TemplateData, error) {
+	var m menu.Menu
+	if err := json.Unmarshal(data, &m); err != nil {
+		return TemplateData{}, fmt.Errorf("parsing fixture: %w", err)
+	}
+
+	days := m.Days()
+	if len(days) == 0 {
+		return TemplateData{}, fmt.Errorf("fixture contains no menu days")
+	}
+	return NewTemplateData(render.Document{School: "Sample Elementary", Days: days}, days[0].Time(), days[len(days)-1].Time()), nil
+}
+
+func hexColor(c string) bool {
+	if len(c) != 4 && len(c) != 7 || c[0] != '#' {
+		return false
+	}
+	for _, r := range c[1:] {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
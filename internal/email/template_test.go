@@ -0,0 +1,79 @@
+package email
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTemplate(t *testing.T, dir, name, src string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadTemplatesOverride(t *testing.T) {
+	data, err := FixtureData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, defaultText, defaultHTML, err := DefaultTemplates().Execute(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	writeTemplate(t, dir, SubjectTemplate, "{{.School}}\n  lunches from {{.StartDate.Format \"Jan 2\"}}\n")
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	subject, text, html, err := templates.Execute(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "Sample Elementary lunches from Sep 4" {
+		t.Errorf("subject = %q, want the overridden subject on one line", subject)
+	}
+	if text != defaultText || html != defaultHTML {
+		t.Error("the text and HTML bodies are not the defaults")
+	}
+	if !strings.Contains(text, "Cheesy Pasta Bake") {
+		t.Errorf("text body is missing the fixture menu:\n%s", text)
+	}
+}
+
+func TestLoadTemplatesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		src     string
+		wantErr string
+	}{
+		{"subject", SubjectTemplate, "{{.School", "parsing subject.tmpl: template: subject.tmpl:1: unclosed action"},
+		{"text", TextTemplate, "{{range .Days}}", "parsing body.txt.tmpl: template: body.txt.tmpl:1: unexpected EOF"},
+		{"html", HTMLTemplate, "{{nosuchfunc .School}}", `parsing body.html.tmpl: template: body.html.tmpl:1: function "nosuchfunc" not defined`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTemplate(t, dir, tt.file, tt.src)
+			_, err := LoadTemplates(dir)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("LoadTemplates = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// A template that exists but cannot be read is an error, not a reason
+	// to fall back to the default.
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, TextTemplate), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTemplates(dir); err == nil || !strings.HasPrefix(err.Error(), "reading template:") {
+		t.Errorf("LoadTemplates with an unreadable template = %v", err)
+	}
+}
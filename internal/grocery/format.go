@@ -0,0 +1,151 @@
+package grocery
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/planner"
+)
+
+// Formats are the output formats a list can be written in.
+var Formats = []string{"markdown", "csv", "todo", "json"}
+
+var reasonLabels = map[string]string{
+	planner.ReasonNoMenu:   "no menu",
+	planner.ReasonDisliked: "disliked entrees",
+	planner.ReasonAllergen: "allergens",
+}
+
+// Title names the list, e.g. "Packed lunch groceries: Lincoln Elementary,
+// October 19 to 23".
+func (l List) Title() string {
+	title := "Packed lunch groceries"
+	if l.School != "" {
+		title += ": " + l.School
+	}
+	if l.Start == "" {
+		return title
+	}
+	start, end := day(l.Start), day(l.End)
+	switch {
+	case l.Start == l.End:
+		return title + ", " + start.Format("January 2")
+	case start.Month() == end.Month():
+		return title + ", " + start.Format("January 2") + " to " + end.Format("2")
+	}
+	return title + ", " + start.Format("January 2") + " to " + end.Format("January 2")
+}
+
+// WriteMarkdown writes the lunches and a checklist of groceries.
+func WriteMarkdown(w io.Writer, l List) error {
+	fmt.Fprintf(w, "# %s\n\n", l.Title())
+	if len(l.Lunches) == 0 {
+		_, err := fmt.Fprintln(w, "No packed lunches needed.")
+		return err
+	}
+
+	fmt.Fprintln(w, "## Lunches")
+	fmt.Fprintln(w)
+	for _, lunch := range l.Lunches {
+		option := lunch.Option
+		if option == "" {
+			option = "no safe pantry option"
+		}
+		fmt.Fprintf(w, "- %s: %s (%s)\n", day(lunch.Date).Format("Monday, January 2"), option, reasons(lunch.Reasons))
+	}
+
+	fmt.Fprintln(w, "\n## Shopping list")
+	fmt.Fprintln(w)
+	if len(l.Items) == 0 {
+		fmt.Fprintln(w, "Nothing to buy.")
+	}
+	for _, it := range l.Items {
+		fmt.Fprintf(w, "- [ ] %s: %s\n", it.Name, amount(it))
+	}
+
+	if len(l.Excluded) > 0 {
+		fmt.Fprintln(w, "\n## Left out")
+		fmt.Fprintln(w)
+		for _, e := range l.Excluded {
+			fmt.Fprintf(w, "- %s: %s\n", e.Option, e.Reason)
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes the shopping list, one item per row.
+func WriteCSV(w io.Writer, l List) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"item", "quantity", "unit", "for", "first_needed"})
+	for _, it := range l.Items {
+		cw.Write([]string{it.Name, quantity(it.Quantity), it.Unit, strings.Join(it.For, "; "), it.FirstNeeded})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// todoList is the to-do app format: a titled list of tasks, each due the
+// day before it is first packed.
+type todoList struct {
+	Title string     `json:"title"`
+	Tasks []todoTask `json:"tasks"`
+}
+
+type todoTask struct {
+	Title string `json:"title"`
+	Notes string `json:"notes,omitempty"`
+	// Due is YYYY-MM-DD.
+	Due       string `json:"due"`
+	Completed bool   `json:"completed"`
+}
+
+// WriteTodo writes the shopping list as JSON tasks for to-do apps and
+// automations, e.g.
+//
+//	{"title": "Packed lunch groceries: ...", "tasks": [{"title": "Sandwich bread (4 slices)",
+//	 "notes": "For Turkey sandwich", "due": "2026-10-18", "completed": false}]}
+func WriteTodo(w io.Writer, l List) error {
+	list := todoList{Title: l.Title(), Tasks: []todoTask{}}
+	for _, it := range l.Items {
+		list.Tasks = append(list.Tasks, todoTask{
+			Title: fmt.Sprintf("%s (%s)", it.Name, amount(it)),
+			Notes: "For " + strings.Join(it.For, ", "),
+			Due:   day(it.FirstNeeded).AddDate(0, 0, -1).Format(menu.DateLayout),
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(list)
+}
+
+// amount formats an item's total, e.g. "4 slices".
+func amount(it Item) string {
+	if it.Unit == "" {
+		return quantity(it.Quantity)
+	}
+	return quantity(it.Quantity) + " " + it.Unit
+}
+
+func quantity(q float64) string {
+	return strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+func reasons(list []string) string {
+	labels := make([]string, len(list))
+	for i, r := range list {
+		if labels[i] = reasonLabels[r]; labels[i] == "" {
+			labels[i] = r
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+func day(date string) time.Time {
+	return menu.Day{Date: date}.Time()
+}
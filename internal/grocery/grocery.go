@@ -0,0 +1,205 @@
+// Package grocery builds a shopping list for the days a child needs a
+// packed lunch, from a pantry of packed-lunch options the family keeps.
+//
+// Each pack-lunch day in a plan gets one option, taking the safe options
+// in turn so the week has some variety. Options with one of the child's
+// allergens, or matching a dislike, are never picked.
+package grocery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/asachs01/school_menu_connector/internal/planner"
+	"github.com/asachs01/school_menu_connector/internal/search"
+)
+
+// Option is a packed lunch, such as a turkey sandwich, and what it takes
+// to make one.
+type Option struct {
+	Name string `yaml:"name" json:"name"`
+	// Allergens are the allergens it contains, by name, e.g. Wheat. They
+	// are compared with the names of the child's allergens, ignoring case.
+	Allergens   []string     `yaml:"allergens" json:"allergens,omitempty"`
+	Ingredients []Ingredient `yaml:"ingredients" json:"ingredients"`
+}
+
+// Ingredient is something to buy for one lunch.
+type Ingredient struct {
+	Item string `yaml:"item" json:"item"`
+	// Quantity is how much one lunch takes, in Unit. Defaults to 1.
+	Quantity float64 `yaml:"quantity" json:"quantity"`
+	Unit     string  `yaml:"unit" json:"unit,omitempty"`
+}
+
+// Validate checks a pantry: every option needs a name and ingredients,
+// and quantities cannot be negative.
+func Validate(pantry []Option) error {
+	for i, o := range pantry {
+		if strings.TrimSpace(o.Name) == "" {
+			return fmt.Errorf("pantry option %d: name is required", i+1)
+		}
+		if len(o.Ingredients) == 0 {
+			return fmt.Errorf("pantry option %s: at least one ingredient is required", o.Name)
+		}
+		for _, ing := range o.Ingredients {
+			if strings.TrimSpace(ing.Item) == "" {
+				return fmt.Errorf("pantry option %s: ingredients need an item", o.Name)
+			}
+			if ing.Quantity < 0 {
+				return fmt.Errorf("pantry option %s: %s has a negative quantity", o.Name, ing.Item)
+			}
+		}
+	}
+	return nil
+}
+
+// Preferences are what rules a pantry option out for the child.
+type Preferences struct {
+	// Allergens are the names of the child's allergens.
+	Allergens []string
+	// Dislikes are recipe patterns, matched against option names and
+	// ingredients as the planner matches them against recipes.
+	Dislikes []string
+}
+
+// List is the packed lunches for a plan and the groceries they need.
+type List struct {
+	School string `json:"school,omitempty"`
+	// Start and End are the first and last days planned, YYYY-MM-DD.
+	Start   string  `json:"start"`
+	End     string  `json:"end"`
+	Lunches []Lunch `json:"lunches"`
+	Items   []Item  `json:"items"`
+	// Excluded are the pantry options that were ruled out.
+	Excluded []Excluded `json:"excluded,omitempty"`
+}
+
+// Lunch is the packed lunch for one day.
+type Lunch struct {
+	// Date is YYYY-MM-DD.
+	Date    string   `json:"date"`
+	Reasons []string `json:"reasons"`
+	// Option is the pantry option to pack, or empty if none is safe.
+	Option string `json:"option,omitempty"`
+}
+
+// Item is one line of the shopping list.
+type Item struct {
+	Name     string  `json:"item"`
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit,omitempty"`
+	// For lists the options that need it, and FirstNeeded the first day
+	// it is packed, YYYY-MM-DD.
+	For         []string `json:"for"`
+	FirstNeeded string   `json:"firstNeeded"`
+}
+
+// Excluded is a pantry option that was ruled out, and why, e.g. "contains
+// Milk".
+type Excluded struct {
+	Option string `json:"option"`
+	Reason string `json:"reason"`
+}
+
+// Build picks a pantry option for each pack-lunch day in the plan and
+// totals the ingredients.
+func Build(plan planner.Plan, pantry []Option, prefs Preferences) List {
+	l := List{School: plan.School, Lunches: []Lunch{}, Items: []Item{}}
+	if len(plan.Days) > 0 {
+		l.Start, l.End = plan.Days[0].Date, plan.Days[len(plan.Days)-1].Date
+	}
+
+	var safe []Option
+	for _, o := range pantry {
+		if reason := ruledOut(o, prefs); reason != "" {
+			l.Excluded = append(l.Excluded, Excluded{Option: o.Name, Reason: reason})
+			continue
+		}
+		safe = append(safe, o)
+	}
+
+	type key struct{ item, unit string }
+	items := make(map[key]*Item)
+	var order []key
+	for _, d := range plan.PackLunchDays() {
+		lunch := Lunch{Date: d.Date, Reasons: d.Reasons}
+		if len(safe) > 0 {
+			o := safe[len(l.Lunches)%len(safe)]
+			lunch.Option = o.Name
+			for _, ing := range o.Ingredients {
+				k := key{strings.ToLower(strings.TrimSpace(ing.Item)), strings.ToLower(strings.TrimSpace(ing.Unit))}
+				it, ok := items[k]
+				if !ok {
+					it = &Item{Name: strings.TrimSpace(ing.Item), Unit: strings.TrimSpace(ing.Unit), FirstNeeded: d.Date}
+					items[k] = it
+					order = append(order, k)
+				}
+				quantity := ing.Quantity
+				if quantity == 0 {
+					quantity = 1
+				}
+				it.Quantity += quantity
+				if !contains(it.For, o.Name) {
+					it.For = append(it.For, o.Name)
+				}
+			}
+		}
+		l.Lunches = append(l.Lunches, lunch)
+	}
+
+	for _, k := range order {
+		l.Items = append(l.Items, *items[k])
+	}
+	sort.SliceStable(l.Items, func(i, j int) bool {
+		return strings.ToLower(l.Items[i].Name) < strings.ToLower(l.Items[j].Name)
+	})
+	return l
+}
+
+// Unplanned returns the pack-lunch days no pantry option was safe for.
+func (l List) Unplanned() []Lunch {
+	var list []Lunch
+	for _, lunch := range l.Lunches {
+		if lunch.Option == "" {
+			list = append(list, lunch)
+		}
+	}
+	return list
+}
+
+// ruledOut returns why the child cannot have the option, or "".
+func ruledOut(o Option, prefs Preferences) string {
+	var allergens []string
+	for _, a := range o.Allergens {
+		for _, child := range prefs.Allergens {
+			if strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(child)) {
+				allergens = append(allergens, child)
+			}
+		}
+	}
+	if len(allergens) > 0 {
+		return "contains " + strings.Join(allergens, ", ")
+	}
+	for _, pattern := range prefs.Dislikes {
+		if search.Matches(pattern, o.Name) {
+			return "disliked: " + pattern
+		}
+		for _, ing := range o.Ingredients {
+			if search.Matches(pattern, ing.Item) {
+				return "disliked: " + pattern
+			}
+		}
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
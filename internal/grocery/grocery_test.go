@@ -0,0 +1,177 @@
+package grocery
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/asachs01/school_menu_connector/internal/planner"
+)
+
+var (
+	turkey = Option{Name: "Turkey sandwich", Allergens: []string{"Wheat"}, Ingredients: []Ingredient{
+		{Item: "Sandwich bread", Quantity: 2, Unit: "slices"},
+		{Item: "Sliced turkey", Quantity: 3, Unit: "oz"},
+		{Item: "Apple"},
+	}}
+	crackers = Option{Name: "Cheese and crackers", Allergens: []string{"milk", "Wheat"}, Ingredients: []Ingredient{
+		{Item: "Cheddar", Quantity: 2, Unit: "oz"},
+		{Item: "Crackers", Quantity: 10},
+		{Item: "apple ", Quantity: 1},
+	}}
+	rice = Option{Name: "Rice bowl", Ingredients: []Ingredient{
+		{Item: "Rice", Quantity: 1, Unit: "cup"},
+		{Item: "Fish sticks", Quantity: 4},
+	}}
+)
+
+// week plans five days, packing a lunch on those given.
+func week(pack ...string) planner.Plan {
+	p := planner.Plan{School: "Lincoln", Session: "Lunch"}
+	for _, date := range []string{"2024-09-02", "2024-09-03", "2024-09-04", "2024-09-05", "2024-09-06"} {
+		d := planner.Day{Date: date}
+		for _, pd := range pack {
+			if pd == date {
+				d.PackLunch, d.Reasons = true, []string{planner.ReasonDisliked}
+			}
+		}
+		p.Days = append(p.Days, d)
+	}
+	return p
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name     string
+		plan     planner.Plan
+		pantry   []Option
+		prefs    Preferences
+		lunches  string
+		items    []string
+		excluded string
+	}{
+		{
+			name:    "options taken in turn",
+			plan:    week("2024-09-02", "2024-09-04", "2024-09-06"),
+			pantry:  []Option{turkey, crackers},
+			lunches: "2024-09-02 Turkey sandwich, 2024-09-04 Cheese and crackers, 2024-09-06 Turkey sandwich",
+			items: []string{
+				// Apples from both options add up, however they are spelled.
+				"3 Apple (Turkey sandwich, Cheese and crackers) from 2024-09-02",
+				"2 oz Cheddar (Cheese and crackers) from 2024-09-04",
+				"10 Crackers (Cheese and crackers) from 2024-09-04",
+				"4 slices Sandwich bread (Turkey sandwich) from 2024-09-02",
+				"6 oz Sliced turkey (Turkey sandwich) from 2024-09-02",
+			},
+		},
+		{
+			name:     "allergens ruled out ignoring case",
+			plan:     week("2024-09-03"),
+			pantry:   []Option{crackers, rice},
+			prefs:    Preferences{Allergens: []string{"Milk"}},
+			lunches:  "2024-09-03 Rice bowl",
+			items:    []string{"4 Fish sticks (Rice bowl) from 2024-09-03", "1 cup Rice (Rice bowl) from 2024-09-03"},
+			excluded: "Cheese and crackers: contains Milk",
+		},
+		{
+			name:     "disliked ingredient",
+			plan:     week("2024-09-03"),
+			pantry:   []Option{rice, turkey},
+			prefs:    Preferences{Dislikes: []string{"fish stick"}},
+			lunches:  "2024-09-03 Turkey sandwich",
+			items:    []string{"1 Apple (Turkey sandwich) from 2024-09-03", "2 slices Sandwich bread (Turkey sandwich) from 2024-09-03", "3 oz Sliced turkey (Turkey sandwich) from 2024-09-03"},
+			excluded: "Rice bowl: disliked: fish stick",
+		},
+		{
+			name:     "disliked name",
+			plan:     week("2024-09-03"),
+			pantry:   []Option{turkey},
+			prefs:    Preferences{Dislikes: []string{"sandwich"}},
+			lunches:  "2024-09-03 -",
+			excluded: "Turkey sandwich: disliked: sandwich",
+		},
+		{
+			name:     "nothing safe",
+			plan:     week("2024-09-02", "2024-09-05"),
+			pantry:   []Option{turkey, crackers},
+			prefs:    Preferences{Allergens: []string{"wheat", "Milk"}},
+			lunches:  "2024-09-02 -, 2024-09-05 -",
+			excluded: "Turkey sandwich: contains wheat; Cheese and crackers: contains Milk, wheat",
+		},
+		{
+			name:   "no pack-lunch days",
+			plan:   week(),
+			pantry: []Option{turkey},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := Build(tt.plan, tt.pantry, tt.prefs)
+			if l.School != "Lincoln" || l.Start != "2024-09-02" || l.End != "2024-09-06" {
+				t.Errorf("list covers %q %s to %s", l.School, l.Start, l.End)
+			}
+			if l.Lunches == nil || l.Items == nil {
+				t.Error("lunches or items are nil, want empty lists")
+			}
+
+			var lunches []string
+			for _, lunch := range l.Lunches {
+				option := lunch.Option
+				if option == "" {
+					option = "-"
+				}
+				lunches = append(lunches, lunch.Date+" "+option)
+			}
+			if got := strings.Join(lunches, ", "); got != tt.lunches {
+				t.Errorf("lunches = %s, want %s", got, tt.lunches)
+			}
+
+			var items []string
+			for _, it := range l.Items {
+				unit := ""
+				if it.Unit != "" {
+					unit = it.Unit + " "
+				}
+				items = append(items, fmt.Sprintf("%g %s%s (%s) from %s", it.Quantity, unit, it.Name, strings.Join(it.For, ", "), it.FirstNeeded))
+			}
+			if fmt.Sprintf("%q", items) != fmt.Sprintf("%q", tt.items) {
+				t.Errorf("items =\n%q\nwant\n%q", items, tt.items)
+			}
+
+			var excluded []string
+			for _, e := range l.Excluded {
+				excluded = append(excluded, e.Option+": "+e.Reason)
+			}
+			if got := strings.Join(excluded, "; "); got != tt.excluded {
+				t.Errorf("excluded = %s, want %s", got, tt.excluded)
+			}
+
+			if n := len(l.Unplanned()); (n > 0) != strings.Contains(tt.lunches, " -") {
+				t.Errorf("%d unplanned lunches in %s", n, tt.lunches)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		pantry  []Option
+		wantErr string
+	}{
+		{"valid", []Option{turkey, crackers, rice}, ""},
+		{"empty", nil, ""},
+		{"no name", []Option{{Name: " ", Ingredients: turkey.Ingredients}}, "pantry option 1: name is required"},
+		{"no ingredients", []Option{turkey, {Name: "Leftovers"}}, "pantry option Leftovers: at least one ingredient is required"},
+		{"no item", []Option{{Name: "Soup", Ingredients: []Ingredient{{Quantity: 1}}}}, "pantry option Soup: ingredients need an item"},
+		{"negative", []Option{{Name: "Soup", Ingredients: []Ingredient{{Item: "Broth", Quantity: -1}}}}, "pantry option Soup: Broth has a negative quantity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.pantry)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("Validate = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,440 @@
+// Package homeassistant publishes school menus to Home Assistant over MQTT.
+//
+// Each school and session gets a sensor for today's and tomorrow's menu,
+// announced with MQTT discovery so Home Assistant creates them without any
+// YAML. A sensor's state is a short summary such as "Chicken Tenders, Corn"
+// and its attributes hold the full recipe list with categories, allergens
+// and calories.
+package homeassistant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/cache"
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/notify"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults for Options.
+const (
+	// DefaultDiscoveryPrefix is the topic prefix Home Assistant watches for
+	// discovery messages.
+	DefaultDiscoveryPrefix = "homeassistant"
+	// DefaultTopicPrefix is the prefix for the sensors' own topics.
+	DefaultTopicPrefix = "school_menu"
+	// DefaultInterval is how often sensors are refreshed.
+	DefaultInterval = time.Hour
+)
+
+// maxState is the longest sensor state Home Assistant accepts.
+const maxState = 255
+
+// timeout bounds each broker operation.
+const timeout = 30 * time.Second
+
+// School is a school whose menus get sensors.
+type School struct {
+	// ID names the school in topics and entity IDs, e.g. emma. It is
+	// made safe for topics, so a display name works too.
+	ID         string
+	Name       string
+	BuildingID string
+	DistrictID string
+	// Sessions are the meals to publish; empty means Lunch.
+	Sessions []string
+	// Allergens maps display names to LINQ allergen IDs. When set, only
+	// these allergens are listed, by name; otherwise every allergen ID is.
+	Allergens map[string]string
+}
+
+// Options configures a Publisher.
+type Options struct {
+	// Broker is the MQTT broker URL, e.g. tcp://homeassistant.local:1883.
+	Broker   string
+	Username string
+	Password string
+	// ClientID defaults to school-menu-connector.
+	ClientID        string
+	DiscoveryPrefix string
+	TopicPrefix     string
+	// Timezone decides which day is today; empty means SCHOOL_TZ or local.
+	Timezone string
+	// Summary picks the recipes in each sensor's state.
+	Summary notify.Summary
+	// Cache is read before fetching from LINQ Connect. Optional.
+	Cache  *cache.Cache
+	Logger *logrus.Logger
+}
+
+// Publisher keeps Home Assistant sensors up to date with school menus.
+type Publisher struct {
+	opts    Options
+	schools []School
+	client  mqtt.Client
+	// republish is signalled when Home Assistant restarts and needs the
+	// discovery messages again.
+	republish chan struct{}
+}
+
+// days are the sensors each session gets, by the day they show.
+var days = []struct {
+	key, name string
+	offset    int
+}{
+	{"today", "Today", 0},
+	{"tomorrow", "Tomorrow", 1},
+}
+
+// New returns a Publisher for the schools. Call Connect before publishing.
+func New(schools []School, opts Options) (*Publisher, error) {
+	if opts.Broker == "" {
+		return nil, fmt.Errorf("no MQTT broker configured")
+	}
+	if len(schools) == 0 {
+		return nil, fmt.Errorf("no schools to publish")
+	}
+	seen := make(map[string]bool)
+	for i, s := range schools {
+		if s.BuildingID == "" || s.DistrictID == "" {
+			return nil, fmt.Errorf("school %q: building and district IDs are required", s.ID)
+		}
+		schools[i].ID = slug(s.ID)
+		if schools[i].ID == "" {
+			schools[i].ID = slug(s.BuildingID)
+		}
+		if seen[schools[i].ID] {
+			return nil, fmt.Errorf("school %q is listed twice", schools[i].ID)
+		}
+		seen[schools[i].ID] = true
+		if len(s.Sessions) == 0 {
+			schools[i].Sessions = []string{"Lunch"}
+		}
+	}
+	if _, err := dates.Location(opts.Timezone); err != nil {
+		return nil, err
+	}
+	if err := opts.Summary.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.ClientID == "" {
+		opts.ClientID = "school-menu-connector"
+	}
+	if opts.DiscoveryPrefix == "" {
+		opts.DiscoveryPrefix = DefaultDiscoveryPrefix
+	}
+	if opts.TopicPrefix == "" {
+		opts.TopicPrefix = DefaultTopicPrefix
+	}
+	if opts.Logger == nil {
+		opts.Logger = logrus.StandardLogger()
+	}
+	return &Publisher{opts: opts, schools: schools, republish: make(chan struct{}, 1)}, nil
+}
+
+// availabilityTopic is where the publisher reports online or offline; the
+// broker sends offline for it if the connection drops.
+func (p *Publisher) availabilityTopic() string {
+	return p.opts.TopicPrefix + "/status"
+}
+
+// Connect connects to the broker. It reconnects by itself if the
+// connection drops later.
+func (p *Publisher) Connect() error {
+	opts := mqtt.NewClientOptions().
+		AddBroker(p.opts.Broker).
+		SetClientID(p.opts.ClientID).
+		SetUsername(p.opts.Username).
+		SetPassword(p.opts.Password).
+		SetWill(p.availabilityTopic(), "offline", 1, true).
+		SetAutoReconnect(true).
+		SetConnectTimeout(timeout).
+		SetOnConnectHandler(func(c mqtt.Client) {
+			c.Publish(p.availabilityTopic(), 1, true, "online")
+			// Home Assistant announces itself on its status topic when it
+			// starts; send the discovery messages again so it finds the
+			// sensors without waiting for the next refresh.
+			c.Subscribe(p.opts.DiscoveryPrefix+"/status", 1, func(_ mqtt.Client, msg mqtt.Message) {
+				if string(msg.Payload()) == "online" {
+					select {
+					case p.republish <- struct{}{}:
+					default:
+					}
+				}
+			})
+		})
+	p.client = mqtt.NewClient(opts)
+	if err := wait(p.client.Connect()); err != nil {
+		return fmt.Errorf("connecting to MQTT broker %s: %w", p.opts.Broker, err)
+	}
+	return nil
+}
+
+// Close marks the sensors unavailable and disconnects.
+func (p *Publisher) Close() {
+	if p.client == nil || !p.client.IsConnected() {
+		return
+	}
+	wait(p.client.Publish(p.availabilityTopic(), 1, true, "offline"))
+	p.client.Disconnect(250)
+}
+
+// Run publishes the sensors now, then every interval and just after
+// midnight, when today's menu becomes yesterday's, until ctx is done.
+func (p *Publisher) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	for {
+		if err := p.Publish(ctx); err != nil {
+			p.opts.Logger.WithError(err).Warn("Publishing menus to Home Assistant failed")
+		}
+
+		now, _ := dates.Now(p.opts.Timezone)
+		wake := interval
+		if untilMidnight := dates.Day(now).AddDate(0, 0, 1).Sub(wallClock(now)) + time.Minute; untilMidnight < wake {
+			wake = untilMidnight
+		}
+		timer := time.NewTimer(wake)
+		select {
+		case <-timer.C:
+		case <-p.republish:
+			timer.Stop()
+			p.opts.Logger.Info("Home Assistant restarted, publishing sensors again")
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		}
+	}
+}
+
+// wallClock returns now with its wall clock read as UTC, to measure against
+// dates at midnight UTC.
+func wallClock(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(), 0, time.UTC)
+}
+
+// Publish sends the discovery, state and attributes messages for every
+// sensor. A school whose menu cannot be fetched keeps its previous state.
+// A failure does not stop the other sensors; the errors are returned
+// together.
+func (p *Publisher) Publish(ctx context.Context) error {
+	if p.client == nil {
+		return fmt.Errorf("not connected")
+	}
+	now, err := dates.Now(p.opts.Timezone)
+	if err != nil {
+		return err
+	}
+	summary := p.opts.Summary
+	summary.Now = now
+	today := dates.Day(now)
+
+	var errs []error
+	for _, school := range p.schools {
+		if ctx.Err() != nil {
+			return errors.Join(append(errs, ctx.Err())...)
+		}
+		failed := len(errs)
+		for _, d := range days {
+			date := today.AddDate(0, 0, d.offset)
+			m, err := p.fetch(school, date)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", school.ID, err))
+				continue
+			}
+			for _, session := range school.Sessions {
+				var day menu.Day
+				var served bool
+				if m != nil {
+					day, served = m.DayFor(date, session)
+				}
+				s := sensor{school: school, session: session, day: d.key, dayName: d.name}
+				if err := p.publishSensor(s, date, day, served, summary); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", school.ID, err))
+				}
+			}
+		}
+		if len(errs) == failed {
+			p.opts.Logger.WithField("school", school.ID).Info("Published menus to Home Assistant")
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fetch returns the menu for one day, using the per-day cache the web
+// server shares.
+func (p *Publisher) fetch(school School, date time.Time) (*menu.Menu, error) {
+	dateStr := date.Format("01-02-2006")
+	if p.opts.Cache != nil {
+		if m, ok := p.opts.Cache.Get(school.BuildingID, school.DistrictID, dateStr, dateStr); ok {
+			return m, nil
+		}
+	}
+	m, err := menu.Fetch(school.BuildingID, school.DistrictID, dateStr, dateStr, false)
+	if err != nil {
+		return nil, fmt.Errorf("fetching menu for %s: %w", date.Format(menu.DateLayout), err)
+	}
+	if p.opts.Cache != nil {
+		p.opts.Cache.Set(school.BuildingID, school.DistrictID, dateStr, dateStr, m)
+	}
+	return m, nil
+}
+
+// sensor identifies one sensor: a school's session on today or tomorrow.
+type sensor struct {
+	school       School
+	session      string
+	day, dayName string
+}
+
+func (s sensor) objectID() string {
+	return slug(s.school.ID + "_" + s.session + "_" + s.day)
+}
+
+// Attributes are a sensor's attributes in Home Assistant.
+type Attributes struct {
+	School  string `json:"school"`
+	Session string `json:"session"`
+	// Date is YYYY-MM-DD.
+	Date    string   `json:"date"`
+	Served  bool     `json:"served"`
+	Recipes []Recipe `json:"recipes"`
+	// Allergens lists every allergen in the session's recipes.
+	Allergens []string `json:"allergens"`
+}
+
+// Recipe is one recipe in a sensor's attributes.
+type Recipe struct {
+	Name      string   `json:"name"`
+	Category  string   `json:"category"`
+	Meal      string   `json:"meal,omitempty"`
+	Allergens []string `json:"allergens"`
+	Calories  float64  `json:"calories,omitempty"`
+}
+
+func (p *Publisher) publishSensor(s sensor, date time.Time, day menu.Day, served bool, summary notify.Summary) error {
+	base := fmt.Sprintf("%s/%s/%s/%s", p.opts.TopicPrefix, s.school.ID, slug(s.session), s.day)
+	name := s.school.Name
+	if name == "" {
+		name = s.school.ID
+	}
+
+	discovery := map[string]interface{}{
+		"name":                  s.dayName + " " + s.session,
+		"object_id":             "school_menu_" + s.objectID(),
+		"unique_id":             "school_menu_" + s.objectID(),
+		"state_topic":           base + "/state",
+		"json_attributes_topic": base + "/attributes",
+		"availability_topic":    p.availabilityTopic(),
+		"icon":                  "mdi:food-apple",
+		"device": map[string]interface{}{
+			"identifiers":  []string{"school_menu_" + s.school.ID},
+			"name":         name + " Menu",
+			"manufacturer": "School Menu Connector",
+			"model":        "LINQ Connect menu",
+		},
+	}
+
+	attrs := Attributes{School: name, Session: s.session, Date: date.Format(menu.DateLayout), Served: served, Recipes: []Recipe{}, Allergens: []string{}}
+	state := "No menu"
+	if served {
+		session, _ := day.Session(s.session)
+		attrs.Recipes, attrs.Allergens = recipes(session, s.school.Allergens)
+		state = truncate(summary.Line(day), maxState)
+	}
+
+	return errors.Join(
+		p.publishJSON(fmt.Sprintf("%s/sensor/school_menu_%s/config", p.opts.DiscoveryPrefix, s.objectID()), discovery),
+		p.publishJSON(base+"/attributes", attrs),
+		p.publish(base+"/state", []byte(state)),
+	)
+}
+
+func (p *Publisher) publishJSON(topic string, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return p.publish(topic, payload)
+}
+
+// publish sends a retained message, so Home Assistant sees the latest
+// value whenever it subscribes.
+func (p *Publisher) publish(topic string, payload []byte) error {
+	if err := wait(p.client.Publish(topic, 1, true, payload)); err != nil {
+		return fmt.Errorf("publishing %s: %w", topic, err)
+	}
+	return nil
+}
+
+// recipes lists a session's recipes for the attributes, with allergen
+// names where known, and every allergen served.
+func recipes(session menu.Session, allergenIDs map[string]string) ([]Recipe, []string) {
+	names := make(map[string]string, len(allergenIDs))
+	for name, id := range allergenIDs {
+		names[id] = name
+	}
+
+	list := []Recipe{}
+	all := make(map[string]bool)
+	for _, c := range session.Categories {
+		for _, item := range c.Recipes {
+			r := Recipe{Name: item.Name, Category: c.Name, Meal: c.Meal, Allergens: []string{}, Calories: item.Calories()}
+			for _, id := range item.Allergens {
+				name := id
+				if len(names) > 0 {
+					if name = names[id]; name == "" {
+						continue
+					}
+				}
+				r.Allergens = append(r.Allergens, name)
+				all[name] = true
+			}
+			list = append(list, r)
+		}
+	}
+
+	allergens := make([]string, 0, len(all))
+	for name := range all {
+		allergens = append(allergens, name)
+	}
+	sort.Strings(allergens)
+	return list, allergens
+}
+
+// wait waits for an MQTT operation to finish or time out.
+func wait(t mqtt.Token) error {
+	if !t.WaitTimeout(timeout) {
+		return fmt.Errorf("timed out")
+	}
+	return t.Error()
+}
+
+var unsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slug makes a name safe for MQTT topics and Home Assistant object IDs.
+func slug(s string) string {
+	return strings.Trim(unsafe.ReplaceAllString(strings.ToLower(s), "_"), "_")
+}
+
+// truncate shortens s to at most max bytes, ending with an ellipsis.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := max - len("…")
+	for cut > 0 && s[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	return s[:cut] + "…"
+}
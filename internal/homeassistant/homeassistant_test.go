@@ -0,0 +1,281 @@
+package homeassistant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/cache"
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/sirupsen/logrus"
+)
+
+// broker is an in-process MQTT broker that acknowledges everything and
+// keeps the retained messages it is sent.
+type broker struct {
+	net.Listener
+
+	mu       sync.Mutex
+	retained map[string]string
+}
+
+func newBroker(t *testing.T) *broker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &broker{Listener: ln, retained: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return b
+}
+
+func (b *broker) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		cp, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		var reply packets.ControlPacket
+		switch p := cp.(type) {
+		case *packets.ConnectPacket:
+			reply = packets.NewControlPacket(packets.Connack)
+		case *packets.PublishPacket:
+			if p.Retain {
+				b.mu.Lock()
+				b.retained[p.TopicName] = string(p.Payload)
+				b.mu.Unlock()
+			}
+			if p.Qos == 1 {
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				reply = ack
+			}
+		case *packets.SubscribePacket:
+			ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			ack.MessageID = p.MessageID
+			ack.ReturnCodes = p.Qoss
+			reply = ack
+		case *packets.PingreqPacket:
+			reply = packets.NewControlPacket(packets.Pingresp)
+		case *packets.DisconnectPacket:
+			return
+		}
+		if reply != nil {
+			if err := reply.Write(conn); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// message returns the retained message on topic.
+func (b *broker) message(topic string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	msg, ok := b.retained[topic]
+	return msg, ok
+}
+
+// waitFor waits until topic holds payload.
+func (b *broker) waitFor(t *testing.T, topic, payload string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if msg, _ := b.message(topic); msg == payload {
+			return
+		}
+	}
+	t.Fatalf("%s never became %q", topic, payload)
+}
+
+// testCache returns a cache holding Cheese Pizza for lunch today and no
+// menu tomorrow for building b1.
+func testCache(t *testing.T) *cache.Cache {
+	t.Helper()
+	c, err := cache.New(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now, err := dates.Now("UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := dates.Day(now)
+	lunch := &menu.Menu{FamilyMenuSessions: []menu.FamilyMenuSession{{
+		ServingSession: "Lunch",
+		MenuPlans: []menu.MenuPlan{{Days: []menu.MenuDay{{
+			Date: today.Format("1/2/2006"),
+			MenuMeals: []menu.MenuMeal{{
+				MenuMealName: "Lunch",
+				RecipeCategories: []menu.RecipeCategory{
+					{CategoryName: "Entree", Recipes: []menu.Recipe{{RecipeName: "Cheese Pizza", Allergens: []string{"a-milk"}}}},
+				},
+			}},
+		}}}},
+	}}}
+	todayStr, tomorrowStr := today.Format("01-02-2006"), today.AddDate(0, 0, 1).Format("01-02-2006")
+	for _, building := range []string{"b1", "b2"} {
+		c.Set(building, "d1", todayStr, todayStr, lunch)
+		c.Set(building, "d1", tomorrowStr, tomorrowStr, &menu.Menu{})
+	}
+	return c
+}
+
+func testPublisher(t *testing.T, broker string, schools ...School) *Publisher {
+	t.Helper()
+	logger := logrus.New()
+	logger.Out = io.Discard
+	p, err := New(schools, Options{Broker: broker, Timezone: "UTC", Cache: testCache(t), Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPublishToBroker(t *testing.T) {
+	b := newBroker(t)
+	p := testPublisher(t, "tcp://"+b.Addr().String(),
+		School{ID: "Emma", Name: "Emma Elementary", BuildingID: "b1", DistrictID: "d1", Allergens: map[string]string{"Milk": "a-milk"}})
+	if err := p.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	b.waitFor(t, "school_menu/status", "online")
+	if err := p.Publish(context.Background()); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	configTopic := "homeassistant/sensor/school_menu_emma_lunch_today/config"
+	raw, ok := b.message(configTopic)
+	if !ok {
+		t.Fatalf("no discovery message on %s", configTopic)
+	}
+	var config struct {
+		Name            string `json:"name"`
+		UniqueID        string `json:"unique_id"`
+		StateTopic      string `json:"state_topic"`
+		AttributesTopic string `json:"json_attributes_topic"`
+		Availability    string `json:"availability_topic"`
+		Device          struct {
+			Identifiers []string `json:"identifiers"`
+			Name        string   `json:"name"`
+		} `json:"device"`
+	}
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		t.Fatalf("decoding %s: %v", raw, err)
+	}
+	if config.Name != "Today Lunch" || config.UniqueID != "school_menu_emma_lunch_today" ||
+		config.StateTopic != "school_menu/emma/lunch/today/state" || config.AttributesTopic != "school_menu/emma/lunch/today/attributes" ||
+		config.Availability != "school_menu/status" {
+		t.Errorf("config = %+v", config)
+	}
+	if len(config.Device.Identifiers) != 1 || config.Device.Identifiers[0] != "school_menu_emma" || config.Device.Name != "Emma Elementary Menu" {
+		t.Errorf("device = %+v", config.Device)
+	}
+	if _, ok := b.message("homeassistant/sensor/school_menu_emma_lunch_tomorrow/config"); !ok {
+		t.Error("no discovery message for tomorrow")
+	}
+
+	if state, _ := b.message("school_menu/emma/lunch/today/state"); !strings.Contains(state, "Cheese Pizza") {
+		t.Errorf("today's state = %q, want the entree", state)
+	}
+	if state, _ := b.message("school_menu/emma/lunch/tomorrow/state"); state != "No menu" {
+		t.Errorf("tomorrow's state = %q, want No menu", state)
+	}
+	raw, _ = b.message("school_menu/emma/lunch/today/attributes")
+	var attrs Attributes
+	if err := json.Unmarshal([]byte(raw), &attrs); err != nil {
+		t.Fatalf("decoding %s: %v", raw, err)
+	}
+	if !attrs.Served || attrs.School != "Emma Elementary" || len(attrs.Recipes) != 1 ||
+		attrs.Recipes[0].Name != "Cheese Pizza" || strings.Join(attrs.Allergens, ",") != "Milk" {
+		t.Errorf("attributes = %+v", attrs)
+	}
+
+	p.Close()
+	if status, _ := b.message("school_menu/status"); status != "offline" {
+		t.Errorf("status after Close = %q, want offline", status)
+	}
+}
+
+// failingClient is an mqtt.Client whose publishes to one topic fail.
+type failingClient struct {
+	mqtt.Client
+	fail string
+
+	mu     sync.Mutex
+	topics []string
+}
+
+func (c *failingClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	if topic == c.fail {
+		return doneToken{errors.New("not authorized")}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.topics = append(c.topics, topic)
+	return doneToken{}
+}
+
+func (c *failingClient) published() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.topics...)
+}
+
+// doneToken is a finished MQTT operation.
+type doneToken struct{ err error }
+
+func (t doneToken) Wait() bool                     { return true }
+func (t doneToken) WaitTimeout(time.Duration) bool { return true }
+func (t doneToken) Error() error                   { return t.err }
+
+func (t doneToken) Done() <-chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+func TestPublishContinuesPastFailures(t *testing.T) {
+	p := testPublisher(t, "tcp://unused:1883",
+		School{ID: "emma", BuildingID: "b1", DistrictID: "d1"},
+		School{ID: "lincoln", BuildingID: "b2", DistrictID: "d1"})
+	client := &failingClient{fail: "school_menu/emma/lunch/today/attributes"}
+	p.client = client
+
+	err := p.Publish(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "emma: publishing school_menu/emma/lunch/today/attributes: not authorized") {
+		t.Fatalf("err = %v, want the failed publish", err)
+	}
+	got := make(map[string]bool)
+	for _, topic := range client.published() {
+		got[topic] = true
+	}
+	for _, want := range []string{
+		"school_menu/emma/lunch/today/state",
+		"school_menu/emma/lunch/tomorrow/state",
+		"homeassistant/sensor/school_menu_lincoln_lunch_today/config",
+		"school_menu/lincoln/lunch/tomorrow/state",
+	} {
+		if !got[want] {
+			t.Errorf("%s was not published after the failure", want)
+		}
+	}
+}
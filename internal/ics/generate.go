@@ -8,6 +8,7 @@ import (
 
 	ics "github.com/arran4/golang-ical"
 	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/render"
 )
 
 func GenerateICSFile(buildingID, districtID, startDate, endDate string, outputPath string, debug bool) ([]byte, error) {
@@ -21,7 +22,7 @@ func GenerateICSFile(buildingID, districtID, startDate, endDate string, outputPa
 	}
 
 	if debug {
-		fmt.Printf("Creating ICS file for date range: %s to %s\n", start.Format("2006-01-02"), end.Format("2006-01-02"))
+		fmt.Fprintf(os.Stderr, "Creating ICS file for date range: %s to %s\n", start.Format("2006-01-02"), end.Format("2006-01-02"))
 	}
 
 	cal := ics.NewCalendar()
@@ -31,18 +32,21 @@ func GenerateICSFile(buildingID, districtID, startDate, endDate string, outputPa
 		dateStr := date.Format("01-02-2006")
 
 		if debug {
-			fmt.Printf("Fetching menu for date: %s\n", dateStr)
+			fmt.Fprintf(os.Stderr, "Fetching menu for date: %s\n", dateStr)
 		}
 
 		menu, err := menu.Fetch(buildingID, districtID, dateStr, dateStr, debug)
 		if err != nil {
 			if debug {
-				fmt.Printf("Error fetching menu for date %s: %v\n", dateStr, err)
+				fmt.Fprintf(os.Stderr, "Error fetching menu for date %s: %v\n", dateStr, err)
 			}
 			continue
 		}
 
-		lunchMenu := menu.GetLunchMenuForDate(date.Format("1/2/2006"), debug)
+		var lunchMenu string
+		if day, ok := menu.DayFor(date, "Lunch"); ok {
+			lunchMenu = render.SessionText(day, "Lunch")
+		}
 
 		if lunchMenu != "" {
 			event := cal.AddEvent(fmt.Sprintf("lunch-%s", date.Format("2006-01-02")))
@@ -55,10 +59,10 @@ func GenerateICSFile(buildingID, districtID, startDate, endDate string, outputPa
 			event.SetDescription(lunchMenu)
 
 			if debug {
-				fmt.Printf("Added event for date: %s\n", date.Format("2006-01-02"))
+				fmt.Fprintf(os.Stderr, "Added event for date: %s\n", date.Format("2006-01-02"))
 			}
 		} else if debug {
-			fmt.Printf("No lunch menu found for date: %s\n", date.Format("01/02/2006"))
+			fmt.Fprintf(os.Stderr, "No lunch menu found for date: %s\n", date.Format("01/02/2006"))
 		}
 	}
 
@@ -87,12 +91,10 @@ func GenerateICSFileWithMealTypes(buildingID, districtID, startDate, endDate str
 	}
 
 	if debug {
-		fmt.Printf("Creating ICS file for date range: %s to %s\n", start.Format("2006-01-02"), end.Format("2006-01-02"))
+		fmt.Fprintf(os.Stderr, "Creating ICS file for date range: %s to %s\n", start.Format("2006-01-02"), end.Format("2006-01-02"))
 	}
 
-	cal := ics.NewCalendar()
-	cal.SetMethod(ics.MethodPublish)
-
+	var days []menu.Day
 	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
 		dateStr := date.Format("01-02-2006")
 		menuData, err := menu.Fetch(buildingID, districtID, dateStr, dateStr, debug)
@@ -100,21 +102,50 @@ func GenerateICSFileWithMealTypes(buildingID, districtID, startDate, endDate str
 			continue
 		}
 
-		for _, mealType := range mealTypes {
-			mealMenu := menuData.GetMenuForSession(mealType, date.Format("1/2/2006"), debug)
-			
-			if mealMenu != "" {
-				event := cal.AddEvent(fmt.Sprintf("%s-%s", strings.ToLower(mealType), date.Format("2006-01-02")))
-				event.SetCreatedTime(time.Now())
-				event.SetDtStampTime(time.Now())
-				event.SetModifiedAt(time.Now())
-				event.SetAllDayStartAt(date)
-				event.SetAllDayEndAt(date.AddDate(0, 0, 1))
-				event.SetSummary(fmt.Sprintf("%s Menu - %s", mealType, date.Format("01/02/2006")))
-				event.SetDescription(mealMenu)
+		if day, ok := menuData.DayFor(date, mealTypes...); ok {
+			days = append(days, day)
+		}
+	}
+
+	return Generate(days), nil
+}
+
+// Generate builds a calendar with one all-day event per serving session on
+// each day, described with the plain-text menu.
+func Generate(days []menu.Day) []byte {
+	return GenerateMarked(days, nil)
+}
+
+// Marker returns text to put before the title of a session's event on a
+// day, such as a favorite-day marker, or "" for none.
+type Marker func(day menu.Day, session string) string
+
+// GenerateMarked is Generate with each event title led by the marker's
+// text, e.g. "⭐ Favorite: Lunch Menu - 10/20/2026". A nil marker marks
+// nothing.
+func GenerateMarked(days []menu.Day, marker Marker) []byte {
+	cal := ics.NewCalendar()
+	cal.SetMethod(ics.MethodPublish)
+
+	for _, day := range days {
+		date := day.Time()
+		for _, session := range day.Sessions {
+			event := cal.AddEvent(fmt.Sprintf("%s-%s", strings.ToLower(session.Name), day.Date))
+			event.SetCreatedTime(time.Now())
+			event.SetDtStampTime(time.Now())
+			event.SetModifiedAt(time.Now())
+			event.SetAllDayStartAt(date)
+			event.SetAllDayEndAt(date.AddDate(0, 0, 1))
+			summary := fmt.Sprintf("%s Menu - %s", session.Name, date.Format("01/02/2006"))
+			if marker != nil {
+				if mark := marker(day, session.Name); mark != "" {
+					summary = mark + ": " + summary
+				}
 			}
+			event.SetSummary(summary)
+			event.SetDescription(render.SessionText(day, session.Name))
 		}
 	}
 
-	return []byte(cal.Serialize()), nil
+	return []byte(cal.Serialize())
 }
@@ -0,0 +1,47 @@
+package menu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// District represents a LINQ Connect district and its buildings, as returned
+// by the FamilyMenuIdentifier endpoint.
+type District struct {
+	DistrictID   string     `json:"DistrictId"`
+	DistrictName string     `json:"DistrictName"`
+	Buildings    []Building `json:"Buildings"`
+}
+
+// Building is a single school within a district.
+type Building struct {
+	BuildingID string `json:"BuildingId"`
+	Name       string `json:"Name"`
+}
+
+// ErrNoDistrict is returned by Discover when no district has the
+// identifier.
+var ErrNoDistrict = errors.New("no district found")
+
+// Discover looks up a district and its buildings by the short identifier
+// shown on a district's LINQ Connect menu page (e.g. "4QDPT3"). Like Fetch,
+// it goes through the proxy at PROXY_URL when one is set.
+func Discover(identifier string, debug bool) (*District, error) {
+	body, err := get("/api/FamilyMenuIdentifier", "identifier="+url.QueryEscape(identifier), debug)
+	if err != nil {
+		return nil, err
+	}
+
+	var district District
+	if err := json.Unmarshal(body, &district); err != nil {
+		return nil, fmt.Errorf("unmarshaling JSON: %w", err)
+	}
+
+	if district.DistrictID == "" {
+		return nil, fmt.Errorf("%w for identifier %q", ErrNoDistrict, identifier)
+	}
+
+	return &district, nil
+}
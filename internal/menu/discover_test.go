@@ -0,0 +1,52 @@
+package menu
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscoverUsesProxy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/FamilyMenuIdentifier" {
+			t.Errorf("path = %q, want /api/FamilyMenuIdentifier", r.URL.Path)
+		}
+		if got := r.Header.Get("X-Auth-Token"); got != "secret" {
+			t.Errorf("X-Auth-Token = %q, want secret", got)
+		}
+		switch r.URL.Query().Get("identifier") {
+		case "4QDPT3":
+			w.Write([]byte(`{"DistrictId":"d1","DistrictName":"Springfield","Buildings":[{"BuildingId":"b1","Name":"Lincoln"}]}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("PROXY_URL", srv.URL+"/")
+	t.Setenv("PROXY_AUTH_TOKEN", "secret")
+
+	d, err := Discover("4QDPT3", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.DistrictID != "d1" || len(d.Buildings) != 1 || d.Buildings[0].Name != "Lincoln" {
+		t.Errorf("Discover = %+v", d)
+	}
+
+	if _, err := Discover("NOPE", false); !errors.Is(err, ErrNoDistrict) {
+		t.Errorf("unknown identifier: err = %v, want ErrNoDistrict", err)
+	}
+}
+
+func TestFetchUpstreamError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "blocked", http.StatusForbidden)
+	}))
+	defer srv.Close()
+	t.Setenv("PROXY_URL", srv.URL)
+
+	if _, err := Fetch("b1", "d1", "10-19-2026", "10-19-2026", false); err == nil {
+		t.Error("Fetch succeeded on a 403")
+	}
+}
@@ -7,40 +7,105 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
-const apiURL = "https://api.linqconnect.com/api/FamilyMenu"
+const apiBase = "https://api.linqconnect.com"
 
 // Menu represents the structure of the API response
 type Menu struct {
-	FamilyMenuSessions []struct {
-		ServingSession string `json:"ServingSession"`
-		MenuPlans      []struct {
-			Days []struct {
-				Date      string `json:"Date"`
-				MenuMeals []struct {
-					MenuMealName     string `json:"MenuMealName"`
-					RecipeCategories []struct {
-						CategoryName string `json:"CategoryName"`
-						Recipes      []struct {
-							RecipeName string `json:"RecipeName"`
-						} `json:"Recipes"`
-					} `json:"RecipeCategories"`
-				} `json:"MenuMeals"`
-			} `json:"Days"`
-		} `json:"MenuPlans"`
-	} `json:"FamilyMenuSessions"`
-	AcademicCalendars []interface{} `json:"AcademicCalendars"`
+	FamilyMenuSessions []FamilyMenuSession `json:"FamilyMenuSessions"`
+	AcademicCalendars  []interface{}       `json:"AcademicCalendars"`
+}
+
+// FamilyMenuSession holds the menu plans for one serving session
+// (Breakfast, Lunch, or Snack).
+type FamilyMenuSession struct {
+	ServingSession string     `json:"ServingSession"`
+	MenuPlans      []MenuPlan `json:"MenuPlans"`
+}
+
+// MenuPlan is a named plan (e.g. "2024/2025 Lunch K-5") and its days.
+type MenuPlan struct {
+	MenuPlanName string    `json:"MenuPlanName"`
+	Days         []MenuDay `json:"Days"`
+}
+
+// MenuDay holds the meals served on a single date. Date is in M/D/YYYY form.
+type MenuDay struct {
+	Date      string     `json:"Date"`
+	MenuMeals []MenuMeal `json:"MenuMeals"`
+}
+
+// MenuMeal is a meal line within a day, such as "Main".
+type MenuMeal struct {
+	MenuMealName     string           `json:"MenuMealName"`
+	RecipeCategories []RecipeCategory `json:"RecipeCategories"`
+}
+
+// RecipeCategory groups recipes such as "Entree" or "Fruit". Color is the
+// hex display color assigned by the district.
+type RecipeCategory struct {
+	CategoryName string   `json:"CategoryName"`
+	Color        string   `json:"Color"`
+	Recipes      []Recipe `json:"Recipes"`
+}
+
+// Recipe is a single menu item. Allergens holds LINQ allergen IDs.
+type Recipe struct {
+	ItemID           string     `json:"ItemId"`
+	RecipeIdentifier string     `json:"RecipeIdentifier"`
+	RecipeName       string     `json:"RecipeName"`
+	ServingSize      string     `json:"ServingSize"`
+	GramPerServing   float64    `json:"GramPerServing"`
+	Nutrients        []Nutrient `json:"Nutrients"`
+	Allergens        []string   `json:"Allergens"`
+	HasNutrients     bool       `json:"HasNutrients"`
+}
+
+// Nutrient is a single nutrition fact for a recipe serving.
+type Nutrient struct {
+	Name                string  `json:"Name"`
+	Value               float64 `json:"Value"`
+	HasMissingNutrients bool    `json:"HasMissingNutrients"`
+	Unit                string  `json:"Unit"`
+	Abbreviation        string  `json:"Abbreviation"`
 }
 
 // Fetch retrieves the menu from the API for the given building, district, and date range.
 // If PROXY_URL and PROXY_AUTH_TOKEN env vars are set, requests are routed through
 // the Cloudflare Worker proxy to avoid IP-based blocking.
 func Fetch(buildingID, districtID, startDate, endDate string, debug bool) (*Menu, error) {
-	url := constructURL(buildingID, districtID, startDate, endDate)
+	query := fmt.Sprintf("buildingId=%s&districtId=%s&startDate=%s&endDate=%s", buildingID, districtID, startDate, endDate)
+	body, err := get("/api/FamilyMenu", query, debug)
+	if err != nil {
+		return nil, err
+	}
+
+	if debug {
+		fmt.Fprintf(os.Stderr, "Response body: %s\n", string(body))
+	}
+
+	var menu Menu
+	if err := json.Unmarshal(body, &menu); err != nil {
+		return nil, fmt.Errorf("unmarshaling JSON: %w", err)
+	}
 
 	if debug {
-		fmt.Printf("API URL: %s\n", url)
+		fmt.Fprintf(os.Stderr, "Parsed menu: %+v\n", menu)
+	}
+
+	return &menu, nil
+}
+
+// get requests a LINQ Connect API path and returns the body of a 200
+// response. Requests go through the proxy at PROXY_URL, with
+// PROXY_AUTH_TOKEN, when it is set.
+func get(path, query string, debug bool) ([]byte, error) {
+	url := constructURL(path, query)
+
+	if debug {
+		fmt.Fprintf(os.Stderr, "API URL: %s\n", url)
 	}
 
 	req, err := http.NewRequest("GET", url, nil)
@@ -72,134 +137,46 @@ func Fetch(buildingID, districtID, startDate, endDate string, debug bool) (*Menu
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
 	}
-
-	if debug {
-		fmt.Printf("Response body: %s\n", string(body))
-	}
-
-	var menu Menu
-	if err := json.Unmarshal(body, &menu); err != nil {
-		return nil, fmt.Errorf("unmarshaling JSON: %w", err)
-	}
-
-	if debug {
-		fmt.Printf("Parsed menu: %+v\n", menu)
-	}
-
-	return &menu, nil
+	return body, nil
 }
 
-// constructURL builds the API URL with the given parameters.
+// constructURL builds the API URL for path with the given query.
 // Uses the Cloudflare Worker proxy if PROXY_URL is set.
-func constructURL(buildingID, districtID, startDate, endDate string) string {
-	baseURL := apiURL
+func constructURL(path, query string) string {
+	baseURL := apiBase
 	if proxyURL := os.Getenv("PROXY_URL"); proxyURL != "" {
-		baseURL = strings.TrimRight(proxyURL, "/") + "/api/FamilyMenu"
+		baseURL = strings.TrimRight(proxyURL, "/")
 	}
-	return fmt.Sprintf("%s?buildingId=%s&districtId=%s&startDate=%s&endDate=%s",
-		baseURL, buildingID, districtID, startDate, endDate)
+	return baseURL + path + "?" + query
 }
 
+// GetLunchMenuString returns the text menu for every lunch day.
 func (m *Menu) GetLunchMenuString() string {
-	var lunchMenu strings.Builder
-
-	for _, session := range m.FamilyMenuSessions {
-		if session.ServingSession == "Lunch" {
-			for _, plan := range session.MenuPlans {
-				for _, day := range plan.Days {
-					fmt.Fprintf(&lunchMenu, "Lunch Menu for %s:\n\n", day.Date)
-					for _, meal := range day.MenuMeals {
-						fmt.Fprintf(&lunchMenu, "%s:\n", meal.MenuMealName)
-						for _, category := range meal.RecipeCategories {
-							fmt.Fprintf(&lunchMenu, "  %s:\n", category.CategoryName)
-							for _, recipe := range category.Recipes {
-								fmt.Fprintf(&lunchMenu, "    - %s\n", recipe.RecipeName)
-							}
-						}
-						lunchMenu.WriteString("\n")
-					}
-					lunchMenu.WriteString("\n")
-				}
-			}
-			return lunchMenu.String()
-		}
+	var b strings.Builder
+	for _, day := range m.Days("Lunch") {
+		b.WriteString(day.Text("Lunch"))
+		b.WriteString("\n")
 	}
-	return ""
+	return b.String()
 }
 
+// GetLunchMenuForDate returns the text lunch menu for a date in M/D/YYYY
+// form, or "" if there is none.
 func (m *Menu) GetLunchMenuForDate(date string, debug bool) string {
-	var lunchMenu strings.Builder
-
-	if debug {
-		fmt.Printf("Searching for lunch menu on date: %s\n", date)
-	}
-
-	for _, session := range m.FamilyMenuSessions {
-		if session.ServingSession == "Lunch" {
-			for _, plan := range session.MenuPlans {
-				for _, day := range plan.Days {
-					if debug {
-						fmt.Printf("Checking day: %s\n", day.Date)
-					}
-
-					if day.Date == date {
-						fmt.Fprintf(&lunchMenu, "Lunch Menu for %s:\n\n", day.Date)
-						for _, meal := range day.MenuMeals {
-							fmt.Fprintf(&lunchMenu, "%s:\n", meal.MenuMealName)
-							for _, category := range meal.RecipeCategories {
-								fmt.Fprintf(&lunchMenu, "  %s:\n", category.CategoryName)
-								for _, recipe := range category.Recipes {
-									fmt.Fprintf(&lunchMenu, "    - %s\n", recipe.RecipeName)
-								}
-							}
-							lunchMenu.WriteString("\n")
-						}
-						return lunchMenu.String()
-					}
-				}
-			}
-		}
-	}
-
-	if debug {
-		fmt.Printf("No lunch menu found for date: %s\n", date)
-	}
-	return ""
+	return m.GetMenuForSession("Lunch", date, debug)
 }
 
-// GetMenuForSession returns the menu string for a specific serving session (Breakfast, Lunch, or Snack)
+// GetMenuForSession returns the text menu for a serving session (Breakfast,
+// Lunch, or Snack) on a date in M/D/YYYY form, or "" if there is none.
 func (m *Menu) GetMenuForSession(session string, date string, debug bool) string {
-	var menuBuilder strings.Builder
-
-	for _, sess := range m.FamilyMenuSessions {
-		if sess.ServingSession == session {
-			for _, plan := range sess.MenuPlans {
-				for _, day := range plan.Days {
-					if debug {
-						fmt.Printf("Checking day: %s\n", day.Date)
-					}
-
-					if day.Date == date {
-						fmt.Fprintf(&menuBuilder, "%s Menu for %s:\n\n", session, day.Date)
-						for _, meal := range day.MenuMeals {
-							fmt.Fprintf(&menuBuilder, "%s:\n", meal.MenuMealName)
-							for _, category := range meal.RecipeCategories {
-								fmt.Fprintf(&menuBuilder, "  %s:\n", category.CategoryName)
-								for _, recipe := range category.Recipes {
-									fmt.Fprintf(&menuBuilder, "    - %s\n", recipe.RecipeName)
-								}
-							}
-							menuBuilder.WriteString("\n")
-						}
-						return menuBuilder.String()
-					}
-				}
-			}
+	t, err := time.Parse(apiDateLayout, date)
+	if err == nil {
+		if day, ok := m.DayFor(t, session); ok {
+			return day.Text(session)
 		}
 	}
-
 	if debug {
-		fmt.Printf("No %s menu found for date: %s\n", session, date)
+		fmt.Fprintf(os.Stderr, "No %s menu found for date: %s\n", session, date)
 	}
 	return ""
 }
@@ -0,0 +1,216 @@
+package menu
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DateLayout is the ISO layout used for dates in the normalized model.
+const DateLayout = "2006-01-02"
+
+// apiDateLayout is the layout LINQ Connect uses for MenuDay.Date.
+const apiDateLayout = "1/2/2006"
+
+// Day is the normalized menu for a single date, organized by serving
+// session, then recipe category.
+type Day struct {
+	Date     string    `json:"date"`
+	Sessions []Session `json:"sessions"`
+}
+
+// Session is one serving session (Breakfast, Lunch, or Snack) on a day.
+// Description is the preformatted text menu, only filled in on request.
+type Session struct {
+	Name        string     `json:"name"`
+	Categories  []Category `json:"categories"`
+	Description string     `json:"description,omitempty"`
+}
+
+// Category groups the recipes served in one category of a meal.
+type Category struct {
+	Name    string `json:"name"`
+	Meal    string `json:"meal"`
+	Color   string `json:"color,omitempty"`
+	Recipes []Item `json:"recipes"`
+}
+
+// Item is a single recipe on the menu.
+type Item struct {
+	ItemID      string         `json:"itemId"`
+	Identifier  string         `json:"identifier,omitempty"`
+	Name        string         `json:"name"`
+	ServingSize string         `json:"servingSize,omitempty"`
+	Allergens   []string       `json:"allergens"`
+	Nutrients   []ItemNutrient `json:"nutrients"`
+}
+
+// ItemNutrient is a single nutrition fact for an item serving.
+type ItemNutrient struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// Time returns the day's date at midnight UTC.
+func (d Day) Time() time.Time {
+	t, _ := time.Parse(DateLayout, d.Date)
+	return t
+}
+
+// Session returns the named session for the day, if it was served.
+func (d Day) Session(name string) (Session, bool) {
+	for _, s := range d.Sessions {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Session{}, false
+}
+
+// Text returns the plain-text menu for one session of the day, with the
+// categories listed under their meals, or "" if the session was not
+// served.
+func (d Day) Text(session string) string {
+	s, ok := d.Session(session)
+	if !ok {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s Menu for %s:\n\n", s.Name, d.Time().Format(apiDateLayout))
+	for i, c := range s.Categories {
+		if i == 0 || c.Meal != s.Categories[i-1].Meal {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "%s:\n", c.Meal)
+		}
+		fmt.Fprintf(&b, "  %s:\n", c.Name)
+		for _, recipe := range c.Recipes {
+			fmt.Fprintf(&b, "    - %s\n", recipe.Name)
+		}
+	}
+	if len(s.Categories) > 0 {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Calories returns the item's calories, or zero if they are not listed.
+func (i Item) Calories() float64 {
+	for _, n := range i.Nutrients {
+		if n.Name == "Calories" {
+			return n.Value
+		}
+	}
+	return 0
+}
+
+// Days returns the menu organized by date, then serving session, then recipe
+// category. Only the named sessions are included, in the order given; if no
+// sessions are given, every session is included in API order. Days are
+// sorted by date and days without any matching session are omitted.
+func (m *Menu) Days(sessions ...string) []Day {
+	order := sessions
+	if len(order) == 0 {
+		for _, s := range m.FamilyMenuSessions {
+			order = append(order, s.ServingSession)
+		}
+	}
+
+	byDate := make(map[string]*Day)
+	for _, name := range order {
+		for _, sess := range m.FamilyMenuSessions {
+			if sess.ServingSession != name {
+				continue
+			}
+			for _, plan := range sess.MenuPlans {
+				for _, day := range plan.Days {
+					t, err := time.Parse(apiDateLayout, day.Date)
+					if err != nil {
+						continue
+					}
+					key := t.Format(DateLayout)
+					d, ok := byDate[key]
+					if !ok {
+						d = &Day{Date: key}
+						byDate[key] = d
+					}
+					d.Sessions = appendSession(d.Sessions, name, normalizeMeals(day.MenuMeals))
+				}
+			}
+		}
+	}
+
+	days := make([]Day, 0, len(byDate))
+	for _, d := range byDate {
+		days = append(days, *d)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days
+}
+
+// DayFor returns the normalized menu for a single date.
+func (m *Menu) DayFor(date time.Time, sessions ...string) (Day, bool) {
+	key := date.Format(DateLayout)
+	for _, d := range m.Days(sessions...) {
+		if d.Date == key {
+			return d, true
+		}
+	}
+	return Day{}, false
+}
+
+// appendSession merges categories into the named session, which may already
+// exist when a session has more than one menu plan for the same day.
+func appendSession(sessions []Session, name string, categories []Category) []Session {
+	if len(categories) == 0 {
+		return sessions
+	}
+	for i := range sessions {
+		if sessions[i].Name == name {
+			sessions[i].Categories = append(sessions[i].Categories, categories...)
+			return sessions
+		}
+	}
+	return append(sessions, Session{Name: name, Categories: categories})
+}
+
+func normalizeMeals(meals []MenuMeal) []Category {
+	var categories []Category
+	for _, meal := range meals {
+		for _, rc := range meal.RecipeCategories {
+			c := Category{
+				Name:    rc.CategoryName,
+				Meal:    meal.MenuMealName,
+				Color:   rc.Color,
+				Recipes: make([]Item, 0, len(rc.Recipes)),
+			}
+			for _, r := range rc.Recipes {
+				c.Recipes = append(c.Recipes, normalizeRecipe(r))
+			}
+			categories = append(categories, c)
+		}
+	}
+	return categories
+}
+
+func normalizeRecipe(r Recipe) Item {
+	item := Item{
+		ItemID:      r.ItemID,
+		Identifier:  r.RecipeIdentifier,
+		Name:        r.RecipeName,
+		ServingSize: r.ServingSize,
+		Allergens:   r.Allergens,
+		Nutrients:   make([]ItemNutrient, 0, len(r.Nutrients)),
+	}
+	if item.Allergens == nil {
+		item.Allergens = []string{}
+	}
+	for _, n := range r.Nutrients {
+		item.Nutrients = append(item.Nutrients, ItemNutrient{Name: n.Name, Value: n.Value, Unit: n.Unit})
+	}
+	return item
+}
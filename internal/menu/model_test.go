@@ -0,0 +1,43 @@
+package menu
+
+import "testing"
+
+func testMenu() *Menu {
+	day := func(date, recipe string) MenuDay {
+		return MenuDay{Date: date, MenuMeals: []MenuMeal{{
+			MenuMealName: "Main",
+			RecipeCategories: []RecipeCategory{
+				{CategoryName: "Entree", Recipes: []Recipe{{RecipeName: recipe}}},
+				{CategoryName: "Fruit", Recipes: []Recipe{{RecipeName: "Apple"}}},
+			},
+		}}}
+	}
+	return &Menu{FamilyMenuSessions: []FamilyMenuSession{
+		{ServingSession: "Breakfast", MenuPlans: []MenuPlan{{Days: []MenuDay{day("9/4/2024", "Waffles")}}}},
+		{ServingSession: "Lunch", MenuPlans: []MenuPlan{{Days: []MenuDay{day("9/5/2024", "Fish Sticks"), day("9/4/2024", "Cheese Pizza")}}}},
+	}}
+}
+
+func TestMenuText(t *testing.T) {
+	m := testMenu()
+	pizza := "Lunch Menu for 9/4/2024:\n\nMain:\n  Entree:\n    - Cheese Pizza\n  Fruit:\n    - Apple\n\n"
+	fish := "Lunch Menu for 9/5/2024:\n\nMain:\n  Entree:\n    - Fish Sticks\n  Fruit:\n    - Apple\n\n"
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"lunch for date", m.GetLunchMenuForDate("9/4/2024", false), pizza},
+		{"session for date", m.GetMenuForSession("Breakfast", "9/4/2024", false), "Breakfast Menu for 9/4/2024:\n\nMain:\n  Entree:\n    - Waffles\n  Fruit:\n    - Apple\n\n"},
+		{"no menu", m.GetMenuForSession("Snack", "9/4/2024", false), ""},
+		{"bad date", m.GetLunchMenuForDate("2024-09-04", false), ""},
+		{"all lunches", m.GetLunchMenuString(), pizza + "\n" + fish + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// Alert is a short message about a menu rather than the menu itself, such
+// as a change to a day that was already published.
+type Alert struct {
+	School string
+	// Title is the heading, e.g. "Lincoln Elementary menu changed".
+	Title string
+	// Text is the plain-text message.
+	Text string
+	// Details are passed to webhook templates as .Details and included in
+	// the default payload, e.g. the structured changes.
+	Details interface{}
+}
+
+// Alerter posts alerts. Every built-in Notifier is one.
+type Alerter interface {
+	Alert(ctx context.Context, a Alert) error
+}
+
+// SendAlert posts a to every target. A failing target does not stop the
+// others; the errors are returned together.
+func SendAlert(ctx context.Context, cfgs []Config, a Alert) error {
+	var errs []error
+	for _, cfg := range cfgs {
+		n, err := New(cfg)
+		if err == nil {
+			alerter, ok := n.(Alerter)
+			if !ok {
+				err = fmt.Errorf("cannot send alerts")
+			} else {
+				err = alerter.Alert(ctx, a)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", strings.ToLower(cfg.Type), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Slack) Alert(ctx context.Context, a Alert) error {
+	msg := slackMessage{
+		Text: a.Title,
+		Blocks: []slackBlock{
+			{Type: "header", Text: &slackText{Type: "plain_text", Text: truncate(a.Title, slackMaxHeaderText)}},
+			slackSection(truncate(slackEscape(a.Text), slackMaxSectionText)),
+		},
+	}
+	return postJSON(ctx, s.Client, s.URL, msg)
+}
+
+func (d *Discord) Alert(ctx context.Context, a Alert) error {
+	msg := discordMessage{Embeds: []discordEmbed{{
+		Title:       truncate(a.Title, discordMaxTitle),
+		Description: truncate(discordEscape(a.Text), discordMaxDescription),
+	}}}
+	return postJSON(ctx, d.Client, d.URL, msg)
+}
+
+func (n *Ntfy) Alert(ctx context.Context, a Alert) error {
+	header := http.Header{
+		"Content-Type": {"text/plain; charset=utf-8"},
+		"Title":        {mime.QEncoding.Encode("utf-8", a.Title)},
+		"Tags":         {"warning"},
+	}
+	if n.Priority > 0 {
+		header.Set("Priority", strconv.Itoa(n.Priority))
+	}
+	if n.Token != "" {
+		header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return post(ctx, n.Client, n.URL, []byte(a.Text), header)
+}
+
+func (g *Gotify) Alert(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(gotifyMessage{Title: a.Title, Message: a.Text, Priority: g.Priority})
+	if err != nil {
+		return fmt.Errorf("encoding message: %w", err)
+	}
+	return g.post(ctx, body)
+}
+
+func (w *Webhook) Alert(ctx context.Context, a Alert) error {
+	return w.send(ctx, WebhookData{Title: a.Title, School: a.School, Summary: a.Text, Days: []menu.Day{}, Details: a.Details})
+}
@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/render"
+)
+
+// Discord limits on webhook messages and embeds.
+const (
+	discordMaxEmbeds      = 10
+	discordMaxFields      = 25
+	discordMaxTitle       = 256
+	discordMaxDescription = 4096
+	discordMaxFieldName   = 256
+	discordMaxFieldValue  = 1024
+	discordMaxTotal       = 6000
+)
+
+// Discord posts to a Discord webhook with an embed per day and session and
+// a field per recipe category, colored like the session's first category.
+type Discord struct {
+	URL string
+	// Client defaults to one with DefaultTimeout.
+	Client *http.Client
+}
+
+type discordField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color,omitempty"`
+	Fields      []discordField `json:"fields,omitempty"`
+}
+
+// size is the embed's length as Discord counts it against the message
+// limit.
+func (e discordEmbed) size() int {
+	n := len([]rune(e.Title)) + len([]rune(e.Description))
+	for _, f := range e.Fields {
+		n += len([]rune(f.Name)) + len([]rune(f.Value))
+	}
+	return n
+}
+
+type discordMessage struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []discordEmbed `json:"embeds,omitempty"`
+}
+
+func (d *Discord) Notify(ctx context.Context, doc render.Document) error {
+	for _, msg := range discordMessages(doc) {
+		if err := postJSON(ctx, d.Client, d.URL, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discordMessages builds the messages for doc, starting a new message
+// whenever the next embed would break Discord's embed count or size limits.
+func discordMessages(doc render.Document) []discordMessage {
+	msg := discordMessage{Content: "**" + discordEscape(title(doc)) + "**"}
+	if len(doc.Days) == 0 {
+		msg.Content += "\nNo menu found for the selected dates."
+		return []discordMessage{msg}
+	}
+
+	var msgs []discordMessage
+	size := 0
+	for _, day := range doc.Days {
+		for _, session := range day.Sessions {
+			embed := discordSessionEmbed(doc, day, session)
+			if len(msg.Embeds) == discordMaxEmbeds || size+embed.size() > discordMaxTotal {
+				msgs = append(msgs, msg)
+				msg, size = discordMessage{}, 0
+			}
+			msg.Embeds = append(msg.Embeds, embed)
+			size += embed.size()
+		}
+	}
+	return append(msgs, msg)
+}
+
+// discordSessionEmbed lists a session's recipes with a field per category,
+// named after its meal too when the session has several.
+func discordSessionEmbed(doc render.Document, day menu.Day, session menu.Session) discordEmbed {
+	embed := discordEmbed{Title: truncate(sessionTitle(day, session), discordMaxTitle)}
+	meals := render.Meals(session)
+	for _, meal := range meals {
+		for _, category := range meal.Categories {
+			if embed.Color == 0 {
+				embed.Color = discordColor(category.Color)
+			}
+			var lines []string
+			for _, recipe := range category.Recipes {
+				line := "• " + discordEscape(recipe.Name)
+				if names, flagged := allergens(doc, recipe); flagged {
+					line += " " + allergenFlag
+					if len(names) > 0 {
+						line += " *" + discordEscape(strings.Join(names, ", ")) + "*"
+					}
+				}
+				lines = append(lines, line)
+			}
+			name := category.Name
+			if len(meals) > 1 {
+				name = meal.Name + " · " + name
+			}
+			field := discordField{
+				Name:  truncate(name, discordMaxFieldName),
+				Value: truncate(strings.Join(lines, "\n"), discordMaxFieldValue),
+			}
+			if field.Value == "" {
+				field.Value = "-"
+			}
+			embed.Fields = append(embed.Fields, field)
+		}
+	}
+
+	// Fold categories past the field limit into the description.
+	if len(embed.Fields) > discordMaxFields {
+		var extra []string
+		for _, f := range embed.Fields[discordMaxFields:] {
+			extra = append(extra, "**"+f.Name+"**\n"+f.Value)
+		}
+		embed.Fields = embed.Fields[:discordMaxFields]
+		embed.Description = truncate(strings.Join(extra, "\n\n"), discordMaxFieldValue)
+	}
+	return embed
+}
+
+// discordColor converts a #rrggbb category color to an embed color, or 0
+// (no color) if it is not one.
+func discordColor(c string) int {
+	if len(c) != 7 || c[0] != '#' {
+		return 0
+	}
+	v, err := strconv.ParseInt(c[1:], 16, 32)
+	if err != nil {
+		return 0
+	}
+	return int(v)
+}
+
+var discordReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"~", `\~`,
+	"`", "\\`",
+	"|", `\|`,
+)
+
+// discordEscape escapes characters that would otherwise be read as Discord
+// markdown.
+func discordEscape(s string) string {
+	return discordReplacer.Replace(s)
+}
@@ -0,0 +1,249 @@
+// Package notify posts menus to chat and push notification services, for
+// families and staff who would rather read the menu there than in email.
+//
+// Every service implements Notifier and renders the same render.Document,
+// so the CLI and the scheduler can post to any mix of them. Chat services
+// get the full menu; push services get a one-line Summary. Each can also
+// post a short Alert, such as a notice that a menu changed.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/render"
+)
+
+// Notifier posts a menu.
+type Notifier interface {
+	Notify(ctx context.Context, doc render.Document) error
+}
+
+// Notifier types accepted by New.
+const (
+	TypeSlack   = "slack"
+	TypeDiscord = "discord"
+	TypeNtfy    = "ntfy"
+	TypeGotify  = "gotify"
+	TypeWebhook = "webhook"
+)
+
+// DefaultTimeout limits each request to a service.
+const DefaultTimeout = 30 * time.Second
+
+// maxRetryAfter is the longest a rate-limited request waits before its one
+// retry.
+const maxRetryAfter = 30 * time.Second
+
+// Config selects and configures a Notifier. Profiles and schedule files
+// list targets in this form.
+type Config struct {
+	// Type is slack, discord, ntfy, gotify or webhook.
+	Type string `yaml:"type"`
+	// URL is the Slack or Discord incoming webhook URL, the ntfy topic URL
+	// (e.g. https://ntfy.sh/lunch), the Gotify server URL, or the URL a
+	// generic webhook posts to.
+	URL string `yaml:"url"`
+	// Token is the ntfy access token or Gotify application token.
+	Token string `yaml:"token"`
+	// Priority is the ntfy (1-5) or Gotify (0-10) message priority. Zero
+	// leaves the server default.
+	Priority int `yaml:"priority"`
+
+	// Summary is the summary rule for push services and webhooks, e.g.
+	// first-category, all or categories:entree,vegetable.
+	Summary string `yaml:"summary"`
+	// SummaryItems limits the recipes per session in a summary.
+	SummaryItems int `yaml:"summaryItems"`
+	// Timezone decides which day a summary calls Today. Defaults to
+	// SCHOOL_TZ or the local zone.
+	Timezone string `yaml:"timezone"`
+
+	// Template is the generic webhook's JSON payload, as a Go template
+	// over WebhookData. Defaults to DefaultWebhookTemplate.
+	Template string `yaml:"template"`
+	// Headers are extra HTTP headers for the generic webhook.
+	Headers map[string]string `yaml:"headers"`
+}
+
+// summary returns the configured summary builder.
+func (cfg Config) summary() (Summary, error) {
+	s := Summary{Rule: cfg.Summary, MaxItems: cfg.SummaryItems}
+	if err := validSummaryRule(cfg.Summary); err != nil {
+		return s, err
+	}
+	now, err := dates.Now(cfg.Timezone)
+	if err != nil {
+		return s, err
+	}
+	s.Now = now
+	return s, nil
+}
+
+// New returns the Notifier for cfg.Type.
+func New(cfg Config) (Notifier, error) {
+	typ := strings.ToLower(cfg.Type)
+	switch typ {
+	case TypeSlack, TypeDiscord, TypeNtfy, TypeGotify, TypeWebhook:
+	default:
+		return nil, fmt.Errorf("unknown notifier %q (want slack, discord, ntfy, gotify or webhook)", cfg.Type)
+	}
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("%s URL is required", typ)
+	}
+	summary, err := cfg.summary()
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case TypeSlack:
+		return &Slack{URL: cfg.URL}, nil
+	case TypeDiscord:
+		return &Discord{URL: cfg.URL}, nil
+	case TypeNtfy:
+		return &Ntfy{URL: cfg.URL, Token: cfg.Token, Priority: cfg.Priority, Summary: summary}, nil
+	case TypeGotify:
+		if cfg.Token == "" {
+			return nil, fmt.Errorf("gotify application token is required")
+		}
+		return &Gotify{URL: cfg.URL, Token: cfg.Token, Priority: cfg.Priority, Summary: summary}, nil
+	default:
+		return NewWebhook(cfg.URL, cfg.Template, cfg.Headers, summary)
+	}
+}
+
+// Validate reports the first target that New would reject.
+func Validate(cfgs []Config) error {
+	for _, cfg := range cfgs {
+		if _, err := New(cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Send posts doc to every target. A failing target does not stop the
+// others; the errors are returned together.
+func Send(ctx context.Context, cfgs []Config, doc render.Document) error {
+	var errs []error
+	for _, cfg := range cfgs {
+		n, err := New(cfg)
+		if err == nil {
+			err = n.Notify(ctx, doc)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", strings.ToLower(cfg.Type), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// postJSON posts payload to url as JSON.
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding message: %w", err)
+	}
+	return post(ctx, client, url, body, http.Header{"Content-Type": {"application/json"}})
+}
+
+// post posts body to url with the given headers. A 429 response is retried
+// once after the delay the service asks for, up to maxRetryAfter.
+func post(ctx context.Context, client *http.Client, url string, body []byte, header http.Header) error {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("creating request: %w", err)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.Header.Set("User-Agent", "SchoolMenuConnector/1.0")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("posting message: %w", err)
+		}
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		if resp.StatusCode == http.StatusTooManyRequests && attempt == 0 {
+			wait := retryAfter(resp.Header.Get("Retry-After"))
+			select {
+			case <-time.After(wait):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+}
+
+// retryAfter parses a Retry-After header in seconds, defaulting to one
+// second and capped at maxRetryAfter.
+func retryAfter(header string) time.Duration {
+	wait := time.Second
+	if secs, err := strconv.ParseFloat(header, 64); err == nil && secs > 0 {
+		wait = time.Duration(secs * float64(time.Second))
+	}
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	return wait
+}
+
+// title is the heading posted above the menu.
+func title(doc render.Document) string {
+	if doc.School != "" {
+		return doc.School + " Menu"
+	}
+	return "School Menu"
+}
+
+// sessionTitle heads one session of a day, e.g. "Tuesday, October 20 · Lunch".
+func sessionTitle(day menu.Day, session menu.Session) string {
+	return day.Time().Format("Monday, January 2") + " · " + session.Name
+}
+
+// allergenFlag marks recipes that contain an allergen. It is followed by
+// the allergens' names where doc.AllergenNames has them.
+const allergenFlag = "⚠️"
+
+// allergens returns the names of an item's allergens, and whether it has
+// any at all. Allergens without a known name are flagged but not named.
+func allergens(doc render.Document, item menu.Item) (names []string, flagged bool) {
+	for _, id := range item.Allergens {
+		if name := doc.AllergenNames[id]; name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, len(item.Allergens) > 0
+}
+
+// truncate shortens s to at most n runes, ending it with an ellipsis.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,246 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/render"
+)
+
+// request is one request a test server received.
+type request struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// recorder is a test server that records requests and answers each with
+// the next status in statuses, then 200.
+type recorder struct {
+	*httptest.Server
+	statuses []int
+	header   http.Header
+
+	mu       sync.Mutex
+	requests []request
+}
+
+func newRecorder(t *testing.T, statuses ...int) *recorder {
+	rec := &recorder{statuses: statuses, header: http.Header{}}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		rec.requests = append(rec.requests, request{r.URL.Path, r.Header.Clone(), body})
+		status := http.StatusOK
+		if n := len(rec.requests); n <= len(rec.statuses) {
+			status = rec.statuses[n-1]
+		}
+		rec.mu.Unlock()
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(status)
+		if status >= 300 {
+			io.WriteString(w, "rate limited or broken")
+		}
+	}))
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+func (rec *recorder) received() []request {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]request(nil), rec.requests...)
+}
+
+func testDoc() render.Document {
+	return render.Document{
+		School: "Lincoln",
+		Days: []menu.Day{{Date: "2024-09-04", Sessions: []menu.Session{{
+			Name: "Lunch",
+			Categories: []menu.Category{
+				{Name: "Entree", Meal: "Lunch", Color: "#ff0000", Recipes: []menu.Item{{Name: "Cheese Pizza", Allergens: []string{"milk"}}}},
+				{Name: "Fruit", Meal: "Lunch", Recipes: []menu.Item{{Name: "Apple"}}},
+			},
+		}}}},
+		AllergenNames: map[string]string{"milk": "Milk"},
+	}
+}
+
+func TestNotifyPayloads(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		check func(t *testing.T, r request)
+	}{
+		{"slack", Config{Type: TypeSlack}, func(t *testing.T, r request) {
+			var msg slackMessage
+			mustDecode(t, r.body, &msg)
+			if msg.Text != "Lincoln Menu for Wednesday, September 4" {
+				t.Errorf("text = %q", msg.Text)
+			}
+			if len(msg.Blocks) != 2 || msg.Blocks[0].Type != "header" || msg.Blocks[0].Text.Text != "Lincoln Menu" {
+				t.Fatalf("blocks = %+v, want a header and a section", msg.Blocks)
+			}
+			section := msg.Blocks[1].Text
+			if section.Type != "mrkdwn" || !strings.Contains(section.Text, "• Cheese Pizza ⚠️ _Milk_") {
+				t.Errorf("section = %+v", section)
+			}
+		}},
+		{"discord", Config{Type: TypeDiscord}, func(t *testing.T, r request) {
+			var msg discordMessage
+			mustDecode(t, r.body, &msg)
+			if msg.Content != "**Lincoln Menu**" || len(msg.Embeds) != 1 {
+				t.Fatalf("message = %+v, want the title and one embed", msg)
+			}
+			e := msg.Embeds[0]
+			if e.Title != "Wednesday, September 4 · Lunch" || e.Color != 0xff0000 {
+				t.Errorf("embed title %q, color %x", e.Title, e.Color)
+			}
+			if len(e.Fields) != 2 || e.Fields[0].Name != "Entree" || e.Fields[0].Value != "• Cheese Pizza ⚠️ *Milk*" {
+				t.Errorf("fields = %+v", e.Fields)
+			}
+		}},
+		{"ntfy", Config{Type: TypeNtfy, Token: "tk", Priority: 4}, func(t *testing.T, r request) {
+			if got := r.header.Get("Title"); got != "Lincoln Menu" {
+				t.Errorf("Title = %q", got)
+			}
+			if r.header.Get("Authorization") != "Bearer tk" || r.header.Get("Priority") != "4" || r.header.Get("Tags") != "fork_and_knife" {
+				t.Errorf("headers = %v", r.header)
+			}
+			if !strings.HasSuffix(string(r.body), ": Cheese Pizza") {
+				t.Errorf("body = %q, want the summary", r.body)
+			}
+		}},
+		{"gotify", Config{Type: TypeGotify, Token: "app", Priority: 7}, func(t *testing.T, r request) {
+			if r.path != "/message" || r.header.Get("X-Gotify-Key") != "app" {
+				t.Errorf("path %q, key %q", r.path, r.header.Get("X-Gotify-Key"))
+			}
+			var msg gotifyMessage
+			mustDecode(t, r.body, &msg)
+			if msg.Title != "Lincoln Menu" || msg.Priority != 7 || !strings.HasSuffix(msg.Message, ": Cheese Pizza") {
+				t.Errorf("message = %+v", msg)
+			}
+		}},
+		{"webhook", Config{Type: TypeWebhook, Headers: map[string]string{"X-Key": "k"}}, func(t *testing.T, r request) {
+			if r.header.Get("X-Key") != "k" || r.header.Get("Content-Type") != "application/json" {
+				t.Errorf("headers = %v", r.header)
+			}
+			var payload struct {
+				Title   string     `json:"title"`
+				School  string     `json:"school"`
+				Date    string     `json:"date"`
+				Summary string     `json:"summary"`
+				Days    []menu.Day `json:"days"`
+			}
+			mustDecode(t, r.body, &payload)
+			if payload.Title != "Lincoln Menu" || payload.School != "Lincoln" || payload.Date != "2024-09-04" ||
+				!strings.HasSuffix(payload.Summary, ": Cheese Pizza") || len(payload.Days) != 1 {
+				t.Errorf("payload = %+v", payload)
+			}
+		}},
+		{"webhook template", Config{Type: TypeWebhook, Template: `{"text": {{json .Summary}}}`}, func(t *testing.T, r request) {
+			var payload map[string]string
+			mustDecode(t, r.body, &payload)
+			if len(payload) != 1 || !strings.HasSuffix(payload["text"], ": Cheese Pizza") {
+				t.Errorf("payload = %v", payload)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := newRecorder(t)
+			tt.cfg.URL = rec.URL
+			if err := Send(context.Background(), []Config{tt.cfg}, testDoc()); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			got := rec.received()
+			if len(got) != 1 {
+				t.Fatalf("got %d requests, want 1", len(got))
+			}
+			tt.check(t, got[0])
+		})
+	}
+}
+
+func TestAlertPayload(t *testing.T) {
+	rec := newRecorder(t)
+	a := Alert{School: "Lincoln", Title: "Lincoln menu changed", Text: "+ Tacos", Details: map[string]int{"changed": 1}}
+	if err := SendAlert(context.Background(), []Config{{Type: TypeWebhook, URL: rec.URL}}, a); err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Title   string         `json:"title"`
+		Summary string         `json:"summary"`
+		Days    []menu.Day     `json:"days"`
+		Details map[string]int `json:"details"`
+	}
+	mustDecode(t, rec.received()[0].body, &payload)
+	if payload.Title != a.Title || payload.Summary != a.Text || payload.Days == nil || payload.Details["changed"] != 1 {
+		t.Errorf("payload = %+v", payload)
+	}
+}
+
+func TestPostRetriesAfter429(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		wantErr  bool
+		wantReqs int
+	}{
+		{"retried once", []int{http.StatusTooManyRequests}, false, 2},
+		{"gives up after one retry", []int{http.StatusTooManyRequests, http.StatusTooManyRequests}, true, 2},
+		{"other errors not retried", []int{http.StatusServiceUnavailable}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := newRecorder(t, tt.statuses...)
+			rec.header.Set("Retry-After", "0.01")
+			err := (&Slack{URL: rec.URL}).Notify(context.Background(), testDoc())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if n := len(rec.received()); n != tt.wantReqs {
+				t.Errorf("got %d requests, want %d", n, tt.wantReqs)
+			}
+		})
+	}
+}
+
+func TestNon2xxIsError(t *testing.T) {
+	for _, status := range []int{http.StatusMultipleChoices, http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			rec := newRecorder(t, status)
+			err := (&Discord{URL: rec.URL}).Notify(context.Background(), testDoc())
+			if err == nil || !strings.Contains(err.Error(), http.StatusText(status)) || !strings.Contains(err.Error(), "rate limited or broken") {
+				t.Errorf("err = %v, want the status and response body", err)
+			}
+		})
+	}
+}
+
+func TestSendContinuesPastFailures(t *testing.T) {
+	broken, ok := newRecorder(t, http.StatusInternalServerError), newRecorder(t)
+	err := Send(context.Background(), []Config{{Type: "Slack", URL: broken.URL}, {Type: TypeDiscord, URL: ok.URL}}, testDoc())
+	if err == nil || !strings.HasPrefix(err.Error(), "slack: ") {
+		t.Errorf("err = %v, want the slack failure", err)
+	}
+	if len(ok.received()) != 1 {
+		t.Error("the target after a failing one was not posted to")
+	}
+}
+
+func mustDecode(t *testing.T, body []byte, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(body, v); err != nil {
+		t.Fatalf("decoding %s: %v", body, err)
+	}
+}
@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/asachs01/school_menu_connector/internal/render"
+)
+
+// Ntfy publishes a one-line summary to an ntfy topic, on ntfy.sh or a
+// self-hosted server.
+type Ntfy struct {
+	// URL is the topic URL, e.g. https://ntfy.sh/lincoln-lunch.
+	URL string
+	// Token is an optional access token for protected topics.
+	Token string
+	// Priority is 1 (min) to 5 (max); 0 leaves the default.
+	Priority int
+	Summary  Summary
+	// Client defaults to one with DefaultTimeout.
+	Client *http.Client
+}
+
+func (n *Ntfy) Notify(ctx context.Context, doc render.Document) error {
+	header := http.Header{
+		"Content-Type": {"text/plain; charset=utf-8"},
+		// Header values are ASCII; RFC 2047 keeps names like "Zoë" intact.
+		"Title": {mime.QEncoding.Encode("utf-8", title(doc))},
+		"Tags":  {"fork_and_knife"},
+	}
+	if n.Priority > 0 {
+		header.Set("Priority", strconv.Itoa(n.Priority))
+	}
+	if n.Token != "" {
+		header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return post(ctx, n.Client, n.URL, []byte(n.Summary.Text(doc)), header)
+}
+
+// Gotify sends a one-line summary as a Gotify message.
+type Gotify struct {
+	// URL is the server URL, e.g. https://gotify.example.com.
+	URL string
+	// Token is the application token.
+	Token string
+	// Priority is 0 to 10; 0 leaves the default.
+	Priority int
+	Summary  Summary
+	// Client defaults to one with DefaultTimeout.
+	Client *http.Client
+}
+
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+func (g *Gotify) Notify(ctx context.Context, doc render.Document) error {
+	body, err := json.Marshal(gotifyMessage{Title: title(doc), Message: g.Summary.Text(doc), Priority: g.Priority})
+	if err != nil {
+		return fmt.Errorf("encoding message: %w", err)
+	}
+	return g.post(ctx, body)
+}
+
+// post sends an encoded gotifyMessage to the server's message endpoint.
+func (g *Gotify) post(ctx context.Context, body []byte) error {
+	url := strings.TrimSuffix(g.URL, "/")
+	if !strings.HasSuffix(url, "/message") {
+		url += "/message"
+	}
+	header := http.Header{
+		"Content-Type": {"application/json"},
+		"X-Gotify-Key": {g.Token},
+	}
+	return post(ctx, g.Client, url, body, header)
+}
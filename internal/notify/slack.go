@@ -0,0 +1,132 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/render"
+)
+
+// Slack limits on Block Kit messages.
+const (
+	slackMaxBlocks      = 50
+	slackMaxHeaderText  = 150
+	slackMaxSectionText = 3000
+)
+
+// Slack posts to a Slack incoming webhook as a Block Kit message, with a
+// section per day and session listing the recipes by category.
+type Slack struct {
+	URL string
+	// Client defaults to one with DefaultTimeout.
+	Client *http.Client
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+type slackMessage struct {
+	// Text is the notification fallback for clients that cannot show
+	// blocks.
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+func (s *Slack) Notify(ctx context.Context, doc render.Document) error {
+	for _, msg := range slackMessages(doc) {
+		if err := postJSON(ctx, s.Client, s.URL, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// slackMessages builds the messages for doc, splitting long menus across
+// several so each stays within Slack's block limit.
+func slackMessages(doc render.Document) []slackMessage {
+	heading := title(doc)
+	blocks := []slackBlock{{
+		Type: "header",
+		Text: &slackText{Type: "plain_text", Text: truncate(heading, slackMaxHeaderText)},
+	}}
+	if len(doc.Days) == 0 {
+		blocks = append(blocks, slackSection("_No menu found for the selected dates._"))
+	}
+	for i, day := range doc.Days {
+		if i > 0 {
+			blocks = append(blocks, slackBlock{Type: "divider"})
+		}
+		for _, session := range day.Sessions {
+			blocks = append(blocks, slackSection(slackSessionText(doc, day, session)))
+		}
+	}
+
+	fallback := heading
+	if n := len(doc.Days); n > 0 {
+		fallback = fmt.Sprintf("%s for %s", heading, doc.Days[0].Time().Format("Monday, January 2"))
+		if n > 1 {
+			fallback += " to " + doc.Days[n-1].Time().Format("Monday, January 2")
+		}
+	}
+
+	var msgs []slackMessage
+	for start := 0; start < len(blocks); start += slackMaxBlocks {
+		end := start + slackMaxBlocks
+		if end > len(blocks) {
+			end = len(blocks)
+		}
+		msgs = append(msgs, slackMessage{Text: fallback, Blocks: blocks[start:end]})
+	}
+	return msgs
+}
+
+func slackSection(text string) slackBlock {
+	return slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: truncate(text, slackMaxSectionText)}}
+}
+
+// slackSessionText lists a session's recipes under their categories in
+// Slack mrkdwn, and the categories under their meals when there are several.
+func slackSessionText(doc render.Document, day menu.Day, session menu.Session) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s*", slackEscape(sessionTitle(day, session)))
+	meals := render.Meals(session)
+	for _, meal := range meals {
+		if len(meals) > 1 {
+			fmt.Fprintf(&b, "\n\n*%s*", slackEscape(meal.Name))
+		}
+		for _, category := range meal.Categories {
+			if len(meals) > 1 {
+				fmt.Fprintf(&b, "\n_%s_", slackEscape(category.Name))
+			} else {
+				fmt.Fprintf(&b, "\n\n*%s*", slackEscape(category.Name))
+			}
+			for _, recipe := range category.Recipes {
+				fmt.Fprintf(&b, "\n• %s", slackEscape(recipe.Name))
+				if names, flagged := allergens(doc, recipe); flagged {
+					b.WriteString(" " + allergenFlag)
+					if len(names) > 0 {
+						fmt.Fprintf(&b, " _%s_", slackEscape(strings.Join(names, ", ")))
+					}
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+var slackReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackEscape escapes the characters Slack reserves for links and mentions.
+func slackEscape(s string) string {
+	return slackReplacer.Replace(s)
+}
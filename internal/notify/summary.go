@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/render"
+)
+
+// Summary rules choose which recipes go in a one-line summary such as
+// "Today: Chicken Tenders, Corn".
+const (
+	// SummaryFirstCategory takes the recipes in each session's first
+	// category, usually the entrees. It is the default.
+	SummaryFirstCategory = "first-category"
+	// SummaryAll takes every recipe.
+	SummaryAll = "all"
+	// SummaryCategories, followed by a comma-separated list such as
+	// "categories:entree,vegetable", takes the recipes in categories whose
+	// names contain any of the words.
+	SummaryCategories = "categories:"
+)
+
+// defaultSummaryItems is how many recipes a summary lists per session
+// unless configured otherwise.
+const defaultSummaryItems = 4
+
+// Summary builds one-line menu summaries for push notifications.
+type Summary struct {
+	// Rule is a summary rule; empty means SummaryFirstCategory.
+	Rule string
+	// MaxItems limits the recipes listed per session; 0 means
+	// defaultSummaryItems and a negative value means no limit.
+	MaxItems int
+	// Now is the time "Today" and "Tomorrow" are relative to, in the
+	// school's time zone. Zero means the current time in SCHOOL_TZ.
+	Now time.Time
+}
+
+// validSummaryRule reports an error for an unknown rule.
+func validSummaryRule(rule string) error {
+	switch {
+	case rule == "", rule == SummaryFirstCategory, rule == SummaryAll:
+		return nil
+	case strings.HasPrefix(rule, SummaryCategories) && strings.TrimPrefix(rule, SummaryCategories) != "":
+		return nil
+	}
+	return fmt.Errorf("unknown summary rule %q (want %s, %s or %sNAME,...)", rule, SummaryFirstCategory, SummaryAll, SummaryCategories)
+}
+
+// Validate reports an error for an unknown rule.
+func (s Summary) Validate() error {
+	return validSummaryRule(s.Rule)
+}
+
+// Text summarizes doc with a line per day, e.g.
+// "Today: Chicken Tenders, Corn". Sessions are labelled when a day has
+// more than one.
+func (s Summary) Text(doc render.Document) string {
+	if len(doc.Days) == 0 {
+		return "No menu found for the selected dates."
+	}
+	var lines []string
+	for _, day := range doc.Days {
+		lines = append(lines, s.dayLabel(day)+": "+s.Line(day))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Line summarizes one day's menu, without the day label.
+func (s Summary) Line(day menu.Day) string {
+	var parts []string
+	for _, session := range day.Sessions {
+		items := strings.Join(s.recipes(session), ", ")
+		if items == "" {
+			continue
+		}
+		if len(day.Sessions) > 1 {
+			items = session.Name + ": " + items
+		}
+		parts = append(parts, items)
+	}
+	if len(parts) == 0 {
+		return "no menu"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// recipes returns the names of the session's recipes the rule selects,
+// without duplicates, up to MaxItems.
+func (s Summary) recipes(session menu.Session) []string {
+	var categories []menu.Category
+	switch {
+	case s.Rule == SummaryAll:
+		categories = session.Categories
+	case strings.HasPrefix(s.Rule, SummaryCategories):
+		words := strings.Split(strings.ToLower(strings.TrimPrefix(s.Rule, SummaryCategories)), ",")
+		for _, c := range session.Categories {
+			name := strings.ToLower(c.Name)
+			for _, w := range words {
+				if w = strings.TrimSpace(w); w != "" && strings.Contains(name, w) {
+					categories = append(categories, c)
+					break
+				}
+			}
+		}
+	default:
+		if len(session.Categories) > 0 {
+			categories = session.Categories[:1]
+		}
+	}
+
+	limit := s.MaxItems
+	if limit == 0 {
+		limit = defaultSummaryItems
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, c := range categories {
+		for _, r := range c.Recipes {
+			if seen[r.Name] {
+				continue
+			}
+			if limit > 0 && len(names) == limit {
+				return names
+			}
+			seen[r.Name] = true
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+// dayLabel names a day relative to Now: Today, Tomorrow, or its weekday
+// and date.
+func (s Summary) dayLabel(day menu.Day) string {
+	now := s.Now
+	if now.IsZero() {
+		var err error
+		if now, err = dates.Now(""); err != nil {
+			now = time.Now()
+		}
+	}
+	today := dates.Day(now)
+	switch d := day.Time(); {
+	case d.Equal(today):
+		return "Today"
+	case d.Equal(today.AddDate(0, 0, 1)):
+		return "Tomorrow"
+	default:
+		return d.Format("Monday, January 2")
+	}
+}
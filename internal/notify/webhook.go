@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/render"
+)
+
+// DefaultWebhookTemplate is the generic webhook payload unless one is
+// configured.
+const DefaultWebhookTemplate = `{"title": {{json .Title}}, "school": {{json .School}}, "date": {{json .Date}}, "summary": {{json .Summary}}, "days": {{json .Days}}{{if .Details}}, "details": {{json .Details}}{{end}}}`
+
+// WebhookData is the model passed to webhook payload templates.
+type WebhookData struct {
+	// Title is the message title, e.g. "Lincoln Elementary Menu".
+	Title  string
+	School string
+	// Date is the first day's date as YYYY-MM-DD, or empty if there is no
+	// menu.
+	Date string
+	// Summary is the one-line summary, e.g. "Today: Chicken Tenders, Corn",
+	// or an alert's text.
+	Summary string
+	Days    []menu.Day
+	// Details are an alert's structured details, such as menu changes.
+	Details interface{}
+}
+
+// Webhook posts a JSON payload rendered from a template, for services with
+// no built-in notifier.
+type Webhook struct {
+	URL     string
+	Headers map[string]string
+	Summary Summary
+	// Client defaults to one with DefaultTimeout.
+	Client *http.Client
+
+	tmpl *template.Template
+}
+
+// NewWebhook returns a Webhook posting to url. payload is a template over
+// WebhookData producing JSON, with a json function that encodes any value;
+// an empty payload uses DefaultWebhookTemplate.
+func NewWebhook(url, payload string, headers map[string]string, summary Summary) (*Webhook, error) {
+	if payload == "" {
+		payload = DefaultWebhookTemplate
+	}
+	tmpl, err := template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(payload)
+	if err != nil {
+		return nil, fmt.Errorf("parsing webhook template: %w", err)
+	}
+	return &Webhook{URL: url, Headers: headers, Summary: summary, tmpl: tmpl}, nil
+}
+
+func (w *Webhook) Notify(ctx context.Context, doc render.Document) error {
+	data := WebhookData{
+		Title:   title(doc),
+		School:  doc.School,
+		Summary: w.Summary.Text(doc),
+		Days:    doc.Days,
+	}
+	if len(doc.Days) > 0 {
+		data.Date = doc.Days[0].Date
+	}
+	return w.send(ctx, data)
+}
+
+// send renders the payload template over data and posts it.
+func (w *Webhook) send(ctx context.Context, data WebhookData) error {
+	var buf bytes.Buffer
+	if err := w.tmpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("rendering webhook template: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return fmt.Errorf("webhook template did not produce valid JSON")
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	for k, v := range w.Headers {
+		header.Set(k, v)
+	}
+	return post(ctx, w.Client, w.URL, buf.Bytes(), header)
+}
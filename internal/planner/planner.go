@@ -0,0 +1,290 @@
+// Package planner marks upcoming school days by what a child likes:
+// favorite days, when a favorite recipe is served, and pack-lunch days,
+// when there is no school lunch they will eat or can safely eat.
+//
+// Likes and dislikes are recipe patterns such as "pizza" or "fish stick",
+// matched word by word as in a search, so "fish stick" finds "Breaded
+// Fish Sticks".
+package planner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/search"
+)
+
+// DefaultSession is the meal planned for unless Preferences say otherwise.
+const DefaultSession = "Lunch"
+
+// Reasons a day is a pack-lunch day.
+const (
+	// ReasonDisliked means disliked entrees are among those ruled out.
+	ReasonDisliked = "disliked"
+	// ReasonAllergen means entrees with one of the child's allergens are
+	// among those ruled out.
+	ReasonAllergen = "allergen"
+	// ReasonNoMenu means no menu is published for the school day.
+	ReasonNoMenu = "no-menu"
+)
+
+// Markers put before calendar event titles and plan lines.
+const (
+	MarkerFavorite  = "⭐ Favorite"
+	MarkerPackLunch = "🎒 Pack lunch"
+)
+
+// Preferences are a child's liked and disliked recipe patterns and
+// allergens.
+type Preferences struct {
+	Favorites []string
+	Dislikes  []string
+	// Allergens maps the LINQ IDs of the child's allergens to display
+	// names. Entrees containing them are ruled out.
+	Allergens map[string]string
+	// Session is the meal planned for. Defaults to DefaultSession.
+	Session string
+}
+
+// Empty reports whether there are no likes, dislikes or allergens to plan
+// by.
+func (p Preferences) Empty() bool {
+	return len(p.Favorites) == 0 && len(p.Dislikes) == 0 && len(p.Allergens) == 0
+}
+
+// Plan marks each school day in a period.
+type Plan struct {
+	School  string `json:"school,omitempty"`
+	Session string `json:"session"`
+	Days    []Day  `json:"days"`
+}
+
+// Day is one school day in a plan.
+type Day struct {
+	// Date is YYYY-MM-DD.
+	Date      string `json:"date"`
+	Favorite  bool   `json:"favorite"`
+	PackLunch bool   `json:"packLunch"`
+	// Reasons say why a pack-lunch day is one: ReasonNoMenu, or
+	// ReasonDisliked and ReasonAllergen for the entrees ruled out.
+	Reasons []string `json:"reasons,omitempty"`
+	// Entrees are the entrees served, Favorites the recipes that match a
+	// favorite and Disliked the entrees that match a dislike.
+	Entrees   []string `json:"entrees,omitempty"`
+	Favorites []string `json:"favorites,omitempty"`
+	Disliked  []string `json:"disliked,omitempty"`
+	// Unsafe are the entrees with the child's allergens, which are named
+	// in Allergens.
+	Unsafe    []string `json:"unsafe,omitempty"`
+	Allergens []string `json:"allergens,omitempty"`
+}
+
+// Build plans the school days in dates from the menus in days. A day is a
+// pack-lunch day when every entree is disliked or has one of the child's
+// allergens, or when the session is not on the menu.
+func Build(school string, dates []time.Time, days []menu.Day, prefs Preferences) Plan {
+	session := prefs.Session
+	if session == "" {
+		session = DefaultSession
+	}
+	byDate := make(map[string]menu.Day, len(days))
+	for _, d := range days {
+		byDate[d.Date] = d
+	}
+
+	p := Plan{School: school, Session: session, Days: []Day{}}
+	for _, date := range dates {
+		d := Day{Date: date.Format(menu.DateLayout)}
+		s, ok := byDate[d.Date].Session(session)
+		if !ok || len(entrees(s)) == 0 {
+			d.PackLunch = true
+			d.Reasons = []string{ReasonNoMenu}
+			p.Days = append(p.Days, d)
+			continue
+		}
+
+		edible := 0
+		for _, item := range entrees(s) {
+			d.Entrees = appendNew(d.Entrees, item.Name)
+			disliked := matchesAny(prefs.Dislikes, item.Name)
+			if disliked {
+				d.Disliked = appendNew(d.Disliked, item.Name)
+			}
+			unsafe := false
+			for _, id := range item.Allergens {
+				if name, ok := prefs.Allergens[id]; ok {
+					d.Allergens = appendNew(d.Allergens, name)
+					unsafe = true
+				}
+			}
+			if unsafe {
+				d.Unsafe = appendNew(d.Unsafe, item.Name)
+			}
+			if !disliked && !unsafe {
+				edible++
+			}
+		}
+		for _, c := range s.Categories {
+			for _, item := range c.Recipes {
+				if matchesAny(prefs.Favorites, item.Name) {
+					d.Favorites = appendNew(d.Favorites, item.Name)
+				}
+			}
+		}
+		d.Favorite = len(d.Favorites) > 0
+		if edible == 0 {
+			d.PackLunch = true
+			if len(d.Disliked) > 0 {
+				d.Reasons = append(d.Reasons, ReasonDisliked)
+			}
+			if len(d.Unsafe) > 0 {
+				d.Reasons = append(d.Reasons, ReasonAllergen)
+			}
+		}
+		p.Days = append(p.Days, d)
+	}
+	return p
+}
+
+// PackLunchDays returns the days that need a packed lunch.
+func (p Plan) PackLunchDays() []Day {
+	var list []Day
+	for _, d := range p.Days {
+		if d.PackLunch {
+			list = append(list, d)
+		}
+	}
+	return list
+}
+
+// Marker returns the text to put before the title of a day's calendar
+// event for the session: MarkerPackLunch, MarkerFavorite, or "" for an
+// ordinary day or another session.
+func (p Plan) Marker(day menu.Day, session string) string {
+	if session != p.Session {
+		return ""
+	}
+	for _, d := range p.Days {
+		if d.Date != day.Date {
+			continue
+		}
+		switch {
+		case d.PackLunch:
+			return MarkerPackLunch
+		case d.Favorite:
+			return MarkerFavorite
+		}
+	}
+	return ""
+}
+
+// Title sums up the plan, e.g. "Lincoln Elementary lunch plan: pack 2 of
+// 5 days".
+func (p Plan) Title() string {
+	title := strings.ToLower(p.Session) + " plan"
+	if p.School != "" {
+		title = p.School + " " + title
+	} else {
+		title = strings.ToUpper(title[:1]) + title[1:]
+	}
+	n := len(p.PackLunchDays())
+	if n == 0 {
+		return title + ": no packed lunches"
+	}
+	return fmt.Sprintf("%s: pack %d of %d days", title, n, len(p.Days))
+}
+
+// Text describes the plan for people, one day per line, e.g.
+//
+//	Monday, October 19 · 🎒 Pack lunch: disliked entrees (Fish Sticks)
+//	Tuesday, October 20 · ⭐ Favorite: Cheese Pizza
+//	Wednesday, October 21 · School lunch: Chicken Nuggets
+func (p Plan) Text() string {
+	var b strings.Builder
+	for _, d := range p.Days {
+		date := menu.Day{Date: d.Date}.Time().Format("Monday, January 2")
+		fmt.Fprintf(&b, "%s · %s\n", date, d.describe(p.Session))
+	}
+	return b.String()
+}
+
+func (d Day) describe(session string) string {
+	var parts []string
+	switch {
+	case d.PackLunch:
+		var why []string
+		for _, r := range d.Reasons {
+			switch r {
+			case ReasonNoMenu:
+				why = append(why, "no menu")
+			case ReasonDisliked:
+				why = append(why, fmt.Sprintf("disliked entrees (%s)", strings.Join(d.Disliked, ", ")))
+			case ReasonAllergen:
+				why = append(why, fmt.Sprintf("%s in %s", strings.Join(d.Allergens, ", "), strings.Join(d.Unsafe, ", ")))
+			default:
+				why = append(why, r)
+			}
+		}
+		parts = append(parts, MarkerPackLunch+": "+strings.Join(why, "; "))
+		if d.Favorite {
+			parts = append(parts, "favorites: "+strings.Join(d.Favorites, ", "))
+		}
+	case d.Favorite:
+		parts = append(parts, MarkerFavorite+": "+strings.Join(d.Favorites, ", "))
+	default:
+		parts = append(parts, "School "+strings.ToLower(session)+": "+strings.Join(d.Entrees, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// entrees returns the session's entrees: the recipes in categories named
+// as entrees or mains, or every recipe if no category is.
+func entrees(s menu.Session) []menu.Item {
+	var items, all []menu.Item
+	for _, c := range s.Categories {
+		all = append(all, c.Recipes...)
+		name := strings.ToLower(c.Name)
+		if strings.Contains(name, "entree") || strings.Contains(name, "entrée") || strings.Contains(name, "main") {
+			items = append(items, c.Recipes...)
+		}
+	}
+	if len(items) == 0 {
+		return all
+	}
+	return items
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if search.Matches(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendNew(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// SessionFor returns the session to plan for a child who has the meal
+// types: DefaultSession if it is one of them or none are given, or else
+// the first.
+func SessionFor(mealTypes []string) string {
+	if len(mealTypes) == 0 {
+		return DefaultSession
+	}
+	for _, t := range mealTypes {
+		if t == DefaultSession {
+			return t
+		}
+	}
+	return mealTypes[0]
+}
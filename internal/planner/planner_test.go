@@ -0,0 +1,149 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+const milk = "a-milk"
+
+func date(s string) time.Time {
+	t, err := time.Parse(menu.DateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// lunch returns a Lunch day with the entrees, each given as a name and
+// optionally its allergen IDs after a colon, and a side of Apple.
+func lunch(date string, entrees ...string) menu.Day {
+	c := menu.Category{Name: "Lunch Entree", Meal: "Main"}
+	for _, e := range entrees {
+		name, allergens, _ := strings.Cut(e, ":")
+		item := menu.Item{Name: name}
+		if allergens != "" {
+			item.Allergens = strings.Split(allergens, ",")
+		}
+		c.Recipes = append(c.Recipes, item)
+	}
+	return menu.Day{Date: date, Sessions: []menu.Session{{Name: "Lunch", Categories: []menu.Category{
+		c,
+		{Name: "Fruit", Meal: "Main", Recipes: []menu.Item{{Name: "Apple"}}},
+	}}}}
+}
+
+func TestBuild(t *testing.T) {
+	prefs := Preferences{
+		Favorites: []string{"pizza", "apple"},
+		Dislikes:  []string{"fish stick"},
+		Allergens: map[string]string{milk: "Milk"},
+	}
+	tests := []struct {
+		name  string
+		day   menu.Day
+		prefs Preferences
+		want  string
+	}{
+		{"ordinary", lunch("2024-09-02", "Chicken Nuggets"), Preferences{Dislikes: prefs.Dislikes}, "entrees [Chicken Nuggets]"},
+		{"favorite", lunch("2024-09-02", "Pepperoni Pizza", "Chicken Nuggets"), prefs, "favorite [Pepperoni Pizza Apple]; entrees [Pepperoni Pizza Chicken Nuggets]"},
+		{"favorite side", lunch("2024-09-02", "Chicken Nuggets"), prefs, "favorite [Apple]; entrees [Chicken Nuggets]"},
+		{"one entree left", lunch("2024-09-02", "Breaded Fish Sticks", "Chicken Nuggets"), prefs, "favorite [Apple]; entrees [Breaded Fish Sticks Chicken Nuggets]; disliked [Breaded Fish Sticks]"},
+		{"all disliked", lunch("2024-09-02", "Breaded Fish Sticks"), Preferences{Dislikes: prefs.Dislikes}, "pack [disliked]; entrees [Breaded Fish Sticks]; disliked [Breaded Fish Sticks]"},
+		{"allergen", lunch("2024-09-02", "Mac and Cheese:"+milk), Preferences{Allergens: prefs.Allergens}, "pack [allergen]; entrees [Mac and Cheese]; unsafe [Mac and Cheese] [Milk]"},
+		{"other allergens are fine", lunch("2024-09-02", "Chicken Nuggets:a-wheat"), Preferences{Allergens: prefs.Allergens}, "entrees [Chicken Nuggets]"},
+		{"disliked and allergen", lunch("2024-09-02", "Breaded Fish Sticks", "Cheese Pizza:a-wheat,"+milk), prefs,
+			"pack [disliked allergen]; favorite [Cheese Pizza Apple]; entrees [Breaded Fish Sticks Cheese Pizza]; disliked [Breaded Fish Sticks]; unsafe [Cheese Pizza] [Milk]"},
+		{"no menu", menu.Day{Date: "2024-09-01"}, prefs, "pack [no-menu]"},
+		{"no entrees", lunch("2024-09-02"), prefs, "favorite [Apple]; entrees [Apple]"},
+		{"other session", lunch("2024-09-02", "Pepperoni Pizza"), Preferences{Favorites: prefs.Favorites, Session: "Breakfast"}, "pack [no-menu]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Build("Lincoln", []time.Time{date("2024-09-02")}, []menu.Day{tt.day}, tt.prefs)
+			if len(p.Days) != 1 || p.Days[0].Date != "2024-09-02" {
+				t.Fatalf("days = %+v, want 2024-09-02", p.Days)
+			}
+			if got := outline(p.Days[0]); got != tt.want {
+				t.Errorf("day = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// outline describes a planned day compactly.
+func outline(d Day) string {
+	var parts []string
+	if d.PackLunch {
+		parts = append(parts, fmt.Sprint("pack ", d.Reasons))
+	}
+	if d.Favorite {
+		parts = append(parts, fmt.Sprint("favorite ", d.Favorites))
+	}
+	if d.Entrees != nil {
+		parts = append(parts, fmt.Sprint("entrees ", d.Entrees))
+	}
+	if d.Disliked != nil {
+		parts = append(parts, fmt.Sprint("disliked ", d.Disliked))
+	}
+	if d.Unsafe != nil {
+		parts = append(parts, fmt.Sprint("unsafe ", d.Unsafe, " ", d.Allergens))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func TestPlanOutput(t *testing.T) {
+	days := []menu.Day{
+		lunch("2024-10-21", "Breaded Fish Sticks"),
+		lunch("2024-10-22", "Cheese Pizza"),
+		lunch("2024-10-23", "Chicken Nuggets", "Mac and Cheese"),
+	}
+	week := []time.Time{date("2024-10-21"), date("2024-10-22"), date("2024-10-23"), date("2024-10-24")}
+	p := Build("Lincoln Elementary", week, days, Preferences{Favorites: []string{"pizza"}, Dislikes: []string{"fish stick"}})
+
+	if got, want := p.Title(), "Lincoln Elementary lunch plan: pack 2 of 4 days"; got != want {
+		t.Errorf("Title = %q, want %q", got, want)
+	}
+	want := "Monday, October 21 · 🎒 Pack lunch: disliked entrees (Breaded Fish Sticks)\n" +
+		"Tuesday, October 22 · ⭐ Favorite: Cheese Pizza\n" +
+		"Wednesday, October 23 · School lunch: Chicken Nuggets, Mac and Cheese\n" +
+		"Thursday, October 24 · 🎒 Pack lunch: no menu\n"
+	if got := p.Text(); got != want {
+		t.Errorf("Text =\n%s\nwant\n%s", got, want)
+	}
+
+	markers := []string{MarkerPackLunch, MarkerFavorite, "", MarkerPackLunch}
+	for i, d := range week {
+		day := menu.Day{Date: d.Format(menu.DateLayout)}
+		if got := p.Marker(day, "Lunch"); got != markers[i] {
+			t.Errorf("Marker(%s) = %q, want %q", day.Date, got, markers[i])
+		}
+		if got := p.Marker(day, "Breakfast"); got != "" {
+			t.Errorf("Marker(%s, Breakfast) = %q, want none", day.Date, got)
+		}
+	}
+
+	if got := Build("", week[1:2], days, Preferences{Favorites: []string{"pizza"}}).Title(); got != "Lunch plan: no packed lunches" {
+		t.Errorf("Title without school = %q", got)
+	}
+}
+
+func TestSessionFor(t *testing.T) {
+	tests := []struct {
+		mealTypes []string
+		want      string
+	}{
+		{nil, "Lunch"},
+		{[]string{"Breakfast", "Lunch"}, "Lunch"},
+		{[]string{"Breakfast", "Snack"}, "Breakfast"},
+	}
+	for _, tt := range tests {
+		if got := SessionFor(tt.mealTypes); got != tt.want {
+			t.Errorf("SessionFor(%v) = %q, want %q", tt.mealTypes, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package render
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSV renders one row per recipe, suitable for spreadsheets.
+type CSV struct{}
+
+func (CSV) ContentType() string { return "text/csv; charset=utf-8" }
+func (CSV) Extension() string   { return "csv" }
+
+var csvHeader = []string{"date", "session", "meal", "category", "recipe", "item_id", "calories", "allergens"}
+
+func (CSV) Render(w io.Writer, doc Document) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, day := range doc.Days {
+		for _, session := range day.Sessions {
+			for _, category := range session.Categories {
+				for _, recipe := range category.Recipes {
+					calories := ""
+					if c := recipe.Calories(); c != 0 {
+						calories = strconv.FormatFloat(c, 'f', -1, 64)
+					}
+					row := []string{
+						day.Date,
+						session.Name,
+						category.Meal,
+						category.Name,
+						recipe.Name,
+						recipe.ItemID,
+						calories,
+						strings.Join(allergenNames(doc, recipe.Allergens), ";"),
+					}
+					if err := cw.Write(row); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// allergenNames maps allergen IDs to their names, keeping the ID when the
+// name is not known.
+func allergenNames(doc Document, ids []string) []string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		if names[i] = doc.AllergenNames[id]; names[i] == "" {
+			names[i] = id
+		}
+	}
+	return names
+}
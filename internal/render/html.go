@@ -0,0 +1,71 @@
+package render
+
+import (
+	"html/template"
+	"io"
+	"regexp"
+)
+
+// HTML renders a standalone HTML page. Styles are inlined so the output can
+// also be used as an email body.
+type HTML struct{}
+
+func (HTML) ContentType() string { return "text/html; charset=utf-8" }
+func (HTML) Extension() string   { return "html" }
+
+func (HTML) Render(w io.Writer, doc Document) error {
+	return htmlTemplate.Execute(w, doc)
+}
+
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{3,8}$`)
+
+// categoryColor returns a safe CSS color for a category, falling back to a
+// neutral gray when the district did not set one.
+func categoryColor(color string) template.CSS {
+	if hexColor.MatchString(color) {
+		return template.CSS(color)
+	}
+	return template.CSS("#e9ecef")
+}
+
+var htmlTemplate = template.Must(template.New("menu").Funcs(template.FuncMap{
+	"dayTitle": dayTitle,
+	"meals":    Meals,
+	"color":    categoryColor,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if .School}}{{.School}} {{end}}Menu</title>
+</head>
+<body style="margin:0;padding:16px;background:#f8f9fa;font-family:-apple-system,BlinkMacSystemFont,'Segoe UI',Roboto,Helvetica,Arial,sans-serif;color:#212529;">
+<div style="max-width:640px;margin:0 auto;">
+<h1 style="font-size:24px;margin:0 0 16px;">{{if .School}}{{.School}} {{end}}Menu</h1>
+{{- if not .Days}}
+<p style="color:#6c757d;">No menu found for the selected dates.</p>
+{{- end}}
+{{- range .Days}}
+<div style="background:#ffffff;border-radius:8px;padding:16px;margin:0 0 16px;">
+<h2 style="font-size:18px;margin:0 0 12px;">{{dayTitle .}}</h2>
+{{- range .Sessions}}
+<h3 style="font-size:16px;margin:12px 0 8px;color:#495057;">{{.Name}}</h3>
+{{- range meals .}}
+{{- range .Categories}}
+<div style="border-left:6px solid {{color .Color}};padding:4px 0 4px 10px;margin:0 0 8px;">
+<div style="font-weight:600;font-size:14px;">{{.Name}}</div>
+<ul style="margin:4px 0 0;padding-left:18px;">
+{{- range .Recipes}}
+<li style="font-size:14px;">{{.Name}}</li>
+{{- end}}
+</ul>
+</div>
+{{- end}}
+{{- end}}
+{{- end}}
+</div>
+{{- end}}
+</div>
+</body>
+</html>
+`))
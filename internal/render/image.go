@@ -0,0 +1,411 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// PNG renders the first day of a document as an image for e-ink displays
+// and home dashboards. Text is sized to fill the image and shrinks until the
+// whole menu fits; anything that still does not fit is cut off with "...".
+type PNG struct {
+	// Width and Height are the image size in pixels. Defaults to 800x480.
+	Width  int
+	Height int
+	// Depth is the number of bits per pixel: 1, 2, 4 or 8 for grayscale, or
+	// 24 for full color. Defaults to 8.
+	Depth int
+	// Dither applies Floyd-Steinberg dithering when reducing to 1, 2 or 4
+	// bits, which looks better than hard thresholding on e-paper.
+	Dither bool
+}
+
+func (PNG) ContentType() string { return "image/png" }
+func (PNG) Extension() string   { return "png" }
+
+const (
+	defaultImageWidth  = 800
+	defaultImageHeight = 480
+	minImageFontSize   = 9
+)
+
+// Validate reports whether the image options are usable. Zero fields are
+// rejected; Render fills them in from WithDefaults first.
+func (p PNG) Validate() error {
+	if p.Width < 64 || p.Height < 64 || p.Width > 4096 || p.Height > 4096 {
+		return fmt.Errorf("image size must be between 64 and 4096 pixels, got %dx%d", p.Width, p.Height)
+	}
+	switch p.Depth {
+	case 1, 2, 4, 8, 24:
+		return nil
+	}
+	return fmt.Errorf("image depth must be 1, 2, 4, 8 or 24, got %d", p.Depth)
+}
+
+// WithDefaults returns p with zero fields set to the default 800x480 size
+// and depth 8.
+func (p PNG) WithDefaults() PNG {
+	if p.Width == 0 {
+		p.Width = defaultImageWidth
+	}
+	if p.Height == 0 {
+		p.Height = defaultImageHeight
+	}
+	if p.Depth == 0 {
+		p.Depth = 8
+	}
+	return p
+}
+
+func (p PNG) Render(w io.Writer, doc Document) error {
+	p = p.WithDefaults()
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	fonts, err := loadImageFonts()
+	if err != nil {
+		return err
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, p.Width, p.Height))
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+
+	var day *menu.Day
+	if len(doc.Days) > 0 {
+		day = &doc.Days[0]
+	}
+	drawDay(canvas, fonts, doc.School, day, p.Depth == 24)
+
+	return png.Encode(w, p.quantize(canvas))
+}
+
+// quantize reduces the canvas to the requested bit depth.
+func (p PNG) quantize(src *image.RGBA) image.Image {
+	switch p.Depth {
+	case 24:
+		return src
+	case 8:
+		gray := image.NewGray(src.Bounds())
+		draw.Draw(gray, gray.Bounds(), src, image.Point{}, draw.Src)
+		return gray
+	}
+
+	levels := 1 << p.Depth
+	palette := make(color.Palette, levels)
+	for i := range palette {
+		v := uint8(i * 255 / (levels - 1))
+		palette[i] = color.Gray{Y: v}
+	}
+
+	// Convert to gray first so dithering works on luminance, not on the
+	// individual color channels.
+	gray := image.NewGray(src.Bounds())
+	draw.Draw(gray, gray.Bounds(), src, image.Point{}, draw.Src)
+
+	out := image.NewPaletted(src.Bounds(), palette)
+	if p.Dither {
+		draw.FloydSteinberg.Draw(out, out.Bounds(), gray, image.Point{})
+	} else {
+		draw.Draw(out, out.Bounds(), gray, image.Point{}, draw.Src)
+	}
+	return out
+}
+
+type imageFonts struct {
+	regular *opentype.Font
+	bold    *opentype.Font
+}
+
+var (
+	fontsOnce   sync.Once
+	fontsLoaded imageFonts
+	fontsErr    error
+)
+
+func loadImageFonts() (imageFonts, error) {
+	fontsOnce.Do(func() {
+		fontsLoaded.regular, fontsErr = opentype.Parse(goregular.TTF)
+		if fontsErr != nil {
+			return
+		}
+		fontsLoaded.bold, fontsErr = opentype.Parse(gobold.TTF)
+	})
+	if fontsErr != nil {
+		return imageFonts{}, fmt.Errorf("loading fonts: %w", fontsErr)
+	}
+	return fontsLoaded, nil
+}
+
+// lineKind selects the font and decoration for a line of the image.
+type lineKind int
+
+const (
+	lineSession lineKind = iota
+	lineCategory
+	lineRecipe
+	lineNote
+)
+
+type imageLine struct {
+	kind  lineKind
+	text  string
+	color color.Color
+}
+
+type imageFaces struct {
+	header, session, category, recipe font.Face
+}
+
+func newImageFaces(f imageFonts, size float64) (imageFaces, error) {
+	face := func(fnt *opentype.Font, size float64) (font.Face, error) {
+		return opentype.NewFace(fnt, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	}
+
+	var faces imageFaces
+	var err error
+	if faces.header, err = face(f.bold, size*1.2); err != nil {
+		return faces, err
+	}
+	if faces.session, err = face(f.bold, size*1.05); err != nil {
+		return faces, err
+	}
+	if faces.category, err = face(f.bold, size*0.8); err != nil {
+		return faces, err
+	}
+	if faces.recipe, err = face(f.regular, size); err != nil {
+		return faces, err
+	}
+	return faces, nil
+}
+
+func (f imageFaces) forKind(kind lineKind) font.Face {
+	switch kind {
+	case lineSession:
+		return f.session
+	case lineCategory:
+		return f.category
+	}
+	return f.recipe
+}
+
+// menuLines flattens a day into the lines drawn below the header.
+func menuLines(day *menu.Day) []imageLine {
+	if day == nil || len(day.Sessions) == 0 {
+		return []imageLine{{kind: lineNote, text: "No menu today"}}
+	}
+
+	var lines []imageLine
+	for _, session := range day.Sessions {
+		if len(day.Sessions) > 1 {
+			lines = append(lines, imageLine{kind: lineSession, text: session.Name})
+		}
+		for _, category := range session.Categories {
+			r, g, b := hexRGB(category.Color)
+			c := color.RGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: 255}
+			lines = append(lines, imageLine{kind: lineCategory, text: strings.ToUpper(category.Name), color: c})
+			for _, recipe := range category.Recipes {
+				lines = append(lines, imageLine{kind: lineRecipe, text: recipe.Name, color: c})
+			}
+		}
+	}
+	return lines
+}
+
+// drawDay lays out a header band and the day's menu in one or two columns,
+// picking the layout with the largest font size at which everything fits.
+func drawDay(dst *image.RGBA, fonts imageFonts, school string, day *menu.Day, colorMode bool) {
+	width := dst.Bounds().Dx()
+	height := dst.Bounds().Dy()
+	margin := maxInt(4, width/40)
+	barWidth := maxInt(3, width/120)
+
+	lines := menuLines(day)
+
+	var faces imageFaces
+	var columns [][]imageLine
+	best := 0.0
+	for cols := 1; cols <= 2; cols++ {
+		colWidth := (width-margin)/cols - margin - barWidth - margin/2
+		for size := maxFloat(float64(height)/12, minImageFontSize); size >= minImageFontSize; size-- {
+			f, err := newImageFaces(fonts, size)
+			if err != nil {
+				return
+			}
+			headerH := lineHeight(f.header) + margin
+			flowed, fits := flowColumns(wrapLines(lines, f, colWidth), f, cols, height-headerH-margin)
+			if fits || size-1 < minImageFontSize {
+				// A second column only wins if it allows a larger font.
+				if size > best || columns == nil {
+					best, faces, columns = size, f, flowed
+				}
+				break
+			}
+		}
+	}
+
+	// Header band: session and date on the left, school on the right.
+	headerH := lineHeight(faces.header) + margin
+	draw.Draw(dst, image.Rect(0, 0, width, headerH), image.Black, image.Point{}, draw.Src)
+	title := "Menu"
+	if day != nil {
+		var names []string
+		for _, s := range day.Sessions {
+			names = append(names, s.Name)
+		}
+		if len(names) > 0 {
+			title = strings.Join(names, " & ")
+		}
+		title += " - " + day.Time().Format("Mon Jan 2")
+	}
+	baseline := (headerH + faces.header.Metrics().Ascent.Ceil() - faces.header.Metrics().Descent.Ceil()) / 2
+	drawText(dst, faces.header, image.White, margin, baseline, title)
+	if school != "" {
+		sw := font.MeasureString(faces.category, school).Ceil()
+		titleW := font.MeasureString(faces.header, title).Ceil()
+		if margin+titleW+2*margin+sw <= width-margin {
+			drawText(dst, faces.category, image.White, width-margin-sw, baseline, school)
+		}
+	}
+
+	colWidth := (width - margin) / len(columns)
+	for c, column := range columns {
+		left := margin + c*colWidth
+		textLeft := left + barWidth + margin/2
+		y := headerH + margin/2
+
+		for i, line := range column {
+			face := faces.forKind(line.kind)
+			h := lineHeight(face)
+			if line.kind == lineCategory && i > 0 {
+				y += h / 3
+			}
+			if y+h > height-margin/4 {
+				// Out of room: mark the cut with an ellipsis.
+				drawText(dst, faces.recipe, image.Black, textLeft, y-lineHeight(faces.recipe)/4, "...")
+				break
+			}
+
+			if line.color != nil && line.kind != lineSession {
+				bar := line.color
+				if !colorMode {
+					bar = color.Black
+				}
+				draw.Draw(dst, image.Rect(left, y, left+barWidth, y+h), image.NewUniform(bar), image.Point{}, draw.Src)
+			}
+
+			x := textLeft
+			if line.kind == lineNote || line.kind == lineSession {
+				x = left
+			}
+			drawText(dst, face, image.Black, x, y+face.Metrics().Ascent.Ceil(), line.text)
+			y += h
+		}
+	}
+}
+
+// flowColumns splits lines into up to cols columns of the given height,
+// preferring to break before a category or session heading. It reports
+// whether everything fit.
+func flowColumns(lines []imageLine, faces imageFaces, cols, height int) ([][]imageLine, bool) {
+	columns := [][]imageLine{nil}
+	used := 0
+	for i, line := range lines {
+		h := lineHeight(faces.forKind(line.kind))
+		if line.kind == lineCategory && len(columns[len(columns)-1]) > 0 {
+			h += h / 3
+		}
+		if used+h > height && len(columns) < cols {
+			// Move a dangling heading along with its first recipe.
+			last := columns[len(columns)-1]
+			var carry []imageLine
+			if n := len(last); n > 0 && last[n-1].kind != lineRecipe && i > 0 {
+				carry = []imageLine{last[n-1]}
+				columns[len(columns)-1] = last[:n-1]
+			}
+			columns = append(columns, carry)
+			used = linesHeight(carry, faces)
+			h = lineHeight(faces.forKind(line.kind))
+		}
+		columns[len(columns)-1] = append(columns[len(columns)-1], line)
+		used += h
+	}
+	return columns, used <= height
+}
+
+// wrapLines splits any line wider than width at word boundaries.
+func wrapLines(lines []imageLine, faces imageFaces, width int) []imageLine {
+	var out []imageLine
+	for _, line := range lines {
+		face := faces.forKind(line.kind)
+		words := strings.Fields(line.text)
+		current := ""
+		for _, word := range words {
+			candidate := word
+			if current != "" {
+				candidate = current + " " + word
+			}
+			if current != "" && font.MeasureString(face, candidate).Ceil() > width {
+				out = append(out, imageLine{kind: line.kind, text: current, color: line.color})
+				current = word
+				continue
+			}
+			current = candidate
+		}
+		out = append(out, imageLine{kind: line.kind, text: current, color: line.color})
+	}
+	return out
+}
+
+func linesHeight(lines []imageLine, faces imageFaces) int {
+	total := 0
+	for i, line := range lines {
+		h := lineHeight(faces.forKind(line.kind))
+		total += h
+		if line.kind == lineCategory && i > 0 {
+			total += h / 3
+		}
+	}
+	return total
+}
+
+func lineHeight(face font.Face) int {
+	return face.Metrics().Height.Ceil()
+}
+
+func drawText(dst draw.Image, face font.Face, src image.Image, x, y int, text string) {
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  src,
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
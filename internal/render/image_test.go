@@ -0,0 +1,100 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestPNG(t *testing.T) {
+	tests := []struct {
+		name    string
+		png     PNG
+		bounds  image.Rectangle
+		palette color.Palette
+	}{
+		{"defaults", PNG{}, image.Rect(0, 0, 800, 480), nil},
+		{"e-ink", PNG{Width: 296, Height: 128, Depth: 1, Dither: true}, image.Rect(0, 0, 296, 128), color.Palette{color.Gray{0}, color.Gray{255}}},
+		{"four grays", PNG{Width: 400, Height: 300, Depth: 2}, image.Rect(0, 0, 400, 300), color.Palette{color.Gray{0}, color.Gray{85}, color.Gray{170}, color.Gray{255}}},
+		{"color", PNG{Width: 1024, Height: 768, Depth: 24}, image.Rect(0, 0, 1024, 768), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.png.Render(&buf, testDocument()); err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			img, err := png.Decode(&buf)
+			if err != nil {
+				t.Fatalf("decoding PNG: %v", err)
+			}
+			if img.Bounds() != tt.bounds {
+				t.Errorf("bounds = %v, want %v", img.Bounds(), tt.bounds)
+			}
+
+			if tt.palette == nil {
+				return
+			}
+			paletted, ok := img.(*image.Paletted)
+			if !ok {
+				t.Fatalf("image is %T, want a paletted image", img)
+			}
+			if len(paletted.Palette) != len(tt.palette) {
+				t.Fatalf("palette = %v, want %v", paletted.Palette, tt.palette)
+			}
+			for i, c := range tt.palette {
+				if !sameColor(paletted.Palette[i], c) {
+					t.Errorf("palette[%d] = %v, want %v", i, paletted.Palette[i], c)
+				}
+			}
+			// Text is drawn in black on white, so both ends of the palette
+			// are used.
+			used := make(map[uint8]bool)
+			for _, idx := range paletted.Pix {
+				used[idx] = true
+			}
+			if !used[0] || !used[uint8(len(tt.palette)-1)] {
+				t.Errorf("palette indexes used = %v, want black and white", used)
+			}
+		})
+	}
+}
+
+func sameColor(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	return ar == br && ag == bg && ab == bb && aa == ba
+}
+
+func TestPNGValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		png     PNG
+		wantErr string
+	}{
+		{"valid", PNG{Width: 800, Height: 480, Depth: 1}, ""},
+		{"smallest", PNG{Width: 64, Height: 64, Depth: 24}, ""},
+		{"largest", PNG{Width: 4096, Height: 4096, Depth: 8}, ""},
+		{"defaults", PNG{}.WithDefaults(), ""},
+		{"zero width", PNG{Height: 480, Depth: 8}, "image size must be between 64 and 4096 pixels, got 0x480"},
+		{"zero height", PNG{Width: 800, Depth: 8}, "image size must be between 64 and 4096 pixels, got 800x0"},
+		{"negative width", PNG{Width: -800, Height: 480, Depth: 8}, "image size must be between 64 and 4096 pixels, got -800x480"},
+		{"too small", PNG{Width: 63, Height: 480, Depth: 8}, "image size must be between 64 and 4096 pixels, got 63x480"},
+		{"too wide", PNG{Width: 4097, Height: 480, Depth: 8}, "image size must be between 64 and 4096 pixels, got 4097x480"},
+		{"too tall", PNG{Width: 800, Height: 100000, Depth: 8}, "image size must be between 64 and 4096 pixels, got 800x100000"},
+		{"zero depth", PNG{Width: 800, Height: 480}, "image depth must be 1, 2, 4, 8 or 24, got 0"},
+		{"negative depth", PNG{Width: 800, Height: 480, Depth: -1}, "image depth must be 1, 2, 4, 8 or 24, got -1"},
+		{"unsupported depth", PNG{Width: 800, Height: 480, Depth: 16}, "image depth must be 1, 2, 4, 8 or 24, got 16"},
+		{"too deep", PNG{Width: 800, Height: 480, Depth: 32}, "image depth must be 1, 2, 4, 8 or 24, got 32"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.png.Validate()
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("Validate = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// JSON renders the structured menu model.
+type JSON struct{}
+
+func (JSON) ContentType() string { return "application/json" }
+func (JSON) Extension() string   { return "json" }
+
+type jsonDocument struct {
+	School string     `json:"school,omitempty"`
+	Days   []menu.Day `json:"days"`
+}
+
+func (JSON) Render(w io.Writer, doc Document) error {
+	days := doc.Days
+	if days == nil {
+		days = []menu.Day{}
+	}
+	return json.NewEncoder(w).Encode(jsonDocument{School: doc.School, Days: days})
+}
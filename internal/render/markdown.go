@@ -0,0 +1,61 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Markdown renders a document with one heading per day and session.
+type Markdown struct{}
+
+func (Markdown) ContentType() string { return "text/markdown; charset=utf-8" }
+func (Markdown) Extension() string   { return "md" }
+
+func (Markdown) Render(w io.Writer, doc Document) error {
+	var b strings.Builder
+
+	title := "School Menu"
+	if doc.School != "" {
+		title = doc.School + " Menu"
+	}
+	fmt.Fprintf(&b, "# %s\n\n", mdEscape(title))
+
+	if len(doc.Days) == 0 {
+		b.WriteString("_No menu found for the selected dates._\n")
+	}
+
+	for _, day := range doc.Days {
+		fmt.Fprintf(&b, "## %s\n\n", dayTitle(day))
+		for _, session := range day.Sessions {
+			fmt.Fprintf(&b, "### %s\n\n", mdEscape(session.Name))
+			for _, meal := range Meals(session) {
+				for _, category := range meal.Categories {
+					fmt.Fprintf(&b, "**%s**\n\n", mdEscape(category.Name))
+					for _, recipe := range category.Recipes {
+						fmt.Fprintf(&b, "- %s\n", mdEscape(recipe.Name))
+					}
+					b.WriteString("\n")
+				}
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var mdReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"[", `\[`,
+	"]", `\]`,
+	"#", `\#`,
+)
+
+// mdEscape escapes characters that would otherwise be read as Markdown.
+func mdEscape(s string) string {
+	return mdReplacer.Replace(s)
+}
@@ -0,0 +1,484 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/go-pdf/fpdf"
+)
+
+// PDF renders a printable calendar grid on US Letter paper in landscape,
+// one page per week or, when Monthly is set, one page per month.
+type PDF struct {
+	// Monthly lays out a whole month per page instead of a week per page.
+	Monthly bool
+	// Allergens draws a numbered marker after each recipe that contains an
+	// allergen, with a legend at the bottom of the page. Names come from
+	// Document.AllergenNames when available.
+	Allergens bool
+}
+
+func (PDF) ContentType() string { return "application/pdf" }
+func (PDF) Extension() string   { return "pdf" }
+
+const (
+	pdfMargin     = 10.0
+	pdfTitleH     = 14.0
+	pdfHeaderH    = 7.0
+	pdfLegendH    = 10.0
+	pdfCellPad    = 1.5
+	pdfDefaultHex = "#e9ecef"
+)
+
+// allergenPalette colors the numbered allergen markers.
+var allergenPalette = [][3]int{
+	{220, 53, 69}, {253, 126, 20}, {25, 135, 84}, {13, 110, 253},
+	{111, 66, 193}, {214, 51, 132}, {32, 201, 151}, {102, 16, 242},
+}
+
+func (p PDF) Render(w io.Writer, doc Document) error {
+	pdf := fpdf.New("L", "mm", "Letter", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, pdfMargin)
+	pdf.SetTitle(documentTitle(doc), true)
+	pdf.SetCreator("School Menu Connector", true)
+
+	r := &pdfRenderer{
+		pdf:      pdf,
+		tr:       pdf.UnicodeTranslatorFromDescriptor(""),
+		opts:     p,
+		doc:      doc,
+		days:     make(map[string]menu.Day, len(doc.Days)),
+		allergen: make(map[string]int),
+	}
+	for _, d := range doc.Days {
+		r.days[d.Date] = d
+		if d.Time().Weekday() == time.Saturday || d.Time().Weekday() == time.Sunday {
+			r.weekends = true
+		}
+	}
+
+	if len(doc.Days) == 0 {
+		pdf.AddPage()
+		r.title("")
+		pdf.SetFont("Helvetica", "I", 12)
+		pdf.SetXY(pdfMargin, pdfMargin+pdfTitleH+10)
+		pdf.CellFormat(0, 8, r.tr("No menu found for the selected dates."), "", 0, "L", false, 0, "")
+	} else if p.Monthly {
+		for _, month := range r.months() {
+			r.monthPage(month)
+		}
+	} else {
+		for _, week := range r.weeks() {
+			r.weekPage(week)
+		}
+	}
+
+	if err := pdf.Error(); err != nil {
+		return fmt.Errorf("building PDF: %w", err)
+	}
+	return pdf.Output(w)
+}
+
+// pdfRenderer holds the state for rendering one document.
+type pdfRenderer struct {
+	pdf      *fpdf.Fpdf
+	tr       func(string) string
+	opts     PDF
+	doc      Document
+	days     map[string]menu.Day
+	weekends bool
+
+	// allergen numbers allergen IDs in order of first appearance.
+	allergen      map[string]int
+	allergenOrder []string
+	pageAllergens map[string]bool
+}
+
+func documentTitle(doc Document) string {
+	if doc.School != "" {
+		return doc.School + " Menu"
+	}
+	return "School Menu"
+}
+
+// columns returns the weekdays shown in the grid.
+func (r *pdfRenderer) columns() []time.Weekday {
+	cols := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	if r.weekends {
+		cols = append(cols, time.Saturday, time.Sunday)
+	}
+	return cols
+}
+
+// weekStart returns the Monday on or before t.
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return t.AddDate(0, 0, -offset)
+}
+
+func (r *pdfRenderer) weeks() []time.Time {
+	seen := make(map[time.Time]bool)
+	var weeks []time.Time
+	for _, d := range r.doc.Days {
+		ws := weekStart(d.Time())
+		if !seen[ws] {
+			seen[ws] = true
+			weeks = append(weeks, ws)
+		}
+	}
+	sort.Slice(weeks, func(i, j int) bool { return weeks[i].Before(weeks[j]) })
+	return weeks
+}
+
+func (r *pdfRenderer) months() []time.Time {
+	seen := make(map[time.Time]bool)
+	var months []time.Time
+	for _, d := range r.doc.Days {
+		t := d.Time()
+		m := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if !seen[m] {
+			seen[m] = true
+			months = append(months, m)
+		}
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+	return months
+}
+
+// sessionNames lists the sessions served anywhere in the document.
+func (r *pdfRenderer) sessionNames() string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, d := range r.doc.Days {
+		for _, s := range d.Sessions {
+			if !seen[s.Name] {
+				seen[s.Name] = true
+				names = append(names, s.Name)
+			}
+		}
+	}
+	return strings.Join(names, " & ")
+}
+
+func (r *pdfRenderer) title(subtitle string) {
+	pdf := r.pdf
+	pdf.SetTextColor(33, 37, 41)
+	pdf.SetXY(pdfMargin, pdfMargin)
+	pdf.SetFont("Helvetica", "B", 16)
+	title := documentTitle(r.doc)
+	if sessions := r.sessionNames(); sessions != "" {
+		title = sessions + " Menu"
+		if r.doc.School != "" {
+			title = r.doc.School + " - " + title
+		}
+	}
+	pdf.CellFormat(0, 8, r.tr(title), "", 1, "L", false, 0, "")
+	if subtitle != "" {
+		pdf.SetFont("Helvetica", "", 11)
+		pdf.CellFormat(0, 5, r.tr(subtitle), "", 1, "L", false, 0, "")
+	}
+}
+
+func (r *pdfRenderer) weekPage(start time.Time) {
+	r.pdf.AddPage()
+	r.pageAllergens = make(map[string]bool)
+	r.title("Week of " + start.Format("January 2, 2006"))
+
+	cols := r.columns()
+	pageW, pageH := r.pdf.GetPageSize()
+	gridW := pageW - 2*pdfMargin
+	colW := gridW / float64(len(cols))
+	top := pdfMargin + pdfTitleH
+	cellH := pageH - top - pdfHeaderH - pdfMargin - r.legendHeight()
+
+	for i, wd := range cols {
+		date := start.AddDate(0, 0, (int(wd)+6)%7)
+		x := pdfMargin + float64(i)*colW
+		r.headerCell(x, top, colW, date.Format("Monday, Jan 2"))
+		r.dayCell(x, top+pdfHeaderH, colW, cellH, date, false, true)
+	}
+	r.legend()
+}
+
+func (r *pdfRenderer) monthPage(month time.Time) {
+	r.pdf.AddPage()
+	r.pageAllergens = make(map[string]bool)
+	r.title(month.Format("January 2006"))
+
+	cols := r.columns()
+	first := weekStart(month)
+	last := month.AddDate(0, 1, -1)
+	rows := int(last.Sub(first).Hours()/24)/7 + 1
+
+	pageW, pageH := r.pdf.GetPageSize()
+	gridW := pageW - 2*pdfMargin
+	colW := gridW / float64(len(cols))
+	top := pdfMargin + pdfTitleH
+	cellH := (pageH - top - pdfHeaderH - pdfMargin - r.legendHeight()) / float64(rows)
+
+	for i, wd := range cols {
+		r.headerCell(pdfMargin+float64(i)*colW, top, colW, wd.String())
+	}
+	for row := 0; row < rows; row++ {
+		for i, wd := range cols {
+			date := first.AddDate(0, 0, row*7+(int(wd)+6)%7)
+			x := pdfMargin + float64(i)*colW
+			y := top + pdfHeaderH + float64(row)*cellH
+			r.dayCell(x, y, colW, cellH, date, true, date.Month() == month.Month())
+		}
+	}
+	r.legend()
+}
+
+func (r *pdfRenderer) legendHeight() float64 {
+	if r.opts.Allergens {
+		return pdfLegendH
+	}
+	return 0
+}
+
+func (r *pdfRenderer) headerCell(x, y, w float64, label string) {
+	pdf := r.pdf
+	pdf.SetFillColor(52, 58, 64)
+	pdf.SetTextColor(255, 255, 255)
+	pdf.SetDrawColor(173, 181, 189)
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetXY(x, y)
+	pdf.CellFormat(w, pdfHeaderH, r.tr(label), "1", 0, "C", true, 0, "")
+}
+
+// dayCell draws one calendar cell. In compact (monthly) mode the category
+// name is replaced by a colored square before each recipe.
+func (r *pdfRenderer) dayCell(x, y, w, h float64, date time.Time, compact, inPeriod bool) {
+	pdf := r.pdf
+	pdf.SetDrawColor(173, 181, 189)
+	if inPeriod {
+		pdf.SetFillColor(255, 255, 255)
+	} else {
+		pdf.SetFillColor(241, 243, 245)
+	}
+	pdf.Rect(x, y, w, h, "FD")
+	if !inPeriod {
+		return
+	}
+
+	fontSize, lineH := 8.0, 3.6
+	if compact {
+		fontSize, lineH = 6.0, 2.7
+	}
+
+	cursor := y + pdfCellPad
+	bottom := y + h - pdfCellPad
+	innerX := x + pdfCellPad
+	innerW := w - 2*pdfCellPad
+
+	if compact {
+		pdf.SetTextColor(33, 37, 41)
+		pdf.SetFont("Helvetica", "B", 8)
+		pdf.SetXY(innerX, cursor)
+		pdf.CellFormat(innerW, 3.5, strconv.Itoa(date.Day()), "", 0, "R", false, 0, "")
+		cursor += 3.5
+	}
+
+	day, ok := r.days[date.Format(menu.DateLayout)]
+	if !ok && compact {
+		return
+	}
+	if !ok {
+		pdf.SetTextColor(134, 142, 150)
+		pdf.SetFont("Helvetica", "I", fontSize)
+		pdf.SetXY(innerX, cursor)
+		pdf.CellFormat(innerW, lineH, r.tr("No menu"), "", 0, "L", false, 0, "")
+		return
+	}
+
+	overflow := func(need float64) bool {
+		if cursor+need <= bottom {
+			return false
+		}
+		pdf.SetTextColor(134, 142, 150)
+		pdf.SetFont("Helvetica", "I", fontSize)
+		pdf.SetXY(innerX, bottom-lineH)
+		pdf.CellFormat(innerW, lineH, r.tr("..."), "", 0, "R", false, 0, "")
+		return true
+	}
+
+	for _, session := range day.Sessions {
+		if len(day.Sessions) > 1 {
+			if overflow(lineH) {
+				return
+			}
+			pdf.SetTextColor(33, 37, 41)
+			pdf.SetFont("Helvetica", "B", fontSize+0.5)
+			pdf.SetXY(innerX, cursor)
+			pdf.CellFormat(innerW, lineH, r.tr(session.Name), "", 0, "L", false, 0, "")
+			cursor += lineH
+		}
+
+		for _, category := range session.Categories {
+			cr, cg, cb := hexRGB(category.Color)
+			textX, textW := innerX, innerW
+
+			if compact {
+				textX, textW = innerX+2.2, innerW-2.2
+			} else {
+				if overflow(lineH + 0.6) {
+					return
+				}
+				pdf.SetFillColor(cr, cg, cb)
+				tr, tg, tb := contrastRGB(cr, cg, cb)
+				pdf.SetTextColor(tr, tg, tb)
+				pdf.SetFont("Helvetica", "B", fontSize)
+				pdf.SetXY(innerX, cursor)
+				pdf.CellFormat(innerW, lineH+0.4, r.tr(category.Name), "", 0, "L", true, 0, "")
+				cursor += lineH + 0.6
+			}
+
+			pdf.SetTextColor(33, 37, 41)
+			pdf.SetFont("Helvetica", "", fontSize)
+			for _, recipe := range category.Recipes {
+				// Translated text is cp1252 bytes, which SplitText would
+				// misread as UTF-8.
+				var lines []string
+				for _, line := range pdf.SplitLines([]byte(r.tr(recipe.Name)), textW) {
+					lines = append(lines, string(line))
+				}
+				markers := r.markers(recipe)
+				markerW := float64(len(markers)) * (lineH * 0.9)
+				if len(lines) > 0 && len(markers) > 0 && pdf.GetStringWidth(lines[len(lines)-1])+markerW > textW {
+					lines = append(lines, "")
+				}
+				if overflow(float64(len(lines)) * lineH) {
+					return
+				}
+
+				if compact {
+					pdf.SetFillColor(cr, cg, cb)
+					pdf.Rect(innerX, cursor+lineH*0.25, 1.6, lineH*0.5, "F")
+				}
+				for i, line := range lines {
+					pdf.SetXY(textX, cursor)
+					pdf.CellFormat(textW, lineH, line, "", 0, "L", false, 0, "")
+					if i == len(lines)-1 && len(markers) > 0 {
+						r.drawMarkers(textX+pdf.GetStringWidth(line)+0.6, cursor, lineH, markers)
+						pdf.SetTextColor(33, 37, 41)
+						pdf.SetFont("Helvetica", "", fontSize)
+					}
+					cursor += lineH
+				}
+			}
+		}
+	}
+}
+
+// markers returns the legend numbers for a recipe's allergens.
+func (r *pdfRenderer) markers(recipe menu.Item) []int {
+	if !r.opts.Allergens {
+		return nil
+	}
+	var nums []int
+	for _, id := range recipe.Allergens {
+		n, ok := r.allergen[id]
+		if !ok {
+			r.allergenOrder = append(r.allergenOrder, id)
+			n = len(r.allergenOrder)
+			r.allergen[id] = n
+		}
+		r.pageAllergens[id] = true
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+func (r *pdfRenderer) drawMarkers(x, y, lineH float64, nums []int) {
+	pdf := r.pdf
+	radius := lineH * 0.4
+	fontSize := lineH * 1.4
+	for i, n := range nums {
+		cx := x + float64(i)*(lineH*0.9) + radius
+		cy := y + lineH/2
+		c := allergenPalette[(n-1)%len(allergenPalette)]
+		pdf.SetFillColor(c[0], c[1], c[2])
+		pdf.Circle(cx, cy, radius, "F")
+		pdf.SetTextColor(255, 255, 255)
+		pdf.SetFont("Helvetica", "B", fontSize)
+		pdf.SetXY(cx-radius, cy-radius)
+		pdf.CellFormat(2*radius, 2*radius, strconv.Itoa(n), "", 0, "C", false, 0, "")
+	}
+}
+
+// legend lists the allergens marked on the current page.
+func (r *pdfRenderer) legend() {
+	if !r.opts.Allergens || len(r.pageAllergens) == 0 {
+		return
+	}
+	pdf := r.pdf
+	_, pageH := pdf.GetPageSize()
+	y := pageH - pdfMargin - pdfLegendH + 3
+	x := pdfMargin
+
+	pdf.SetTextColor(33, 37, 41)
+	pdf.SetFont("Helvetica", "B", 8)
+	pdf.SetXY(x, y)
+	pdf.CellFormat(18, 4, "Allergens:", "", 0, "L", false, 0, "")
+	x += 18
+
+	for i, id := range r.allergenOrder {
+		if !r.pageAllergens[id] {
+			continue
+		}
+		name := r.doc.AllergenNames[id]
+		if name == "" {
+			name = shortID(id)
+		}
+		r.drawMarkers(x, y, 4, []int{i + 1})
+		pdf.SetFont("Helvetica", "", 8)
+		label := r.tr(name)
+		pdf.SetXY(x+4.5, y)
+		pdf.CellFormat(pdf.GetStringWidth(label)+1, 4, label, "", 0, "L", false, 0, "")
+		x += 4.5 + pdf.GetStringWidth(label) + 4
+	}
+}
+
+// shortID abbreviates an unnamed allergen ID for the legend.
+func shortID(id string) string {
+	if i := strings.Index(id, "-"); i > 0 {
+		return id[:i]
+	}
+	return id
+}
+
+// hexRGB parses a #rgb or #rrggbb color, falling back to light gray.
+func hexRGB(color string) (int, int, int) {
+	if !hexColor.MatchString(color) {
+		color = pdfDefaultHex
+	}
+	hex := strings.TrimPrefix(color, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) < 6 {
+		return hexRGB(pdfDefaultHex)
+	}
+	v, err := strconv.ParseUint(hex[:6], 16, 32)
+	if err != nil {
+		return hexRGB(pdfDefaultHex)
+	}
+	return int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff)
+}
+
+// contrastRGB picks black or white text for a background color.
+func contrastRGB(r, g, b int) (int, int, int) {
+	luminance := 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+	if luminance > 150 {
+		return 33, 37, 41
+	}
+	return 255, 255, 255
+}
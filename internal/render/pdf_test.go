@@ -0,0 +1,60 @@
+package render
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// testDocument spans two weeks and two months, with allergens and a
+// category color.
+func testDocument() Document {
+	lunch := func(date string, recipes ...menu.Item) menu.Day {
+		return menu.Day{Date: date, Sessions: []menu.Session{{Name: "Lunch", Categories: []menu.Category{
+			{Name: "Entree", Meal: "Main", Color: "#ffa8a8", Recipes: recipes},
+			{Name: "Fruit", Meal: "Main", Recipes: []menu.Item{{Name: "Apple"}}},
+		}}}}
+	}
+	return Document{
+		School: "Lincoln Élémentaire",
+		Days: []menu.Day{
+			lunch("2024-09-27", menu.Item{Name: "Cheese Pizza", Allergens: []string{"a-milk", "a-wheat"}}),
+			lunch("2024-09-30", menu.Item{Name: "Crème brûlée", Allergens: []string{"a-milk", "a-egg"}}),
+			lunch("2024-10-01", menu.Item{Name: "Chicken Nuggets", Allergens: []string{"a-wheat"}}, menu.Item{Name: "Soft Tacos"}),
+		},
+		AllergenNames: map[string]string{"a-milk": "Milk", "a-wheat": "Wheat"},
+	}
+}
+
+var pdfPage = regexp.MustCompile(`/Type /Page\b[^s]`)
+
+func TestPDF(t *testing.T) {
+	tests := []struct {
+		name  string
+		pdf   PDF
+		doc   Document
+		pages int
+	}{
+		{"week", PDF{}, testDocument(), 2},
+		{"week with allergens", PDF{Allergens: true}, testDocument(), 2},
+		{"month with allergens", PDF{Monthly: true, Allergens: true}, testDocument(), 2},
+		{"no menu", PDF{Monthly: true}, Document{School: "Lincoln"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.pdf.Render(&buf, tt.doc); err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			out := buf.Bytes()
+			if !bytes.HasPrefix(out, []byte("%PDF-")) || !bytes.HasSuffix(bytes.TrimSpace(out), []byte("%%EOF")) {
+				t.Fatalf("output is not a PDF document: %.40q...", out)
+			}
+			if n := len(pdfPage.FindAll(out, -1)); n != tt.pages {
+				t.Errorf("rendered %d pages, want %d", n, tt.pages)
+			}
+		})
+	}
+}
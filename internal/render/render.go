@@ -0,0 +1,167 @@
+// Package render formats normalized menus for people and programs.
+//
+// Every output format implements Renderer, so callers (the CLI, the web
+// API, email) pick a format by name or by HTTP Accept header and render the
+// same Document.
+package render
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// Document is the menu to render.
+type Document struct {
+	// School is the school name, if known. Renderers that have a title use it.
+	School string
+	Days   []menu.Day
+	// AllergenNames maps LINQ allergen IDs to display names. Renderers that
+	// show allergens fall back to the ID when a name is missing.
+	AllergenNames map[string]string
+}
+
+// Renderer writes a Document in one output format.
+type Renderer interface {
+	// Render writes doc to w.
+	Render(w io.Writer, doc Document) error
+	// ContentType is the MIME type of the rendered output.
+	ContentType() string
+	// Extension is the conventional file extension, without the dot.
+	Extension() string
+}
+
+var renderers = map[string]Renderer{}
+
+// aliases maps alternate format names onto registered ones.
+var aliases = map[string]string{
+	"txt": "text",
+	"md":  "markdown",
+	"htm": "html",
+}
+
+// Register makes a renderer available by name. It is intended to be called
+// from init functions and panics if the name is already taken.
+func Register(name string, r Renderer) {
+	if _, ok := renderers[name]; ok {
+		panic("render: duplicate format " + name)
+	}
+	renderers[name] = r
+}
+
+func init() {
+	Register("text", Text{})
+	Register("markdown", Markdown{})
+	Register("html", HTML{})
+	Register("csv", CSV{})
+	Register("json", JSON{})
+	Register("pdf", PDF{})
+	Register("png", PNG{})
+}
+
+// ForFormat returns the renderer for a format name such as "markdown" or a
+// file extension such as "md".
+func ForFormat(name string) (Renderer, error) {
+	name = strings.ToLower(strings.TrimPrefix(name, "."))
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	if r, ok := renderers[name]; ok {
+		return r, nil
+	}
+	for _, r := range renderers {
+		if r.Extension() == name {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown format %q (available: %s)", name, strings.Join(Formats(), ", "))
+}
+
+// Formats returns the registered format names in sorted order.
+func Formats() []string {
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Negotiate picks the renderer that best matches an HTTP Accept header.
+// It returns fallback when the header is empty, only contains wildcards,
+// or names no supported type.
+func Negotiate(accept string, fallback Renderer) Renderer {
+	type candidate struct {
+		mediaType string
+		q         float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{mediaType, q})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if c.mediaType == "*/*" {
+			return fallback
+		}
+		for _, name := range Formats() {
+			r := renderers[name]
+			if mediaTypeOf(r) == c.mediaType {
+				return r
+			}
+		}
+	}
+	return fallback
+}
+
+func mediaTypeOf(r Renderer) string {
+	mediaType, _, err := mime.ParseMediaType(r.ContentType())
+	if err != nil {
+		return r.ContentType()
+	}
+	return mediaType
+}
+
+// dayTitle formats a day's date for headings, e.g. "Wednesday, September 4, 2024".
+func dayTitle(d menu.Day) string {
+	return d.Time().Format("Monday, January 2, 2006")
+}
+
+// MealGroup is a run of categories served as part of the same meal.
+type MealGroup struct {
+	Name       string
+	Categories []menu.Category
+}
+
+// Meals groups a session's categories by meal, preserving order.
+func Meals(s menu.Session) []MealGroup {
+	var groups []MealGroup
+	for _, c := range s.Categories {
+		if n := len(groups); n > 0 && groups[n-1].Name == c.Meal {
+			groups[n-1].Categories = append(groups[n-1].Categories, c)
+			continue
+		}
+		groups = append(groups, MealGroup{Name: c.Meal, Categories: []menu.Category{c}})
+	}
+	return groups
+}
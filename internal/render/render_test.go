@@ -0,0 +1,56 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   Renderer
+	}{
+		{"empty", "", JSON{}},
+		{"wildcard", "*/*", JSON{}},
+		{"exact", "text/csv", CSV{}},
+		{"parameters ignored", "text/html; charset=utf-8", HTML{}},
+		{"first supported", "application/xml, text/markdown", Markdown{}},
+		{"highest q wins", "text/plain;q=0.5, application/pdf;q=0.9", PDF{}},
+		{"q zero refused", "text/csv;q=0, text/plain", Text{}},
+		{"wildcard preferred", "*/*;q=1, image/png;q=0.8", JSON{}},
+		{"unsupported only", "application/xml", JSON{}},
+		{"malformed skipped", "not a type, image/png", PNG{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Negotiate(tt.accept, JSON{}); got != tt.want {
+				t.Errorf("Negotiate(%q) = %T, want %T", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVAllergenNames(t *testing.T) {
+	doc := Document{
+		Days: []menu.Day{{Date: "2024-09-04", Sessions: []menu.Session{{
+			Name: "Lunch",
+			Categories: []menu.Category{{Name: "Entree", Meal: "Main", Recipes: []menu.Item{
+				{ItemID: "r1", Name: "Cheese Pizza", Allergens: []string{"a-milk", "a-unknown"}},
+			}}},
+		}}}},
+		AllergenNames: map[string]string{"a-milk": "Milk"},
+	}
+	var buf bytes.Buffer
+	if err := (CSV{}).Render(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := "2024-09-04,Lunch,Main,Entree,Cheese Pizza,r1,,Milk;a-unknown"
+	if len(lines) != 2 || lines[1] != want {
+		t.Errorf("CSV rows = %q, want header and %q", lines, want)
+	}
+}
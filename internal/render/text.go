@@ -0,0 +1,33 @@
+package render
+
+import (
+	"io"
+	"strings"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// Text renders the indented plain-text menu used in email bodies and
+// calendar event descriptions.
+type Text struct{}
+
+func (Text) ContentType() string { return "text/plain; charset=utf-8" }
+func (Text) Extension() string   { return "txt" }
+
+func (Text) Render(w io.Writer, doc Document) error {
+	var b strings.Builder
+	for _, day := range doc.Days {
+		for _, session := range day.Sessions {
+			b.WriteString(SessionText(day, session.Name))
+			b.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// SessionText returns the plain-text menu for one session of a day, or an
+// empty string if the session was not served that day.
+func SessionText(day menu.Day, session string) string {
+	return day.Text(session)
+}
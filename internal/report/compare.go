@@ -0,0 +1,88 @@
+package report
+
+import (
+	"sort"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// topRecipes is how many of its most served recipes a Building lists.
+const topRecipes = 5
+
+// School is one building's archived days, to compare with others.
+type School struct {
+	BuildingID string
+	Name       string
+	Days       []menu.Day
+}
+
+// Building compares one school's menus with the other buildings in its
+// district.
+type Building struct {
+	BuildingID string `json:"buildingId"`
+	School     string `json:"school,omitempty"`
+	Days       int    `json:"days"`
+	// Recipes is the number of different recipes served; Unique counts
+	// those no other compared building served.
+	Recipes int `json:"recipes"`
+	Unique  int `json:"unique"`
+	// Shared is the share of its recipes that every compared building
+	// served. A building compared with no others has no unique recipes
+	// and shares all of them.
+	Shared     float64           `json:"shared"`
+	Calories   []SessionCalories `json:"calories"`
+	TopRecipes []string          `json:"topRecipes"`
+}
+
+// Compare summarizes each school's menus side by side, in the order
+// given.
+func Compare(schools []School) []Building {
+	servedAt := make(map[string]int)
+	names := make([][]*served, len(schools))
+	for i, s := range schools {
+		names[i] = collect(s.Days)
+		for _, r := range names[i] {
+			servedAt[r.name]++
+		}
+	}
+
+	list := make([]Building, 0, len(schools))
+	for i, s := range schools {
+		b := Building{
+			BuildingID: s.BuildingID,
+			School:     s.Name,
+			Days:       len(s.Days),
+			Recipes:    len(names[i]),
+			Calories:   calories(s.Days),
+			TopRecipes: []string{},
+		}
+		shared := 0
+		for _, r := range names[i] {
+			switch {
+			case len(schools) < 2:
+				// A building on its own has nothing to differ from.
+				shared++
+			case servedAt[r.name] == 1:
+				b.Unique++
+			case servedAt[r.name] == len(schools):
+				shared++
+			}
+		}
+		if b.Recipes > 0 {
+			b.Shared = round(float64(shared)/float64(b.Recipes), 3)
+		}
+
+		top := append([]*served(nil), names[i]...)
+		sort.SliceStable(top, func(a, c int) bool {
+			if len(top[a].dates) != len(top[c].dates) {
+				return len(top[a].dates) > len(top[c].dates)
+			}
+			return top[a].name < top[c].name
+		})
+		for j := 0; j < len(top) && j < topRecipes; j++ {
+			b.TopRecipes = append(b.TopRecipes, top[j].name)
+		}
+		list = append(list, b)
+	}
+	return list
+}
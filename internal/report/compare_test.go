@@ -0,0 +1,76 @@
+package report
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	lincoln := School{BuildingID: "b1", Name: "Lincoln", Days: entrees(map[string][]string{
+		"Cheese Pizza":     {"2024-09-06", "2024-09-13"},
+		"Tacos":            {"2024-09-03"},
+		"Fish Sticks":      {"2024-09-04"},
+		"Chicken  Pot Pie": {"2024-09-05"},
+	})}
+	emma := School{BuildingID: "b2", Days: entrees(map[string][]string{
+		"Cheese Pizza":    {"2024-09-06"},
+		"Tacos":           {"2024-09-03"},
+		"Chicken Pot Pie": {"2024-09-05"},
+	})}
+	oak := School{BuildingID: "b3", Name: "Oak", Days: entrees(map[string][]string{
+		"Cheese Pizza": {"2024-09-06"},
+		"Tacos":        {"2024-09-03"},
+		"Burger":       {"2024-09-04"},
+	})}
+
+	tests := []struct {
+		name    string
+		schools []School
+		want    []string
+	}{
+		{"none", nil, nil},
+		{"one building", []School{lincoln}, []string{"b1 Lincoln: 4 recipes, 0 unique, 1 shared"}},
+		{"two buildings", []School{lincoln, emma}, []string{
+			"b1 Lincoln: 4 recipes, 1 unique, 0.75 shared",
+			"b2 : 3 recipes, 0 unique, 1 shared",
+		}},
+		{"three buildings", []School{oak, lincoln, emma}, []string{
+			"b3 Oak: 3 recipes, 1 unique, 0.667 shared",
+			"b1 Lincoln: 4 recipes, 1 unique, 0.5 shared",
+			"b2 : 3 recipes, 0 unique, 0.667 shared",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := Compare(tt.schools)
+			if list == nil {
+				t.Fatal("Compare returned nil, want a list")
+			}
+			var got []string
+			for _, b := range list {
+				got = append(got, fmt.Sprintf("%s %s: %d recipes, %d unique, %g shared", b.BuildingID, b.School, b.Recipes, b.Unique, b.Shared))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Compare =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareTopRecipes(t *testing.T) {
+	school := School{BuildingID: "b1", Days: entrees(map[string][]string{
+		"Cheese Pizza": {"2024-09-06", "2024-09-13", "2024-09-20"},
+		"Tacos":        {"2024-09-03", "2024-09-17"},
+		"Burger":       {"2024-09-04", "2024-09-18"},
+		"Soup":         {"2024-09-05"},
+		"Apple":        {"2024-09-05"},
+		"Salad":        {"2024-09-05"},
+	})}
+	b := Compare([]School{school})[0]
+	if want := []string{"Cheese Pizza", "Burger", "Tacos", "Apple", "Salad"}; fmt.Sprint(b.TopRecipes) != fmt.Sprint(want) {
+		t.Errorf("top recipes = %v, want %v", b.TopRecipes, want)
+	}
+	if b.Days != 8 {
+		t.Errorf("days = %d, want 8", b.Days)
+	}
+}
@@ -0,0 +1,186 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteMarkdown writes the report's sections as Markdown tables.
+func WriteMarkdown(w io.Writer, r Report) error {
+	title := "Menu report"
+	if r.School != "" {
+		title += ": " + r.School
+	}
+	fmt.Fprintf(w, "# %s\n\n", title)
+	if r.Days == 0 {
+		_, err := fmt.Fprintln(w, "No archived menus.")
+		return err
+	}
+	fmt.Fprintf(w, "%s to %s, %d days with a menu.\n", r.Start, r.End, r.Days)
+
+	for _, section := range Sections {
+		header, rows := table(r, section)
+		if rows == nil {
+			continue
+		}
+		fmt.Fprintf(w, "\n## %s\n\n", sectionTitles[section])
+		if len(rows) == 0 {
+			fmt.Fprintln(w, "None found.")
+			continue
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(header, " | "))
+		fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(header)))
+		for _, row := range rows {
+			for i, cell := range row {
+				row[i] = strings.ReplaceAll(cell, "|", `\|`)
+			}
+			fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes one section of the report as CSV.
+func WriteCSV(w io.Writer, r Report, section string) error {
+	header, ok := csvHeaders[section]
+	if !ok {
+		return fmt.Errorf("unknown report section %q", section)
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range csvRows(r, section) {
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var sectionTitles = map[string]string{
+	SectionRecipes:   "Most served recipes",
+	SectionCycles:    "Repeat cycles",
+	SectionCalories:  "Average daily calories",
+	SectionAllergens: "Allergens by week",
+	SectionBuildings: "Buildings",
+}
+
+// table returns a section as display rows, or nil rows if the report
+// does not include it.
+func table(r Report, section string) (header []string, rows [][]string) {
+	switch section {
+	case SectionRecipes:
+		if r.Recipes == nil {
+			return nil, nil
+		}
+		rows = [][]string{}
+		for _, c := range r.Recipes {
+			rows = append(rows, []string{c.Name, c.Category, strings.Join(c.Sessions, ", "), strconv.Itoa(c.Days), percent(c.Share), c.First, c.Last})
+		}
+		return []string{"Recipe", "Category", "Sessions", "Days", "Share", "First", "Last"}, rows
+	case SectionCycles:
+		if r.Cycles == nil {
+			return nil, nil
+		}
+		rows = [][]string{}
+		for _, c := range r.Cycles {
+			rows = append(rows, []string{c.Recipe, c.Description, strconv.Itoa(c.Days), percent(c.Regularity)})
+		}
+		return []string{"Recipe", "Pattern", "Days", "Regularity"}, rows
+	case SectionCalories:
+		if r.Calories == nil {
+			return nil, nil
+		}
+		rows = [][]string{}
+		for _, c := range r.Calories {
+			rows = append(rows, []string{c.Session, strconv.Itoa(c.Days), number(c.Average), number(c.Min), number(c.Max), number(c.RecipeAverage)})
+		}
+		return []string{"Session", "Days", "Average", "Min", "Max", "Per recipe"}, rows
+	case SectionAllergens:
+		if r.Allergens == nil {
+			return nil, nil
+		}
+		rows = [][]string{}
+		for _, a := range r.Allergens {
+			rows = append(rows, []string{a.Week, a.Allergen, fmt.Sprintf("%d of %d", a.Recipes, a.Total), percent(a.Share)})
+		}
+		return []string{"Week of", "Allergen", "Recipes", "Share"}, rows
+	case SectionBuildings:
+		if r.Buildings == nil {
+			return nil, nil
+		}
+		rows = [][]string{}
+		for _, b := range r.Buildings {
+			var cal []string
+			for _, c := range b.Calories {
+				cal = append(cal, fmt.Sprintf("%s %s", c.Session, number(c.Average)))
+			}
+			name := b.School
+			if name == "" {
+				name = b.BuildingID
+			}
+			rows = append(rows, []string{name, strconv.Itoa(b.Days), strconv.Itoa(b.Recipes), strconv.Itoa(b.Unique), percent(b.Shared), strings.Join(cal, ", "), strings.Join(b.TopRecipes, ", ")})
+		}
+		return []string{"School", "Days", "Recipes", "Unique", "Shared", "Calories", "Most served"}, rows
+	}
+	return nil, nil
+}
+
+var csvHeaders = map[string][]string{
+	SectionRecipes:   {"recipe", "category", "sessions", "days", "share", "first", "last"},
+	SectionCycles:    {"recipe", "weekday", "interval_days", "days", "regularity", "pattern"},
+	SectionCalories:  {"session", "days", "average", "min", "max", "recipe_average"},
+	SectionAllergens: {"week", "allergen", "allergen_id", "recipes", "total", "share"},
+	SectionBuildings: {"building_id", "school", "days", "recipes", "unique", "shared", "session", "average_calories", "top_recipes"},
+}
+
+// csvRows returns a section as machine-readable rows, with plain numbers
+// rather than the display rows' percentages.
+func csvRows(r Report, section string) [][]string {
+	var rows [][]string
+	f := func(x float64) string { return strconv.FormatFloat(x, 'f', -1, 64) }
+	switch section {
+	case SectionRecipes:
+		for _, c := range r.Recipes {
+			rows = append(rows, []string{c.Name, c.Category, strings.Join(c.Sessions, ";"), strconv.Itoa(c.Days), f(c.Share), c.First, c.Last})
+		}
+	case SectionCycles:
+		for _, c := range r.Cycles {
+			rows = append(rows, []string{c.Recipe, c.Weekday, strconv.Itoa(c.IntervalDays), strconv.Itoa(c.Days), f(c.Regularity), c.Description})
+		}
+	case SectionCalories:
+		for _, c := range r.Calories {
+			rows = append(rows, []string{c.Session, strconv.Itoa(c.Days), f(c.Average), f(c.Min), f(c.Max), f(c.RecipeAverage)})
+		}
+	case SectionAllergens:
+		for _, a := range r.Allergens {
+			rows = append(rows, []string{a.Week, a.Allergen, a.AllergenID, strconv.Itoa(a.Recipes), strconv.Itoa(a.Total), f(a.Share)})
+		}
+	case SectionBuildings:
+		// One row per building and session, so calories stay a number.
+		for _, b := range r.Buildings {
+			base := []string{b.BuildingID, b.School, strconv.Itoa(b.Days), strconv.Itoa(b.Recipes), strconv.Itoa(b.Unique), f(b.Shared)}
+			top := strings.Join(b.TopRecipes, ";")
+			if len(b.Calories) == 0 {
+				rows = append(rows, append(append([]string{}, base...), "", "", top))
+			}
+			for _, c := range b.Calories {
+				rows = append(rows, append(append([]string{}, base...), c.Session, f(c.Average), top))
+			}
+		}
+	}
+	return rows
+}
+
+func percent(x float64) string {
+	return strconv.FormatFloat(x*100, 'f', 0, 64) + "%"
+}
+
+func number(x float64) string {
+	return strconv.FormatFloat(x, 'f', 0, 64)
+}
@@ -0,0 +1,40 @@
+package report
+
+import (
+	"github.com/asachs01/school_menu_connector/internal/archive"
+)
+
+// Load reports on the archived menus q selects. With SectionBuildings, the
+// report also compares every archived building in q's district over the
+// same dates and sessions.
+func Load(store *archive.Store, q archive.Query, opts Options) (Report, error) {
+	days, err := store.Days(q)
+	if err != nil {
+		return Report{}, err
+	}
+	r := Build(days, opts)
+	r.BuildingID, r.DistrictID = q.BuildingID, q.DistrictID
+	if !opts.Wants(SectionBuildings) {
+		return r, nil
+	}
+
+	archived, err := store.Schools()
+	if err != nil {
+		return r, err
+	}
+	var schools []School
+	for _, s := range archived {
+		if s.DistrictID != q.DistrictID {
+			continue
+		}
+		bq := q
+		bq.BuildingID = s.BuildingID
+		days, err := store.Days(bq)
+		if err != nil {
+			return r, err
+		}
+		schools = append(schools, School{BuildingID: s.BuildingID, Name: s.Name, Days: days})
+	}
+	r.Buildings = Compare(schools)
+	return r, nil
+}
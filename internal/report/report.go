@@ -0,0 +1,445 @@
+// Package report summarizes archived menus: how often each recipe is
+// served, which recipes follow a cycle such as pizza every Friday, how
+// many calories a day's meal holds, how common each allergen is week by
+// week, and how the buildings of a district compare.
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// Report sections.
+const (
+	SectionRecipes   = "recipes"
+	SectionCycles    = "cycles"
+	SectionCalories  = "calories"
+	SectionAllergens = "allergens"
+	SectionBuildings = "buildings"
+)
+
+// Sections lists every section in report order.
+var Sections = []string{SectionRecipes, SectionCycles, SectionCalories, SectionAllergens, SectionBuildings}
+
+// DefaultMinRepeats is how many times a recipe must be served before it
+// can count as a cycle.
+const DefaultMinRepeats = 3
+
+// cycleShare is the least share of a recipe's servings that must fit a
+// pattern for it to count as a cycle.
+const cycleShare = 0.75
+
+// Report holds analytics for one school's menus.
+type Report struct {
+	School     string `json:"school,omitempty"`
+	BuildingID string `json:"buildingId,omitempty"`
+	DistrictID string `json:"districtId,omitempty"`
+	// Start and End are the first and last dates with a menu, YYYY-MM-DD.
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Days is the number of days with a menu.
+	Days      int               `json:"days"`
+	Recipes   []RecipeCount     `json:"recipes,omitempty"`
+	Cycles    []Cycle           `json:"cycles,omitempty"`
+	Calories  []SessionCalories `json:"calories,omitempty"`
+	Allergens []AllergenWeek    `json:"allergens,omitempty"`
+	Buildings []Building        `json:"buildings,omitempty"`
+}
+
+// RecipeCount is how often a recipe was served.
+type RecipeCount struct {
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Sessions []string `json:"sessions"`
+	// Days is the number of days it was served; Share is Days as a
+	// fraction of the days with a menu.
+	Days  int     `json:"days"`
+	Share float64 `json:"share"`
+	First string  `json:"first"`
+	Last  string  `json:"last"`
+}
+
+// Cycle is a recipe served on a regular pattern.
+type Cycle struct {
+	Recipe string `json:"recipe"`
+	// Weekday is set when the recipe keeps to one day of the week.
+	Weekday string `json:"weekday,omitempty"`
+	// IntervalDays is the usual number of days between servings.
+	IntervalDays int `json:"intervalDays"`
+	Days         int `json:"days"`
+	// Regularity is the share of servings that fit the pattern.
+	Regularity float64 `json:"regularity"`
+	// Description reads like "every Friday" or "every other Monday".
+	Description string `json:"description"`
+}
+
+// SessionCalories summarizes the calories on a session's menus. A day's
+// calories are those of a plate with one recipe from each category, taking
+// each category's average, as a student picks one entree, one fruit and so
+// on. Categories of the same name in different meals, such as a Main and
+// a Combos entree, count as one.
+type SessionCalories struct {
+	Session string  `json:"session"`
+	Days    int     `json:"days"`
+	Average float64 `json:"average"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	// RecipeAverage is the mean calories of a single recipe.
+	RecipeAverage float64 `json:"recipeAverage"`
+}
+
+// AllergenWeek is how common an allergen was in a week's recipes.
+type AllergenWeek struct {
+	// Week is the Monday starting the week, YYYY-MM-DD.
+	Week       string `json:"week"`
+	Allergen   string `json:"allergen"`
+	AllergenID string `json:"allergenId"`
+	// Recipes is how many of the week's Total recipe servings contain the
+	// allergen; Share is the fraction.
+	Recipes int     `json:"recipes"`
+	Total   int     `json:"total"`
+	Share   float64 `json:"share"`
+}
+
+// Options configure a report.
+type Options struct {
+	// Sections to include; none means all but SectionBuildings, which
+	// compares other schools and so is only included on request.
+	Sections []string
+	// AllergenNames maps allergen IDs to names. When set, only those
+	// allergens are reported.
+	AllergenNames map[string]string
+	// MinRepeats is the fewest servings for a cycle (default
+	// DefaultMinRepeats).
+	MinRepeats int
+	// Top limits the recipes listed, most served first; 0 lists all.
+	Top int
+}
+
+// Validate checks the section names.
+func (o Options) Validate() error {
+	for _, s := range o.Sections {
+		if !contains(Sections, s) {
+			return fmt.Errorf("unknown report section %q (want %s)", s, strings.Join(Sections, ", "))
+		}
+	}
+	return nil
+}
+
+// Wants reports whether the options include section.
+func (o Options) Wants(section string) bool {
+	if len(o.Sections) == 0 {
+		return section != SectionBuildings
+	}
+	return contains(o.Sections, section)
+}
+
+// Build reports on a school's days, as returned by the archive.
+func Build(days []menu.Day, opts Options) Report {
+	var r Report
+	r.Days = len(days)
+	if len(days) > 0 {
+		r.Start, r.End = days[0].Date, days[len(days)-1].Date
+	}
+	servings := collect(days)
+	if opts.Wants(SectionRecipes) {
+		r.Recipes = recipeCounts(servings, len(days), opts.Top)
+	}
+	if opts.Wants(SectionCycles) {
+		r.Cycles = cycles(servings, opts.MinRepeats)
+	}
+	if opts.Wants(SectionCalories) {
+		r.Calories = calories(days)
+	}
+	if opts.Wants(SectionAllergens) {
+		r.Allergens = allergenWeeks(days, opts.AllergenNames)
+	}
+	return r
+}
+
+// served is every day a recipe was on the menu.
+type served struct {
+	name     string
+	category string
+	sessions []string
+	dates    []time.Time
+}
+
+// collect groups the days' recipes by name, each counted once a day.
+func collect(days []menu.Day) []*served {
+	byName := make(map[string]*served)
+	var list []*served
+	for _, d := range days {
+		t := d.Time()
+		for _, s := range d.Sessions {
+			for _, c := range s.Categories {
+				for _, item := range c.Recipes {
+					name := recipeName(item.Name)
+					r, ok := byName[name]
+					if !ok {
+						r = &served{name: name, category: c.Name}
+						byName[name] = r
+						list = append(list, r)
+					}
+					if !contains(r.sessions, s.Name) {
+						r.sessions = append(r.sessions, s.Name)
+					}
+					if n := len(r.dates); n == 0 || !r.dates[n-1].Equal(t) {
+						r.dates = append(r.dates, t)
+					}
+				}
+			}
+		}
+	}
+	return list
+}
+
+// recipeName tidies the spacing in a recipe name, which LINQ Connect
+// sometimes doubles, so both spellings count as one recipe.
+func recipeName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+func recipeCounts(servings []*served, days, top int) []RecipeCount {
+	counts := make([]RecipeCount, 0, len(servings))
+	for _, r := range servings {
+		counts = append(counts, RecipeCount{
+			Name:     r.name,
+			Category: r.category,
+			Sessions: r.sessions,
+			Days:     len(r.dates),
+			Share:    round(float64(len(r.dates))/float64(days), 3),
+			First:    r.dates[0].Format(menu.DateLayout),
+			Last:     r.dates[len(r.dates)-1].Format(menu.DateLayout),
+		})
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		if counts[i].Days != counts[j].Days {
+			return counts[i].Days > counts[j].Days
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	if top > 0 && len(counts) > top {
+		counts = counts[:top]
+	}
+	return counts
+}
+
+// cycles finds recipes that keep to a day of the week, or come back after
+// a steady number of days. Staples served nearly every day are left out.
+func cycles(servings []*served, minRepeats int) []Cycle {
+	if minRepeats <= 0 {
+		minRepeats = DefaultMinRepeats
+	}
+	list := []Cycle{}
+	for _, r := range servings {
+		n := len(r.dates)
+		if n < minRepeats {
+			continue
+		}
+		gaps := make([]int, 0, n-1)
+		for i := 1; i < n; i++ {
+			gaps = append(gaps, int(r.dates[i].Sub(r.dates[i-1]).Hours()/24))
+		}
+		interval := median(gaps)
+		if interval < 2 {
+			continue
+		}
+
+		var byWeekday [7]int
+		for _, t := range r.dates {
+			byWeekday[t.Weekday()]++
+		}
+		weekday := time.Sunday
+		for w := time.Sunday; w <= time.Saturday; w++ {
+			if byWeekday[w] > byWeekday[weekday] {
+				weekday = w
+			}
+		}
+
+		c := Cycle{Recipe: r.name, IntervalDays: interval, Days: n}
+		if share := float64(byWeekday[weekday]) / float64(n); share >= cycleShare {
+			c.Weekday = weekday.String()
+			c.Regularity = round(share, 3)
+			switch weeks := int(math.Round(float64(interval) / 7)); {
+			case weeks <= 1:
+				c.Description = "every " + c.Weekday
+			case weeks == 2:
+				c.Description = "every other " + c.Weekday
+			default:
+				c.Description = fmt.Sprintf("every %d weeks on %s", weeks, c.Weekday)
+			}
+		} else {
+			fit := 0
+			for _, g := range gaps {
+				if g >= interval-1 && g <= interval+1 {
+					fit++
+				}
+			}
+			share := float64(fit) / float64(len(gaps))
+			if share < cycleShare {
+				continue
+			}
+			c.Regularity = round(share, 3)
+			if interval%7 == 0 {
+				c.Description = fmt.Sprintf("every %d weeks", interval/7)
+			} else {
+				c.Description = fmt.Sprintf("every %d days", interval)
+			}
+		}
+		list = append(list, c)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Days != list[j].Days {
+			return list[i].Days > list[j].Days
+		}
+		return list[i].Recipe < list[j].Recipe
+	})
+	return list
+}
+
+func calories(days []menu.Day) []SessionCalories {
+	list := []SessionCalories{}
+	index := make(map[string]int)
+	recipeTotal := make(map[string]float64)
+	recipeCount := make(map[string]int)
+	for _, d := range days {
+		for _, s := range d.Sessions {
+			// Average each category's recipes, merging categories of the
+			// same name across meals.
+			sums := make(map[string]float64)
+			counts := make(map[string]int)
+			for _, c := range s.Categories {
+				for _, item := range c.Recipes {
+					if cal := item.Calories(); cal > 0 {
+						sums[c.Name] += cal
+						counts[c.Name]++
+						recipeTotal[s.Name] += cal
+						recipeCount[s.Name]++
+					}
+				}
+			}
+			if len(counts) == 0 {
+				continue
+			}
+			plate := 0.0
+			for name, n := range counts {
+				plate += sums[name] / float64(n)
+			}
+
+			i, ok := index[s.Name]
+			if !ok {
+				i = len(list)
+				index[s.Name] = i
+				list = append(list, SessionCalories{Session: s.Name, Min: plate, Max: plate})
+			}
+			sc := &list[i]
+			sc.Days++
+			sc.Average += plate
+			sc.Min = math.Min(sc.Min, plate)
+			sc.Max = math.Max(sc.Max, plate)
+		}
+	}
+	for i := range list {
+		sc := &list[i]
+		sc.Average = round(sc.Average/float64(sc.Days), 1)
+		sc.Min, sc.Max = round(sc.Min, 1), round(sc.Max, 1)
+		sc.RecipeAverage = round(recipeTotal[sc.Session]/float64(recipeCount[sc.Session]), 1)
+	}
+	return list
+}
+
+// allergenWeeks counts, for each week, the recipe servings that contain
+// each allergen. A recipe is counted once per day and session.
+func allergenWeeks(days []menu.Day, names map[string]string) []AllergenWeek {
+	type weekCounts struct {
+		total int
+		byID  map[string]int
+	}
+	weeks := make(map[string]*weekCounts)
+	var order []string
+	for _, d := range days {
+		week := dates.Monday(d.Time()).Format(menu.DateLayout)
+		w, ok := weeks[week]
+		if !ok {
+			w = &weekCounts{byID: make(map[string]int)}
+			weeks[week] = w
+			order = append(order, week)
+		}
+		for _, s := range d.Sessions {
+			seen := make(map[string]bool)
+			for _, c := range s.Categories {
+				for _, item := range c.Recipes {
+					name := recipeName(item.Name)
+					if seen[name] {
+						continue
+					}
+					seen[name] = true
+					w.total++
+					for _, id := range item.Allergens {
+						if names == nil || names[id] != "" {
+							w.byID[id]++
+						}
+					}
+				}
+			}
+		}
+	}
+
+	list := []AllergenWeek{}
+	for _, week := range order {
+		w := weeks[week]
+		start := len(list)
+		for id, n := range w.byID {
+			name := names[id]
+			if name == "" {
+				name = id
+			}
+			list = append(list, AllergenWeek{
+				Week:       week,
+				Allergen:   name,
+				AllergenID: id,
+				Recipes:    n,
+				Total:      w.total,
+				Share:      round(float64(n)/float64(w.total), 3),
+			})
+		}
+		rows := list[start:]
+		sort.Slice(rows, func(i, j int) bool {
+			if rows[i].Recipes != rows[j].Recipes {
+				return rows[i].Recipes > rows[j].Recipes
+			}
+			return rows[i].Allergen < rows[j].Allergen
+		})
+	}
+	return list
+}
+
+func median(values []int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	return sorted[len(sorted)/2]
+}
+
+func round(x float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(x*p) / p
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
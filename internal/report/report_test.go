@@ -0,0 +1,132 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// entrees returns Lunch days serving each recipe as an entree on its
+// dates, sorted by date as the archive returns them.
+func entrees(servings map[string][]string) []menu.Day {
+	byDate := make(map[string]*menu.Day)
+	for recipe, dates := range servings {
+		for _, date := range dates {
+			d, ok := byDate[date]
+			if !ok {
+				d = &menu.Day{Date: date, Sessions: []menu.Session{{Name: "Lunch", Categories: []menu.Category{{Name: "Entree", Meal: "Main"}}}}}
+				byDate[date] = d
+			}
+			c := &d.Sessions[0].Categories[0]
+			c.Recipes = append(c.Recipes, menu.Item{Name: recipe})
+		}
+	}
+	days := make([]menu.Day, 0, len(byDate))
+	for _, d := range byDate {
+		days = append(days, *d)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days
+}
+
+func TestCycles(t *testing.T) {
+	tests := []struct {
+		name       string
+		dates      []string
+		minRepeats int
+		want       string
+	}{
+		{"every Friday", []string{"2024-09-06", "2024-09-13", "2024-09-20", "2024-09-27"}, 0, "every Friday (Friday, 7 days, 4 servings, 1)"},
+		{"every other Tuesday", []string{"2024-09-03", "2024-09-17", "2024-10-01"}, 0, "every other Tuesday (Tuesday, 14 days, 3 servings, 1)"},
+		{"every few weeks", []string{"2024-09-02", "2024-09-23", "2024-10-14"}, 0, "every 3 weeks on Monday (Monday, 21 days, 3 servings, 1)"},
+		{"mostly Fridays", []string{"2024-09-06", "2024-09-13", "2024-09-19", "2024-09-27"}, 0, "every Friday (Friday, 7 days, 4 servings, 0.75)"},
+		{"steady days apart", []string{"2024-09-02", "2024-09-10", "2024-09-18", "2024-09-26"}, 0, "every 8 days (, 8 days, 4 servings, 1)"},
+		{"irregular", []string{"2024-09-02", "2024-09-04", "2024-09-13", "2024-10-03"}, 0, ""},
+		{"daily staple", []string{"2024-09-02", "2024-09-03", "2024-09-04", "2024-09-05"}, 0, ""},
+		{"too few servings", []string{"2024-09-06", "2024-09-13"}, 0, ""},
+		{"fewer repeats allowed", []string{"2024-09-06", "2024-09-13"}, 2, "every Friday (Friday, 7 days, 2 servings, 1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Build(entrees(map[string][]string{"Cheese Pizza": tt.dates}), Options{Sections: []string{SectionCycles}, MinRepeats: tt.minRepeats})
+			var got string
+			for _, c := range r.Cycles {
+				if c.Recipe != "Cheese Pizza" {
+					t.Errorf("cycle for %q", c.Recipe)
+				}
+				got = fmt.Sprintf("%s (%s, %d days, %d servings, %g)", c.Description, c.Weekday, c.IntervalDays, c.Days, c.Regularity)
+			}
+			if got != tt.want {
+				t.Errorf("cycle = %q, want %q", got, tt.want)
+			}
+			if r.Recipes != nil || r.Calories != nil {
+				t.Error("sections not asked for were included")
+			}
+		})
+	}
+}
+
+func TestCyclesOrder(t *testing.T) {
+	days := entrees(map[string][]string{
+		"Tacos":        {"2024-09-03", "2024-09-17", "2024-10-01"},
+		"Cheese Pizza": {"2024-09-06", "2024-09-13", "2024-09-20", "2024-09-27"},
+		"Burger":       {"2024-09-04", "2024-09-18", "2024-10-02"},
+	})
+	var got []string
+	for _, c := range Build(days, Options{}).Cycles {
+		got = append(got, c.Recipe)
+	}
+	if want := []string{"Cheese Pizza", "Burger", "Tacos"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("cycles = %v, want the most served first, then by name: %v", got, want)
+	}
+}
+
+func withCalories(name string, cal float64) menu.Item {
+	item := menu.Item{Name: name}
+	if cal > 0 {
+		item.Nutrients = []menu.ItemNutrient{{Name: "Calories", Value: cal, Unit: "kcals"}}
+	}
+	return item
+}
+
+func TestCalories(t *testing.T) {
+	days := []menu.Day{
+		{Date: "2024-09-04", Sessions: []menu.Session{
+			{Name: "Lunch", Categories: []menu.Category{
+				{Name: "Entree", Meal: "Main", Recipes: []menu.Item{withCalories("Pizza", 300), withCalories("Salad", 200)}},
+				{Name: "Fruit", Meal: "Main", Recipes: []menu.Item{withCalories("Apple", 60), withCalories("Water", 0)}},
+				{Name: "Milk", Meal: "Main", Recipes: []menu.Item{withCalories("1% Milk", 110)}},
+				// A Combos entree is one more entree choice, not another plate.
+				{Name: "Entree", Meal: "Combos", Recipes: []menu.Item{withCalories("Burger", 400)}},
+			}},
+			{Name: "Breakfast", Categories: []menu.Category{
+				{Name: "Entree", Meal: "Main", Recipes: []menu.Item{withCalories("Waffles", 0)}},
+			}},
+		}},
+		{Date: "2024-09-05", Sessions: []menu.Session{
+			{Name: "Breakfast", Categories: []menu.Category{
+				{Name: "Entree", Meal: "Main", Recipes: []menu.Item{withCalories("Cereal", 150)}},
+			}},
+			{Name: "Lunch", Categories: []menu.Category{
+				{Name: "Entree", Meal: "Main", Recipes: []menu.Item{withCalories("Tacos", 350)}},
+				{Name: "Milk", Meal: "Main", Recipes: []menu.Item{withCalories("1% Milk", 110)}},
+			}},
+		}},
+	}
+	r := Build(days, Options{Sections: []string{SectionCalories}})
+	want := []SessionCalories{
+		// Plates of 300+60+110 and 350+110; recipes average 1530/7.
+		{Session: "Lunch", Days: 2, Average: 465, Min: 460, Max: 470, RecipeAverage: 218.6},
+		// Waffles without calories do not make a 0-calorie breakfast.
+		{Session: "Breakfast", Days: 1, Average: 150, Min: 150, Max: 150, RecipeAverage: 150},
+	}
+	if fmt.Sprint(r.Calories) != fmt.Sprint(want) {
+		t.Errorf("calories = %+v, want %+v", r.Calories, want)
+	}
+
+	if r := Build(entrees(map[string][]string{"Pizza": {"2024-09-04"}}), Options{Sections: []string{SectionCalories}}); r.Calories == nil || len(r.Calories) != 0 {
+		t.Errorf("calories without nutrients = %#v, want an empty list", r.Calories)
+	}
+}
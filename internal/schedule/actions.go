@@ -0,0 +1,395 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/changes"
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/email"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/notify"
+	"github.com/asachs01/school_menu_connector/internal/planner"
+	"github.com/asachs01/school_menu_connector/internal/render"
+)
+
+// Built-in actions.
+const (
+	// ActionEmail emails the menu for the job's range to its recipients.
+	ActionEmail = "email"
+	// ActionNotify posts the menu for the job's range to its chat targets.
+	ActionNotify = "notify"
+	// ActionWarmCache fetches the menu for the job's range into the cache,
+	// one day at a time as the web server reads it.
+	ActionWarmCache = "warm-cache"
+	// ActionWatch fetches the upcoming days in the job's range, compares
+	// them with the menus last seen, and emails the recipients and posts to
+	// the notify targets when one changed.
+	ActionWatch = "watch"
+	// ActionPlan emails the recipients and posts to the notify targets
+	// which days in the job's range are favorite days and which need a
+	// packed lunch, by the job's favorites and dislikes.
+	ActionPlan = "plan"
+)
+
+// fetchDays returns the menu for each school day in the period, reading
+// and filling the per-day cache when one is configured.
+func fetchDays(opts Options, school School, period Period, mealTypes []string) ([]menu.Day, error) {
+	var days []menu.Day
+	for _, date := range period.Days {
+		dateStr := date.Format("01-02-2006")
+		var m *menu.Menu
+		if opts.Cache != nil {
+			m, _ = opts.Cache.Get(school.BuildingID, school.DistrictID, dateStr, dateStr)
+		}
+		if m == nil {
+			var err error
+			m, err = menu.Fetch(school.BuildingID, school.DistrictID, dateStr, dateStr, false)
+			if err != nil {
+				return nil, fmt.Errorf("fetching menu for %s: %w", date.Format(menu.DateLayout), err)
+			}
+			opts.keep(school, dateStr, m)
+		}
+		if day, ok := m.DayFor(date, mealTypes...); ok {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+// keep stores a menu just fetched for one day in the cache and the
+// archive, whichever are configured.
+func (o Options) keep(school School, dateStr string, m *menu.Menu) {
+	if o.Cache != nil {
+		o.Cache.Set(school.BuildingID, school.DistrictID, dateStr, dateStr, m)
+	}
+	if o.Archive != nil {
+		if err := o.Archive.Save(school.Name, school.BuildingID, school.DistrictID, m); err != nil && o.Logger != nil {
+			o.Logger.WithError(err).WithField("buildingID", school.BuildingID).Warn("Failed to archive menu")
+		}
+	}
+}
+
+func emailAction(opts Options) Action {
+	return func(ctx context.Context, run Run) error {
+		if opts.Mailer == nil {
+			return fmt.Errorf("no email provider configured")
+		}
+		job := run.Job
+		school := job.Schools[0]
+
+		mealTypes := job.MealTypes
+		if len(mealTypes) == 0 {
+			mealTypes = []string{"Lunch"}
+		}
+		days, err := fetchDays(opts, school, run.Period, mealTypes)
+		if err != nil {
+			return err
+		}
+		if len(days) == 0 {
+			return fmt.Errorf("%w: no menu for %s", ErrSkipped, run.Period.Key())
+		}
+
+		tmpl := email.DefaultTemplates()
+		if job.TemplateDir != "" {
+			if tmpl, err = email.LoadTemplates(job.TemplateDir); err != nil {
+				return err
+			}
+		}
+
+		from := job.From
+		if from == "" {
+			from = opts.From
+		}
+		data := email.NewTemplateData(render.Document{School: school.Name, Days: days}, run.Period.Start, run.Period.End)
+		msg, err := email.MenuMessage(tmpl, data, from, job.Subject)
+		if err != nil {
+			return err
+		}
+		if job.AttachICS {
+			msg.Attachments = append(msg.Attachments, email.CalendarAttachment(days, fmt.Sprintf("menu_%s_to_%s.ics", run.Period.Start.Format("01-02-2006"), run.Period.End.Format("01-02-2006"))))
+		}
+
+		delivery := email.DeliveryOptions{}
+		if job.Individual {
+			delivery.Mode = email.DeliverIndividual
+		}
+		var d deliveries
+		d.email(opts.Mailer.Send(msg, job.Recipients, delivery))
+		return d.err()
+	}
+}
+
+func notifyAction(opts Options) Action {
+	return func(ctx context.Context, run Run) error {
+		job := run.Job
+		school := job.Schools[0]
+
+		mealTypes := job.MealTypes
+		if len(mealTypes) == 0 {
+			mealTypes = []string{"Lunch"}
+		}
+		days, err := fetchDays(opts, school, run.Period, mealTypes)
+		if err != nil {
+			return err
+		}
+		if len(days) == 0 {
+			return fmt.Errorf("%w: no menu for %s", ErrSkipped, run.Period.Key())
+		}
+		targets := make([]notify.Config, len(job.Notify))
+		for i, t := range job.Notify {
+			if t.Timezone == "" {
+				t.Timezone = run.Time.Location().String()
+			}
+			targets[i] = t
+		}
+		var d deliveries
+		d.notify(len(targets), notify.Send(ctx, targets, render.Document{School: school.Name, Days: days}))
+		return d.err()
+	}
+}
+
+// deliveries tallies what an action sent, so a run that reached anyone is
+// reported with ErrPartial rather than failing and being repeated.
+type deliveries struct {
+	sent int
+	errs []error
+}
+
+// email records the results of a Mailer.Send.
+func (d *deliveries) email(results []email.DeliveryResult, err error) {
+	for _, r := range results {
+		if r.Err == nil {
+			d.sent++
+		}
+	}
+	if err != nil {
+		d.errs = append(d.errs, err)
+	}
+}
+
+// notify records posting to n targets, where err joins the failures as
+// notify.Send and notify.SendAlert return them.
+func (d *deliveries) notify(n int, err error) {
+	failed := 0
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		failed = len(joined.Unwrap())
+	} else if err != nil {
+		failed = 1
+	}
+	d.sent += n - failed
+	if err != nil {
+		d.errs = append(d.errs, err)
+	}
+}
+
+func (d *deliveries) err() error {
+	err := errors.Join(d.errs...)
+	if err != nil && d.sent > 0 {
+		return fmt.Errorf("%w: %w", ErrPartial, err)
+	}
+	return err
+}
+
+// warmCacheAction fetches every school and day into the cache. A day that
+// fails does not stop the rest; if some were cached, the run is partial.
+func warmCacheAction(opts Options) Action {
+	return func(ctx context.Context, run Run) error {
+		if opts.Cache == nil {
+			return fmt.Errorf("no cache configured")
+		}
+		cached := 0
+		var errs []error
+		for _, school := range run.Job.Schools {
+			for _, date := range run.Period.Days {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				dateStr := date.Format("01-02-2006")
+				m, err := menu.Fetch(school.BuildingID, school.DistrictID, dateStr, dateStr, false)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("fetching menu for %s on %s: %w", school.BuildingID, date.Format(menu.DateLayout), err))
+					continue
+				}
+				opts.keep(school, dateStr, m)
+				cached++
+			}
+		}
+		if len(errs) == 0 {
+			return nil
+		}
+		err := fmt.Errorf("cached %d, failed %d: %w", cached, len(errs), errors.Join(errs...))
+		if cached > 0 {
+			return fmt.Errorf("%w: %w", ErrPartial, err)
+		}
+		return err
+	}
+}
+
+func watchAction(opts Options, snapshotDir string) Action {
+	var (
+		mu    sync.Mutex
+		store *changes.Store
+	)
+	// open opens the store on first use, so schedules without watch jobs
+	// do not create the directory. A failed open is retried on the next run.
+	open := func() (*changes.Store, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if store == nil {
+			s, err := changes.Open(snapshotDir)
+			if err != nil {
+				return nil, err
+			}
+			store = s
+		}
+		return store, nil
+	}
+	return func(ctx context.Context, run Run) error {
+		store, err := open()
+		if err != nil {
+			return err
+		}
+		job := run.Job
+		mealTypes := job.MealTypes
+		if len(mealTypes) == 0 {
+			mealTypes = []string{"Lunch"}
+		}
+
+		// Changes to days already served don't matter.
+		today := dates.Day(run.Time)
+		var upcoming []time.Time
+		for _, d := range run.Period.Days {
+			if !d.Before(today) {
+				upcoming = append(upcoming, d)
+			}
+		}
+		if len(upcoming) == 0 {
+			return fmt.Errorf("%w: no upcoming days in %s", ErrSkipped, run.Period.Key())
+		}
+
+		var errs []error
+		for _, school := range job.Schools {
+			diffs, snap, err := checkSchool(ctx, opts, store, school, upcoming, mealTypes)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if len(diffs) > 0 {
+				// Keep the old snapshot if sending fails, so the next run
+				// reports the changes again, unless someone got them.
+				if err := sendChanges(ctx, opts, job, school, diffs); err != nil {
+					errs = append(errs, err)
+					if !errors.Is(err, ErrPartial) {
+						continue
+					}
+				}
+			}
+			if err := store.Save(snap); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// checkSchool fetches each day fresh from LINQ Connect, refreshing the
+// cache, and returns the changes since the last check with the snapshot to
+// save.
+func checkSchool(ctx context.Context, opts Options, store *changes.Store, school School, days []time.Time, mealTypes []string) ([]changes.DayDiff, changes.Snapshot, error) {
+	var fetched []menu.Day
+	for _, date := range days {
+		if err := ctx.Err(); err != nil {
+			return nil, changes.Snapshot{}, err
+		}
+		dateStr := date.Format("01-02-2006")
+		m, err := menu.Fetch(school.BuildingID, school.DistrictID, dateStr, dateStr, false)
+		if err != nil {
+			return nil, changes.Snapshot{}, fmt.Errorf("fetching menu for %s on %s: %w", school.BuildingID, date.Format(menu.DateLayout), err)
+		}
+		opts.keep(school, dateStr, m)
+		if day, ok := m.DayFor(date, mealTypes...); ok {
+			fetched = append(fetched, day)
+		}
+	}
+	return store.Check(school.Name, school.BuildingID, school.DistrictID, days, fetched)
+}
+
+// sendChanges tells the job's recipients and notify targets about changed
+// days.
+func sendChanges(ctx context.Context, opts Options, job Job, school School, diffs []changes.DayDiff) error {
+	title := "School menu changed"
+	if school.Name != "" {
+		title = school.Name + " menu changed"
+	}
+	text := changes.Text(diffs)
+	a := notify.Alert{School: school.Name, Title: title, Text: text, Details: diffs}
+	return sendAlert(ctx, opts, job, a, "The menu changed since it was published:\n\n"+text)
+}
+
+// sendAlert emails body to the job's recipients, under the job's subject
+// or else the alert title, and posts the alert to its notify targets.
+func sendAlert(ctx context.Context, opts Options, job Job, a notify.Alert, body string) error {
+	var d deliveries
+	if len(job.Recipients) > 0 {
+		from := job.From
+		if from == "" {
+			from = opts.From
+		}
+		subject := job.Subject
+		if subject == "" {
+			subject = a.Title
+		}
+		msg := &email.Message{From: from, Subject: subject, Text: body}
+		delivery := email.DeliveryOptions{}
+		if job.Individual {
+			delivery.Mode = email.DeliverIndividual
+		}
+		if opts.Mailer == nil {
+			d.errs = append(d.errs, fmt.Errorf("no email provider configured"))
+		} else {
+			d.email(opts.Mailer.Send(msg, job.Recipients, delivery))
+		}
+	}
+	if len(job.Notify) > 0 {
+		d.notify(len(job.Notify), notify.SendAlert(ctx, job.Notify, a))
+	}
+	return d.err()
+}
+
+func planAction(opts Options) Action {
+	return func(ctx context.Context, run Run) error {
+		job := run.Job
+		school := job.Schools[0]
+		prefs := planner.Preferences{Favorites: job.Favorites, Dislikes: job.Dislikes, Session: planner.SessionFor(job.MealTypes)}
+		if len(job.Allergens) > 0 {
+			prefs.Allergens = make(map[string]string, len(job.Allergens))
+			for name, id := range job.Allergens {
+				prefs.Allergens[id] = name
+			}
+		}
+
+		var days []time.Time
+		for _, d := range run.Period.Days {
+			if !dates.Weekend(d) {
+				days = append(days, d)
+			}
+		}
+		if len(days) == 0 {
+			return fmt.Errorf("%w: no school days in %s", ErrSkipped, run.Period.Key())
+		}
+		// Days without a menu, even a whole unpublished week, are planned
+		// as pack-lunch days.
+		menus, err := fetchDays(opts, school, Period{Days: days}, []string{prefs.Session})
+		if err != nil {
+			return err
+		}
+
+		plan := planner.Build(school.Name, days, menus, prefs)
+		a := notify.Alert{School: school.Name, Title: plan.Title(), Text: plan.Text(), Details: plan}
+		return sendAlert(ctx, opts, job, a, a.Text)
+	}
+}
@@ -0,0 +1,196 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/cache"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+func TestPlanActionAllergens(t *testing.T) {
+	c, err := cache.New(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	monday := time.Date(2024, time.September, 2, 0, 0, 0, 0, time.UTC)
+	c.Set("b1", "d1", "09-02-2024", "09-02-2024", &menu.Menu{FamilyMenuSessions: []menu.FamilyMenuSession{{
+		ServingSession: "Lunch",
+		MenuPlans: []menu.MenuPlan{{Days: []menu.MenuDay{{
+			Date: "9/2/2024",
+			MenuMeals: []menu.MenuMeal{{MenuMealName: "Main", RecipeCategories: []menu.RecipeCategory{
+				{CategoryName: "Entree", Recipes: []menu.Recipe{{RecipeName: "Mac and Cheese", Allergens: []string{"a-milk"}}}},
+			}}},
+		}}}},
+	}}})
+
+	mailer := &fakeMailer{}
+	job := Job{
+		Name:       "plan",
+		Action:     ActionPlan,
+		Schools:    []School{{Name: "Lincoln", BuildingID: "b1", DistrictID: "d1"}},
+		Recipients: []string{"parent@example.com"},
+		Allergens:  map[string]string{"Milk": "a-milk"},
+	}
+	run := Run{Job: job, Period: Period{Start: monday, End: monday, Days: []time.Time{monday}}}
+	if err := planAction(Options{Cache: c, Mailer: mailer})(context.Background(), run); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.texts) != 1 || !strings.Contains(mailer.texts[0], "Pack lunch: Milk in Mac and Cheese") {
+		t.Errorf("plan sent = %q, want Monday ruled out for milk", mailer.texts)
+	}
+}
+
+func TestPlanActionUnpublishedWeek(t *testing.T) {
+	c, err := cache.New(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	monday := time.Date(2024, time.September, 2, 0, 0, 0, 0, time.UTC)
+	var days []time.Time
+	for i := 0; i < 7; i++ {
+		d := monday.AddDate(0, 0, i)
+		days = append(days, d)
+		c.Set("b1", "d1", d.Format("01-02-2006"), d.Format("01-02-2006"), &menu.Menu{})
+	}
+
+	mailer := &fakeMailer{}
+	job := Job{
+		Name:       "plan",
+		Action:     ActionPlan,
+		Schools:    []School{{Name: "Lincoln", BuildingID: "b1", DistrictID: "d1"}},
+		Recipients: []string{"parent@example.com"},
+		Dislikes:   []string{"fish stick"},
+	}
+	run := Run{Job: job, Period: Period{Start: days[0], End: days[6], Days: days}}
+	if err := planAction(Options{Cache: c, Mailer: mailer})(context.Background(), run); err != nil {
+		t.Fatalf("plan for an unpublished week: %v", err)
+	}
+	if len(mailer.texts) != 1 || strings.Count(mailer.texts[0], "Pack lunch: no menu") != 5 {
+		t.Errorf("plan sent = %q, want five pack-lunch days", mailer.texts)
+	}
+}
+
+// fakeLINQ answers menu requests through PROXY_URL with an empty menu,
+// failing those for the dates in fail, and records the dates requested.
+type fakeLINQ struct {
+	*httptest.Server
+	fail  map[string]bool
+	mu    sync.Mutex
+	dates []string
+}
+
+func newFakeLINQ(t *testing.T, fail ...string) *fakeLINQ {
+	f := &fakeLINQ{fail: make(map[string]bool)}
+	for _, d := range fail {
+		f.fail[d] = true
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		date := r.URL.Query().Get("startDate")
+		f.mu.Lock()
+		f.dates = append(f.dates, date)
+		f.mu.Unlock()
+		if f.fail[date] {
+			http.Error(w, "upstream timeout", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"FamilyMenuSessions":[],"AcademicCalendars":[]}`)
+	}))
+	t.Cleanup(f.Close)
+	t.Setenv("PROXY_URL", f.URL)
+	t.Setenv("PROXY_AUTH_TOKEN", "")
+	return f
+}
+
+func (f *fakeLINQ) requested() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.dates...)
+}
+
+func TestWarmCacheAction(t *testing.T) {
+	monday := time.Date(2024, time.September, 2, 0, 0, 0, 0, time.UTC)
+	days := []time.Time{monday, monday.AddDate(0, 0, 1), monday.AddDate(0, 0, 2)}
+	job := Job{
+		Name:    "warm",
+		Action:  ActionWarmCache,
+		Schools: []School{{BuildingID: "b1", DistrictID: "d1"}, {BuildingID: "b2", DistrictID: "d1"}},
+	}
+	run := Run{Job: job, Period: Period{Start: days[0], End: days[2], Days: days}}
+
+	tests := []struct {
+		name        string
+		fail        []string
+		wantErr     string
+		wantPartial bool
+	}{
+		{name: "all cached"},
+		{name: "one day fails", fail: []string{"09-03-2024"}, wantErr: "cached 4, failed 2", wantPartial: true},
+		{name: "every day fails", fail: []string{"09-02-2024", "09-03-2024", "09-04-2024"}, wantErr: "cached 0, failed 6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			linq := newFakeLINQ(t, tt.fail...)
+			c, err := cache.New(t.TempDir(), time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = warmCacheAction(Options{Cache: c})(context.Background(), run)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("warm-cache = %v, want %q", err, tt.wantErr)
+			}
+			if got := errors.Is(err, ErrPartial); got != tt.wantPartial {
+				t.Errorf("partial = %v, want %v", got, tt.wantPartial)
+			}
+			if n := len(linq.requested()); n != 6 {
+				t.Errorf("fetched %d school days, want all 6", n)
+			}
+			for _, school := range job.Schools {
+				for _, d := range days {
+					dateStr := d.Format("01-02-2006")
+					if _, ok := c.Get(school.BuildingID, school.DistrictID, dateStr, dateStr); ok == linq.fail[dateStr] {
+						t.Errorf("%s on %s cached = %v", school.BuildingID, dateStr, ok)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWatchActionRetriesOpen(t *testing.T) {
+	newFakeLINQ(t)
+	dir := t.TempDir()
+	blocked := filepath.Join(dir, "snapshots")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	monday := time.Date(2024, time.September, 2, 0, 0, 0, 0, time.UTC)
+	job := Job{Name: "watch", Action: ActionWatch, Schools: []School{{BuildingID: "b1", DistrictID: "d1"}}}
+	run := Run{Job: job, Period: Period{Start: monday, End: monday, Days: []time.Time{monday}}, Time: monday}
+	watch := watchAction(Options{}, filepath.Join(blocked, "menus"))
+
+	if err := watch(context.Background(), run); err == nil {
+		t.Fatal("watch with an unusable snapshot dir succeeded")
+	}
+	if err := os.Remove(blocked); err != nil {
+		t.Fatal(err)
+	}
+	if err := watch(context.Background(), run); err != nil {
+		t.Fatalf("watch after the snapshot dir was fixed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(blocked, "menus")); err != nil {
+		t.Errorf("snapshot dir not created: %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package schedule
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+	"github.com/asachs01/school_menu_connector/internal/notify"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a schedule file: a list of jobs and where to keep their state.
+type Config struct {
+	// Timezone is the IANA zone jobs run in, e.g. America/New_York.
+	// Defaults to the local zone.
+	Timezone string `yaml:"timezone"`
+	// State is the file that records each job's last run, so a restart
+	// does not repeat work. Defaults to schedule-state.json.
+	State string `yaml:"state"`
+	// Snapshots is the directory where watch jobs keep the last-seen menus.
+	// Defaults to menu-snapshots.
+	Snapshots string `yaml:"snapshots"`
+	// SkipDates are no-school days, as YYYY-MM-DD, for every job.
+	SkipDates []string `yaml:"skipDates"`
+	Jobs      []Job    `yaml:"jobs"`
+}
+
+// Job runs an action on a cron schedule.
+type Job struct {
+	Name string `yaml:"name"`
+	// Schedule is a standard five-field cron expression or a descriptor
+	// such as @daily. It may start with CRON_TZ=Zone to override the
+	// config time zone.
+	Schedule string `yaml:"schedule"`
+	// Action is email, notify, warm-cache, watch, plan, or an action
+	// registered by the host, such as digest in the web server.
+	Action string `yaml:"action"`
+	// Range is the menu period the job covers, as a relative date such as
+	// tomorrow, next-school-day, this-week, next-week or +1d. Defaults to
+	// today.
+	Range     string   `yaml:"range"`
+	Schools   []School `yaml:"schools"`
+	MealTypes []string `yaml:"mealTypes"`
+	// SkipWeekends leaves Saturdays and Sundays out of the range. A job
+	// whose whole range is skipped does not run.
+	SkipWeekends bool `yaml:"skipWeekends"`
+	// SkipDates are extra no-school days for this job.
+	SkipDates []string `yaml:"skipDates"`
+	// Repeat runs the job on every tick, even if it already completed the
+	// same period. Otherwise a job runs once per period, so a restart does
+	// not send the same menu twice. warm-cache and watch jobs always
+	// repeat.
+	Repeat bool `yaml:"repeat"`
+
+	// Email settings for the email action. Watch and plan jobs email
+	// their recipients too.
+	Recipients  []string `yaml:"recipients"`
+	From        string   `yaml:"from"`
+	Subject     string   `yaml:"subject"`
+	TemplateDir string   `yaml:"templateDir"`
+	AttachICS   bool     `yaml:"attachICS"`
+	Individual  bool     `yaml:"individual"`
+
+	// Notify lists the chat targets for the notify, watch and plan
+	// actions.
+	Notify []notify.Config `yaml:"notify"`
+
+	// Favorites and Dislikes are recipe patterns, such as pizza or fish
+	// stick, that the plan action marks favorite and pack-lunch days by.
+	Favorites []string `yaml:"favorites"`
+	Dislikes  []string `yaml:"dislikes"`
+	// Allergens maps display names to LINQ allergen IDs, as in a CLI
+	// profile. The plan action rules out entrees that contain them.
+	Allergens map[string]string `yaml:"allergens"`
+
+	// Params holds settings for host-registered actions.
+	Params map[string]string `yaml:"params"`
+}
+
+// School identifies a school's menu.
+type School struct {
+	Name       string `yaml:"name"`
+	BuildingID string `yaml:"buildingId"`
+	DistrictID string `yaml:"districtId"`
+}
+
+// Load reads and validates a schedule file.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading schedule: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing schedule: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	if _, err := c.Location(); err != nil {
+		return err
+	}
+	if err := validDates(c.SkipDates); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for i, job := range c.Jobs {
+		if job.Name == "" {
+			return fmt.Errorf("job %d: name is required", i+1)
+		}
+		if seen[job.Name] {
+			return fmt.Errorf("job %s: duplicate name", job.Name)
+		}
+		seen[job.Name] = true
+
+		if job.Schedule == "" {
+			return fmt.Errorf("job %s: schedule is required", job.Name)
+		}
+		if _, err := parser.Parse(job.Schedule); err != nil {
+			return fmt.Errorf("job %s: invalid schedule: %w", job.Name, err)
+		}
+		if job.Action == "" {
+			return fmt.Errorf("job %s: action is required", job.Name)
+		}
+		if !validRange(job.Range) {
+			return fmt.Errorf("job %s: range %q is not a relative date such as tomorrow, next-week or +1d", job.Name, job.Range)
+		}
+		if err := validDates(job.SkipDates); err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+		for _, s := range job.Schools {
+			if s.BuildingID == "" || s.DistrictID == "" {
+				return fmt.Errorf("job %s: schools need a buildingId and districtId", job.Name)
+			}
+		}
+		if job.Action == ActionEmail && (len(job.Schools) != 1 || len(job.Recipients) == 0) {
+			return fmt.Errorf("job %s: email jobs need exactly one school and at least one recipient", job.Name)
+		}
+		if job.Action == ActionNotify {
+			if len(job.Schools) != 1 || len(job.Notify) == 0 {
+				return fmt.Errorf("job %s: notify jobs need exactly one school and at least one notify target", job.Name)
+			}
+			if err := notify.Validate(job.Notify); err != nil {
+				return fmt.Errorf("job %s: %w", job.Name, err)
+			}
+		}
+		if job.Action == ActionWatch {
+			if len(job.Schools) == 0 || len(job.Recipients)+len(job.Notify) == 0 {
+				return fmt.Errorf("job %s: watch jobs need at least one school and a recipient or notify target", job.Name)
+			}
+			if err := notify.Validate(job.Notify); err != nil {
+				return fmt.Errorf("job %s: %w", job.Name, err)
+			}
+		}
+		if job.Action == ActionPlan {
+			if len(job.Schools) != 1 || len(job.Recipients)+len(job.Notify) == 0 {
+				return fmt.Errorf("job %s: plan jobs need exactly one school and a recipient or notify target", job.Name)
+			}
+			if len(job.Favorites)+len(job.Dislikes)+len(job.Allergens) == 0 {
+				return fmt.Errorf("job %s: plan jobs need favorites, dislikes or allergens", job.Name)
+			}
+			if err := notify.Validate(job.Notify); err != nil {
+				return fmt.Errorf("job %s: %w", job.Name, err)
+			}
+		}
+		if job.Action == ActionWarmCache && len(job.Schools) == 0 {
+			return fmt.Errorf("job %s: warm-cache jobs need at least one school", job.Name)
+		}
+	}
+	return nil
+}
+
+// Location returns the configured time zone.
+func (c *Config) Location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+	return loc, nil
+}
+
+func validDates(dates []string) error {
+	for _, d := range dates {
+		if _, err := time.Parse(menu.DateLayout, d); err != nil {
+			return fmt.Errorf("invalid skip date %q, expected YYYY-MM-DD", d)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/dates"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// validRange reports whether r is a relative date expression, such as
+// next-week or +1d. Exact dates would make a recurring job repeat forever.
+func validRange(r string) bool {
+	return r == "" || dates.Relative(r)
+}
+
+// Period is the span of school days a run covers. Start and End bound the
+// range; Days lists the dates in it that are not skipped. Dates are at
+// midnight UTC, like menu.Day.Time.
+type Period struct {
+	Start time.Time
+	End   time.Time
+	Days  []time.Time
+}
+
+// Key identifies the period in the job state, e.g. 2024-09-09..2024-09-13.
+func (p Period) Key() string {
+	return p.Start.Format(menu.DateLayout) + ".." + p.End.Format(menu.DateLayout)
+}
+
+// Empty reports whether every day in the period is skipped.
+func (p Period) Empty() bool {
+	return len(p.Days) == 0
+}
+
+// Contains reports whether date is one of the period's school days.
+func (p Period) Contains(date string) bool {
+	for _, d := range p.Days {
+		if d.Format(menu.DateLayout) == date {
+			return true
+		}
+	}
+	return false
+}
+
+// period returns the days job covers when run at now, which must be in the
+// schedule's time zone.
+func (j Job) period(now time.Time, globalSkip []string) Period {
+	skip := make(map[string]bool)
+	for _, d := range globalSkip {
+		skip[d] = true
+	}
+	for _, d := range j.SkipDates {
+		skip[d] = true
+	}
+	skipped := func(d time.Time) bool {
+		if j.SkipWeekends && (d.Weekday() == time.Saturday || d.Weekday() == time.Sunday) {
+			return true
+		}
+		return skip[d.Format(menu.DateLayout)]
+	}
+
+	var start, end time.Time
+	if j.Range == dates.NextSchoolDay {
+		// Step over the job's no-school days as well as weekends.
+		start = dates.SchoolDayAfter(dates.Day(now), func(d time.Time) bool {
+			return skip[d.Format(menu.DateLayout)]
+		})
+		end = start
+	} else {
+		r := j.Range
+		if r == "" {
+			r = dates.Today
+		}
+		// validate has already checked the range.
+		start, end, _ = dates.Range(r, now)
+	}
+
+	p := Period{Start: start, End: end}
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		if !skipped(d) {
+			p.Days = append(p.Days, d)
+		}
+	}
+	return p
+}
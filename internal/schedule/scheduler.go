@@ -0,0 +1,278 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/archive"
+	"github.com/asachs01/school_menu_connector/internal/cache"
+	"github.com/asachs01/school_menu_connector/internal/email"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// parser accepts standard five-field expressions, descriptors such as
+// @daily, and a CRON_TZ= prefix.
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ErrSkipped is returned by an action that found nothing to do, for example
+// because the school has no menu for the period. The run is recorded as
+// skipped rather than failed, and is not retried for the same period.
+var ErrSkipped = errors.New("nothing to do")
+
+// ErrPartial is returned by an action that delivered to some recipients or
+// targets but not all. The run is recorded as partial and the period as
+// done, so the ones that got it are not sent it again; the failures are
+// logged.
+var ErrPartial = errors.New("partly delivered")
+
+// Run is one execution of a job.
+type Run struct {
+	Job    Job
+	Period Period
+	// Time is when the run started, in the schedule's time zone.
+	Time time.Time
+}
+
+// Action carries out a job run.
+type Action func(ctx context.Context, run Run) error
+
+// Options supplies the services the built-in actions use.
+type Options struct {
+	// Cache is warmed by warm-cache jobs and read by email jobs. Optional
+	// for email jobs.
+	Cache *cache.Cache
+	// Archive, if set, keeps every menu the jobs fetch.
+	Archive *archive.Store
+	// Mailer sends email jobs. From is the default sender.
+	Mailer email.Mailer
+	From   string
+	Logger *logrus.Logger
+}
+
+// Scheduler runs jobs on their cron schedules.
+type Scheduler struct {
+	cfg     *Config
+	loc     *time.Location
+	cron    *cron.Cron
+	state   *stateFile
+	logger  *logrus.Logger
+	actions map[string]Action
+	entries map[string]cron.EntryID
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+// New returns a Scheduler for cfg with the email, notify, warm-cache and
+// watch actions registered. Call Register to add other actions before Start.
+func New(cfg *Config, opts Options) (*Scheduler, error) {
+	loc, err := cfg.Location()
+	if err != nil {
+		return nil, err
+	}
+
+	statePath := cfg.State
+	if statePath == "" {
+		statePath = "schedule-state.json"
+	}
+	state, err := loadState(statePath)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+
+	s := &Scheduler{
+		cfg:     cfg,
+		loc:     loc,
+		cron:    cron.New(cron.WithLocation(loc), cron.WithParser(parser)),
+		state:   state,
+		logger:  logger,
+		actions: make(map[string]Action),
+		entries: make(map[string]cron.EntryID),
+		running: make(map[string]bool),
+	}
+	s.Register(ActionEmail, emailAction(opts))
+	s.Register(ActionNotify, notifyAction(opts))
+	s.Register(ActionWarmCache, warmCacheAction(opts))
+	s.Register(ActionWatch, watchAction(opts, cfg.Snapshots))
+	s.Register(ActionPlan, planAction(opts))
+	return s, nil
+}
+
+// Register adds or replaces the action with the given name.
+func (s *Scheduler) Register(name string, action Action) {
+	s.actions[name] = action
+}
+
+// Start schedules every job and begins running them in the background.
+func (s *Scheduler) Start() error {
+	for _, job := range s.cfg.Jobs {
+		if _, ok := s.actions[job.Action]; !ok {
+			return fmt.Errorf("job %s: unknown action %q", job.Name, job.Action)
+		}
+	}
+
+	for _, job := range s.cfg.Jobs {
+		job := job
+		id, err := s.cron.AddFunc(job.Schedule, func() {
+			if err := s.RunJob(context.Background(), job.Name); err != nil {
+				s.logger.WithError(err).WithField("job", job.Name).Warn("Scheduled job failed")
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+		s.entries[job.Name] = id
+	}
+
+	s.cron.Start()
+	for _, st := range s.Status() {
+		s.logger.WithFields(logrus.Fields{"job": st.Name, "next": st.NextRun}).Info("Job scheduled")
+	}
+	return nil
+}
+
+// Stop stops scheduling new runs and waits for running jobs to finish.
+func (s *Scheduler) Stop() {
+	<-s.cron.Stop().Done()
+}
+
+// RunJob runs the named job now. A run is skipped if the job is already
+// running, if every day in its period is a weekend or no-school day, or if
+// it already completed the same period and does not repeat; a restart
+// therefore never sends the same menu twice. A run that delivered to anyone
+// completes its period, even if some deliveries failed.
+func (s *Scheduler) RunJob(ctx context.Context, name string) error {
+	job, ok := s.job(name)
+	if !ok {
+		return fmt.Errorf("unknown job %q", name)
+	}
+	action := s.actions[job.Action]
+	if action == nil {
+		return fmt.Errorf("job %s: unknown action %q", job.Name, job.Action)
+	}
+
+	s.mu.Lock()
+	if s.running[name] {
+		s.mu.Unlock()
+		s.logger.WithField("job", name).Info("Job still running, skipping")
+		return nil
+	}
+	s.running[name] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, name)
+		s.mu.Unlock()
+	}()
+
+	now := time.Now().In(s.loc)
+	run := Run{Job: job, Period: job.period(now, s.cfg.SkipDates), Time: now}
+	st := s.state.get(name)
+	st.LastRun = now
+	st.LastPeriod = run.Period.Key()
+
+	var err error
+	switch {
+	case run.Period.Empty():
+		st.LastStatus, st.LastMessage = StatusSkipped, "no school days in period"
+	case st.DonePeriod == run.Period.Key() && !job.Repeat && job.Action != ActionWarmCache && job.Action != ActionWatch:
+		st.LastStatus, st.LastMessage = StatusSkipped, "already completed for period"
+	default:
+		err = action(ctx, run)
+		switch {
+		case errors.Is(err, ErrSkipped):
+			st.LastStatus, st.LastMessage = StatusSkipped, err.Error()
+			st.DonePeriod = run.Period.Key()
+			err = nil
+		case errors.Is(err, ErrPartial):
+			st.LastStatus, st.LastMessage = StatusPartial, err.Error()
+			st.DonePeriod = run.Period.Key()
+		case err != nil:
+			st.LastStatus, st.LastMessage = StatusFailed, err.Error()
+		default:
+			st.LastStatus, st.LastMessage = StatusSuccess, ""
+			st.DonePeriod = run.Period.Key()
+		}
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"job":     name,
+		"period":  st.LastPeriod,
+		"status":  st.LastStatus,
+		"message": st.LastMessage,
+	}).Info("Job run")
+
+	if serr := s.state.set(name, st); serr != nil {
+		s.logger.WithError(serr).Error("Error saving schedule state")
+	}
+	return err
+}
+
+func (s *Scheduler) job(name string) (Job, bool) {
+	for _, j := range s.cfg.Jobs {
+		if j.Name == name {
+			return j, true
+		}
+	}
+	return Job{}, false
+}
+
+// JobStatus describes a job for the status endpoint.
+type JobStatus struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	Action   string    `json:"action"`
+	Range    string    `json:"range,omitempty"`
+	Running  bool      `json:"running"`
+	NextRun  time.Time `json:"nextRun,omitempty"`
+	JobState
+}
+
+// Status returns the state of every job, sorted by name.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.cfg.Jobs))
+	for _, job := range s.cfg.Jobs {
+		st := JobStatus{
+			Name:     job.Name,
+			Schedule: job.Schedule,
+			Action:   job.Action,
+			Range:    job.Range,
+			Running:  s.running[job.Name],
+			JobState: s.state.get(job.Name),
+		}
+		if id, ok := s.entries[job.Name]; ok {
+			st.NextRun = s.cron.Entry(id).Next
+		}
+		statuses = append(statuses, st)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// ServeHTTP writes the job status as JSON.
+func (s *Scheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"timezone": s.loc.String(),
+		"jobs":     s.Status(),
+	})
+}
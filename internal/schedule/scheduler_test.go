@@ -0,0 +1,137 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/asachs01/school_menu_connector/internal/email"
+	"github.com/asachs01/school_menu_connector/internal/notify"
+)
+
+// fakeMailer accepts every recipient except those in reject, and keeps
+// the text of each message.
+type fakeMailer struct {
+	reject map[string]bool
+	sends  int
+	texts  []string
+}
+
+func (m *fakeMailer) Send(msg *email.Message, recipients []string, opts email.DeliveryOptions) ([]email.DeliveryResult, error) {
+	m.sends++
+	m.texts = append(m.texts, msg.Text)
+	results := make([]email.DeliveryResult, len(recipients))
+	failed := false
+	for i, r := range recipients {
+		results[i].Recipient = r
+		if m.reject[r] {
+			results[i].Err = errors.New("mailbox unavailable")
+			failed = true
+		}
+	}
+	if failed {
+		return results, &email.DeliveryError{Results: results}
+	}
+	return results, nil
+}
+
+func TestRunJobDelivery(t *testing.T) {
+	tests := []struct {
+		name       string
+		reject     map[string]bool
+		wantStatus string
+		wantErr    bool
+		// wantSends is how many times the mailer is called over two runs.
+		wantSends int
+	}{
+		{"all delivered", nil, StatusSuccess, false, 1},
+		{"partly delivered", map[string]bool{"b@example.com": true}, StatusPartial, true, 1},
+		{"nothing delivered", map[string]bool{"a@example.com": true, "b@example.com": true}, StatusFailed, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Timezone: "UTC",
+				State:    filepath.Join(t.TempDir(), "state.json"),
+				Jobs: []Job{{
+					Name:       "alert",
+					Schedule:   "@daily",
+					Action:     "alert",
+					Recipients: []string{"a@example.com", "b@example.com"},
+					Individual: true,
+				}},
+			}
+			mailer := &fakeMailer{reject: tt.reject}
+			opts := Options{Mailer: mailer, From: "menus@example.com"}
+			s, err := New(cfg, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.Register("alert", func(ctx context.Context, run Run) error {
+				return sendAlert(ctx, opts, run.Job, notify.Alert{Title: "Menu", Text: "Pizza"}, "Pizza")
+			})
+
+			err = s.RunJob(context.Background(), "alert")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunJob = %v, want error %v", err, tt.wantErr)
+			}
+			if st := s.state.get("alert"); st.LastStatus != tt.wantStatus {
+				t.Errorf("status = %q, want %q", st.LastStatus, tt.wantStatus)
+			}
+
+			// A second run only retries if nobody got the first.
+			s.RunJob(context.Background(), "alert")
+			if mailer.sends != tt.wantSends {
+				t.Errorf("mailer called %d times over two runs, want %d", mailer.sends, tt.wantSends)
+			}
+		})
+	}
+}
+
+func TestDeliveries(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+	tests := []struct {
+		name        string
+		record      func(d *deliveries)
+		wantErr     bool
+		wantPartial bool
+	}{
+		{"nothing", func(d *deliveries) {}, false, false},
+		{"email ok", func(d *deliveries) {
+			d.email([]email.DeliveryResult{{Recipient: "a"}}, nil)
+		}, false, false},
+		{"email partial", func(d *deliveries) {
+			results := []email.DeliveryResult{{Recipient: "a"}, {Recipient: "b", Err: errB}}
+			d.email(results, &email.DeliveryError{Results: results})
+		}, true, true},
+		{"email failed", func(d *deliveries) {
+			results := []email.DeliveryResult{{Recipient: "a", Err: errA}}
+			d.email(results, &email.DeliveryError{Results: results})
+		}, true, false},
+		{"one of two targets failed", func(d *deliveries) {
+			d.notify(2, errors.Join(errA))
+		}, true, true},
+		{"both targets failed", func(d *deliveries) {
+			d.notify(2, errors.Join(errA, errB))
+		}, true, false},
+		{"email failed, target ok", func(d *deliveries) {
+			results := []email.DeliveryResult{{Recipient: "a", Err: errA}}
+			d.email(results, &email.DeliveryError{Results: results})
+			d.notify(1, nil)
+		}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d deliveries
+			tt.record(&d)
+			err := d.err()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, ErrPartial); got != tt.wantPartial {
+				t.Errorf("partial = %v, want %v (err %v)", got, tt.wantPartial, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Run outcomes recorded in JobState.LastStatus.
+const (
+	StatusSuccess = "success"
+	StatusSkipped = "skipped"
+	StatusPartial = "partial"
+	StatusFailed  = "failed"
+)
+
+// JobState is what the scheduler remembers about a job between restarts.
+type JobState struct {
+	LastRun    time.Time `json:"lastRun"`
+	LastStatus string    `json:"lastStatus"`
+	// LastMessage explains a skip or failure.
+	LastMessage string `json:"lastMessage,omitempty"`
+	LastPeriod  string `json:"lastPeriod,omitempty"`
+	// DonePeriod is the last period the job completed, so it is not
+	// repeated if the scheduler restarts and the job fires again.
+	DonePeriod string `json:"donePeriod,omitempty"`
+}
+
+// stateFile persists job state as JSON.
+type stateFile struct {
+	path string
+	mu   sync.Mutex
+	jobs map[string]JobState
+}
+
+func loadState(path string) (*stateFile, error) {
+	s := &stateFile{path: path, jobs: make(map[string]JobState)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading schedule state: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.jobs); err != nil {
+		return nil, fmt.Errorf("parsing schedule state: %w", err)
+	}
+	return s, nil
+}
+
+func (s *stateFile) get(name string) JobState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[name]
+}
+
+// set records a job's state and writes the file. The file is replaced
+// atomically so a crash cannot leave it half-written.
+func (s *stateFile) set(name string, st JobState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[name] = st
+
+	data, err := json.MarshalIndent(s.jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating state dir: %w", err)
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("writing schedule state: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
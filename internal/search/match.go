@@ -0,0 +1,163 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Scores for how well a query word matches a word in a recipe.
+const (
+	scoreExact  = 1.0
+	scorePrefix = 0.8
+	scoreFuzzy  = 0.6
+	// categoryWeight scales matches on the category rather than the
+	// recipe name, so "pizza" ranks a pizza above a recipe filed under a
+	// Pizza category.
+	categoryWeight = 0.5
+)
+
+// words splits text into lowercase words and stems them.
+func words(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, f := range fields {
+		fields[i] = stem(f)
+	}
+	return fields
+}
+
+// stem strips common English endings so that "tacos", "taco", "baked" and
+// "bake" match. It is a light stemmer for short food names, not a full
+// Porter stemmer.
+func stem(w string) string {
+	if len(w) <= 3 {
+		return w
+	}
+	switch {
+	case strings.HasSuffix(w, "ies") && len(w) > 4:
+		w = w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "sses"):
+		w = w[:len(w)-2]
+	case strings.HasSuffix(w, "ches"), strings.HasSuffix(w, "shes"), strings.HasSuffix(w, "xes"), strings.HasSuffix(w, "oes"):
+		w = w[:len(w)-2]
+	case strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && !strings.HasSuffix(w, "us") && !strings.HasSuffix(w, "is"):
+		w = w[:len(w)-1]
+	case strings.HasSuffix(w, "ing") && len(w) > 5:
+		w = undouble(w[:len(w)-3])
+	case strings.HasSuffix(w, "ed") && len(w) > 4:
+		w = undouble(w[:len(w)-2])
+	}
+	if len(w) >= 4 && strings.HasSuffix(w, "e") {
+		w = w[:len(w)-1]
+	}
+	return w
+}
+
+// undouble drops a doubled final consonant, as in "dipp" from "dipping".
+func undouble(w string) string {
+	n := len(w)
+	if n >= 2 && w[n-1] == w[n-2] && !strings.ContainsRune("aeioulsz", rune(w[n-1])) {
+		return w[:n-1]
+	}
+	return w
+}
+
+// matchWord scores how well the query word q matches the word w, both
+// stemmed: exactly, as a prefix of at least three letters, or within a
+// typo or two.
+func matchWord(q, w string) float64 {
+	switch {
+	case q == w:
+		return scoreExact
+	case len(q) >= 3 && strings.HasPrefix(w, q):
+		return scorePrefix
+	}
+	allowed := 0
+	switch {
+	case len(q) >= 8:
+		allowed = 2
+	case len(q) >= 4:
+		allowed = 1
+	}
+	if allowed > 0 && distance(q, w, allowed) <= allowed {
+		return scoreFuzzy
+	}
+	return 0
+}
+
+// score rates a recipe against the query words: the average of each query
+// word's best match in the name, or in the category at categoryWeight.
+// Every query word must match somewhere, or the score is zero.
+func score(query, name, category []string) float64 {
+	total := 0.0
+	for _, q := range query {
+		best := 0.0
+		for _, w := range name {
+			best = max(best, matchWord(q, w))
+		}
+		for _, w := range category {
+			best = max(best, categoryWeight*matchWord(q, w))
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total / float64(len(query))
+}
+
+// distance returns the Levenshtein distance between a and b, or limit+1
+// once it must exceed limit.
+func distance(a, b string, limit int) int {
+	if d := len(a) - len(b); d > limit || -d > limit {
+		return limit + 1
+	}
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// Matches reports whether every word of pattern is in text, after
+// stemming, whole or as a prefix of at least three letters. It allows no
+// typos, unlike a search, so that a list such as a child's dislikes does
+// not catch near misses.
+func Matches(pattern, text string) bool {
+	query := words(pattern)
+	if len(query) == 0 {
+		return false
+	}
+	name := words(text)
+	for _, q := range query {
+		found := false
+		for _, w := range name {
+			if matchWord(q, w) >= scorePrefix {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package search
+
+import "testing"
+
+func TestStem(t *testing.T) {
+	tests := []struct{ word, want string }{
+		{"tacos", "taco"},
+		{"taco", "taco"},
+		{"berries", "berry"},
+		{"fries", "fry"},
+		{"glasses", "glass"},
+		{"sandwiches", "sandwich"},
+		{"dishes", "dish"},
+		{"boxes", "box"},
+		{"potatoes", "potato"},
+		{"nuggets", "nugget"},
+		{"grass", "grass"},
+		{"hummus", "hummus"},
+		{"quesadillas", "quesadilla"},
+		{"baked", "bak"},
+		{"bake", "bak"},
+		{"dipping", "dip"},
+		{"dressing", "dress"},
+		{"breaded", "bread"},
+		{"rolled", "roll"},
+		{"cheese", "chees"},
+		{"peas", "pea"},
+		{"egg", "egg"},
+	}
+	for _, tt := range tests {
+		if got := stem(tt.word); got != tt.want {
+			t.Errorf("stem(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestMatchWord(t *testing.T) {
+	tests := []struct {
+		q, w string
+		want float64
+	}{
+		{"pizza", "pizza", scoreExact},
+		{"nug", "nugget", scorePrefix},
+		{"nu", "nugget", 0},
+		{"piza", "pizza", scoreFuzzy},
+		{"tac", "taco", scorePrefix},
+		{"tco", "taco", 0},
+		{"chiken", "chicken", scoreFuzzy},
+		{"spagetti", "spaghetti", scoreFuzzy},
+		{"spagheti", "spagetti", scoreFuzzy},
+		{"brocolli", "broccoli", scoreFuzzy},
+		{"burger", "burrito", 0},
+		{"pizza", "pasta", 0},
+		{"taco", "tac", scoreFuzzy},
+	}
+	for _, tt := range tests {
+		if got := matchWord(tt.q, tt.w); got != tt.want {
+			t.Errorf("matchWord(%q, %q) = %v, want %v", tt.q, tt.w, got, tt.want)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"pizza", "pizza", 1, 0},
+		{"piza", "pizza", 1, 1},
+		{"pizza", "pizzas", 1, 1},
+		{"kitten", "sitting", 3, 3},
+		{"kitten", "sitting", 2, 3},
+		{"taco", "tacoburger", 2, 3},
+	}
+	for _, tt := range tests {
+		if got := distance(tt.a, tt.b, tt.limit); got != tt.want {
+			t.Errorf("distance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		pattern, text string
+		want          bool
+	}{
+		{"fish stick", "Breaded Fish Sticks", true},
+		{"taco", "Soft Tacos", true},
+		{"nug", "Chicken Nuggets", true},
+		{"chicken nugget", "Chicken Patty", false},
+		{"piza", "Pizza", false},
+		{"bake", "Baked Potato", true},
+		{"baked", "Bake Sale Cookies", true},
+		{"", "Pizza", false},
+		{"!!", "Pizza", false},
+	}
+	for _, tt := range tests {
+		if got := Matches(tt.pattern, tt.text); got != tt.want {
+			t.Errorf("Matches(%q, %q) = %v, want %v", tt.pattern, tt.text, got, tt.want)
+		}
+	}
+}
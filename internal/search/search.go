@@ -0,0 +1,238 @@
+// Package search finds recipes across schools and dates, answering
+// questions like "when is pizza day next?".
+//
+// Menus come from the archive and the cache. Recipe and category names are
+// matched word by word after stemming, so "taco" finds "Tacos", and with
+// some tolerance for prefixes and typos, so "nugget" and "piza" find
+// "Chicken Nuggets" and "Pizza".
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/archive"
+	"github.com/asachs01/school_menu_connector/internal/cache"
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+// DefaultLimit is how many results are returned unless a query says
+// otherwise.
+const DefaultLimit = 50
+
+// Query is a search for recipes.
+type Query struct {
+	Text string
+	// BuildingID and DistrictID restrict the search to one school; empty
+	// searches every school in the sources.
+	BuildingID string
+	DistrictID string
+	// Sessions restricts the search to those sessions; none searches all.
+	Sessions []string
+	// Start and End bound the dates, inclusive. A zero time leaves that
+	// end open.
+	Start time.Time
+	End   time.Time
+	// Limit caps the results; 0 means DefaultLimit and -1 no limit.
+	Limit int
+}
+
+// Result is a day and session whose menu matches the query.
+type Result struct {
+	// Date is YYYY-MM-DD.
+	Date       string  `json:"date"`
+	School     string  `json:"school,omitempty"`
+	BuildingID string  `json:"buildingId"`
+	DistrictID string  `json:"districtId"`
+	Session    string  `json:"session"`
+	Matches    []Match `json:"matches"`
+	// Score is the best match's score, from 0 to 1.
+	Score float64 `json:"score"`
+}
+
+// Match is a recipe that matches the query.
+type Match struct {
+	Recipe   string  `json:"recipe"`
+	Category string  `json:"category"`
+	Meal     string  `json:"meal,omitempty"`
+	Score    float64 `json:"score"`
+}
+
+// SchoolDays are a school's menus to search.
+type SchoolDays struct {
+	Name       string
+	BuildingID string
+	DistrictID string
+	Days       []menu.Day
+}
+
+// Search returns the days and sessions with a recipe matching q, by date,
+// then best match first.
+func Search(schools []SchoolDays, q Query) ([]Result, error) {
+	query := words(q.Text)
+	if len(query) == 0 {
+		return nil, fmt.Errorf("empty search")
+	}
+
+	results := []Result{}
+	for _, s := range schools {
+		for _, day := range s.Days {
+			if !inRange(day.Time(), q.Start, q.End) {
+				continue
+			}
+			for _, session := range day.Sessions {
+				if len(q.Sessions) > 0 && !contains(q.Sessions, session.Name) {
+					continue
+				}
+				r := Result{Date: day.Date, School: s.Name, BuildingID: s.BuildingID, DistrictID: s.DistrictID, Session: session.Name}
+				seen := make(map[string]bool)
+				for _, c := range session.Categories {
+					category := words(c.Name)
+					for _, item := range c.Recipes {
+						if seen[item.Name] {
+							continue
+						}
+						seen[item.Name] = true
+						if sc := score(query, words(item.Name), category); sc > 0 {
+							r.Matches = append(r.Matches, Match{Recipe: item.Name, Category: c.Name, Meal: c.Meal, Score: round(sc)})
+							r.Score = max(r.Score, round(sc))
+						}
+					}
+				}
+				if len(r.Matches) > 0 {
+					sort.SliceStable(r.Matches, func(i, j int) bool { return r.Matches[i].Score > r.Matches[j].Score })
+					results = append(results, r)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.School < b.School
+	})
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// Collect gathers the menus q may match from the archive and the cache,
+// either of which may be nil. Where both have a school's day, the cached
+// one is used, as it is the more recent fetch.
+func Collect(store *archive.Store, c *cache.Cache, q Query) ([]SchoolDays, error) {
+	type key struct{ building, district string }
+	var order []key
+	schools := make(map[key]*SchoolDays)
+	byDate := make(map[key]map[string]menu.Day)
+	add := func(k key, name string) map[string]menu.Day {
+		if _, ok := schools[k]; !ok {
+			schools[k] = &SchoolDays{Name: name, BuildingID: k.building, DistrictID: k.district}
+			byDate[k] = make(map[string]menu.Day)
+			order = append(order, k)
+		}
+		return byDate[k]
+	}
+	wanted := func(k key) bool {
+		return (q.BuildingID == "" || q.BuildingID == k.building) && (q.DistrictID == "" || q.DistrictID == k.district)
+	}
+
+	if store != nil {
+		archived, err := store.Schools()
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range archived {
+			k := key{s.BuildingID, s.DistrictID}
+			if !wanted(k) {
+				continue
+			}
+			days, err := store.Days(archive.Query{BuildingID: s.BuildingID, DistrictID: s.DistrictID, Start: q.Start, End: q.End, Sessions: q.Sessions})
+			if err != nil {
+				return nil, err
+			}
+			dates := add(k, s.Name)
+			for _, d := range days {
+				dates[d.Date] = d
+			}
+		}
+	}
+
+	if c != nil {
+		entries, err := c.Entries()
+		if err != nil {
+			return nil, fmt.Errorf("reading menu cache: %w", err)
+		}
+		for _, e := range entries {
+			k := key{e.BuildingID, e.DistrictID}
+			if !wanted(k) {
+				continue
+			}
+			dates := add(k, "")
+			for _, d := range e.Menu.Days(q.Sessions...) {
+				if inRange(d.Time(), q.Start, q.End) {
+					dates[d.Date] = d
+				}
+			}
+		}
+	}
+
+	list := make([]SchoolDays, 0, len(order))
+	for _, k := range order {
+		s := schools[k]
+		for _, d := range byDate[k] {
+			s.Days = append(s.Days, d)
+		}
+		sort.Slice(s.Days, func(i, j int) bool { return s.Days[i].Date < s.Days[j].Date })
+		list = append(list, *s)
+	}
+	return list, nil
+}
+
+// Text lists results for people, one matching session per line.
+func Text(results []Result) string {
+	var b strings.Builder
+	for _, r := range results {
+		day := menu.Day{Date: r.Date}
+		var names []string
+		for _, m := range r.Matches {
+			names = append(names, m.Recipe)
+		}
+		school := r.School
+		if school == "" {
+			school = r.BuildingID
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\n", day.Time().Format("Mon Jan 2, 2006"), school, r.Session, strings.Join(names, ", "))
+	}
+	return b.String()
+}
+
+func inRange(t, start, end time.Time) bool {
+	date := t.Format(menu.DateLayout)
+	return (start.IsZero() || date >= start.Format(menu.DateLayout)) && (end.IsZero() || date <= end.Format(menu.DateLayout))
+}
+
+func round(x float64) float64 {
+	return float64(int(x*100+0.5)) / 100
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/asachs01/school_menu_connector/internal/menu"
+)
+
+func day(date string, sessions ...menu.Session) menu.Day {
+	return menu.Day{Date: date, Sessions: sessions}
+}
+
+func session(name string, categories ...menu.Category) menu.Session {
+	return menu.Session{Name: name, Categories: categories}
+}
+
+func category(name string, recipes ...string) menu.Category {
+	c := menu.Category{Name: name, Meal: "Main"}
+	for _, r := range recipes {
+		c.Recipes = append(c.Recipes, menu.Item{Name: r})
+	}
+	return c
+}
+
+func testSchools() []SchoolDays {
+	return []SchoolDays{
+		{Name: "Lincoln", BuildingID: "b1", DistrictID: "d1", Days: []menu.Day{
+			day("2024-09-04",
+				session("Breakfast", category("Entree", "Breakfast Pizza")),
+				session("Lunch", category("Entree", "Chicken Nuggets", "Pepperoni Pizza", "Pepperoni Pizza"))),
+			day("2024-09-06", session("Lunch", category("Pizza Bar", "Cheese Slice"), category("Entree", "Cheese Pizza"))),
+		}},
+		{Name: "Emma", BuildingID: "b2", DistrictID: "d1", Days: []menu.Day{
+			day("2024-09-04", session("Lunch", category("Entree", "Pizza"))),
+			day("2024-09-05", session("Lunch", category("Entree", "Soft Tacos"))),
+		}},
+	}
+}
+
+// summary describes results as "date school session: recipe score, ...".
+func summary(results []Result) []string {
+	var list []string
+	for _, r := range results {
+		s := fmt.Sprintf("%s %s %s:", r.Date, r.School, r.Session)
+		for _, m := range r.Matches {
+			s += fmt.Sprintf(" %s %g", m.Recipe, m.Score)
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"by date then score", Query{Text: "pizza"}, []string{
+			"2024-09-04 Emma Lunch: Pizza 1",
+			"2024-09-04 Lincoln Breakfast: Breakfast Pizza 1",
+			"2024-09-04 Lincoln Lunch: Pepperoni Pizza 1",
+			"2024-09-06 Lincoln Lunch: Cheese Pizza 1 Cheese Slice 0.5",
+		}},
+		{"typo", Query{Text: "piza", Sessions: []string{"Lunch"}, End: date("2024-09-04")}, []string{
+			"2024-09-04 Emma Lunch: Pizza 0.6",
+			"2024-09-04 Lincoln Lunch: Pepperoni Pizza 0.6",
+		}},
+		{"every word must match", Query{Text: "cheese pizza"}, []string{
+			"2024-09-06 Lincoln Lunch: Cheese Pizza 1 Cheese Slice 0.75",
+		}},
+		{"plural and prefix", Query{Text: "taco"}, []string{"2024-09-05 Emma Lunch: Soft Tacos 1"}},
+		{"dates", Query{Text: "pizza", Start: date("2024-09-05")}, []string{"2024-09-06 Lincoln Lunch: Cheese Pizza 1 Cheese Slice 0.5"}},
+		{"limit", Query{Text: "pizza", Limit: 1}, []string{"2024-09-04 Emma Lunch: Pizza 1"}},
+		{"no limit", Query{Text: "pizza", Limit: -1}, []string{
+			"2024-09-04 Emma Lunch: Pizza 1",
+			"2024-09-04 Lincoln Breakfast: Breakfast Pizza 1",
+			"2024-09-04 Lincoln Lunch: Pepperoni Pizza 1",
+			"2024-09-06 Lincoln Lunch: Cheese Pizza 1 Cheese Slice 0.5",
+		}},
+		{"no match", Query{Text: "sushi"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Search(testSchools(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if results == nil {
+				t.Error("results are nil, want an empty list")
+			}
+			if got := summary(results); fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+				t.Errorf("Search =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchDefaultLimit(t *testing.T) {
+	school := SchoolDays{BuildingID: "b1"}
+	start := date("2024-09-02")
+	for i := 0; i < DefaultLimit+10; i++ {
+		school.Days = append(school.Days, day(start.AddDate(0, 0, i).Format(menu.DateLayout), session("Lunch", category("Entree", "Pizza"))))
+	}
+	results, err := Search([]SchoolDays{school}, Query{Text: "pizza"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != DefaultLimit {
+		t.Errorf("got %d results, want %d", len(results), DefaultLimit)
+	}
+}
+
+func TestSearchEmpty(t *testing.T) {
+	for _, text := range []string{"", "  ", "?!"} {
+		if _, err := Search(testSchools(), Query{Text: text}); err == nil {
+			t.Errorf("Search(%q) succeeded, want an error", text)
+		}
+	}
+}
+
+func date(s string) time.Time {
+	t, err := time.Parse(menu.DateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
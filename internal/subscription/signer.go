@@ -0,0 +1,66 @@
+package subscription
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Signer creates and checks confirmation and unsubscribe links. Links are
+// signed with HMAC so they work without logging in but cannot be forged for
+// other subscribers.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner returns a Signer using secret as the HMAC key.
+func NewSigner(secret string) *Signer {
+	return &Signer{key: []byte(secret)}
+}
+
+// Sign returns the signature for unsubscribing sub.
+func (s *Signer) Sign(sub Subscription) string {
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "unsubscribe:%d:%s", sub.ID, strings.ToLower(sub.Email))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether sig is a valid unsubscribe signature for sub.
+func (s *Signer) Verify(sub Subscription, sig string) bool {
+	return hmac.Equal([]byte(s.Sign(sub)), []byte(sig))
+}
+
+// UnsubscribeURL returns the signed unsubscribe link for sub under baseURL,
+// e.g. https://menus.example.com/unsubscribe?id=42&sig=....
+func (s *Signer) UnsubscribeURL(baseURL string, sub Subscription) string {
+	q := url.Values{}
+	q.Set("id", strconv.FormatInt(sub.ID, 10))
+	q.Set("sig", s.Sign(sub))
+	return strings.TrimRight(baseURL, "/") + "/unsubscribe?" + q.Encode()
+}
+
+// SignToken returns the signature for a confirmation token.
+func (s *Signer) SignToken(token string) string {
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "confirm:%s", token)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyToken reports whether sig is a valid signature for a confirmation
+// token.
+func (s *Signer) VerifyToken(token, sig string) bool {
+	return token != "" && hmac.Equal([]byte(s.SignToken(token)), []byte(sig))
+}
+
+// ConfirmURL returns the signed confirmation link for token under baseURL,
+// e.g. https://menus.example.com/confirm?token=...&sig=....
+func (s *Signer) ConfirmURL(baseURL, token string) string {
+	q := url.Values{}
+	q.Set("token", token)
+	q.Set("sig", s.SignToken(token))
+	return strings.TrimRight(baseURL, "/") + "/confirm?" + q.Encode()
+}
@@ -0,0 +1,275 @@
+package subscription
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Digest frequencies.
+const (
+	Daily  = "daily"
+	Weekly = "weekly"
+)
+
+// Subscription states.
+const (
+	StatusPending      = "pending"
+	StatusActive       = "active"
+	StatusUnsubscribed = "unsubscribed"
+)
+
+// ErrNotFound is returned when no subscription matches.
+var ErrNotFound = errors.New("subscription not found")
+
+// Subscription is one address subscribed to one school's menu digest.
+type Subscription struct {
+	ID         int64
+	Email      string
+	BuildingID string
+	DistrictID string
+	School     string
+	// Frequency is Daily or Weekly.
+	Frequency string
+	MealTypes []string
+	Status    string
+	// Pending holds new preferences for an active subscription that wait
+	// for the subscriber to confirm them. It is nil otherwise.
+	Pending *Preferences
+	// Token is the confirmation token sent in the opt-in email. It is
+	// cleared once the subscription or its pending change is confirmed.
+	Token       string
+	CreatedAt   time.Time
+	ConfirmedAt time.Time
+}
+
+// Preferences are the school name, frequency and meal types of a
+// subscription.
+type Preferences struct {
+	School    string
+	Frequency string
+	MealTypes []string
+}
+
+// Store keeps subscriptions in a SQLite database.
+type Store struct {
+	db *sql.DB
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS subscriptions (
+	id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+	email              TEXT NOT NULL,
+	building_id        TEXT NOT NULL,
+	district_id        TEXT NOT NULL,
+	school             TEXT NOT NULL DEFAULT '',
+	frequency          TEXT NOT NULL,
+	meal_types         TEXT NOT NULL,
+	status             TEXT NOT NULL,
+	token              TEXT NOT NULL DEFAULT '',
+	pending_school     TEXT NOT NULL DEFAULT '',
+	pending_frequency  TEXT NOT NULL DEFAULT '',
+	pending_meal_types TEXT NOT NULL DEFAULT '',
+	created_at         TIMESTAMP NOT NULL,
+	confirmed_at       TIMESTAMP,
+	UNIQUE (email, building_id, district_id)
+);
+CREATE INDEX IF NOT EXISTS subscriptions_token ON subscriptions (token);
+CREATE INDEX IF NOT EXISTS subscriptions_status ON subscriptions (status, frequency);
+`
+
+// Open opens or creates the subscription database at path.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("opening subscription database: %w", err)
+	}
+	// SQLite allows a single writer; one connection avoids lock errors.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating subscription tables: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Subscribe records a pending subscription and returns it with a new
+// confirmation token. Subscribing again before confirming replaces the
+// preferences and token. An address that is already active keeps its
+// subscription unchanged: the new school name, frequency and meal types are
+// held in Pending, behind a new token, until they are confirmed.
+func (s *Store) Subscribe(sub Subscription) (*Subscription, error) {
+	sub.Email = strings.ToLower(strings.TrimSpace(sub.Email))
+
+	existing, err := s.find(`email = ? AND building_id = ? AND district_id = ?`, sub.Email, sub.BuildingID, sub.DistrictID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil && existing.Status == StatusActive {
+		if _, err := s.db.Exec(`UPDATE subscriptions SET pending_school = ?, pending_frequency = ?, pending_meal_types = ?, token = ? WHERE id = ?`,
+			sub.School, sub.Frequency, strings.Join(sub.MealTypes, ","), token, existing.ID); err != nil {
+			return nil, fmt.Errorf("saving subscription change: %w", err)
+		}
+		existing.Pending = &Preferences{School: sub.School, Frequency: sub.Frequency, MealTypes: sub.MealTypes}
+		existing.Token = token
+		return existing, nil
+	}
+
+	now := time.Now().UTC()
+	_, err = s.db.Exec(`
+		INSERT INTO subscriptions (email, building_id, district_id, school, frequency, meal_types, status, token, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (email, building_id, district_id) DO UPDATE SET
+			school = excluded.school,
+			frequency = excluded.frequency,
+			meal_types = excluded.meal_types,
+			status = excluded.status,
+			token = excluded.token,
+			pending_school = '',
+			pending_frequency = '',
+			pending_meal_types = '',
+			created_at = excluded.created_at,
+			confirmed_at = NULL`,
+		sub.Email, sub.BuildingID, sub.DistrictID, sub.School, sub.Frequency,
+		strings.Join(sub.MealTypes, ","), StatusPending, token, now)
+	if err != nil {
+		return nil, fmt.Errorf("saving subscription: %w", err)
+	}
+
+	return s.find(`email = ? AND building_id = ? AND district_id = ?`, sub.Email, sub.BuildingID, sub.DistrictID)
+}
+
+// Confirm activates the pending subscription with the given token, or
+// applies the pending change to the active subscription with it.
+func (s *Store) Confirm(token string) (*Subscription, error) {
+	if token == "" {
+		return nil, ErrNotFound
+	}
+	sub, err := s.find(`token = ? AND status IN (?, ?)`, token, StatusPending, StatusActive)
+	if err != nil {
+		return nil, err
+	}
+
+	if sub.Status == StatusActive {
+		if sub.Pending == nil {
+			return nil, ErrNotFound
+		}
+		if _, err := s.db.Exec(`
+			UPDATE subscriptions SET school = pending_school, frequency = pending_frequency, meal_types = pending_meal_types,
+				pending_school = '', pending_frequency = '', pending_meal_types = '', token = ''
+			WHERE id = ?`, sub.ID); err != nil {
+			return nil, fmt.Errorf("confirming subscription change: %w", err)
+		}
+		sub.School, sub.Frequency, sub.MealTypes = sub.Pending.School, sub.Pending.Frequency, sub.Pending.MealTypes
+		sub.Pending, sub.Token = nil, ""
+		return sub, nil
+	}
+
+	now := time.Now().UTC()
+	if _, err := s.db.Exec(`UPDATE subscriptions SET status = ?, token = '', confirmed_at = ? WHERE id = ?`, StatusActive, now, sub.ID); err != nil {
+		return nil, fmt.Errorf("confirming subscription: %w", err)
+	}
+	sub.Status, sub.Token, sub.ConfirmedAt = StatusActive, "", now
+	return sub, nil
+}
+
+// Unsubscribe stops all digests for the subscription.
+func (s *Store) Unsubscribe(id int64) error {
+	res, err := s.db.Exec(`
+		UPDATE subscriptions SET status = ?, token = '', pending_school = '', pending_frequency = '', pending_meal_types = ''
+		WHERE id = ?`, StatusUnsubscribed, id)
+	if err != nil {
+		return fmt.Errorf("unsubscribing: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Get returns the subscription with the given ID.
+func (s *Store) Get(id int64) (*Subscription, error) {
+	return s.find(`id = ?`, id)
+}
+
+// Active returns the confirmed subscriptions with the given frequency.
+func (s *Store) Active(frequency string) ([]Subscription, error) {
+	rows, err := s.db.Query(`SELECT `+columns+` FROM subscriptions WHERE status = ? AND frequency = ? ORDER BY building_id, district_id, id`, StatusActive, frequency)
+	if err != nil {
+		return nil, fmt.Errorf("listing subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []Subscription
+	for rows.Next() {
+		sub, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+	return subs, rows.Err()
+}
+
+const columns = `id, email, building_id, district_id, school, frequency, meal_types, status, token,
+	pending_school, pending_frequency, pending_meal_types, created_at, confirmed_at`
+
+func (s *Store) find(where string, args ...interface{}) (*Subscription, error) {
+	row := s.db.QueryRow(`SELECT `+columns+` FROM subscriptions WHERE `+where, args...)
+	sub, err := scan(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return sub, err
+}
+
+func scan(row interface{ Scan(...interface{}) error }) (*Subscription, error) {
+	var sub Subscription
+	var mealTypes string
+	var pending Preferences
+	var pendingMealTypes string
+	var confirmed sql.NullTime
+	err := row.Scan(&sub.ID, &sub.Email, &sub.BuildingID, &sub.DistrictID, &sub.School,
+		&sub.Frequency, &mealTypes, &sub.Status, &sub.Token,
+		&pending.School, &pending.Frequency, &pendingMealTypes, &sub.CreatedAt, &confirmed)
+	if err != nil {
+		return nil, err
+	}
+	if mealTypes != "" {
+		sub.MealTypes = strings.Split(mealTypes, ",")
+	}
+	// A pending change always has a frequency.
+	if pending.Frequency != "" {
+		if pendingMealTypes != "" {
+			pending.MealTypes = strings.Split(pendingMealTypes, ",")
+		}
+		sub.Pending = &pending
+	}
+	sub.ConfirmedAt = confirmed.Time
+	return &sub, nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,125 @@
+package subscription
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSubscribe(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "subscriptions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	sub := Subscription{Email: " Parent@Example.com ", BuildingID: "b1", DistrictID: "d1", School: "Lincoln", Frequency: Daily, MealTypes: []string{"Lunch"}}
+	pending, err := store.Subscribe(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Status != StatusPending || pending.Token == "" || pending.Email != "parent@example.com" {
+		t.Fatalf("Subscribe = %+v, want a pending subscription with a token", pending)
+	}
+
+	sub.Frequency = Weekly
+	again, err := store.Subscribe(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != pending.ID || again.Token == pending.Token || again.Frequency != Weekly {
+		t.Errorf("resubscribing before confirming = %+v, want the same subscription, weekly, with a new token", again)
+	}
+	if _, err := store.Confirm(again.Token); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		school    string
+		frequency string
+		mealTypes []string
+	}{
+		{"unchanged", "Lincoln", Weekly, []string{"Lunch"}},
+		{"frequency", "Lincoln", Daily, []string{"Lunch"}},
+		{"meals and school", "Lincoln Elementary", Daily, []string{"Breakfast", "Lunch"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := store.Get(pending.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sub.School, sub.Frequency, sub.MealTypes = tt.school, tt.frequency, tt.mealTypes
+			got, err := store.Subscribe(sub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Token == "" || got.Status != StatusActive || got.Pending == nil || got.Pending.Frequency != tt.frequency {
+				t.Fatalf("Subscribe = %+v, want it still active with a pending change and a token", got)
+			}
+			unconfirmed, err := store.Get(pending.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary(unconfirmed) != summary(before) {
+				t.Errorf("before confirming = %s, want unchanged %s", summary(unconfirmed), summary(before))
+			}
+
+			if _, err := store.Confirm(got.Token); err != nil {
+				t.Fatal(err)
+			}
+			saved, err := store.Get(pending.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := "active " + tt.school + " " + tt.frequency + " " + strings.Join(tt.mealTypes, ",")
+			if got := summary(saved); got != want || saved.Pending != nil || saved.Token != "" {
+				t.Errorf("confirmed = %s %+v, want %s", got, saved, want)
+			}
+			if _, err := store.Confirm(got.Token); err != ErrNotFound {
+				t.Errorf("confirming twice = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestUnsubscribeDropsPendingChange(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "subscriptions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	sub := Subscription{Email: "parent@example.com", BuildingID: "b1", DistrictID: "d1", Frequency: Daily, MealTypes: []string{"Lunch"}}
+	pending, err := store.Subscribe(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Confirm(pending.Token); err != nil {
+		t.Fatal(err)
+	}
+	sub.Frequency = Weekly
+	change, err := store.Subscribe(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Unsubscribe(change.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Confirm(change.Token); err != ErrNotFound {
+		t.Errorf("confirming a change after unsubscribing = %v, want ErrNotFound", err)
+	}
+	saved, err := store.Get(change.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != StatusUnsubscribed || saved.Pending != nil || saved.Frequency != Daily {
+		t.Errorf("unsubscribed = %+v, want daily with no pending change", saved)
+	}
+}
+
+// summary describes a subscription's status and preferences.
+func summary(sub *Subscription) string {
+	return sub.Status + " " + sub.School + " " + sub.Frequency + " " + strings.Join(sub.MealTypes, ",")
+}
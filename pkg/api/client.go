@@ -0,0 +1,248 @@
+// Package api is a Go client for the School Menu Connector web service.
+//
+// It wraps the HTTP endpoints exposed by cmd/web so that other Go programs
+// can fetch menus, download calendar files, warm the server cache and look
+// up district identifiers without hand-writing HTTP calls:
+//
+//	client, err := api.NewClient("https://schoolmenuconnector.com")
+//	if err != nil {
+//		return err
+//	}
+//	days, err := client.GetMenu(ctx, api.MenuRequest{
+//		BuildingID: "...",
+//		DistrictID: "...",
+//		StartDate:  time.Now(),
+//		EndDate:    time.Now().AddDate(0, 0, 4),
+//	})
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout   = 30 * time.Second
+	defaultUserAgent = "school-menu-connector-go/1.0"
+
+	// dateFormat is the date layout sent to the server.
+	dateFormat = "2006-01-02"
+)
+
+// Client talks to a School Menu Connector server. A Client is safe for
+// concurrent use by multiple goroutines.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	apiKey     string
+	userAgent  string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests. The default client
+// has a 30 second timeout.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		if hc != nil {
+			c.httpClient = hc
+		}
+	}
+}
+
+// WithAPIKey sets the key sent in the X-API-Key header on protected
+// endpoints such as RefreshCache.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithUserAgent overrides the User-Agent header sent with each request.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// NewClient returns a Client for the server at baseURL, for example
+// "https://schoolmenuconnector.com" or "http://localhost:8080".
+func NewClient(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("base URL must be http or https, got %q", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		userAgent:  defaultUserAgent,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// GetMenu returns the structured menu for each school day in the requested
+// range. Days without any of the requested meal types are omitted.
+func (c *Client) GetMenu(ctx context.Context, req MenuRequest) ([]Day, error) {
+	body, err := req.payload()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp menuResponse
+	if err := c.doJSON(ctx, http.MethodPost, "/get-menu-json", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Days, nil
+}
+
+// DownloadICS returns an iCalendar file containing the requested menus.
+func (c *Client) DownloadICS(ctx context.Context, req MenuRequest) ([]byte, error) {
+	body, err := req.payload()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/get-menu", nil, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	return data, nil
+}
+
+// RefreshCache asks the server to pre-fetch menus for the given schools and
+// store them in its cache. The server may require an API key; see
+// WithAPIKey.
+func (c *Client) RefreshCache(ctx context.Context, req RefreshRequest) (*RefreshResult, error) {
+	if len(req.Schools) == 0 {
+		return nil, fmt.Errorf("%w: at least one school is required", ErrInvalidRequest)
+	}
+	if req.StartDate.IsZero() || req.EndDate.IsZero() {
+		return nil, fmt.Errorf("%w: start and end dates are required", ErrInvalidRequest)
+	}
+
+	body := refreshPayload{
+		Schools:   req.Schools,
+		StartDate: req.StartDate.Format(dateFormat),
+		EndDate:   req.EndDate.Format(dateFormat),
+	}
+
+	var result RefreshResult
+	if err := c.doJSON(ctx, http.MethodPost, "/refresh-cache", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Discover resolves a district identifier, as shown on a district's LINQ
+// Connect menu page, into the district and its buildings.
+func (c *Client) Discover(ctx context.Context, identifier string) (*District, error) {
+	if identifier == "" {
+		return nil, fmt.Errorf("%w: identifier is required", ErrInvalidRequest)
+	}
+
+	query := url.Values{"identifier": {identifier}}
+	var district District
+	if err := c.doJSON(ctx, http.MethodGet, "/discover", query, nil, &district); err != nil {
+		return nil, err
+	}
+	return &district, nil
+}
+
+// Health reports whether the server is up.
+func (c *Client) Health(ctx context.Context) error {
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, "/health", nil, nil, &status); err != nil {
+		return err
+	}
+	if status.Status != "ok" {
+		return fmt.Errorf("server reported status %q", status.Status)
+	}
+	return nil
+}
+
+// doJSON performs a request and decodes a JSON response into out.
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	resp, err := c.do(ctx, method, path, query, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+// do sends a request with an optional JSON body and returns the response if
+// the server answered with a 2xx status. Any other status is returned as an
+// *Error.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in interface{}) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path = c.baseURL.Path + path
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("encoding request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP request: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &Error{
+			Method:     method,
+			Path:       path,
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(msg)),
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer answers every request with status and body and records the
+// requests it gets.
+type fakeServer struct {
+	*httptest.Server
+	status int
+	body   string
+
+	mu       sync.Mutex
+	requests []recordedRequest
+}
+
+type recordedRequest struct {
+	method, path, query string
+	header              http.Header
+	body                string
+}
+
+func newFakeServer(t *testing.T, status int, body string) *fakeServer {
+	s := &fakeServer{status: status, body: body}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests = append(s.requests, recordedRequest{r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Clone(), string(data)})
+		s.mu.Unlock()
+		w.WriteHeader(s.status)
+		io.WriteString(w, s.body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// recorded returns the only request the server got.
+func (s *fakeServer) recorded(t *testing.T) recordedRequest {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) != 1 {
+		t.Fatalf("server got %d requests, want 1", len(s.requests))
+	}
+	return s.requests[0]
+}
+
+func newTestClient(t *testing.T, s *fakeServer, opts ...Option) *Client {
+	t.Helper()
+	c, err := NewClient(s.URL+"/", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func testMenuRequest() MenuRequest {
+	return MenuRequest{
+		BuildingID: "b1",
+		DistrictID: "d1",
+		StartDate:  time.Date(2024, time.September, 2, 0, 0, 0, 0, time.UTC),
+		EndDate:    time.Date(2024, time.September, 6, 0, 0, 0, 0, time.UTC),
+		MealTypes:  []string{"Lunch"},
+	}
+}
+
+func TestGetMenu(t *testing.T) {
+	s := newFakeServer(t, http.StatusOK, `{"days":[{"date":"2024-09-02","sessions":[{"name":"Lunch","categories":[
+		{"name":"Entree","meal":"Main","color":"#ff0000","recipes":[{"itemId":"i1","name":"Cheese Pizza","allergens":["a-milk"],
+		"nutrients":[{"name":"Calories","value":280,"unit":"kcal"}]}]}]}]}]}`)
+	c := newTestClient(t, s, WithUserAgent("test/1.0"))
+
+	days, err := c.GetMenu(context.Background(), testMenuRequest())
+	if err != nil {
+		t.Fatalf("GetMenu: %v", err)
+	}
+	if len(days) != 1 || len(days[0].Sessions) != 1 || len(days[0].Sessions[0].Categories) != 1 {
+		t.Fatalf("days = %+v, want one day with one session and category", days)
+	}
+	if d, err := days[0].Time(); err != nil || !d.Equal(time.Date(2024, time.September, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Time = %v, %v", d, err)
+	}
+	c0 := days[0].Sessions[0].Categories[0]
+	if c0.Name != "Entree" || c0.Color != "#ff0000" || len(c0.Recipes) != 1 {
+		t.Fatalf("category = %+v", c0)
+	}
+	item := c0.Recipes[0]
+	if item.Name != "Cheese Pizza" || item.Allergens[0] != "a-milk" || item.Nutrients[0] != (Nutrient{"Calories", 280, "kcal"}) {
+		t.Errorf("item = %+v", item)
+	}
+
+	req := s.recorded(t)
+	if req.method != http.MethodPost || req.path != "/get-menu-json" {
+		t.Errorf("request = %s %s, want POST /get-menu-json", req.method, req.path)
+	}
+	if req.header.Get("Content-Type") != "application/json" || req.header.Get("User-Agent") != "test/1.0" || req.header.Get("X-API-Key") != "" {
+		t.Errorf("headers = %v", req.header)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(req.body), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["buildingId"] != "b1" || body["districtId"] != "d1" || body["startDate"] != "2024-09-02" || body["endDate"] != "2024-09-06" {
+		t.Errorf("body = %s", req.body)
+	}
+}
+
+func TestDownloadICS(t *testing.T) {
+	const ics = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nEND:VCALENDAR\r\n"
+	s := newFakeServer(t, http.StatusOK, ics)
+	data, err := newTestClient(t, s).DownloadICS(context.Background(), testMenuRequest())
+	if err != nil {
+		t.Fatalf("DownloadICS: %v", err)
+	}
+	if string(data) != ics {
+		t.Errorf("DownloadICS = %q, want %q", data, ics)
+	}
+	if req := s.recorded(t); req.method != http.MethodPost || req.path != "/get-menu" || !strings.Contains(req.body, `"mealTypes":["Lunch"]`) {
+		t.Errorf("request = %s %s %s", req.method, req.path, req.body)
+	}
+}
+
+func TestRefreshCache(t *testing.T) {
+	s := newFakeServer(t, http.StatusOK, `{"cached":9,"failed":1}`)
+	c := newTestClient(t, s, WithAPIKey("secret"))
+	req := testMenuRequest()
+
+	result, err := c.RefreshCache(context.Background(), RefreshRequest{
+		Schools:   []School{{BuildingID: "b1", DistrictID: "d1"}, {BuildingID: "b2", DistrictID: "d1"}},
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+	})
+	if err != nil {
+		t.Fatalf("RefreshCache: %v", err)
+	}
+	if *result != (RefreshResult{Cached: 9, Failed: 1}) {
+		t.Errorf("result = %+v", result)
+	}
+	got := s.recorded(t)
+	if got.path != "/refresh-cache" || got.header.Get("X-API-Key") != "secret" {
+		t.Errorf("request = %s with X-API-Key %q, want /refresh-cache with secret", got.path, got.header.Get("X-API-Key"))
+	}
+	if want := `{"schools":[{"buildingId":"b1","districtId":"d1"},{"buildingId":"b2","districtId":"d1"}],"startDate":"2024-09-02","endDate":"2024-09-06"}`; got.body != want {
+		t.Errorf("body = %s, want %s", got.body, want)
+	}
+
+	for _, invalid := range []RefreshRequest{{StartDate: req.StartDate, EndDate: req.EndDate}, {Schools: []School{{"b1", "d1"}}}} {
+		if _, err := c.RefreshCache(context.Background(), invalid); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("RefreshCache(%+v) = %v, want ErrInvalidRequest", invalid, err)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	s := newFakeServer(t, http.StatusOK, `{"DistrictId":"d1","DistrictName":"Springfield","Buildings":[{"BuildingId":"b1","Name":"Lincoln"}]}`)
+	district, err := newTestClient(t, s).Discover(context.Background(), "SPRING 1")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if district.DistrictID != "d1" || len(district.Buildings) != 1 || district.Buildings[0] != (Building{"b1", "Lincoln"}) {
+		t.Errorf("district = %+v", district)
+	}
+	if req := s.recorded(t); req.method != http.MethodGet || req.path != "/discover" || req.query != "identifier=SPRING+1" {
+		t.Errorf("request = %s %s?%s", req.method, req.path, req.query)
+	}
+}
+
+func TestDiscoverNotFound(t *testing.T) {
+	s := newFakeServer(t, http.StatusNotFound, "District not found\n")
+	_, err := newTestClient(t, s).Discover(context.Background(), "NOPE")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Discover = %v, want ErrNotFound", err)
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "District not found" {
+		t.Errorf("err = %#v", err)
+	}
+	if want := "GET /discover: server returned 404: District not found"; err.Error() != want {
+		t.Errorf("Error = %q, want %q", err, want)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	for _, base := range []string{"ftp://example.com", "example.com", "://"} {
+		if _, err := NewClient(base); err == nil {
+			t.Errorf("NewClient(%q) succeeded, want an error", base)
+		}
+	}
+}
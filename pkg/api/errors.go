@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrInvalidRequest is returned when a request is rejected before it is
+	// sent, or when the server answers 400 Bad Request.
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrUnauthorized is returned when the server rejects the API key.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrNotFound is returned when the endpoint does not exist on the server,
+	// or when Discover finds no district with the identifier.
+	ErrNotFound = errors.New("not found")
+	// ErrUpstream is returned when the server could not reach LINQ Connect.
+	ErrUpstream = errors.New("upstream error")
+)
+
+// Error is returned for any non-2xx response from the server. Use
+// errors.Is with the sentinel errors above to check for common cases.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s: server returned %d", e.Method, e.Path, e.StatusCode)
+	}
+	return fmt.Sprintf("%s %s: server returned %d: %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// Is maps HTTP status codes onto the package's sentinel errors.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrInvalidRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUpstream:
+		return e.StatusCode == http.StatusBadGateway || e.StatusCode == http.StatusServiceUnavailable
+	}
+	return false
+}
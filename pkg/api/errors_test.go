@@ -0,0 +1,32 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestErrorIs(t *testing.T) {
+	sentinels := []error{ErrInvalidRequest, ErrUnauthorized, ErrNotFound, ErrUpstream}
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusBadRequest, ErrInvalidRequest},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrUnauthorized},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusBadGateway, ErrUpstream},
+		{http.StatusServiceUnavailable, ErrUpstream},
+		{http.StatusInternalServerError, nil},
+		{http.StatusTeapot, nil},
+	}
+	for _, tt := range tests {
+		err := error(&Error{Method: http.MethodGet, Path: "/health", StatusCode: tt.status})
+		for _, sentinel := range sentinels {
+			if got := errors.Is(err, sentinel); got != (sentinel == tt.want) {
+				t.Errorf("errors.Is(%d, %v) = %v", tt.status, sentinel, got)
+			}
+		}
+	}
+}
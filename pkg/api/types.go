@@ -0,0 +1,139 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// MenuRequest selects the school, date range and meal types for a menu.
+type MenuRequest struct {
+	BuildingID string
+	DistrictID string
+	StartDate  time.Time
+	EndDate    time.Time
+	// MealTypes defaults to Lunch on the server when empty.
+	MealTypes []string
+	// IncludeDescription asks the server to fill in Session.Description
+	// with the preformatted text menu.
+	IncludeDescription bool
+}
+
+// menuPayload is the JSON body expected by the menu endpoints.
+type menuPayload struct {
+	BuildingID string   `json:"buildingId"`
+	DistrictID string   `json:"districtId"`
+	StartDate  string   `json:"startDate"`
+	EndDate    string   `json:"endDate"`
+	MealTypes  []string `json:"mealTypes,omitempty"`
+
+	IncludeDescription bool `json:"includeDescription,omitempty"`
+}
+
+func (r MenuRequest) payload() (menuPayload, error) {
+	if r.BuildingID == "" || r.DistrictID == "" {
+		return menuPayload{}, fmt.Errorf("%w: building and district IDs are required", ErrInvalidRequest)
+	}
+	if r.StartDate.IsZero() || r.EndDate.IsZero() {
+		return menuPayload{}, fmt.Errorf("%w: start and end dates are required", ErrInvalidRequest)
+	}
+	if r.EndDate.Before(r.StartDate) {
+		return menuPayload{}, fmt.Errorf("%w: end date is before start date", ErrInvalidRequest)
+	}
+
+	return menuPayload{
+		BuildingID: r.BuildingID,
+		DistrictID: r.DistrictID,
+		StartDate:  r.StartDate.Format(dateFormat),
+		EndDate:    r.EndDate.Format(dateFormat),
+		MealTypes:  r.MealTypes,
+
+		IncludeDescription: r.IncludeDescription,
+	}, nil
+}
+
+// Day is the menu for a single date, organized by serving session, then
+// recipe category.
+type Day struct {
+	Date     string    `json:"date"`
+	Sessions []Session `json:"sessions"`
+}
+
+// Time parses the day's date.
+func (d Day) Time() (time.Time, error) {
+	return time.Parse(dateFormat, d.Date)
+}
+
+// Session is one serving session (Breakfast, Lunch, or Snack) on a day.
+type Session struct {
+	Name        string     `json:"name"`
+	Categories  []Category `json:"categories"`
+	Description string     `json:"description,omitempty"`
+}
+
+// Category groups the recipes served in one category of a meal. Color is
+// the hex display color assigned by the district.
+type Category struct {
+	Name    string `json:"name"`
+	Meal    string `json:"meal"`
+	Color   string `json:"color,omitempty"`
+	Recipes []Item `json:"recipes"`
+}
+
+// Item is a single recipe. Allergens holds LINQ allergen IDs.
+type Item struct {
+	ItemID      string     `json:"itemId"`
+	Identifier  string     `json:"identifier,omitempty"`
+	Name        string     `json:"name"`
+	ServingSize string     `json:"servingSize,omitempty"`
+	Allergens   []string   `json:"allergens"`
+	Nutrients   []Nutrient `json:"nutrients"`
+}
+
+// Nutrient is a single nutrition fact for an item serving.
+type Nutrient struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+type menuResponse struct {
+	Days []Day `json:"days"`
+}
+
+// School identifies a building within a district.
+type School struct {
+	BuildingID string `json:"buildingId"`
+	DistrictID string `json:"districtId"`
+}
+
+// RefreshRequest lists the schools and date range to pre-fetch.
+type RefreshRequest struct {
+	Schools   []School
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+type refreshPayload struct {
+	Schools   []School `json:"schools"`
+	StartDate string   `json:"startDate"`
+	EndDate   string   `json:"endDate"`
+}
+
+// RefreshResult reports how many school days were cached or failed.
+type RefreshResult struct {
+	Cached int `json:"cached"`
+	Failed int `json:"failed"`
+}
+
+// District is a LINQ Connect district and its buildings.
+type District struct {
+	DistrictID   string     `json:"DistrictId"`
+	DistrictName string     `json:"DistrictName"`
+	Buildings    []Building `json:"Buildings"`
+}
+
+// Building is a single school within a district.
+type Building struct {
+	BuildingID string `json:"BuildingId"`
+	Name       string `json:"Name"`
+}
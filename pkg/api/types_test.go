@@ -0,0 +1,42 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMenuRequestPayload(t *testing.T) {
+	monday := time.Date(2024, time.September, 2, 0, 0, 0, 0, time.UTC)
+	friday := monday.AddDate(0, 0, 4)
+	tests := []struct {
+		name    string
+		req     MenuRequest
+		wantErr string
+	}{
+		{"valid", MenuRequest{BuildingID: "b1", DistrictID: "d1", StartDate: monday, EndDate: friday}, ""},
+		{"one day", MenuRequest{BuildingID: "b1", DistrictID: "d1", StartDate: monday, EndDate: monday}, ""},
+		{"no building", MenuRequest{DistrictID: "d1", StartDate: monday, EndDate: friday}, "invalid request: building and district IDs are required"},
+		{"no district", MenuRequest{BuildingID: "b1", StartDate: monday, EndDate: friday}, "invalid request: building and district IDs are required"},
+		{"no start", MenuRequest{BuildingID: "b1", DistrictID: "d1", EndDate: friday}, "invalid request: start and end dates are required"},
+		{"no end", MenuRequest{BuildingID: "b1", DistrictID: "d1", StartDate: monday}, "invalid request: start and end dates are required"},
+		{"end before start", MenuRequest{BuildingID: "b1", DistrictID: "d1", StartDate: friday, EndDate: monday}, "invalid request: end date is before start date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := tt.req.payload()
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrInvalidRequest) || err.Error() != tt.wantErr {
+					t.Errorf("payload = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("payload: %v", err)
+			}
+			if p.StartDate != tt.req.StartDate.Format(dateFormat) || p.EndDate != tt.req.EndDate.Format(dateFormat) || p.BuildingID != "b1" {
+				t.Errorf("payload = %+v", p)
+			}
+		})
+	}
+}